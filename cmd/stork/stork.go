@@ -568,7 +568,7 @@ func runStork(mgr manager.Manager, ctx context.Context, d volume.Driver, recorde
 				Recorder:          recorder,
 				ResourceCollector: resourceCollector,
 			}
-			if err := migration.Init(mgr, adminNamespace, c.Int("migration-max-threads")); err != nil {
+			if err := migration.Init(mgr, adminNamespace, c.Int("migration-max-threads"), signalChan); err != nil {
 				log.Fatalf("Error initializing migration: %v", err)
 			}
 		}
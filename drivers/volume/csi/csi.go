@@ -16,7 +16,6 @@ import (
 	storkvolume "github.com/libopenstorage/stork/drivers/volume"
 	storkapi "github.com/libopenstorage/stork/pkg/apis/stork/v1alpha1"
 	"github.com/libopenstorage/stork/pkg/applicationmanager/controllers"
-	"github.com/libopenstorage/stork/pkg/crypto"
 	"github.com/libopenstorage/stork/pkg/errors"
 	"github.com/libopenstorage/stork/pkg/k8sutils"
 	"github.com/libopenstorage/stork/pkg/log"
@@ -569,13 +568,8 @@ func (c *csi) uploadObject(
 	if err != nil {
 		return err
 	}
-	if backupLocation.Location.EncryptionKey != "" {
-		return fmt.Errorf("EncryptionKey is deprecated, use EncryptionKeyV2 instead")
-	}
-	if backupLocation.Location.EncryptionV2Key != "" {
-		if data, err = crypto.Encrypt(data, backupLocation.Location.EncryptionV2Key); err != nil {
-			return err
-		}
+	if data, err = objectstore.EncryptData(backupLocation, data); err != nil {
+		return err
 	}
 
 	var options blob.WriterOptions
@@ -1278,11 +1272,10 @@ func (c *csi) downloadObject(
 			return nil, err
 		}
 		bkpDir := filepath.Join(repo.Path, backup.Status.BackupPath)
-		data, err = executor.DownloadObject(bkpDir, objectName, restoreLocation.Location.EncryptionV2Key)
+		data, err = executor.DownloadObject(bkpDir, objectName, "")
 		if err != nil {
 			return nil, fmt.Errorf("error downloading resources: %v", err)
 		}
-		return data, nil
 	} else {
 		// Non NFS backuplocation type
 		bucket, err := objectstore.GetBucket(restoreLocation)
@@ -1300,20 +1293,9 @@ func (c *csi) downloadObject(
 		if err != nil {
 			return nil, err
 		}
-		if restoreLocation.Location.EncryptionKey != "" {
-			return nil, fmt.Errorf("EncryptionKey is deprecated, use EncryptionKeyV2 instead")
-		}
-		if restoreLocation.Location.EncryptionV2Key != "" {
-			var decryptData []byte
-			if decryptData, err = crypto.Decrypt(data, restoreLocation.Location.EncryptionV2Key); err != nil {
-				logrus.Debugf("decrypt failed with: %v and returning the data as it is", err)
-				return data, nil
-			}
-			return decryptData, nil
-		}
 	}
 
-	return data, nil
+	return objectstore.DecryptData(restoreLocation, data)
 }
 
 // getRestoreSnapshotsAndContent retrieves the volumeSnapshots and
@@ -1356,7 +1338,7 @@ func (c *csi) getCSIBackupObject(backupName, backupNamespace string) (*CsiBackup
 
 // getBackupResources gets all objects in resource.json
 func (c *csi) getBackupResources(restore *storkapi.ApplicationRestore) ([]runtime.Unstructured, error) {
-	backup, err := storkops.Instance().GetApplicationBackup(restore.Spec.BackupName, restore.Namespace)
+	backup, err := storkops.Instance().GetApplicationBackup(restore.GetBackupName(), restore.Namespace)
 	if err != nil {
 		return nil, fmt.Errorf("error getting backup resources for CSI restore: %v", err)
 	}
@@ -1734,7 +1716,7 @@ func (c *csi) StartRestore(
 	log.ApplicationRestoreLog(restore).Debugf("started CSI restore %s", restore.UID)
 
 	// Get volumesnapshots.json and volumesnapshotcontents.json
-	csiBackupObject, err := c.getCSIBackupObject(restore.Spec.BackupName, restore.Namespace)
+	csiBackupObject, err := c.getCSIBackupObject(restore.GetBackupName(), restore.Namespace)
 	if err != nil {
 		return nil, err
 	}
@@ -1829,7 +1811,7 @@ func (c *csi) GetRestoreStatus(restore *storkapi.ApplicationRestore) ([]*storkap
 			vrStatus := storkapi.ApplicationRestoreStatusSuccessful
 			if vrInfo.Status == storkapi.ApplicationRestoreStatusRetained {
 				vrStatus = storkapi.ApplicationRestoreStatusRetained
-				reason = fmt.Sprintf("Skipped from volume restore as policy is set to %s and pvc already exists", storkapi.ApplicationRestoreReplacePolicyRetain)
+				reason = fmt.Sprintf("Skipped from volume restore as policy is set to %s and pvc already exists", restore.Spec.ReplacePolicy)
 			}
 			vrInfo.Status = vrStatus
 			vrInfo.Reason = reason
@@ -741,13 +741,13 @@ func (k *kdmp) StartRestore(
 			return nil, err
 		}
 
-		backup, err := storkops.Instance().GetApplicationBackup(restore.Spec.BackupName, restore.Namespace)
+		backup, err := storkops.Instance().GetApplicationBackup(restore.GetBackupName(), restore.Namespace)
 		if err != nil {
-			return nil, fmt.Errorf("unable to get applicationbackup cr %s/%s: %v", restore.Namespace, restore.Spec.BackupName, err)
+			return nil, fmt.Errorf("unable to get applicationbackup cr %s/%s: %v", restore.Namespace, restore.GetBackupName(), err)
 		}
 		var backupUID string
 		if _, ok := backup.Annotations[backupUIDKey]; !ok {
-			msg := fmt.Sprintf("unable to find backup uid from applicationbackup %s/%s", restore.Namespace, restore.Spec.BackupName)
+			msg := fmt.Sprintf("unable to find backup uid from applicationbackup %s/%s", restore.Namespace, restore.GetBackupName())
 			return nil, fmt.Errorf(msg)
 		}
 		backupUID = backup.Annotations[backupUIDKey]
@@ -2754,6 +2754,69 @@ func (p *portworx) CancelMigration(migration *storkapi.Migration) error {
 	return nil
 }
 
+func (p *portworx) GetMigrationReplicationLag(clusterPair *storkapi.ClusterPair, volumes []string) (map[string]time.Duration, error) {
+	if !p.initDone {
+		if err := p.initPortworxClients(); err != nil {
+			return nil, err
+		}
+	}
+
+	volDriver, err := p.getUserVolDriver(clusterPair.Annotations, "" /*templatized ns not supported*/)
+	if err != nil {
+		return nil, err
+	}
+	clusterID := clusterPair.Status.RemoteStorageID
+	status, err := volDriver.CloudMigrateStatus(&api.CloudMigrateStatusRequest{
+		ClusterId: clusterID,
+	})
+	if err != nil {
+		return nil, err
+	}
+	clusterInfo, ok := status.Info[clusterID]
+	if !ok {
+		return map[string]time.Duration{}, nil
+	}
+
+	requested := make(map[string]bool)
+	for _, volume := range volumes {
+		requested[volume] = true
+	}
+	// Use the last migration of each volume that completed
+	lastMigrated := make(map[string]time.Time)
+	for _, mInfo := range clusterInfo.List {
+		if mInfo.CurrentStage != api.CloudMigrate_Done ||
+			mInfo.Status != api.CloudMigrate_Complete ||
+			mInfo.CompletedTime == nil {
+			continue
+		}
+		volume := mInfo.LocalVolumeName
+		if !requested[volume] {
+			volume = mInfo.LocalVolumeId
+			if !requested[volume] {
+				continue
+			}
+		}
+		completed := mInfo.CompletedTime.AsTime()
+		if completed.After(lastMigrated[volume]) {
+			lastMigrated[volume] = completed
+		}
+	}
+
+	lag := make(map[string]time.Duration)
+	for volume, completed := range lastMigrated {
+		lag[volume] = time.Since(completed)
+	}
+	return lag, nil
+}
+
+// SetMigrationBandwidthLimit returns ErrNotSupported
+func (p *portworx) SetMigrationBandwidthLimit(clusterPair *storkapi.ClusterPair, limit uint64) error {
+	return &errors.ErrNotSupported{
+		Feature: "Migration bandwidth limit",
+		Reason:  "cloud migrate API doesn't have a bandwidth limit",
+	}
+}
+
 func (p *portworx) UpdateMigratedPersistentVolumeSpec(
 	pv *v1.PersistentVolume,
 	vInfo *storkapi.ApplicationRestoreVolumeInfo,
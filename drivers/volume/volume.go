@@ -5,6 +5,7 @@ import (
 	"net"
 	"regexp"
 	"strings"
+	"time"
 
 	aws_sdk "github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/credentials"
@@ -200,6 +201,13 @@ type MigratePluginInterface interface {
 	GetMigrationStatus(*storkapi.Migration) ([]*storkapi.MigrationVolumeInfo, error)
 	// Cancel the migration of volumes specified in the status
 	CancelMigration(*storkapi.Migration) error
+	// Get the time since each of the volumes was last migrated to the
+	// remote storage of the cluster pair. Volumes that haven't been
+	// migrated aren't returned.
+	GetMigrationReplicationLag(*storkapi.ClusterPair, []string) (map[string]time.Duration, error)
+	// Limit the bandwidth in Mbps used to migrate volumes to the remote
+	// storage of the cluster pair. A limit of 0 removes it.
+	SetMigrationBandwidthLimit(*storkapi.ClusterPair, uint64) error
 	// Update the PVC spec to point to the migrated volume on the destination
 	// cluster
 	UpdateMigratedPersistentVolumeSpec(*v1.PersistentVolume, *storkapi.ApplicationRestoreVolumeInfo, map[string]string, string, string) (*v1.PersistentVolume, error)
@@ -454,6 +462,16 @@ func (m *MigrationNotSupported) CancelMigration(*storkapi.Migration) error {
 	return &errors.ErrNotSupported{}
 }
 
+// GetMigrationReplicationLag returns ErrNotSupported
+func (m *MigrationNotSupported) GetMigrationReplicationLag(*storkapi.ClusterPair, []string) (map[string]time.Duration, error) {
+	return nil, &errors.ErrNotSupported{}
+}
+
+// SetMigrationBandwidthLimit returns ErrNotSupported
+func (m *MigrationNotSupported) SetMigrationBandwidthLimit(*storkapi.ClusterPair, uint64) error {
+	return &errors.ErrNotSupported{}
+}
+
 // UpdateMigratedPersistentVolumeSpec returns ErrNotSupported
 func (m *MigrationNotSupported) UpdateMigratedPersistentVolumeSpec(
 	*v1.PersistentVolume,
@@ -69,7 +69,11 @@ func (ac *ActionController) Reconcile(ctx context.Context, request reconcile.Req
 		return reconcile.Result{RequeueAfter: controllers.DefaultRequeueError}, err
 	}
 
-	if action.Status == storkv1.ActionStatusScheduled {
+	// Switchovers and failbacks are performed in stages and are resumed while
+	// in progress
+	if action.Status == storkv1.ActionStatusScheduled ||
+		(action.Status == storkv1.ActionStatusInProgress &&
+			(action.Spec.ActionType == storkv1.ActionTypeSwitchover || action.Spec.ActionType == storkv1.ActionTypeFailback)) {
 		if err = ac.handle(context.TODO(), action); err != nil {
 			log.ActionLog(action).Errorf("ActionController handle failed with %s", err)
 			ac.recorder.Event(action, v1.EventTypeWarning, "ActionController", err.Error())
@@ -82,9 +86,9 @@ func (ac *ActionController) Reconcile(ctx context.Context, request reconcile.Req
 }
 
 func (ac *ActionController) handle(ctx context.Context, action *storkv1.Action) error {
-	ac.updateStatus(action, storkv1.ActionStatusInProgress)
 	switch action.Spec.ActionType {
 	case storkv1.ActionTypeFailover:
+		ac.updateStatus(action, storkv1.ActionStatusInProgress)
 		log.ActionLog(action).Info("started failover")
 		err := ac.volDriver.Failover(action)
 		if err != nil {
@@ -93,6 +97,15 @@ func (ac *ActionController) handle(ctx context.Context, action *storkv1.Action)
 		}
 		resourceutils.ScaleReplicas(action.Namespace, true, ac.printFunc(action, "ScaleReplicas"), ac.config)
 		ac.updateStatus(action, storkv1.ActionStatusSuccessful)
+	case storkv1.ActionTypeDeactivate:
+		ac.updateStatus(action, storkv1.ActionStatusInProgress)
+		log.ActionLog(action).Info("started deactivate")
+		resourceutils.ScaleReplicas(action.Namespace, false, ac.printFunc(action, "ScaleReplicas"), ac.config)
+		ac.updateStatus(action, storkv1.ActionStatusSuccessful)
+	case storkv1.ActionTypeSwitchover:
+		return ac.switchover(action)
+	case storkv1.ActionTypeFailback:
+		return ac.failback(action)
 	default:
 		ac.updateStatus(action, storkv1.ActionStatusFailed)
 		return fmt.Errorf("invalid value received for Action.Spec.ActionType")
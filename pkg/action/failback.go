@@ -0,0 +1,259 @@
+package action
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	storkv1 "github.com/libopenstorage/stork/pkg/apis/stork/v1alpha1"
+	"github.com/libopenstorage/stork/pkg/log"
+	storkops "github.com/portworx/sched-ops/k8s/stork"
+	"k8s.io/apimachinery/pkg/api/errors"
+	meta "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	reverseMigrationScheduleSuffix = "-reverse"
+	failbackSwitchoverSuffix       = "-switchover"
+	// scheduleSuspendedAnnotation records on the failback whether the
+	// MigrationSchedule was suspended before it was started so that a
+	// rollback leaves it as it was
+	scheduleSuspendedAnnotation = "stork.libopenstorage.org/scheduleSuspended"
+)
+
+// failback moves the applications back to this cluster after they were
+// failed over to the destination cluster of a MigrationSchedule. A reverse
+// MigrationSchedule is created on the destination cluster to migrate the
+// applications back while they are still running there, and once it has
+// migrated them a switchover is performed from the destination cluster.
+func (ac *ActionController) failback(action *storkv1.Action) error {
+	schedule, err := storkops.Instance().GetMigrationSchedule(action.Spec.MigrationScheduleName, action.Namespace)
+	if action.Status == storkv1.ActionStatusScheduled {
+		if err != nil {
+			ac.updateStatus(action, storkv1.ActionStatusFailed)
+			return fmt.Errorf("error getting MigrationSchedule %v for failback: %v", action.Spec.MigrationScheduleName, err)
+		}
+		action.Status = storkv1.ActionStatusInProgress
+		return ac.startStage(action, storkv1.ActionStageReverseSchedule)
+	}
+	if err != nil {
+		return fmt.Errorf("error getting MigrationSchedule %v for failback: %v", action.Spec.MigrationScheduleName, err)
+	}
+
+	remoteOps, err := getRemoteStorkOps(schedule)
+	if err != nil {
+		return err
+	}
+	switch getCurrentStage(action).Stage {
+	case storkv1.ActionStageReverseSchedule:
+		return ac.failbackReverseSchedule(action, schedule, remoteOps)
+	case storkv1.ActionStageReverseMigration:
+		return ac.failbackReverseMigration(action, schedule, remoteOps)
+	case storkv1.ActionStageSwitchover:
+		return ac.failbackSwitchover(action, schedule, remoteOps)
+	case storkv1.ActionStageRollback:
+		return ac.failbackRollback(action, schedule, remoteOps)
+	}
+	return nil
+}
+
+// failbackReverseSchedule suspends the MigrationSchedule and creates the
+// reverse MigrationSchedule on the destination cluster
+func (ac *ActionController) failbackReverseSchedule(
+	action *storkv1.Action,
+	schedule *storkv1.MigrationSchedule,
+	remoteOps storkops.Ops,
+) error {
+	if _, ok := action.Annotations[scheduleSuspendedAnnotation]; !ok {
+		suspended := schedule.Spec.Suspend != nil && *schedule.Spec.Suspend
+		if action.Annotations == nil {
+			action.Annotations = make(map[string]string)
+		}
+		action.Annotations[scheduleSuspendedAnnotation] = strconv.FormatBool(suspended)
+		if err := ac.client.Update(context.TODO(), action); err != nil {
+			return err
+		}
+	}
+	if schedule.Spec.Suspend == nil || !*schedule.Spec.Suspend {
+		suspend := true
+		schedule.Spec.Suspend = &suspend
+		if _, err := storkops.Instance().UpdateMigrationSchedule(schedule); err != nil {
+			return err
+		}
+	}
+
+	if _, err := remoteOps.GetSchedulePolicy(schedule.Spec.SchedulePolicyName); err != nil {
+		if !errors.IsNotFound(err) {
+			return err
+		}
+		if _, err := remoteOps.GetNamespacedSchedulePolicy(schedule.Spec.SchedulePolicyName, schedule.Namespace); err != nil {
+			if !errors.IsNotFound(err) {
+				return err
+			}
+			return ac.failStage(action, fmt.Sprintf("SchedulePolicy %v doesn't exist on the destination cluster", schedule.Spec.SchedulePolicyName))
+		}
+	}
+
+	reverseClusterPair := action.Spec.ReverseClusterPair
+	if reverseClusterPair == "" {
+		reverseClusterPair = schedule.Spec.Template.Spec.ClusterPair
+	}
+	if _, err := remoteOps.GetClusterPair(reverseClusterPair, schedule.Namespace); err != nil {
+		if !errors.IsNotFound(err) {
+			return err
+		}
+		return ac.failStage(action, fmt.Sprintf("ClusterPair %v doesn't exist on the destination cluster", reverseClusterPair))
+	}
+
+	// Applications are only started by the switchover and the migrations
+	// back can't be triggered from this cluster
+	startApplications := false
+	suspend := false
+	spec := schedule.Spec.Template.Spec
+	spec.ClusterPair = reverseClusterPair
+	spec.AdminClusterPair = ""
+	spec.StartApplications = &startApplications
+	reverseName := schedule.Name + reverseMigrationScheduleSuffix
+	reverseSchedule, err := remoteOps.GetMigrationSchedule(reverseName, schedule.Namespace)
+	if err != nil {
+		if !errors.IsNotFound(err) {
+			return err
+		}
+		reverseSchedule = &storkv1.MigrationSchedule{
+			ObjectMeta: meta.ObjectMeta{
+				Name:      reverseName,
+				Namespace: schedule.Namespace,
+			},
+			Spec: storkv1.MigrationScheduleSpec{
+				Template:           storkv1.MigrationTemplateSpec{Spec: spec},
+				SchedulePolicyName: schedule.Spec.SchedulePolicyName,
+				Suspend:            &suspend,
+			},
+		}
+		log.ActionLog(action).Infof("Creating reverse MigrationSchedule %v on the destination cluster", reverseName)
+		if _, err := remoteOps.CreateMigrationSchedule(reverseSchedule); err != nil {
+			return err
+		}
+	} else {
+		// Reuse the reverse schedule from an earlier failback
+		reverseSchedule.Spec.Template.Spec = spec
+		reverseSchedule.Spec.SchedulePolicyName = schedule.Spec.SchedulePolicyName
+		reverseSchedule.Spec.Suspend = &suspend
+		reverseSchedule.Spec.AutoSuspend = false
+		reverseSchedule.Status = storkv1.MigrationScheduleStatus{}
+		log.ActionLog(action).Infof("Updating reverse MigrationSchedule %v on the destination cluster", reverseName)
+		if _, err := remoteOps.UpdateMigrationSchedule(reverseSchedule); err != nil {
+			return err
+		}
+	}
+	getCurrentStage(action).Resources = []string{reverseName}
+	ac.finishStage(action, storkv1.ActionStatusSuccessful,
+		fmt.Sprintf("Suspended MigrationSchedule %v and created reverse MigrationSchedule %v using ClusterPair %v on the destination cluster",
+			schedule.Name, reverseName, reverseClusterPair))
+	return ac.startStage(action, storkv1.ActionStageReverseMigration)
+}
+
+// failbackReverseMigration waits for a migration by the reverse
+// MigrationSchedule to succeed
+func (ac *ActionController) failbackReverseMigration(
+	action *storkv1.Action,
+	schedule *storkv1.MigrationSchedule,
+	remoteOps storkops.Ops,
+) error {
+	reverseName := schedule.Name + reverseMigrationScheduleSuffix
+	reverseSchedule, err := remoteOps.GetMigrationSchedule(reverseName, schedule.Namespace)
+	if err != nil {
+		return err
+	}
+	for _, policyMigrations := range reverseSchedule.Status.Items {
+		for _, scheduledMigration := range policyMigrations {
+			if scheduledMigration.Status == storkv1.MigrationStatusSuccessful {
+				ac.finishStage(action, storkv1.ActionStatusSuccessful,
+					fmt.Sprintf("Applications were migrated back by migration %v", scheduledMigration.Name))
+				return ac.startStage(action, storkv1.ActionStageSwitchover)
+			}
+		}
+	}
+	log.ActionLog(action).Infof("Waiting for a migration by reverse MigrationSchedule %v to succeed", reverseName)
+	return nil
+}
+
+// failbackSwitchover starts a switchover with the reverse MigrationSchedule on
+// the destination cluster and resumes the MigrationSchedule once it completes
+func (ac *ActionController) failbackSwitchover(
+	action *storkv1.Action,
+	schedule *storkv1.MigrationSchedule,
+	remoteOps storkops.Ops,
+) error {
+	switchoverName := action.Name + failbackSwitchoverSuffix
+	switchover, err := remoteOps.GetAction(switchoverName, schedule.Namespace)
+	if err != nil {
+		if !errors.IsNotFound(err) {
+			return err
+		}
+		switchover = &storkv1.Action{
+			ObjectMeta: meta.ObjectMeta{
+				Name:      switchoverName,
+				Namespace: schedule.Namespace,
+			},
+			Spec: storkv1.ActionSpec{
+				ActionType:            storkv1.ActionTypeSwitchover,
+				MigrationScheduleName: schedule.Name + reverseMigrationScheduleSuffix,
+			},
+			Status: storkv1.ActionStatusScheduled,
+		}
+		log.ActionLog(action).Infof("Starting switchover %v on the destination cluster", switchoverName)
+		if _, err := remoteOps.CreateAction(switchover); err != nil {
+			return err
+		}
+		getCurrentStage(action).Resources = []string{switchoverName}
+		return ac.client.Update(context.TODO(), action)
+	}
+
+	switch switchover.Status {
+	case storkv1.ActionStatusSuccessful:
+		suspend := false
+		schedule.Spec.Suspend = &suspend
+		if _, err := storkops.Instance().UpdateMigrationSchedule(schedule); err != nil {
+			return err
+		}
+		ac.finishStage(action, storkv1.ActionStatusSuccessful,
+			fmt.Sprintf("Applications were switched over from the destination cluster and MigrationSchedule %v was resumed", schedule.Name))
+		ac.updateStatus(action, storkv1.ActionStatusSuccessful)
+	case storkv1.ActionStatusFailed:
+		return ac.failStage(action, fmt.Sprintf("Switchover %v failed on the destination cluster", switchoverName))
+	}
+	return nil
+}
+
+// failbackRollback deletes the reverse MigrationSchedule and resumes the
+// MigrationSchedule if it wasn't suspended before the failback. A failed
+// switchover has already started the applications on the destination cluster
+// again.
+func (ac *ActionController) failbackRollback(
+	action *storkv1.Action,
+	schedule *storkv1.MigrationSchedule,
+	remoteOps storkops.Ops,
+) error {
+	reverseName := schedule.Name + reverseMigrationScheduleSuffix
+	if err := remoteOps.DeleteMigrationSchedule(reverseName, schedule.Namespace); err != nil && !errors.IsNotFound(err) {
+		return err
+	}
+	if suspended, ok := action.Annotations[scheduleSuspendedAnnotation]; ok {
+		suspend, err := strconv.ParseBool(suspended)
+		if err != nil {
+			return fmt.Errorf("invalid value %v for annotation %v: %v", suspended, scheduleSuspendedAnnotation, err)
+		}
+		if schedule.Spec.Suspend == nil || *schedule.Spec.Suspend != suspend {
+			schedule.Spec.Suspend = &suspend
+			if _, err := storkops.Instance().UpdateMigrationSchedule(schedule); err != nil {
+				return err
+			}
+		}
+	}
+	ac.finishStage(action, storkv1.ActionStatusSuccessful,
+		fmt.Sprintf("Deleted reverse MigrationSchedule %v from the destination cluster and restored the suspend state of MigrationSchedule %v",
+			reverseName, schedule.Name))
+	ac.updateStatus(action, storkv1.ActionStatusFailed)
+	return nil
+}
@@ -0,0 +1,230 @@
+//go:build unittest
+// +build unittest
+
+package action
+
+import (
+	"testing"
+
+	storkv1 "github.com/libopenstorage/stork/pkg/apis/stork/v1alpha1"
+	fakeclient "github.com/libopenstorage/stork/pkg/client/clientset/versioned/fake"
+	storkops "github.com/portworx/sched-ops/k8s/stork"
+	"github.com/stretchr/testify/require"
+	meta "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func newTestFailback(stages ...storkv1.ActionStageType) *storkv1.Action {
+	action := newTestSwitchover(stages...)
+	action.Name = "failback"
+	action.Spec.ActionType = storkv1.ActionTypeFailback
+	return action
+}
+
+// newTestRemoteOps returns the client for the destination cluster with the
+// SchedulePolicy and ClusterPair used by the reverse MigrationSchedule
+func newTestRemoteOps(t *testing.T) storkops.Ops {
+	remoteOps := storkops.New(fake.NewSimpleClientset(), fakeclient.NewSimpleClientset(), nil)
+	_, err := remoteOps.CreateSchedulePolicy(&storkv1.SchedulePolicy{
+		ObjectMeta: meta.ObjectMeta{Name: "policy"},
+	})
+	require.NoError(t, err)
+	_, err = remoteOps.CreateClusterPair(&storkv1.ClusterPair{
+		ObjectMeta: meta.ObjectMeta{Name: "reverse", Namespace: "admin"},
+	})
+	require.NoError(t, err)
+	return remoteOps
+}
+
+func createTestFailbackSchedule(t *testing.T, suspend bool) *storkv1.MigrationSchedule {
+	schedule := createTestMigrationSchedule(t, suspend)
+	schedule.Spec.SchedulePolicyName = "policy"
+	schedule, err := storkops.Instance().UpdateMigrationSchedule(schedule)
+	require.NoError(t, err)
+	return schedule
+}
+
+func requireScheduleSuspended(t *testing.T, expected bool) {
+	schedule, err := storkops.Instance().GetMigrationSchedule("schedule", "admin")
+	require.NoError(t, err)
+	require.Equal(t, expected, *schedule.Spec.Suspend, "Unexpected suspend state for MigrationSchedule")
+}
+
+func TestFailbackReverseSchedule(t *testing.T) {
+	setupTestClients(t)
+	remoteOps := newTestRemoteOps(t)
+	schedule := createTestFailbackSchedule(t, false)
+	action := newTestFailback(storkv1.ActionStageReverseSchedule)
+	action.Spec.ReverseClusterPair = "reverse"
+	ac := newTestActionController(t, action)
+
+	require.NoError(t, ac.failbackReverseSchedule(action, schedule, remoteOps))
+	requireScheduleSuspended(t, true)
+	require.Equal(t, "false", action.Annotations[scheduleSuspendedAnnotation], "Suspend state before the failback not recorded")
+	requireStage(t, ac, action, storkv1.ActionStageReverseMigration)
+	require.Equal(t, []string{"schedule-reverse"}, action.Stages[0].Resources)
+	reverseSchedule, err := remoteOps.GetMigrationSchedule("schedule-reverse", "admin")
+	require.NoError(t, err, "Reverse MigrationSchedule not created on the destination cluster")
+	require.Equal(t, "reverse", reverseSchedule.Spec.Template.Spec.ClusterPair)
+	require.Equal(t, "policy", reverseSchedule.Spec.SchedulePolicyName)
+	require.False(t, *reverseSchedule.Spec.Template.Spec.StartApplications, "Reverse migrations shouldn't start applications")
+	require.False(t, *reverseSchedule.Spec.Suspend)
+
+	// The reverse schedule from an earlier failback is reused and the
+	// recorded suspend state isn't overwritten by the one set by the
+	// failback
+	reverseSchedule.Status.Items = map[storkv1.SchedulePolicyType][]*storkv1.ScheduledMigrationStatus{
+		storkv1.SchedulePolicyTypeInterval: {{Name: "old", Status: storkv1.MigrationStatusSuccessful}},
+	}
+	_, err = remoteOps.UpdateMigrationSchedule(reverseSchedule)
+	require.NoError(t, err)
+	schedule, err = storkops.Instance().GetMigrationSchedule("schedule", "admin")
+	require.NoError(t, err)
+	action = newTestFailback(storkv1.ActionStageReverseSchedule)
+	action.Annotations = map[string]string{scheduleSuspendedAnnotation: "false"}
+	action.Spec.ReverseClusterPair = "reverse"
+	ac = newTestActionController(t, action)
+	require.NoError(t, ac.failbackReverseSchedule(action, schedule, remoteOps))
+	require.Equal(t, "false", action.Annotations[scheduleSuspendedAnnotation])
+	reverseSchedule, err = remoteOps.GetMigrationSchedule("schedule-reverse", "admin")
+	require.NoError(t, err)
+	require.Empty(t, reverseSchedule.Status.Items, "Status of the reused reverse MigrationSchedule should be reset")
+}
+
+func TestFailbackReverseScheduleMissingClusterPair(t *testing.T) {
+	setupTestClients(t)
+	remoteOps := newTestRemoteOps(t)
+	schedule := createTestFailbackSchedule(t, true)
+	action := newTestFailback(storkv1.ActionStageReverseSchedule)
+	action.Spec.ReverseClusterPair = "missing"
+	ac := newTestActionController(t, action)
+
+	require.NoError(t, ac.failbackReverseSchedule(action, schedule, remoteOps))
+	require.Equal(t, "true", action.Annotations[scheduleSuspendedAnnotation])
+	requireStage(t, ac, action, storkv1.ActionStageRollback)
+	require.Equal(t, storkv1.ActionStatusFailed, action.Stages[0].Status, "ReverseSchedule should have failed")
+	require.Contains(t, action.Stages[0].Reason, "missing")
+	_, err := remoteOps.GetMigrationSchedule("schedule-reverse", "admin")
+	require.Error(t, err, "Reverse MigrationSchedule shouldn't be created")
+}
+
+func TestFailbackReverseMigration(t *testing.T) {
+	setupTestClients(t)
+	remoteOps := newTestRemoteOps(t)
+	schedule := createTestFailbackSchedule(t, true)
+	reverseSchedule, err := remoteOps.CreateMigrationSchedule(&storkv1.MigrationSchedule{
+		ObjectMeta: meta.ObjectMeta{Name: "schedule-reverse", Namespace: "admin"},
+		Status: storkv1.MigrationScheduleStatus{
+			Items: map[storkv1.SchedulePolicyType][]*storkv1.ScheduledMigrationStatus{
+				storkv1.SchedulePolicyTypeInterval: {{Name: "reverse", Status: storkv1.MigrationStatusFailed}},
+			},
+		},
+	})
+	require.NoError(t, err)
+	action := newTestFailback(storkv1.ActionStageReverseSchedule, storkv1.ActionStageReverseMigration)
+	ac := newTestActionController(t, action)
+
+	require.NoError(t, ac.failbackReverseMigration(action, schedule, remoteOps))
+	requireStage(t, ac, action, storkv1.ActionStageReverseMigration)
+
+	reverseSchedule.Status.Items[storkv1.SchedulePolicyTypeInterval] = append(reverseSchedule.Status.Items[storkv1.SchedulePolicyTypeInterval],
+		&storkv1.ScheduledMigrationStatus{Name: "reverse-2", Status: storkv1.MigrationStatusSuccessful})
+	_, err = remoteOps.UpdateMigrationSchedule(reverseSchedule)
+	require.NoError(t, err)
+	require.NoError(t, ac.failbackReverseMigration(action, schedule, remoteOps))
+	requireStage(t, ac, action, storkv1.ActionStageSwitchover)
+	require.Contains(t, action.Stages[1].Reason, "reverse-2")
+}
+
+func TestFailbackSwitchover(t *testing.T) {
+	setupTestClients(t)
+	remoteOps := newTestRemoteOps(t)
+	schedule := createTestFailbackSchedule(t, true)
+	action := newTestFailback(storkv1.ActionStageReverseSchedule, storkv1.ActionStageReverseMigration,
+		storkv1.ActionStageSwitchover)
+	ac := newTestActionController(t, action)
+
+	require.NoError(t, ac.failbackSwitchover(action, schedule, remoteOps))
+	require.Equal(t, []string{"failback-switchover"}, getCurrentStage(action).Resources)
+	switchover, err := remoteOps.GetAction("failback-switchover", "admin")
+	require.NoError(t, err, "Switchover not created on the destination cluster")
+	require.Equal(t, storkv1.ActionTypeSwitchover, switchover.Spec.ActionType)
+	require.Equal(t, "schedule-reverse", switchover.Spec.MigrationScheduleName)
+
+	// The MigrationSchedule is only resumed once the switchover has completed
+	require.NoError(t, ac.failbackSwitchover(action, schedule, remoteOps))
+	requireScheduleSuspended(t, true)
+	require.Equal(t, storkv1.ActionStatusInProgress, action.Status)
+
+	switchover.Status = storkv1.ActionStatusSuccessful
+	_, err = remoteOps.UpdateAction(switchover)
+	require.NoError(t, err)
+	require.NoError(t, ac.failbackSwitchover(action, schedule, remoteOps))
+	requireScheduleSuspended(t, false)
+	require.Equal(t, storkv1.ActionStatusSuccessful, action.Status, "Failback should have succeeded")
+}
+
+func TestFailbackSwitchoverFailed(t *testing.T) {
+	setupTestClients(t)
+	remoteOps := newTestRemoteOps(t)
+	schedule := createTestFailbackSchedule(t, true)
+	action := newTestFailback(storkv1.ActionStageReverseSchedule, storkv1.ActionStageReverseMigration,
+		storkv1.ActionStageSwitchover)
+	ac := newTestActionController(t, action)
+	_, err := remoteOps.CreateAction(&storkv1.Action{
+		ObjectMeta: meta.ObjectMeta{Name: "failback-switchover", Namespace: "admin"},
+		Spec:       storkv1.ActionSpec{ActionType: storkv1.ActionTypeSwitchover},
+		Status:     storkv1.ActionStatusFailed,
+	})
+	require.NoError(t, err)
+
+	require.NoError(t, ac.failbackSwitchover(action, schedule, remoteOps))
+	requireStage(t, ac, action, storkv1.ActionStageRollback)
+	require.Equal(t, storkv1.ActionStatusFailed, action.Stages[2].Status, "Switchover should have failed")
+	requireScheduleSuspended(t, true)
+}
+
+func TestFailbackRollback(t *testing.T) {
+	tests := []struct {
+		name      string
+		suspended string
+		expected  bool
+	}{
+		{
+			name:      "resumed",
+			suspended: "false",
+			expected:  false,
+		},
+		{
+			name:      "suspended before failback",
+			suspended: "true",
+			expected:  true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			setupTestClients(t)
+			remoteOps := newTestRemoteOps(t)
+			schedule := createTestFailbackSchedule(t, true)
+			_, err := remoteOps.CreateMigrationSchedule(&storkv1.MigrationSchedule{
+				ObjectMeta: meta.ObjectMeta{Name: "schedule-reverse", Namespace: "admin"},
+			})
+			require.NoError(t, err)
+			action := newTestFailback(storkv1.ActionStageReverseSchedule, storkv1.ActionStageReverseMigration,
+				storkv1.ActionStageSwitchover, storkv1.ActionStageRollback)
+			action.Stages[2].Status = storkv1.ActionStatusFailed
+			action.Annotations = map[string]string{scheduleSuspendedAnnotation: test.suspended}
+			ac := newTestActionController(t, action)
+
+			require.NoError(t, ac.failbackRollback(action, schedule, remoteOps))
+			_, err = remoteOps.GetMigrationSchedule("schedule-reverse", "admin")
+			require.Error(t, err, "Reverse MigrationSchedule should be deleted")
+			requireScheduleSuspended(t, test.expected)
+			require.Equal(t, storkv1.ActionStatusFailed, action.Status, "Failback should have failed")
+			require.Equal(t, storkv1.ActionStatusSuccessful, getCurrentStage(action).Status, "Rollback should have succeeded")
+
+			// The rollback is done if the reverse schedule was already deleted
+			require.NoError(t, ac.failbackRollback(action, schedule, remoteOps))
+		})
+	}
+}
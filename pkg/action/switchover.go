@@ -0,0 +1,450 @@
+package action
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	storkv1 "github.com/libopenstorage/stork/pkg/apis/stork/v1alpha1"
+	"github.com/libopenstorage/stork/pkg/log"
+	migration "github.com/libopenstorage/stork/pkg/migration/controllers"
+	"github.com/libopenstorage/stork/pkg/resourceutils"
+	"github.com/portworx/sched-ops/k8s/core"
+	storkops "github.com/portworx/sched-ops/k8s/stork"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	meta "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// podTerminationTimeout is how long to wait for the pods of the
+	// applications scaled down on the source cluster to terminate
+	podTerminationTimeout  = 10 * time.Minute
+	deactivateActionSuffix = "-deactivate"
+)
+
+// switchover moves the applications migrated by a MigrationSchedule to its
+// destination cluster. The applications on the source cluster are scaled
+// down and their pods have terminated before the last migration so that it
+// has all their changes, and they are only started on the destination
+// cluster once it has finished. The progress
+// of each stage is recorded in the Action so that the switchover is resumed
+// after a restart and rolled back if a stage fails.
+func (ac *ActionController) switchover(action *storkv1.Action) error {
+	if action.Status == storkv1.ActionStatusScheduled {
+		schedule, err := storkops.Instance().GetMigrationSchedule(action.Spec.MigrationScheduleName, action.Namespace)
+		if err != nil {
+			ac.updateStatus(action, storkv1.ActionStatusFailed)
+			return fmt.Errorf("error getting MigrationSchedule %v for switchover: %v", action.Spec.MigrationScheduleName, err)
+		}
+		if schedule.Spec.Suspend != nil && *schedule.Spec.Suspend {
+			ac.updateStatus(action, storkv1.ActionStatusFailed)
+			return fmt.Errorf("MigrationSchedule %v is suspended", schedule.Name)
+		}
+		action.Status = storkv1.ActionStatusInProgress
+		return ac.startStage(action, storkv1.ActionStageScaleDownSource)
+	}
+
+	schedule, err := storkops.Instance().GetMigrationSchedule(action.Spec.MigrationScheduleName, action.Namespace)
+	if err != nil {
+		return fmt.Errorf("error getting MigrationSchedule %v for switchover: %v", action.Spec.MigrationScheduleName, err)
+	}
+	switch getCurrentStage(action).Stage {
+	case storkv1.ActionStageScaleDownSource:
+		return ac.switchoverScaleDownSource(action, schedule)
+	case storkv1.ActionStageFinalMigration:
+		return ac.switchoverFinalMigration(action, schedule)
+	case storkv1.ActionStageActivateDestination:
+		remoteOps, err := getRemoteStorkOps(schedule)
+		if err != nil {
+			return err
+		}
+		return ac.switchoverActivateDestination(action, schedule, remoteOps)
+	case storkv1.ActionStageRollback:
+		remoteOps, err := getRemoteStorkOps(schedule)
+		if err != nil {
+			return err
+		}
+		return ac.switchoverRollback(action, schedule, remoteOps)
+	}
+	return nil
+}
+
+// getRemoteStorkOps returns the client for the destination cluster of the
+// MigrationSchedule
+func getRemoteStorkOps(schedule *storkv1.MigrationSchedule) (storkops.Ops, error) {
+	remoteConfig, err := migration.GetClusterPairSchedulerConfig(schedule.Spec.Template.Spec.ClusterPair, schedule.Namespace)
+	if err != nil {
+		return nil, err
+	}
+	return storkops.NewForConfig(remoteConfig)
+}
+
+// switchoverScaleDownSource suspends the MigrationSchedule and scales down the
+// applications in the namespaces it migrates, then waits for their pods to
+// terminate
+func (ac *ActionController) switchoverScaleDownSource(action *storkv1.Action, schedule *storkv1.MigrationSchedule) error {
+	stage := getCurrentStage(action)
+	if len(stage.Resources) == 0 {
+		suspend := true
+		schedule.Spec.Suspend = &suspend
+		if _, err := storkops.Instance().UpdateMigrationSchedule(schedule); err != nil {
+			return ac.failStage(action, fmt.Sprintf("Error suspending MigrationSchedule %v: %v", schedule.Name, err))
+		}
+		namespaces, err := getMigrationScheduleNamespaces(schedule)
+		if err != nil {
+			return ac.failStage(action, fmt.Sprintf("Error getting namespaces of MigrationSchedule %v: %v", schedule.Name, err))
+		}
+		for _, ns := range namespaces {
+			// Annotate the applications like the migrated ones so that
+			// the last migration and a rollback start them again
+			if err := resourceutils.RecordReplicas(ns, ac.printFunc(action, "ScaleReplicas"), ac.config); err != nil {
+				return ac.failStage(action, fmt.Sprintf("Error scaling down applications in namespace %v: %v", ns, err))
+			}
+			resourceutils.ScaleReplicas(ns, false, ac.printFunc(action, "ScaleReplicas"), ac.config)
+		}
+		// Record the namespaces so that the applications are only scaled
+		// down once while waiting for their pods
+		stage.Resources = namespaces
+		stage.LastUpdateTimestamp = meta.Now()
+		if err := ac.client.Update(context.TODO(), action); err != nil {
+			return err
+		}
+	}
+
+	pods, err := getActivePods(stage.Resources)
+	if err != nil {
+		return err
+	}
+	if len(pods) != 0 {
+		if time.Since(stage.LastUpdateTimestamp.Time) > podTerminationTimeout {
+			return ac.failStage(action, fmt.Sprintf("Timed out waiting for pods %v to terminate", pods))
+		}
+		log.ActionLog(action).Infof("Waiting for pods %v to terminate before the final migration", pods)
+		return nil
+	}
+	ac.finishStage(action, storkv1.ActionStatusSuccessful,
+		fmt.Sprintf("Suspended MigrationSchedule %v and scaled down applications in namespaces %v", schedule.Name, stage.Resources))
+	return ac.startStage(action, storkv1.ActionStageFinalMigration)
+}
+
+// getActivePods returns the pods in the namespaces that are still running for
+// the applications scaled down by ScaleReplicas. Pods of daemonsets and jobs
+// and pods without a controller aren't scaled down and are ignored.
+func getActivePods(namespaces []string) ([]string, error) {
+	active := make([]string, 0)
+	for _, ns := range namespaces {
+		pods, err := core.Instance().GetPods(ns, nil)
+		if err != nil {
+			return nil, err
+		}
+		for _, pod := range pods.Items {
+			if pod.Status.Phase == v1.PodSucceeded || pod.Status.Phase == v1.PodFailed {
+				continue
+			}
+			owner := meta.GetControllerOf(&pod)
+			if owner == nil || owner.Kind == "DaemonSet" || owner.Kind == "Job" {
+				continue
+			}
+			active = append(active, pod.Namespace+"/"+pod.Name)
+		}
+	}
+	return active, nil
+}
+
+// switchoverFinalMigration runs one last migration with the spec of the
+// MigrationSchedule once the migrations it triggered have finished, and waits
+// for it to complete
+func (ac *ActionController) switchoverFinalMigration(action *storkv1.Action, schedule *storkv1.MigrationSchedule) error {
+	stage := getCurrentStage(action)
+	if len(stage.Resources) == 0 {
+		for _, policyMigrations := range schedule.Status.Items {
+			for _, scheduledMigration := range policyMigrations {
+				if scheduledMigration.Status == storkv1.MigrationStatusPending ||
+					scheduledMigration.Status == storkv1.MigrationStatusInProgress {
+					log.ActionLog(action).Infof("Waiting for migration %v to finish before the final migration", scheduledMigration.Name)
+					return nil
+				}
+			}
+		}
+		// Record the name before creating the migration so that only one is
+		// created if the update fails
+		stage.Resources = []string{strings.Join([]string{schedule.Name, action.Name}, "-")}
+		stage.LastUpdateTimestamp = meta.Now()
+		if err := ac.client.Update(context.TODO(), action); err != nil {
+			return err
+		}
+	}
+
+	migrationName := stage.Resources[0]
+	finalMigration, err := storkops.Instance().GetMigration(migrationName, schedule.Namespace)
+	if err != nil {
+		if !errors.IsNotFound(err) {
+			return err
+		}
+		startApplications := false
+		finalMigration = &storkv1.Migration{
+			ObjectMeta: meta.ObjectMeta{
+				Name:      migrationName,
+				Namespace: schedule.Namespace,
+			},
+			Spec: schedule.Spec.Template.Spec,
+		}
+		finalMigration.Spec.StartApplications = &startApplications
+		log.ActionLog(action).Infof("Starting final migration %v", migrationName)
+		_, err = storkops.Instance().CreateMigration(finalMigration)
+		return err
+	}
+
+	switch finalMigration.Status.Status {
+	case storkv1.MigrationStatusSuccessful:
+		ac.finishStage(action, storkv1.ActionStatusSuccessful,
+			fmt.Sprintf("Final migration %v completed", migrationName))
+		return ac.startStage(action, storkv1.ActionStageActivateDestination)
+	case storkv1.MigrationStatusFailed, storkv1.MigrationStatusPartialSuccess:
+		return ac.failStage(action, fmt.Sprintf("Final migration %v finished with status %v", migrationName, finalMigration.Status.Status))
+	}
+	return nil
+}
+
+// switchoverActivateDestination starts a failover on the destination cluster
+// for each of the migrated namespaces and waits for them to complete
+func (ac *ActionController) switchoverActivateDestination(
+	action *storkv1.Action,
+	schedule *storkv1.MigrationSchedule,
+	remoteOps storkops.Ops,
+) error {
+	stage := getCurrentStage(action)
+	if len(stage.Resources) == 0 {
+		namespaces, err := getMigrationScheduleNamespaces(schedule)
+		if err != nil {
+			return err
+		}
+		for _, ns := range namespaces {
+			stage.Resources = append(stage.Resources, ns+"/"+action.Name)
+		}
+		stage.LastUpdateTimestamp = meta.Now()
+		if err := ac.client.Update(context.TODO(), action); err != nil {
+			return err
+		}
+	}
+
+	done := true
+	for _, resource := range stage.Resources {
+		parts := strings.SplitN(resource, "/", 2)
+		failover, err := remoteOps.GetAction(parts[1], parts[0])
+		if err != nil {
+			if !errors.IsNotFound(err) {
+				return err
+			}
+			failover = &storkv1.Action{
+				ObjectMeta: meta.ObjectMeta{
+					Name:      parts[1],
+					Namespace: parts[0],
+				},
+				Spec: storkv1.ActionSpec{
+					ActionType: storkv1.ActionTypeFailover,
+				},
+				Status: storkv1.ActionStatusScheduled,
+			}
+			log.ActionLog(action).Infof("Starting failover for namespace %v on the destination cluster", parts[0])
+			if _, err := remoteOps.CreateAction(failover); err != nil {
+				return err
+			}
+			done = false
+			continue
+		}
+		switch failover.Status {
+		case storkv1.ActionStatusSuccessful:
+		case storkv1.ActionStatusFailed:
+			return ac.failStage(action, fmt.Sprintf("Failover for namespace %v failed on the destination cluster", parts[0]))
+		default:
+			done = false
+		}
+	}
+	if !done {
+		return nil
+	}
+	ac.finishStage(action, storkv1.ActionStatusSuccessful, "Applications were started on the destination cluster")
+	ac.updateStatus(action, storkv1.ActionStatusSuccessful)
+	return nil
+}
+
+// switchoverRollback stops the applications that were already started on the
+// destination cluster, then starts the applications on the source cluster
+// again and resumes the MigrationSchedule
+func (ac *ActionController) switchoverRollback(
+	action *storkv1.Action,
+	schedule *storkv1.MigrationSchedule,
+	remoteOps storkops.Ops,
+) error {
+	done, err := ac.deactivateDestination(action, remoteOps)
+	if err != nil || !done {
+		return err
+	}
+	namespaces, err := getMigrationScheduleNamespaces(schedule)
+	if err != nil {
+		return err
+	}
+	for _, ns := range namespaces {
+		resourceutils.ScaleReplicas(ns, true, ac.printFunc(action, "ScaleReplicas"), ac.config)
+	}
+	suspend := false
+	schedule.Spec.Suspend = &suspend
+	if _, err := storkops.Instance().UpdateMigrationSchedule(schedule); err != nil {
+		return err
+	}
+	ac.finishStage(action, storkv1.ActionStatusSuccessful,
+		fmt.Sprintf("Started applications in namespaces %v and resumed MigrationSchedule %v", namespaces, schedule.Name))
+	ac.updateStatus(action, storkv1.ActionStatusFailed)
+	return nil
+}
+
+// deactivateDestination starts a deactivate action on the destination cluster
+// for each namespace where a failover was started by the ActivateDestination
+// stage, and returns true once they have all completed
+func (ac *ActionController) deactivateDestination(action *storkv1.Action, remoteOps storkops.Ops) (bool, error) {
+	var activateStage *storkv1.ActionStageInfo
+	for _, stage := range action.Stages {
+		if stage.Stage == storkv1.ActionStageActivateDestination {
+			activateStage = stage
+		}
+	}
+	if activateStage == nil {
+		return true, nil
+	}
+
+	done := true
+	for _, resource := range activateStage.Resources {
+		parts := strings.SplitN(resource, "/", 2)
+		failover, err := remoteOps.GetAction(parts[1], parts[0])
+		if err != nil {
+			if errors.IsNotFound(err) {
+				continue
+			}
+			return false, err
+		}
+		// The applications could be started after they are deactivated
+		// if the failover is still running
+		if failover.Status == storkv1.ActionStatusScheduled || failover.Status == storkv1.ActionStatusInProgress {
+			log.ActionLog(action).Infof("Waiting for failover for namespace %v to finish on the destination cluster", parts[0])
+			done = false
+			continue
+		}
+
+		deactivateName := parts[1] + deactivateActionSuffix
+		deactivate, err := remoteOps.GetAction(deactivateName, parts[0])
+		if err != nil {
+			if !errors.IsNotFound(err) {
+				return false, err
+			}
+			deactivate = &storkv1.Action{
+				ObjectMeta: meta.ObjectMeta{
+					Name:      deactivateName,
+					Namespace: parts[0],
+				},
+				Spec: storkv1.ActionSpec{
+					ActionType: storkv1.ActionTypeDeactivate,
+				},
+				Status: storkv1.ActionStatusScheduled,
+			}
+			log.ActionLog(action).Infof("Stopping applications in namespace %v on the destination cluster", parts[0])
+			if _, err := remoteOps.CreateAction(deactivate); err != nil {
+				return false, err
+			}
+			done = false
+			continue
+		}
+		switch deactivate.Status {
+		case storkv1.ActionStatusSuccessful:
+		case storkv1.ActionStatusFailed:
+			ac.recorder.Event(action, v1.EventTypeWarning, string(storkv1.ActionStageRollback),
+				fmt.Sprintf("Failed to stop applications in namespace %v on the destination cluster", parts[0]))
+		default:
+			done = false
+		}
+	}
+	return done, nil
+}
+
+func getCurrentStage(action *storkv1.Action) *storkv1.ActionStageInfo {
+	if len(action.Stages) == 0 {
+		return &storkv1.ActionStageInfo{}
+	}
+	return action.Stages[len(action.Stages)-1]
+}
+
+// startStage records the start of the next stage of the action
+func (ac *ActionController) startStage(action *storkv1.Action, stage storkv1.ActionStageType) error {
+	log.ActionLog(action).Infof("Starting stage %v", stage)
+	action.Stages = append(action.Stages, &storkv1.ActionStageInfo{
+		Stage:               stage,
+		Status:              storkv1.ActionStatusInProgress,
+		LastUpdateTimestamp: meta.Now(),
+	})
+	return ac.client.Update(context.TODO(), action)
+}
+
+// finishStage records the result of the current stage of the action, the
+// action is updated along with the start of the next stage
+func (ac *ActionController) finishStage(action *storkv1.Action, status storkv1.ActionStatus, reason string) {
+	stage := getCurrentStage(action)
+	stage.Status = status
+	stage.Reason = reason
+	stage.LastUpdateTimestamp = meta.Now()
+	eventType := v1.EventTypeNormal
+	if status == storkv1.ActionStatusFailed {
+		eventType = v1.EventTypeWarning
+		log.ActionLog(action).Errorf("Stage %v failed: %v", stage.Stage, reason)
+	} else {
+		log.ActionLog(action).Infof("Stage %v completed: %v", stage.Stage, reason)
+	}
+	ac.recorder.Event(action, eventType, string(stage.Stage), reason)
+}
+
+// failStage records the failure of the current stage and starts rolling back
+// the action
+func (ac *ActionController) failStage(action *storkv1.Action, reason string) error {
+	ac.finishStage(action, storkv1.ActionStatusFailed, reason)
+	return ac.startStage(action, storkv1.ActionStageRollback)
+}
+
+// getMigrationScheduleNamespaces returns the namespaces migrated by the
+// schedule, including the ones of its waves
+func getMigrationScheduleNamespaces(schedule *storkv1.MigrationSchedule) ([]string, error) {
+	spec := schedule.Spec.Template.Spec
+	namespaceLists := [][]string{spec.Namespaces}
+	namespaceSelectors := []map[string]string{spec.NamespaceSelectors}
+	for _, wave := range spec.Waves {
+		namespaceLists = append(namespaceLists, wave.Namespaces)
+		namespaceSelectors = append(namespaceSelectors, wave.NamespaceSelectors)
+	}
+
+	namespaces := make([]string, 0)
+	selected := make(map[string]bool)
+	for _, namespaceList := range namespaceLists {
+		for _, ns := range namespaceList {
+			if !selected[ns] {
+				selected[ns] = true
+				namespaces = append(namespaces, ns)
+			}
+		}
+	}
+	for _, selectors := range namespaceSelectors {
+		if len(selectors) == 0 {
+			continue
+		}
+		namespaceList, err := core.Instance().ListNamespaces(selectors)
+		if err != nil {
+			return nil, err
+		}
+		for _, ns := range namespaceList.Items {
+			if !selected[ns.Name] {
+				selected[ns.Name] = true
+				namespaces = append(namespaces, ns.Name)
+			}
+		}
+	}
+	return namespaces, nil
+}
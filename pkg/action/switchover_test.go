@@ -0,0 +1,341 @@
+//go:build unittest
+// +build unittest
+
+package action
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	storkv1 "github.com/libopenstorage/stork/pkg/apis/stork/v1alpha1"
+	"github.com/libopenstorage/stork/pkg/appregistration"
+	fakeclient "github.com/libopenstorage/stork/pkg/client/clientset/versioned/fake"
+	migration "github.com/libopenstorage/stork/pkg/migration/controllers"
+	fakeocpclient "github.com/openshift/client-go/apps/clientset/versioned/fake"
+	fakeocpconfigclient "github.com/openshift/client-go/config/clientset/versioned/fake"
+	fakeocpsecurityclient "github.com/openshift/client-go/security/clientset/versioned/fake"
+	"github.com/portworx/sched-ops/k8s/apps"
+	"github.com/portworx/sched-ops/k8s/batch"
+	"github.com/portworx/sched-ops/k8s/core"
+	"github.com/portworx/sched-ops/k8s/dynamic"
+	"github.com/portworx/sched-ops/k8s/openshift"
+	storkops "github.com/portworx/sched-ops/k8s/stork"
+	"github.com/stretchr/testify/require"
+	appsv1 "k8s.io/api/apps/v1"
+	v1 "k8s.io/api/core/v1"
+	meta "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	fakedynamicclient "k8s.io/client-go/dynamic/fake"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/record"
+	runtimeclient "sigs.k8s.io/controller-runtime/pkg/client"
+	fakeruntimeclient "sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+const testNamespace = "ns1"
+
+var fakeKubeClient *fake.Clientset
+
+func setupTestClients(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, storkv1.AddToScheme(scheme))
+	fakeKubeClient = fake.NewSimpleClientset()
+	core.SetInstance(core.New(fakeKubeClient))
+	storkops.SetInstance(storkops.New(fakeKubeClient, fakeclient.NewSimpleClientset(), nil))
+	apps.SetInstance(apps.New(fakeKubeClient.AppsV1(), fakeKubeClient.CoreV1()))
+	batch.SetInstance(batch.New(fakeKubeClient.BatchV1(), fakeKubeClient.BatchV1beta1()))
+	openshift.SetInstance(openshift.New(fakeKubeClient, fakeocpclient.NewSimpleClientset(),
+		fakeocpsecurityclient.NewSimpleClientset(), fakeocpconfigclient.NewSimpleClientset()))
+	dynamic.SetInstance(dynamic.New(fakedynamicclient.NewSimpleDynamicClientWithCustomListKinds(scheme, appregistration.GetSupportedGVR())))
+}
+
+func newTestActionController(t *testing.T, action *storkv1.Action) *ActionController {
+	scheme := runtime.NewScheme()
+	require.NoError(t, storkv1.AddToScheme(scheme))
+	return &ActionController{
+		client:   fakeruntimeclient.NewClientBuilder().WithScheme(scheme).WithObjects(action).Build(),
+		recorder: record.NewFakeRecorder(100),
+		config:   &rest.Config{Host: "localhost"},
+	}
+}
+
+func newTestSwitchover(stages ...storkv1.ActionStageType) *storkv1.Action {
+	action := &storkv1.Action{
+		ObjectMeta: meta.ObjectMeta{
+			Name:      "switchover",
+			Namespace: "admin",
+		},
+		Spec: storkv1.ActionSpec{
+			ActionType:            storkv1.ActionTypeSwitchover,
+			MigrationScheduleName: "schedule",
+		},
+		Status: storkv1.ActionStatusInProgress,
+	}
+	for i, stage := range stages {
+		status := storkv1.ActionStatusSuccessful
+		if i == len(stages)-1 {
+			status = storkv1.ActionStatusInProgress
+		}
+		action.Stages = append(action.Stages, &storkv1.ActionStageInfo{
+			Stage:               stage,
+			Status:              status,
+			LastUpdateTimestamp: meta.Now(),
+		})
+	}
+	return action
+}
+
+func createTestMigrationSchedule(t *testing.T, suspend bool) *storkv1.MigrationSchedule {
+	schedule, err := storkops.Instance().CreateMigrationSchedule(&storkv1.MigrationSchedule{
+		ObjectMeta: meta.ObjectMeta{
+			Name:      "schedule",
+			Namespace: "admin",
+		},
+		Spec: storkv1.MigrationScheduleSpec{
+			Template: storkv1.MigrationTemplateSpec{
+				Spec: storkv1.MigrationSpec{
+					ClusterPair: "clusterpair",
+					Namespaces:  []string{testNamespace},
+				},
+			},
+			Suspend: &suspend,
+		},
+	})
+	require.NoError(t, err, "Error creating MigrationSchedule")
+	return schedule
+}
+
+func createTestDeployment(t *testing.T, replicas int32, annotations map[string]string) {
+	_, err := apps.Instance().CreateDeployment(&appsv1.Deployment{
+		ObjectMeta: meta.ObjectMeta{
+			Name:        "web",
+			Namespace:   testNamespace,
+			Annotations: annotations,
+		},
+		Spec: appsv1.DeploymentSpec{
+			Replicas: &replicas,
+		},
+	}, meta.CreateOptions{})
+	require.NoError(t, err, "Error creating deployment")
+}
+
+func requireDeploymentReplicas(t *testing.T, expected int32) *appsv1.Deployment {
+	deployment, err := apps.Instance().GetDeployment("web", testNamespace)
+	require.NoError(t, err, "Error getting deployment")
+	require.Equal(t, expected, *deployment.Spec.Replicas, "Unexpected replicas for deployment")
+	return deployment
+}
+
+func createTestPod(t *testing.T, name string, ownerKind string) {
+	controller := true
+	_, err := core.Instance().CreatePod(&v1.Pod{
+		ObjectMeta: meta.ObjectMeta{
+			Name:      name,
+			Namespace: testNamespace,
+			OwnerReferences: []meta.OwnerReference{
+				{Kind: ownerKind, Name: name + "-owner", Controller: &controller},
+			},
+		},
+		Status: v1.PodStatus{Phase: v1.PodRunning},
+	})
+	require.NoError(t, err, "Error creating pod")
+}
+
+func requireStage(t *testing.T, ac *ActionController, action *storkv1.Action, stage storkv1.ActionStageType) {
+	updated := &storkv1.Action{}
+	require.NoError(t, ac.client.Get(context.TODO(), runtimeclient.ObjectKeyFromObject(action), updated))
+	require.Equal(t, stage, getCurrentStage(updated).Stage, "Unexpected stage")
+}
+
+func TestSwitchoverScaleDownSource(t *testing.T) {
+	setupTestClients(t)
+	schedule := createTestMigrationSchedule(t, false)
+	createTestDeployment(t, 3, nil)
+	replicas := int32(2)
+	_, err := apps.Instance().CreateReplicaSet(&appsv1.ReplicaSet{
+		ObjectMeta: meta.ObjectMeta{Name: "rs", Namespace: testNamespace},
+		Spec:       appsv1.ReplicaSetSpec{Replicas: &replicas},
+	}, meta.CreateOptions{})
+	require.NoError(t, err, "Error creating replicaset")
+	createTestPod(t, "web-pod", "ReplicaSet")
+	createTestPod(t, "daemon-pod", "DaemonSet")
+
+	action := newTestSwitchover(storkv1.ActionStageScaleDownSource)
+	ac := newTestActionController(t, action)
+	require.NoError(t, ac.switchoverScaleDownSource(action, schedule))
+
+	schedule, err = storkops.Instance().GetMigrationSchedule("schedule", "admin")
+	require.NoError(t, err)
+	require.True(t, *schedule.Spec.Suspend, "MigrationSchedule should be suspended")
+	deployment := requireDeploymentReplicas(t, 0)
+	require.Equal(t, "3", deployment.Annotations[migration.StorkMigrationReplicasAnnotation], "Replicas not recorded for deployment")
+	rs, err := apps.Instance().GetReplicaSet("rs", testNamespace)
+	require.NoError(t, err)
+	require.Equal(t, int32(0), *rs.Spec.Replicas, "Replicaset not scaled down")
+	require.Equal(t, "2", rs.Annotations[migration.StorkMigrationReplicasAnnotation], "Replicas not recorded for replicaset")
+
+	// The final migration waits for the pods of the applications
+	requireStage(t, ac, action, storkv1.ActionStageScaleDownSource)
+	require.Equal(t, []string{testNamespace}, getCurrentStage(action).Resources)
+	require.NoError(t, ac.switchoverScaleDownSource(action, schedule))
+	requireStage(t, ac, action, storkv1.ActionStageScaleDownSource)
+
+	// The replicas recorded before scaling down are kept
+	require.NoError(t, core.Instance().DeletePod("web-pod", testNamespace, false))
+	require.NoError(t, ac.switchoverScaleDownSource(action, schedule))
+	requireStage(t, ac, action, storkv1.ActionStageFinalMigration)
+	deployment = requireDeploymentReplicas(t, 0)
+	require.Equal(t, "3", deployment.Annotations[migration.StorkMigrationReplicasAnnotation], "Recorded replicas changed")
+}
+
+func TestSwitchoverScaleDownSourceTimeout(t *testing.T) {
+	setupTestClients(t)
+	schedule := createTestMigrationSchedule(t, false)
+	createTestPod(t, "web-pod", "StatefulSet")
+
+	action := newTestSwitchover(storkv1.ActionStageScaleDownSource)
+	ac := newTestActionController(t, action)
+	require.NoError(t, ac.switchoverScaleDownSource(action, schedule))
+	requireStage(t, ac, action, storkv1.ActionStageScaleDownSource)
+
+	getCurrentStage(action).LastUpdateTimestamp = meta.NewTime(time.Now().Add(-podTerminationTimeout - time.Minute))
+	require.NoError(t, ac.switchoverScaleDownSource(action, schedule))
+	requireStage(t, ac, action, storkv1.ActionStageRollback)
+	require.Equal(t, storkv1.ActionStatusFailed, action.Stages[0].Status, "ScaleDownSource should have failed")
+}
+
+func TestSwitchoverFinalMigration(t *testing.T) {
+	setupTestClients(t)
+	schedule := createTestMigrationSchedule(t, true)
+	schedule.Status.Items = map[storkv1.SchedulePolicyType][]*storkv1.ScheduledMigrationStatus{
+		storkv1.SchedulePolicyTypeInterval: {
+			{Name: "scheduled", Status: storkv1.MigrationStatusInProgress},
+		},
+	}
+
+	action := newTestSwitchover(storkv1.ActionStageScaleDownSource, storkv1.ActionStageFinalMigration)
+	ac := newTestActionController(t, action)
+	require.NoError(t, ac.switchoverFinalMigration(action, schedule))
+	require.Empty(t, getCurrentStage(action).Resources, "Final migration shouldn't start while a scheduled migration is running")
+
+	schedule.Status.Items[storkv1.SchedulePolicyTypeInterval][0].Status = storkv1.MigrationStatusSuccessful
+	require.NoError(t, ac.switchoverFinalMigration(action, schedule))
+	require.Equal(t, []string{"schedule-switchover"}, getCurrentStage(action).Resources)
+	finalMigration, err := storkops.Instance().GetMigration("schedule-switchover", "admin")
+	require.NoError(t, err, "Final migration not created")
+	require.False(t, *finalMigration.Spec.StartApplications, "Final migration shouldn't start applications")
+	require.Equal(t, []string{testNamespace}, finalMigration.Spec.Namespaces)
+
+	require.NoError(t, ac.switchoverFinalMigration(action, schedule))
+	requireStage(t, ac, action, storkv1.ActionStageFinalMigration)
+
+	finalMigration.Status.Status = storkv1.MigrationStatusSuccessful
+	_, err = storkops.Instance().UpdateMigration(finalMigration)
+	require.NoError(t, err)
+	require.NoError(t, ac.switchoverFinalMigration(action, schedule))
+	requireStage(t, ac, action, storkv1.ActionStageActivateDestination)
+}
+
+func TestSwitchoverFinalMigrationFailed(t *testing.T) {
+	setupTestClients(t)
+	schedule := createTestMigrationSchedule(t, true)
+	action := newTestSwitchover(storkv1.ActionStageScaleDownSource, storkv1.ActionStageFinalMigration)
+	ac := newTestActionController(t, action)
+	require.NoError(t, ac.switchoverFinalMigration(action, schedule))
+
+	finalMigration, err := storkops.Instance().GetMigration("schedule-switchover", "admin")
+	require.NoError(t, err, "Final migration not created")
+	finalMigration.Status.Status = storkv1.MigrationStatusPartialSuccess
+	_, err = storkops.Instance().UpdateMigration(finalMigration)
+	require.NoError(t, err)
+	require.NoError(t, ac.switchoverFinalMigration(action, schedule))
+	requireStage(t, ac, action, storkv1.ActionStageRollback)
+}
+
+func TestSwitchoverActivateDestination(t *testing.T) {
+	setupTestClients(t)
+	remoteOps := storkops.New(fake.NewSimpleClientset(), fakeclient.NewSimpleClientset(), nil)
+	schedule := createTestMigrationSchedule(t, true)
+	action := newTestSwitchover(storkv1.ActionStageScaleDownSource, storkv1.ActionStageFinalMigration,
+		storkv1.ActionStageActivateDestination)
+	ac := newTestActionController(t, action)
+
+	require.NoError(t, ac.switchoverActivateDestination(action, schedule, remoteOps))
+	require.Equal(t, []string{testNamespace + "/switchover"}, getCurrentStage(action).Resources)
+	failover, err := remoteOps.GetAction("switchover", testNamespace)
+	require.NoError(t, err, "Failover not created on the destination cluster")
+	require.Equal(t, storkv1.ActionTypeFailover, failover.Spec.ActionType)
+
+	failover.Status = storkv1.ActionStatusSuccessful
+	_, err = remoteOps.UpdateAction(failover)
+	require.NoError(t, err)
+	require.NoError(t, ac.switchoverActivateDestination(action, schedule, remoteOps))
+	require.Equal(t, storkv1.ActionStatusSuccessful, action.Status, "Switchover should have succeeded")
+}
+
+func TestSwitchoverRollback(t *testing.T) {
+	setupTestClients(t)
+	remoteOps := storkops.New(fake.NewSimpleClientset(), fakeclient.NewSimpleClientset(), nil)
+	schedule := createTestMigrationSchedule(t, true)
+	createTestDeployment(t, 0, map[string]string{migration.StorkMigrationReplicasAnnotation: "3"})
+
+	action := newTestSwitchover(storkv1.ActionStageScaleDownSource, storkv1.ActionStageFinalMigration,
+		storkv1.ActionStageActivateDestination, storkv1.ActionStageRollback)
+	action.Stages[2].Status = storkv1.ActionStatusFailed
+	// The failover for the second namespace was never started
+	action.Stages[2].Resources = []string{testNamespace + "/switchover", "ns2/switchover"}
+	ac := newTestActionController(t, action)
+	failover, err := remoteOps.CreateAction(&storkv1.Action{
+		ObjectMeta: meta.ObjectMeta{Name: "switchover", Namespace: testNamespace},
+		Spec:       storkv1.ActionSpec{ActionType: storkv1.ActionTypeFailover},
+		Status:     storkv1.ActionStatusInProgress,
+	})
+	require.NoError(t, err)
+
+	// The applications on the destination cluster are only stopped once the
+	// failover has finished
+	require.NoError(t, ac.switchoverRollback(action, schedule, remoteOps))
+	_, err = remoteOps.GetAction("switchover"+deactivateActionSuffix, testNamespace)
+	require.Error(t, err, "Deactivate shouldn't be started while the failover is running")
+
+	failover.Status = storkv1.ActionStatusSuccessful
+	_, err = remoteOps.UpdateAction(failover)
+	require.NoError(t, err)
+	require.NoError(t, ac.switchoverRollback(action, schedule, remoteOps))
+	deactivate, err := remoteOps.GetAction("switchover"+deactivateActionSuffix, testNamespace)
+	require.NoError(t, err, "Deactivate not started on the destination cluster")
+	require.Equal(t, storkv1.ActionTypeDeactivate, deactivate.Spec.ActionType)
+	_, err = remoteOps.GetAction("switchover"+deactivateActionSuffix, "ns2")
+	require.Error(t, err, "Deactivate shouldn't be started for namespace that wasn't activated")
+
+	// The source applications are started once the destination ones were
+	// stopped
+	requireDeploymentReplicas(t, 0)
+	require.Equal(t, storkv1.ActionStatusInProgress, action.Status)
+	deactivate.Status = storkv1.ActionStatusSuccessful
+	_, err = remoteOps.UpdateAction(deactivate)
+	require.NoError(t, err)
+	require.NoError(t, ac.switchoverRollback(action, schedule, remoteOps))
+	requireDeploymentReplicas(t, 3)
+	schedule, err = storkops.Instance().GetMigrationSchedule("schedule", "admin")
+	require.NoError(t, err)
+	require.False(t, *schedule.Spec.Suspend, "MigrationSchedule should be resumed")
+	require.Equal(t, storkv1.ActionStatusFailed, action.Status, "Switchover should have failed")
+	require.Equal(t, storkv1.ActionStatusSuccessful, getCurrentStage(action).Status, "Rollback should have succeeded")
+}
+
+func TestDeactivateAction(t *testing.T) {
+	setupTestClients(t)
+	createTestDeployment(t, 3, map[string]string{migration.StorkMigrationReplicasAnnotation: "3"})
+	action := &storkv1.Action{
+		ObjectMeta: meta.ObjectMeta{Name: "deactivate", Namespace: testNamespace},
+		Spec:       storkv1.ActionSpec{ActionType: storkv1.ActionTypeDeactivate},
+		Status:     storkv1.ActionStatusScheduled,
+	}
+	ac := newTestActionController(t, action)
+	require.NoError(t, ac.handle(context.TODO(), action))
+	requireDeploymentReplicas(t, 0)
+	require.Equal(t, storkv1.ActionStatusSuccessful, action.Status)
+}
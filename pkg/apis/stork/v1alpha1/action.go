@@ -30,11 +30,21 @@ type Action struct {
 	meta.ObjectMeta `json:"metadata,omitempty"`
 	Spec            ActionSpec   `json:"spec"`
 	Status          ActionStatus `json:"status"`
+	// Stages records the progress of each stage of the actions that are
+	// performed in multiple steps, so that they can be resumed or rolled back
+	Stages []*ActionStageInfo `json:"stages,omitempty"`
 }
 
 // ActionSpec specifies the type of Action
 type ActionSpec struct {
 	ActionType ActionType `json:"actionType"`
+	// MigrationScheduleName is the MigrationSchedule in the namespace of the
+	// Action used to migrate the applications for a switchover or a failback
+	MigrationScheduleName string `json:"migrationScheduleName,omitempty"`
+	// ReverseClusterPair is the ClusterPair on the destination cluster of
+	// the MigrationSchedule used to migrate the applications back for a
+	// failback. Defaults to the ClusterPair of the MigrationSchedule.
+	ReverseClusterPair string `json:"reverseClusterPair,omitempty"`
 }
 
 // ActionType lists the various actions that can be performed
@@ -43,8 +53,54 @@ type ActionType string
 const (
 	// to start apps on destination cluster
 	ActionTypeFailover ActionType = "failover"
+	// to stop apps on the source cluster, migrate them one last time and
+	// start them on the destination cluster
+	ActionTypeSwitchover ActionType = "switchover"
+	// to migrate apps back from the destination cluster after a failover
+	// and switch them over to the source cluster
+	ActionTypeFailback ActionType = "failback"
+	// to stop apps on the destination cluster when a switchover is rolled
+	// back after they were started
+	ActionTypeDeactivate ActionType = "deactivate"
 )
 
+// ActionStageType is the stage of an Action performed in multiple steps
+type ActionStageType string
+
+const (
+	// ActionStageScaleDownSource suspends the MigrationSchedule, scales
+	// down the applications on the source cluster and waits for their pods
+	// to terminate
+	ActionStageScaleDownSource ActionStageType = "ScaleDownSource"
+	// ActionStageFinalMigration runs one last migration of the applications
+	ActionStageFinalMigration ActionStageType = "FinalMigration"
+	// ActionStageActivateDestination starts the applications on the
+	// destination cluster
+	ActionStageActivateDestination ActionStageType = "ActivateDestination"
+	// ActionStageReverseSchedule creates a MigrationSchedule on the
+	// destination cluster to migrate the applications back
+	ActionStageReverseSchedule ActionStageType = "ReverseSchedule"
+	// ActionStageReverseMigration waits for the applications to be migrated
+	// back by the reverse MigrationSchedule
+	ActionStageReverseMigration ActionStageType = "ReverseMigration"
+	// ActionStageSwitchover switches the applications over from the
+	// destination cluster and resumes the MigrationSchedule
+	ActionStageSwitchover ActionStageType = "Switchover"
+	// ActionStageRollback undoes the completed stages after a stage failed
+	ActionStageRollback ActionStageType = "Rollback"
+)
+
+// ActionStageInfo is the status of a stage of an Action
+type ActionStageInfo struct {
+	Stage  ActionStageType `json:"stage"`
+	Status ActionStatus    `json:"status"`
+	Reason string          `json:"reason"`
+	// Resources are the names of the objects created for the stage, like
+	// the final migration or the failovers on the destination cluster
+	Resources           []string  `json:"resources,omitempty"`
+	LastUpdateTimestamp meta.Time `json:"lastUpdateTimestamp"`
+}
+
 // ActionStatus is the current status of the Action
 type ActionStatus string
 
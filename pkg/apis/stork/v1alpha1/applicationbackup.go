@@ -43,6 +43,10 @@ type ApplicationBackupSpec struct {
 	IncludeResources []ObjectInfo      `json:"includeResources"`
 	ResourceTypes    []string          `json:"resourceTypes"`
 	BackupType       string            `json:"backupType"`
+	// ReplicaBackupLocation is the name of the backup location that the
+	// backup is copied to once completed. Overrides the replica backup
+	// location of the backup location.
+	ReplicaBackupLocation string `json:"replicaBackupLocation,omitempty"`
 }
 
 // ApplicationBackupReclaimPolicyType is the reclaim policy for the application backup
@@ -71,6 +75,47 @@ type ApplicationBackupStatus struct {
 	TotalSize            uint64                           `json:"totalSize"`
 	ResourceCount        int                              `json:"resourceCount"`
 	LargeResourceEnabled bool                             `json:"largeResourceEnabled"`
+	// Compression is the codec used to compress the resources of the backup
+	Compression BackupCompressionType `json:"compression"`
+	// Integrity is the result of verifying the objects of the backup against
+	// its manifest when it was synced from the backup location
+	Integrity ApplicationBackupIntegrityType `json:"integrity"`
+	// ObjectLock is the retention set on the objects of the backup when it
+	// was uploaded to a backup location with object lock enabled. The backup
+	// can't be deleted from the backup location before RetainUntil.
+	ObjectLock *ApplicationBackupObjectLock `json:"objectLock,omitempty"`
+	// Replica is the status of the copy of the backup to its replica backup
+	// location
+	Replica *ApplicationBackupReplicaStatus `json:"replica,omitempty"`
+}
+
+// ApplicationBackupReplicaStatus is the status of the copy of a backup to a
+// replica backup location
+type ApplicationBackupReplicaStatus struct {
+	BackupLocation      string                      `json:"backupLocation"`
+	Status              ApplicationBackupStatusType `json:"status"`
+	Reason              string                      `json:"reason"`
+	LastUpdateTimestamp metav1.Time                 `json:"lastUpdateTimestamp"`
+	// Volumes is the status of the copy of the data of each volume
+	Volumes []*ApplicationBackupReplicaVolumeInfo `json:"volumes,omitempty"`
+}
+
+// ApplicationBackupReplicaVolumeInfo is the status of the copy of the data of
+// a volume to the replica backup location
+type ApplicationBackupReplicaVolumeInfo struct {
+	PersistentVolumeClaim string                      `json:"persistentVolumeClaim"`
+	Namespace             string                      `json:"namespace"`
+	Volume                string                      `json:"volume"`
+	DriverName            string                      `json:"driverName"`
+	Status                ApplicationBackupStatusType `json:"status"`
+	Reason                string                      `json:"reason"`
+}
+
+// ApplicationBackupObjectLock is the object lock retention of a backup
+type ApplicationBackupObjectLock struct {
+	// Mode is the object lock mode, COMPLIANCE or GOVERNANCE
+	Mode        string      `json:"mode"`
+	RetainUntil metav1.Time `json:"retainUntil"`
 }
 
 // ObjectInfo contains info about an object being backed up or restored
@@ -104,6 +149,24 @@ type ApplicationBackupVolumeInfo struct {
 	VolumeSnapshot           string                      `json:"volumeSnapshot"`
 }
 
+// ApplicationBackupIntegrityType is the result of verifying the objects of a
+// backup against its manifest
+type ApplicationBackupIntegrityType string
+
+const (
+	// ApplicationBackupIntegrityUnknown for when the backup hasn't been verified
+	ApplicationBackupIntegrityUnknown ApplicationBackupIntegrityType = ""
+	// ApplicationBackupIntegrityVerified for when all the objects of the
+	// backup match the manifest
+	ApplicationBackupIntegrityVerified ApplicationBackupIntegrityType = "Verified"
+	// ApplicationBackupIntegrityUnverified for when the backup doesn't have a
+	// manifest, for example because it was taken by an older version
+	ApplicationBackupIntegrityUnverified ApplicationBackupIntegrityType = "Unverified"
+	// ApplicationBackupIntegrityCorrupt for when objects of the backup are
+	// missing or don't match the manifest
+	ApplicationBackupIntegrityCorrupt ApplicationBackupIntegrityType = "Corrupt"
+)
+
 // ApplicationBackupStatusType is the status of the application backup
 type ApplicationBackupStatusType string
 
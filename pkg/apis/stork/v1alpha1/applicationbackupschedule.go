@@ -18,6 +18,9 @@ type ApplicationBackupScheduleSpec struct {
 	Suspend            *bool                         `json:"suspend"`
 	ReclaimPolicy      ReclaimPolicyType             `json:"reclaimPolicy"`
 	BackupType         string                        `json:"backupType"`
+	// ReplicaBackupLocation is the name of the backup location that the
+	// backups created by the schedule are copied to once completed
+	ReplicaBackupLocation string `json:"replicaBackupLocation,omitempty"`
 }
 
 // ApplicationBackupTemplateSpec describes the data a ApplicationBackup should have when created
@@ -38,6 +41,9 @@ type ScheduledApplicationBackupStatus struct {
 	CreationTimestamp meta.Time                   `json:"creationTimestamp"`
 	FinishTimestamp   meta.Time                   `json:"finishTimestamp"`
 	Status            ApplicationBackupStatusType `json:"status"`
+	// RetainedByObjectLock is set once the backup couldn't be pruned because
+	// its objects are under object lock retention
+	RetainedByObjectLock bool `json:"retainedByObjectLock,omitempty"`
 }
 
 // +genclient
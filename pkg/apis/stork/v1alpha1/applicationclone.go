@@ -38,6 +38,10 @@ type ApplicationCloneSpec struct {
 	// ReplacePolicy to decide how to react when a object conflict occurs in the cloning process
 	ReplacePolicy                ApplicationCloneReplacePolicyType `json:"replacePolicy"`
 	IncludeOptionalResourceTypes []string                          `json:"includeOptionalResourceTypes"`
+	// TransformSpecs are the ResourceTransformations in the source namespace
+	// to apply to the resources before they are cloned. Only one is
+	// supported.
+	TransformSpecs []string `json:"transformSpecs,omitempty"`
 }
 
 // ApplicationCloneStatus defines the status of the clone
@@ -57,6 +61,8 @@ type ApplicationCloneResourceInfo struct {
 	Reason                string                     `json:"reason"`
 	Status                ApplicationCloneStatusType `json:"status"`
 	meta.GroupVersionKind `json:",inline"`
+	// TransformedBy is the ResourceTransformation applied to the resource
+	TransformedBy string `json:"transformedBy,omitempty"`
 }
 
 // ApplicationCloneVolumeInfo is the info for the cloning of a volume
@@ -32,6 +32,48 @@ type ApplicationRestoreSpec struct {
 	IncludeResources             []ObjectInfo                        `json:"includeResources"`
 	StorageClassMapping          map[string]string                   `json:"storageClassMapping"`
 	RancherProjectMapping        map[string]string                   `json:"rancherProjectMapping"`
+	// DryRun only reports what the restore would do with each resource and
+	// volume in the status, nothing is changed on the cluster
+	DryRun bool `json:"dryRun,omitempty"`
+	// Namespaces is the subset of the namespaces in the backup to restore,
+	// all the namespaces in the NamespaceMapping are restored if empty
+	Namespaces []string `json:"namespaces,omitempty"`
+	// Selectors only restores the resources that have all these labels
+	Selectors map[string]string `json:"selectors,omitempty"`
+	// ExcludeSelectors skips the resources that have any of these labels
+	ExcludeSelectors map[string]string `json:"excludeSelectors,omitempty"`
+	// ResourceTypes only restores the resources of these kinds
+	ResourceTypes []string `json:"resourceTypes,omitempty"`
+	// ExcludeResourceTypes skips the resources of these kinds
+	ExcludeResourceTypes []string `json:"excludeResourceTypes,omitempty"`
+	// PreExecRule is run on the pods in the restored namespaces once the
+	// resources have been applied and the selected pods are ready. Its
+	// background actions keep running until the restore completes
+	PreExecRule string `json:"preExecRule,omitempty"`
+	// PostExecRule is run on the pods in the restored namespaces after the
+	// PreExecRule, once the selected pods are ready
+	PostExecRule string `json:"postExecRule,omitempty"`
+	// TransformSpecs are the ResourceTransformations in the namespace of
+	// the restore to apply to the resources before they are restored. Only
+	// one is supported.
+	TransformSpecs []string `json:"transformSpecs,omitempty"`
+	// BackupScheduleName is the ApplicationBackupSchedule to restore the
+	// latest successful backup from when BackupName isn't set. The backup
+	// picked is recorded in Status.BackupName. Not supported for backups in
+	// NFS backup locations.
+	BackupScheduleName string `json:"backupScheduleName,omitempty"`
+	// PointInTime restores the latest successful backup from the
+	// BackupScheduleName that was triggered at or before this time
+	PointInTime *metav1.Time `json:"pointInTime,omitempty"`
+}
+
+// GetBackupName returns the name of the backup being restored, either the
+// BackupName from the spec or the backup resolved from the BackupScheduleName
+func (r *ApplicationRestore) GetBackupName() string {
+	if r.Status.BackupName != "" {
+		return r.Status.BackupName
+	}
+	return r.Spec.BackupName
 }
 
 // ApplicationRestoreReplacePolicyType is the replace policy for the application restore
@@ -47,6 +89,30 @@ const (
 	// should retain existing resources that conflict with resources being
 	// restored
 	ApplicationRestoreReplacePolicyRetain ApplicationRestoreReplacePolicyType = "Retain"
+	// ApplicationRestoreReplacePolicyMerge is to specify that the restore
+	// should merge resources being restored into existing resources with a
+	// server-side apply. Existing volumes are retained. Not supported for
+	// backups in NFS backup locations.
+	ApplicationRestoreReplacePolicyMerge ApplicationRestoreReplacePolicyType = "Merge"
+)
+
+// ApplicationRestoreDryRunActionType is what a restore would do with a resource
+// or volume
+type ApplicationRestoreDryRunActionType string
+
+const (
+	// ApplicationRestoreDryRunActionCreate for resources that don't exist
+	// and would be created
+	ApplicationRestoreDryRunActionCreate ApplicationRestoreDryRunActionType = "Create"
+	// ApplicationRestoreDryRunActionReplace for existing resources that
+	// would be replaced or merged
+	ApplicationRestoreDryRunActionReplace ApplicationRestoreDryRunActionType = "Replace"
+	// ApplicationRestoreDryRunActionSkip for resources that wouldn't be
+	// restored
+	ApplicationRestoreDryRunActionSkip ApplicationRestoreDryRunActionType = "Skip"
+	// ApplicationRestoreDryRunActionConflict for existing resources that
+	// differ from the backup and would be retained
+	ApplicationRestoreDryRunActionConflict ApplicationRestoreDryRunActionType = "Conflict"
 )
 
 type ApplicationRestoreResourceStateType string
@@ -72,6 +138,12 @@ type ApplicationRestoreStatus struct {
 	LargeResourceEnabled  bool                                `json:"largeResourceEnabled"`
 	RestoredResourceCount int                                 `json:"restoredresourceCount"`
 	ResourceRestoreState  ApplicationRestoreResourceStateType `json:"resourcerestorestate"`
+	// BackupIntegrity is the result of verifying the backup objects before
+	// restoring the resources
+	BackupIntegrity ApplicationBackupIntegrityType `json:"backupIntegrity"`
+	// BackupName is the backup that was restored when the restore
+	// references a BackupScheduleName
+	BackupName string `json:"backupName,omitempty"`
 }
 
 // ApplicationRestoreResourceInfo is the info for the restore of a resource
@@ -79,6 +151,14 @@ type ApplicationRestoreResourceInfo struct {
 	ObjectInfo `json:',inline"`
 	Status     ApplicationRestoreStatusType `json:"status"`
 	Reason     string                       `json:"reason"`
+	// DryRunAction is what the restore would do with the resource, only set
+	// for dry runs
+	DryRunAction ApplicationRestoreDryRunActionType `json:"dryRunAction,omitempty"`
+	// TransformedBy is the ResourceTransformation applied to the resource
+	TransformedBy string `json:"transformedBy,omitempty"`
+	// Conflicts are the fields of the existing resource managed by others
+	// that were overwritten when the resource was merged
+	Conflicts []string `json:"conflicts,omitempty"`
 }
 
 // ApplicationRestoreVolumeInfo is the info for the restore of a volume
@@ -94,6 +174,9 @@ type ApplicationRestoreVolumeInfo struct {
 	Reason                   string                       `json:"reason"`
 	TotalSize                uint64                       `json:"totalSize"`
 	Options                  map[string]string            `json:"options"`
+	// DryRunAction is what the restore would do with the volume, only set
+	// for dry runs
+	DryRunAction ApplicationRestoreDryRunActionType `json:"dryRunAction,omitempty"`
 }
 
 // ApplicationRestoreStatusType is the status of the application restore
@@ -127,6 +210,12 @@ const (
 	// ApplicationRestoreStageApplications for when applications are being
 	// restored
 	ApplicationRestoreStageApplications ApplicationRestoreStageType = "Applications"
+	// ApplicationRestoreStagePreExecRule for when the PreExecRule is being
+	// executed
+	ApplicationRestoreStagePreExecRule ApplicationRestoreStageType = "PreExecRule"
+	// ApplicationRestoreStagePostExecRule for when the PostExecRule is being
+	// executed
+	ApplicationRestoreStagePostExecRule ApplicationRestoreStageType = "PostExecRule"
 	// ApplicationRestoreStageFinal is the final stage for restore
 	ApplicationRestoreStageFinal ApplicationRestoreStageType = "Final"
 )
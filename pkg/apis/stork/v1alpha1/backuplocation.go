@@ -15,6 +15,8 @@ const (
 	BackupLocationResourceName = "backuplocation"
 	// BackupLocationResourcePlural is plural for "backuplocation" resource
 	BackupLocationResourcePlural = "backuplocations"
+
+	encryptionKeyringSecretPrefix = "encryptionKey."
 )
 
 // +genclient
@@ -24,10 +26,37 @@ const (
 type BackupLocation struct {
 	metav1.TypeMeta   `json:",inline"`
 	metav1.ObjectMeta `json:"metadata,omitempty"`
-	Location          BackupLocationItem `json:"location"`
-	Cluster           ClusterItem        `json:"cluster"`
+	Location          BackupLocationItem   `json:"location"`
+	Cluster           ClusterItem          `json:"cluster"`
+	Status            BackupLocationStatus `json:"status,omitempty"`
+}
+
+// BackupLocationStatus is the result of the last validation of a backup
+// location
+type BackupLocationStatus struct {
+	// LastChecked is the time the backup location was last validated
+	LastChecked metav1.Time `json:"lastChecked,omitempty"`
+	Conditions  []Condition `json:"conditions,omitempty"`
 }
 
+const (
+	// BackupLocationConditionCredentials is true if the credentials of the
+	// backup location could be resolved and were accepted by the objectstore
+	BackupLocationConditionCredentials ConditionType = "CredentialsValid"
+	// BackupLocationConditionBucket is true if the bucket exists and can be
+	// listed
+	BackupLocationConditionBucket ConditionType = "BucketAvailable"
+	// BackupLocationConditionReadWrite is true if a probe object could be
+	// written, read back and deleted
+	BackupLocationConditionReadWrite ConditionType = "ReadWritable"
+	// BackupLocationConditionEncryptionKey is true if the encryption keys of
+	// the backup location can be used to encrypt backups
+	BackupLocationConditionEncryptionKey ConditionType = "EncryptionKeyValid"
+	// BackupLocationConditionObjectLock is true if the object lock settings
+	// of the bucket can be used for backups
+	BackupLocationConditionObjectLock ConditionType = "ObjectLockValid"
+)
+
 // BackupLocationItem is the spec used to store a backup location
 // Only one of S3Config, AzureConfig or GoogleConfig should be specified and
 // should match the Type field. Members of the config can be specified inline or
@@ -47,6 +76,25 @@ type BackupLocationItem struct {
 	RepositoryPassword string        `json:"repositoryPassword"`
 	// EncryptionV2Key will be used to pass encryption key.
 	EncryptionV2Key string `json:"encryptionV2Key"`
+	// Compression is used to compress the resources uploaded for a backup.
+	// Defaults to no compression.
+	Compression BackupCompressionType `json:"compression"`
+	// EncryptionKeys is a keyring of encryption keys. The ID of the key is
+	// recorded in the objects encrypted with it so that they can still be
+	// decrypted after the active key is changed.
+	EncryptionKeys []BackupEncryptionKey `json:"encryptionKeys,omitempty"`
+	// ActiveEncryptionKeyID is the ID of the key from EncryptionKeys used to
+	// encrypt new objects. EncryptionV2Key is used if it isn't set.
+	ActiveEncryptionKeyID string `json:"activeEncryptionKeyID,omitempty"`
+	// ReplicaBackupLocation is the name of a backup location in the same
+	// namespace that completed backups are copied to
+	ReplicaBackupLocation string `json:"replicaBackupLocation,omitempty"`
+}
+
+// BackupEncryptionKey is a key in the encryption keyring of a backup location
+type BackupEncryptionKey struct {
+	ID  string `json:"id"`
+	Key string `json:"key"`
 }
 
 // ClusterItem is the spec used to store a the credentials associated with the cluster
@@ -76,6 +124,21 @@ const (
 	BackupLocationGoogle BackupLocationType = "google"
 	// BackupLocationNFS stores the backup in NFS backed Storage
 	BackupLocationNFS BackupLocationType = "nfs"
+	// BackupLocationLocal stores the backup in a directory mounted in the
+	// stork pod, for example from a hostPath or a PVC
+	BackupLocationLocal BackupLocationType = "local"
+)
+
+// BackupCompressionType is the codec used to compress backup objects
+type BackupCompressionType string
+
+const (
+	// BackupCompressionNone stores backup objects uncompressed
+	BackupCompressionNone BackupCompressionType = ""
+	// BackupCompressionGzip compresses backup objects with gzip
+	BackupCompressionGzip BackupCompressionType = "gzip"
+	// BackupCompressionZstd compresses backup objects with zstd
+	BackupCompressionZstd BackupCompressionType = "zstd"
 )
 
 // ClusterType is the type of the cluster
@@ -159,6 +222,16 @@ func (bl *BackupLocation) UpdateFromSecret(client kubernetes.Interface) error {
 		if val, ok := secretConfig.Data["path"]; ok && val != nil {
 			bl.Location.Path = strings.TrimSuffix(string(val), "\n")
 		}
+		if val, ok := secretConfig.Data["activeEncryptionKeyID"]; ok && val != nil {
+			bl.Location.ActiveEncryptionKeyID = strings.TrimSuffix(string(val), "\n")
+		}
+		// Keys of the keyring are stored as encryptionKey.<id>
+		for name, val := range secretConfig.Data {
+			if !strings.HasPrefix(name, encryptionKeyringSecretPrefix) || val == nil {
+				continue
+			}
+			bl.setEncryptionKey(strings.TrimPrefix(name, encryptionKeyringSecretPrefix), strings.TrimSuffix(string(val), "\n"))
+		}
 	}
 	switch bl.Location.Type {
 	case BackupLocationS3:
@@ -169,12 +242,48 @@ func (bl *BackupLocation) UpdateFromSecret(client kubernetes.Interface) error {
 		return bl.getMergedGoogleConfig(client)
 	case BackupLocationNFS:
 		return bl.getMergedNFSConfig(client)
+	case BackupLocationLocal:
+		return nil
 	default:
 		return fmt.Errorf("Invalid BackupLocation type %v", bl.Location.Type)
 	}
 
 }
 
+func (bl *BackupLocation) setEncryptionKey(id string, key string) {
+	for i := range bl.Location.EncryptionKeys {
+		if bl.Location.EncryptionKeys[i].ID == id {
+			bl.Location.EncryptionKeys[i].Key = key
+			return
+		}
+	}
+	bl.Location.EncryptionKeys = append(bl.Location.EncryptionKeys, BackupEncryptionKey{ID: id, Key: key})
+}
+
+// GetActiveEncryptionKey returns the ID and the key that should be used to
+// encrypt new objects. The ID is empty if EncryptionV2Key should be used.
+func (bl *BackupLocation) GetActiveEncryptionKey() (string, string, error) {
+	if bl.Location.ActiveEncryptionKeyID == "" {
+		return "", bl.Location.EncryptionV2Key, nil
+	}
+	for _, key := range bl.Location.EncryptionKeys {
+		if key.ID == bl.Location.ActiveEncryptionKeyID {
+			return key.ID, key.Key, nil
+		}
+	}
+	return "", "", fmt.Errorf("active encryption key %v not found in the keyring of backuplocation %v/%v",
+		bl.Location.ActiveEncryptionKeyID, bl.Namespace, bl.Name)
+}
+
+// GetEncryptionKeys returns the keys in the keyring indexed by their ID
+func (bl *BackupLocation) GetEncryptionKeys() map[string]string {
+	keys := make(map[string]string)
+	for _, key := range bl.Location.EncryptionKeys {
+		keys[key.ID] = key.Key
+	}
+	return keys
+}
+
 // UpdateFromClusterSecret updated the config information from the cluster secret if not provided inline
 func (bl *BackupLocation) UpdateFromClusterSecret(client kubernetes.Interface) error {
 	if bl.Cluster.SecretConfig != "" {
@@ -30,6 +30,27 @@ type ClusterPairSpec struct {
 	// PlatformOptions are kubernetes platform provider related
 	// options.
 	PlatformOptions PlatformSpec `json:"platformOptions",yaml:"platformOptions"`
+	// Limits on the migrations using the cluster pair
+	Limits *ClusterPairLimits `json:"limits,omitempty"`
+}
+
+// ClusterPairLimits limits the load put on the link to the remote cluster by
+// the migrations using the cluster pair
+type ClusterPairLimits struct {
+	// MaxConcurrentMigrations is the number of migrations using the cluster
+	// pair that can run at the same time. The others wait in the Pending
+	// stage in the order they were created. Not limited if not set.
+	MaxConcurrentMigrations int32 `json:"maxConcurrentMigrations,omitempty"`
+	// RemoteAPIQPS limits the requests sent to the remote cluster by all the
+	// migrations using the cluster pair. Not limited if not set.
+	RemoteAPIQPS int32 `json:"remoteAPIQPS,omitempty"`
+	// RemoteAPIBurst is the number of requests that can be sent above
+	// RemoteAPIQPS. Defaults to RemoteAPIQPS.
+	RemoteAPIBurst int32 `json:"remoteAPIBurst,omitempty"`
+	// VolumeBandwidthMbps limits the bandwidth used to migrate volumes to
+	// the remote storage if the storage driver supports it. Whether it was
+	// applied is reported by the VolumeBandwidthLimited condition.
+	VolumeBandwidthMbps uint64 `json:"volumeBandwidthMbps,omitempty"`
 }
 
 // ClusterPairStatusType is the status of the pair
@@ -64,8 +85,44 @@ type ClusterPairStatus struct {
 	// ID of the remote storage which is paired
 	// +optional
 	RemoteStorageID string `json:"remoteStorageId"`
+	// LastProbed is the time the health of the pair was last checked
+	// +optional
+	LastProbed meta.Time `json:"lastProbed,omitempty"`
+	// APILatency is the time taken by the API server of the remote cluster
+	// to respond to the last probe
+	// +optional
+	APILatency *meta.Duration `json:"apiLatency,omitempty"`
+	// CredentialsExpiry is the time the certificate or token used to access
+	// the remote cluster expires, if it is known
+	// +optional
+	CredentialsExpiry *meta.Time `json:"credentialsExpiry,omitempty"`
+	// Conditions are the results of the last probe of the pair
+	// +optional
+	Conditions []Condition `json:"conditions,omitempty"`
 }
 
+const (
+	// ClusterPairConditionAPIReachable is true if the API server of the
+	// remote cluster responded to the probe
+	ClusterPairConditionAPIReachable ConditionType = "APIReachable"
+	// ClusterPairConditionLatency is true if the API server of the remote
+	// cluster responded within the latency threshold
+	ClusterPairConditionLatency ConditionType = "LatencyAcceptable"
+	// ClusterPairConditionAuthorized is true if the credentials allow the
+	// operations stork performs on the remote cluster
+	ClusterPairConditionAuthorized ConditionType = "Authorized"
+	// ClusterPairConditionCredentials is true if the certificate or token
+	// used to access the remote cluster hasn't expired
+	ClusterPairConditionCredentials ConditionType = "CredentialsValid"
+	// ClusterPairConditionStoragePaired is true if the storage driver
+	// reports the pair with the remote storage
+	ClusterPairConditionStoragePaired ConditionType = "StoragePaired"
+	// ClusterPairConditionVolumeBandwidthLimited is true if the storage
+	// driver applied the volume bandwidth limit of the pair when its volumes
+	// were last migrated
+	ClusterPairConditionVolumeBandwidthLimited ConditionType = "VolumeBandwidthLimited"
+)
+
 // RancherSecret holds the reference to the api keys used to interact
 // with a rancher cluster
 type RancherSecret struct {
@@ -0,0 +1,22 @@
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// ConditionType is the type of a condition reported in the status of a
+// resource
+type ConditionType string
+
+// Condition is the state of one aspect of a resource that is checked
+// periodically
+type Condition struct {
+	Type   ConditionType          `json:"type"`
+	Status metav1.ConditionStatus `json:"status"`
+	// Reason is a one word CamelCase reason for the status
+	Reason string `json:"reason,omitempty"`
+	// Message is a human readable explanation of the status
+	Message string `json:"message,omitempty"`
+	// LastTransitionTime is the time the status last changed
+	LastTransitionTime metav1.Time `json:"lastTransitionTime,omitempty"`
+}
@@ -32,6 +32,31 @@ type MigrationSpec struct {
 	TransformSpecs               []string          `json:"transformSpecs"`
 	IgnoreOwnerReferencesCheck   *bool             `json:"ignoreOwnerReferencesCheck"`
 	ExcludeResourceTypes         []string          `json:"excludeResourceTypes"`
+	// Waves are migrated one after the other instead of migrating all the
+	// namespaces at once. Namespaces, NamespaceSelectors and the rules are
+	// specified for each wave instead of the migration.
+	Waves []MigrationWave `json:"waves,omitempty"`
+}
+
+// MigrationWave is a set of namespaces migrated together
+type MigrationWave struct {
+	// Name of the wave, it needs to be unique in the migration
+	Name               string            `json:"name"`
+	Namespaces         []string          `json:"namespaces"`
+	NamespaceSelectors map[string]string `json:"namespaceSelectors"`
+	PreExecRule        string            `json:"preExecRule"`
+	PostExecRule       string            `json:"postExecRule"`
+	// Readiness, if set, waits for the applications of the wave to be ready
+	// on the destination cluster before the next wave is started
+	Readiness *MigrationWaveReadiness `json:"readiness,omitempty"`
+}
+
+// MigrationWaveReadiness is the readiness gate of a wave on the destination
+// cluster
+type MigrationWaveReadiness struct {
+	// Timeout after which the wave fails if the applications aren't ready.
+	// Defaults to 10 minutes.
+	Timeout *meta.Duration `json:"timeout,omitempty"`
 }
 
 // MigrationStatus is the status of a migration operation
@@ -45,6 +70,81 @@ type MigrationStatus struct {
 	ResourceMigrationFinishTimestamp meta.Time                `json:"resourceMigrationFinishTimestamp"`
 	// Summary provides a short summary on the migration
 	Summary *MigrationSummary `json:"summary"`
+	// Drift is the last comparison of the resources and volumes of the
+	// migration with the destination cluster
+	Drift *MigrationDriftReport `json:"drift,omitempty"`
+	// Waves is the status of the waves of the migration
+	Waves []*MigrationWaveInfo `json:"waves,omitempty"`
+}
+
+// MigrationWaveInfo is the status of a wave of the migration
+type MigrationWaveInfo struct {
+	Name string `json:"name"`
+	// Migration created to migrate the wave
+	Migration       string              `json:"migration"`
+	Status          MigrationStatusType `json:"status"`
+	Reason          string              `json:"reason"`
+	StartTimestamp  meta.Time           `json:"startTimestamp"`
+	FinishTimestamp meta.Time           `json:"finishTimestamp"`
+}
+
+// MigrationDriftStatusType is the state of a resource or volume on the
+// destination cluster compared to the source cluster
+type MigrationDriftStatusType string
+
+const (
+	// MigrationDriftStatusInSync for when the destination matches the source
+	MigrationDriftStatusInSync MigrationDriftStatusType = "InSync"
+	// MigrationDriftStatusStale for when the source has changed since it was
+	// last migrated
+	MigrationDriftStatusStale MigrationDriftStatusType = "Stale"
+	// MigrationDriftStatusMissing for when the destination doesn't have the
+	// resource
+	MigrationDriftStatusMissing MigrationDriftStatusType = "Missing"
+	// MigrationDriftStatusExtra for when a migrated resource on the
+	// destination no longer exists on the source
+	MigrationDriftStatusExtra MigrationDriftStatusType = "Extra"
+)
+
+// MigrationDriftReport compares the resources and volumes selected by a
+// migration on the source cluster with the ones on the destination cluster
+type MigrationDriftReport struct {
+	// Timestamp is the time the comparison was made
+	Timestamp meta.Time `json:"timestamp"`
+	// Reason is set if the comparison couldn't be made
+	Reason    string                        `json:"reason,omitempty"`
+	Summary   *MigrationDriftSummary        `json:"summary,omitempty"`
+	Resources []*MigrationDriftResourceInfo `json:"resources,omitempty"`
+	Volumes   []*MigrationDriftVolumeInfo   `json:"volumes,omitempty"`
+}
+
+// MigrationDriftSummary is the number of resources in each drift state
+type MigrationDriftSummary struct {
+	InSync  uint64 `json:"inSync"`
+	Stale   uint64 `json:"stale"`
+	Missing uint64 `json:"missing"`
+	Extra   uint64 `json:"extra"`
+}
+
+// MigrationDriftResourceInfo is the drift of a resource
+type MigrationDriftResourceInfo struct {
+	Name                  string `json:"name"`
+	Namespace             string `json:"namespace"`
+	meta.GroupVersionKind `json:",inline"`
+	Status                MigrationDriftStatusType `json:"status"`
+	Reason                string                   `json:"reason"`
+}
+
+// MigrationDriftVolumeInfo is the drift of a volume
+type MigrationDriftVolumeInfo struct {
+	PersistentVolumeClaim string                   `json:"persistentVolumeClaim"`
+	Namespace             string                   `json:"namespace"`
+	Volume                string                   `json:"volume"`
+	Status                MigrationDriftStatusType `json:"status"`
+	// ReplicationLag is the time since the data of the volume was last
+	// migrated, if the storage driver reports it
+	ReplicationLag *meta.Duration `json:"replicationLag,omitempty"`
+	Reason         string         `json:"reason"`
 }
 
 // MigrationResourceInfo is the info for the migration of a resource
@@ -55,6 +155,10 @@ type MigrationResourceInfo struct {
 	Status                MigrationStatusType `json:"status"`
 	Reason                string              `json:"reason"`
 	TransformedBy         string              `json:"transformedBy"`
+	// Hash of the resource applied on the destination cluster. Resources
+	// with an unchanged hash aren't applied again when the migration is
+	// resumed.
+	Hash string `json:"hash,omitempty"`
 }
 
 // MigrationSummary provides a short summary on the migration
@@ -126,6 +230,9 @@ type MigrationStageType string
 const (
 	// MigrationStageInitial for when migration is created
 	MigrationStageInitial MigrationStageType = ""
+	// MigrationStagePending for when migration is waiting for other
+	// migrations using the same cluster pair to finish
+	MigrationStagePending MigrationStageType = "Pending"
 	// MigrationStagePreExecRule for when the PreExecRule is being executed
 	MigrationStagePreExecRule MigrationStageType = "PreExecRule"
 	// MigrationStagePostExecRule for when the PostExecRule is being executed
@@ -134,6 +241,9 @@ const (
 	MigrationStageVolumes MigrationStageType = "Volumes"
 	// MigrationStageApplications for when applications are being migrated
 	MigrationStageApplications MigrationStageType = "Applications"
+	// MigrationStageWaves for when the waves of the migration are being
+	// migrated
+	MigrationStageWaves MigrationStageType = "Waves"
 	// MigrationStageFinal is the final stage for migration
 	MigrationStageFinal MigrationStageType = "Final"
 )
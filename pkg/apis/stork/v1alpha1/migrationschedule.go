@@ -29,6 +29,9 @@ type MigrationTemplateSpec struct {
 type MigrationScheduleStatus struct {
 	Items                map[SchedulePolicyType][]*ScheduledMigrationStatus `json:"items"`
 	ApplicationActivated bool                                               `json:"applicationActivated"`
+	// Drift is the last comparison of the resources and volumes selected by
+	// the schedule with the destination cluster
+	Drift *MigrationDriftReport `json:"drift,omitempty"`
 }
 
 // ScheduledMigrationStatus keeps track of the migration that was triggered by a
@@ -23,7 +23,8 @@ package v1alpha1
 
 import (
 	crdv1 "github.com/kubernetes-incubator/external-storage/snapshot/pkg/apis/crd/v1"
-	v1 "k8s.io/api/core/v1"
+	corev1 "k8s.io/api/core/v1"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	runtime "k8s.io/apimachinery/pkg/runtime"
 )
 
@@ -33,6 +34,17 @@ func (in *Action) DeepCopyInto(out *Action) {
 	out.TypeMeta = in.TypeMeta
 	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
 	out.Spec = in.Spec
+	if in.Stages != nil {
+		in, out := &in.Stages, &out.Stages
+		*out = make([]*ActionStageInfo, len(*in))
+		for i := range *in {
+			if (*in)[i] != nil {
+				in, out := &(*in)[i], &(*out)[i]
+				*out = new(ActionStageInfo)
+				(*in).DeepCopyInto(*out)
+			}
+		}
+	}
 	return
 }
 
@@ -103,6 +115,28 @@ func (in *ActionSpec) DeepCopy() *ActionSpec {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ActionStageInfo) DeepCopyInto(out *ActionStageInfo) {
+	*out = *in
+	if in.Resources != nil {
+		in, out := &in.Resources, &out.Resources
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	in.LastUpdateTimestamp.DeepCopyInto(&out.LastUpdateTimestamp)
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ActionStageInfo.
+func (in *ActionStageInfo) DeepCopy() *ActionStageInfo {
+	if in == nil {
+		return nil
+	}
+	out := new(ActionStageInfo)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ApplicationBackup) DeepCopyInto(out *ApplicationBackup) {
 	*out = *in
@@ -164,6 +198,67 @@ func (in *ApplicationBackupList) DeepCopyObject() runtime.Object {
 	return nil
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ApplicationBackupObjectLock) DeepCopyInto(out *ApplicationBackupObjectLock) {
+	*out = *in
+	in.RetainUntil.DeepCopyInto(&out.RetainUntil)
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ApplicationBackupObjectLock.
+func (in *ApplicationBackupObjectLock) DeepCopy() *ApplicationBackupObjectLock {
+	if in == nil {
+		return nil
+	}
+	out := new(ApplicationBackupObjectLock)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ApplicationBackupReplicaStatus) DeepCopyInto(out *ApplicationBackupReplicaStatus) {
+	*out = *in
+	in.LastUpdateTimestamp.DeepCopyInto(&out.LastUpdateTimestamp)
+	if in.Volumes != nil {
+		in, out := &in.Volumes, &out.Volumes
+		*out = make([]*ApplicationBackupReplicaVolumeInfo, len(*in))
+		for i := range *in {
+			if (*in)[i] != nil {
+				in, out := &(*in)[i], &(*out)[i]
+				*out = new(ApplicationBackupReplicaVolumeInfo)
+				**out = **in
+			}
+		}
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ApplicationBackupReplicaStatus.
+func (in *ApplicationBackupReplicaStatus) DeepCopy() *ApplicationBackupReplicaStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(ApplicationBackupReplicaStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ApplicationBackupReplicaVolumeInfo) DeepCopyInto(out *ApplicationBackupReplicaVolumeInfo) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ApplicationBackupReplicaVolumeInfo.
+func (in *ApplicationBackupReplicaVolumeInfo) DeepCopy() *ApplicationBackupReplicaVolumeInfo {
+	if in == nil {
+		return nil
+	}
+	out := new(ApplicationBackupReplicaVolumeInfo)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ApplicationBackupResourceInfo) DeepCopyInto(out *ApplicationBackupResourceInfo) {
 	*out = *in
@@ -381,6 +476,16 @@ func (in *ApplicationBackupStatus) DeepCopyInto(out *ApplicationBackupStatus) {
 	in.TriggerTimestamp.DeepCopyInto(&out.TriggerTimestamp)
 	in.LastUpdateTimestamp.DeepCopyInto(&out.LastUpdateTimestamp)
 	in.FinishTimestamp.DeepCopyInto(&out.FinishTimestamp)
+	if in.ObjectLock != nil {
+		in, out := &in.ObjectLock, &out.ObjectLock
+		*out = new(ApplicationBackupObjectLock)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Replica != nil {
+		in, out := &in.Replica, &out.Replica
+		*out = new(ApplicationBackupReplicaStatus)
+		(*in).DeepCopyInto(*out)
+	}
 	return
 }
 
@@ -532,6 +637,11 @@ func (in *ApplicationCloneSpec) DeepCopyInto(out *ApplicationCloneSpec) {
 		*out = make([]string, len(*in))
 		copy(*out, *in)
 	}
+	if in.TransformSpecs != nil {
+		in, out := &in.TransformSpecs, &out.TransformSpecs
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
 	return
 }
 
@@ -755,6 +865,11 @@ func (in *ApplicationRestoreList) DeepCopyObject() runtime.Object {
 func (in *ApplicationRestoreResourceInfo) DeepCopyInto(out *ApplicationRestoreResourceInfo) {
 	*out = *in
 	out.ObjectInfo = in.ObjectInfo
+	if in.Conflicts != nil {
+		in, out := &in.Conflicts, &out.Conflicts
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
 	return
 }
 
@@ -802,6 +917,44 @@ func (in *ApplicationRestoreSpec) DeepCopyInto(out *ApplicationRestoreSpec) {
 			(*out)[key] = val
 		}
 	}
+	if in.Namespaces != nil {
+		in, out := &in.Namespaces, &out.Namespaces
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Selectors != nil {
+		in, out := &in.Selectors, &out.Selectors
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.ExcludeSelectors != nil {
+		in, out := &in.ExcludeSelectors, &out.ExcludeSelectors
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.ResourceTypes != nil {
+		in, out := &in.ResourceTypes, &out.ResourceTypes
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.ExcludeResourceTypes != nil {
+		in, out := &in.ExcludeResourceTypes, &out.ExcludeResourceTypes
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.TransformSpecs != nil {
+		in, out := &in.TransformSpecs, &out.TransformSpecs
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.PointInTime != nil {
+		in, out := &in.PointInTime, &out.PointInTime
+		*out = (*in).DeepCopy()
+	}
 	return
 }
 
@@ -825,7 +978,7 @@ func (in *ApplicationRestoreStatus) DeepCopyInto(out *ApplicationRestoreStatus)
 			if (*in)[i] != nil {
 				in, out := &(*in)[i], &(*out)[i]
 				*out = new(ApplicationRestoreResourceInfo)
-				**out = **in
+				(*in).DeepCopyInto(*out)
 			}
 		}
 	}
@@ -899,6 +1052,22 @@ func (in *AzureConfig) DeepCopy() *AzureConfig {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *BackupEncryptionKey) DeepCopyInto(out *BackupEncryptionKey) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new BackupEncryptionKey.
+func (in *BackupEncryptionKey) DeepCopy() *BackupEncryptionKey {
+	if in == nil {
+		return nil
+	}
+	out := new(BackupEncryptionKey)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *BackupLocation) DeepCopyInto(out *BackupLocation) {
 	*out = *in
@@ -906,6 +1075,7 @@ func (in *BackupLocation) DeepCopyInto(out *BackupLocation) {
 	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
 	in.Location.DeepCopyInto(&out.Location)
 	in.Cluster.DeepCopyInto(&out.Cluster)
+	in.Status.DeepCopyInto(&out.Status)
 	return
 }
 
@@ -950,6 +1120,11 @@ func (in *BackupLocationItem) DeepCopyInto(out *BackupLocationItem) {
 		*out = new(NFSConfig)
 		**out = **in
 	}
+	if in.EncryptionKeys != nil {
+		in, out := &in.EncryptionKeys, &out.EncryptionKeys
+		*out = make([]BackupEncryptionKey, len(*in))
+		copy(*out, *in)
+	}
 	return
 }
 
@@ -996,6 +1171,30 @@ func (in *BackupLocationList) DeepCopyObject() runtime.Object {
 	return nil
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *BackupLocationStatus) DeepCopyInto(out *BackupLocationStatus) {
+	*out = *in
+	in.LastChecked.DeepCopyInto(&out.LastChecked)
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]Condition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new BackupLocationStatus.
+func (in *BackupLocationStatus) DeepCopy() *BackupLocationStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(BackupLocationStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ClusterDomainInfo) DeepCopyInto(out *ClusterDomainInfo) {
 	*out = *in
@@ -1223,7 +1422,7 @@ func (in *ClusterPair) DeepCopyInto(out *ClusterPair) {
 	out.TypeMeta = in.TypeMeta
 	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
 	in.Spec.DeepCopyInto(&out.Spec)
-	out.Status = in.Status
+	in.Status.DeepCopyInto(&out.Status)
 	return
 }
 
@@ -1245,6 +1444,22 @@ func (in *ClusterPair) DeepCopyObject() runtime.Object {
 	return nil
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ClusterPairLimits) DeepCopyInto(out *ClusterPairLimits) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ClusterPairLimits.
+func (in *ClusterPairLimits) DeepCopy() *ClusterPairLimits {
+	if in == nil {
+		return nil
+	}
+	out := new(ClusterPairLimits)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ClusterPairList) DeepCopyInto(out *ClusterPairList) {
 	*out = *in
@@ -1290,6 +1505,11 @@ func (in *ClusterPairSpec) DeepCopyInto(out *ClusterPairSpec) {
 		}
 	}
 	in.PlatformOptions.DeepCopyInto(&out.PlatformOptions)
+	if in.Limits != nil {
+		in, out := &in.Limits, &out.Limits
+		*out = new(ClusterPairLimits)
+		**out = **in
+	}
 	return
 }
 
@@ -1306,6 +1526,23 @@ func (in *ClusterPairSpec) DeepCopy() *ClusterPairSpec {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ClusterPairStatus) DeepCopyInto(out *ClusterPairStatus) {
 	*out = *in
+	in.LastProbed.DeepCopyInto(&out.LastProbed)
+	if in.APILatency != nil {
+		in, out := &in.APILatency, &out.APILatency
+		*out = new(v1.Duration)
+		**out = **in
+	}
+	if in.CredentialsExpiry != nil {
+		in, out := &in.CredentialsExpiry, &out.CredentialsExpiry
+		*out = (*in).DeepCopy()
+	}
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]Condition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 	return
 }
 
@@ -1319,6 +1556,23 @@ func (in *ClusterPairStatus) DeepCopy() *ClusterPairStatus {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *Condition) DeepCopyInto(out *Condition) {
+	*out = *in
+	in.LastTransitionTime.DeepCopyInto(&out.LastTransitionTime)
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Condition.
+func (in *Condition) DeepCopy() *Condition {
+	if in == nil {
+		return nil
+	}
+	out := new(Condition)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *DailyPolicy) DeepCopyInto(out *DailyPolicy) {
 	*out = *in
@@ -1375,7 +1629,7 @@ func (in *DataExportDestination) DeepCopyInto(out *DataExportDestination) {
 	*out = *in
 	if in.PersistentVolumeClaim != nil {
 		in, out := &in.PersistentVolumeClaim, &out.PersistentVolumeClaim
-		*out = new(v1.PersistentVolumeClaim)
+		*out = new(corev1.PersistentVolumeClaim)
 		(*in).DeepCopyInto(*out)
 	}
 	return
@@ -1429,7 +1683,7 @@ func (in *DataExportSource) DeepCopyInto(out *DataExportSource) {
 	*out = *in
 	if in.PersistentVolumeClaim != nil {
 		in, out := &in.PersistentVolumeClaim, &out.PersistentVolumeClaim
-		*out = new(v1.PersistentVolumeClaim)
+		*out = new(corev1.PersistentVolumeClaim)
 		(*in).DeepCopyInto(*out)
 	}
 	return
@@ -1695,6 +1949,104 @@ func (in *Migration) DeepCopyObject() runtime.Object {
 	return nil
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MigrationDriftReport) DeepCopyInto(out *MigrationDriftReport) {
+	*out = *in
+	in.Timestamp.DeepCopyInto(&out.Timestamp)
+	if in.Summary != nil {
+		in, out := &in.Summary, &out.Summary
+		*out = new(MigrationDriftSummary)
+		**out = **in
+	}
+	if in.Resources != nil {
+		in, out := &in.Resources, &out.Resources
+		*out = make([]*MigrationDriftResourceInfo, len(*in))
+		for i := range *in {
+			if (*in)[i] != nil {
+				in, out := &(*in)[i], &(*out)[i]
+				*out = new(MigrationDriftResourceInfo)
+				**out = **in
+			}
+		}
+	}
+	if in.Volumes != nil {
+		in, out := &in.Volumes, &out.Volumes
+		*out = make([]*MigrationDriftVolumeInfo, len(*in))
+		for i := range *in {
+			if (*in)[i] != nil {
+				in, out := &(*in)[i], &(*out)[i]
+				*out = new(MigrationDriftVolumeInfo)
+				(*in).DeepCopyInto(*out)
+			}
+		}
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MigrationDriftReport.
+func (in *MigrationDriftReport) DeepCopy() *MigrationDriftReport {
+	if in == nil {
+		return nil
+	}
+	out := new(MigrationDriftReport)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MigrationDriftResourceInfo) DeepCopyInto(out *MigrationDriftResourceInfo) {
+	*out = *in
+	out.GroupVersionKind = in.GroupVersionKind
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MigrationDriftResourceInfo.
+func (in *MigrationDriftResourceInfo) DeepCopy() *MigrationDriftResourceInfo {
+	if in == nil {
+		return nil
+	}
+	out := new(MigrationDriftResourceInfo)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MigrationDriftSummary) DeepCopyInto(out *MigrationDriftSummary) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MigrationDriftSummary.
+func (in *MigrationDriftSummary) DeepCopy() *MigrationDriftSummary {
+	if in == nil {
+		return nil
+	}
+	out := new(MigrationDriftSummary)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MigrationDriftVolumeInfo) DeepCopyInto(out *MigrationDriftVolumeInfo) {
+	*out = *in
+	if in.ReplicationLag != nil {
+		in, out := &in.ReplicationLag, &out.ReplicationLag
+		*out = new(v1.Duration)
+		**out = **in
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MigrationDriftVolumeInfo.
+func (in *MigrationDriftVolumeInfo) DeepCopy() *MigrationDriftVolumeInfo {
+	if in == nil {
+		return nil
+	}
+	out := new(MigrationDriftVolumeInfo)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *MigrationList) DeepCopyInto(out *MigrationList) {
 	*out = *in
@@ -1852,6 +2204,11 @@ func (in *MigrationScheduleStatus) DeepCopyInto(out *MigrationScheduleStatus) {
 			(*out)[key] = outVal
 		}
 	}
+	if in.Drift != nil {
+		in, out := &in.Drift, &out.Drift
+		*out = new(MigrationDriftReport)
+		(*in).DeepCopyInto(*out)
+	}
 	return
 }
 
@@ -1949,6 +2306,13 @@ func (in *MigrationSpec) DeepCopyInto(out *MigrationSpec) {
 		*out = make([]string, len(*in))
 		copy(*out, *in)
 	}
+	if in.Waves != nil {
+		in, out := &in.Waves, &out.Waves
+		*out = make([]MigrationWave, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 	return
 }
 
@@ -1995,6 +2359,22 @@ func (in *MigrationStatus) DeepCopyInto(out *MigrationStatus) {
 		*out = new(MigrationSummary)
 		**out = **in
 	}
+	if in.Drift != nil {
+		in, out := &in.Drift, &out.Drift
+		*out = new(MigrationDriftReport)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Waves != nil {
+		in, out := &in.Waves, &out.Waves
+		*out = make([]*MigrationWaveInfo, len(*in))
+		for i := range *in {
+			if (*in)[i] != nil {
+				in, out := &(*in)[i], &(*out)[i]
+				*out = new(MigrationWaveInfo)
+				(*in).DeepCopyInto(*out)
+			}
+		}
+	}
 	return
 }
 
@@ -2057,6 +2437,78 @@ func (in *MigrationVolumeInfo) DeepCopy() *MigrationVolumeInfo {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MigrationWave) DeepCopyInto(out *MigrationWave) {
+	*out = *in
+	if in.Namespaces != nil {
+		in, out := &in.Namespaces, &out.Namespaces
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.NamespaceSelectors != nil {
+		in, out := &in.NamespaceSelectors, &out.NamespaceSelectors
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.Readiness != nil {
+		in, out := &in.Readiness, &out.Readiness
+		*out = new(MigrationWaveReadiness)
+		(*in).DeepCopyInto(*out)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MigrationWave.
+func (in *MigrationWave) DeepCopy() *MigrationWave {
+	if in == nil {
+		return nil
+	}
+	out := new(MigrationWave)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MigrationWaveInfo) DeepCopyInto(out *MigrationWaveInfo) {
+	*out = *in
+	in.StartTimestamp.DeepCopyInto(&out.StartTimestamp)
+	in.FinishTimestamp.DeepCopyInto(&out.FinishTimestamp)
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MigrationWaveInfo.
+func (in *MigrationWaveInfo) DeepCopy() *MigrationWaveInfo {
+	if in == nil {
+		return nil
+	}
+	out := new(MigrationWaveInfo)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MigrationWaveReadiness) DeepCopyInto(out *MigrationWaveReadiness) {
+	*out = *in
+	if in.Timeout != nil {
+		in, out := &in.Timeout, &out.Timeout
+		*out = new(v1.Duration)
+		**out = **in
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MigrationWaveReadiness.
+func (in *MigrationWaveReadiness) DeepCopy() *MigrationWaveReadiness {
+	if in == nil {
+		return nil
+	}
+	out := new(MigrationWaveReadiness)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *MonthlyPolicy) DeepCopyInto(out *MonthlyPolicy) {
 	*out = *in
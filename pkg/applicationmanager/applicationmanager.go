@@ -54,13 +54,29 @@ func (a *ApplicationManager) Init(mgr manager.Manager, adminNamespace string, st
 		return err
 	}
 	syncController := &controllers.BackupSyncController{
-		Recorder:     a.Recorder,
-		SyncInterval: 1 * time.Minute,
+		Recorder:         a.Recorder,
+		SyncInterval:     1 * time.Minute,
+		FullSyncInterval: 24 * time.Hour,
 	}
 	if err := syncController.Init(stopChannel); err != nil {
 		return err
 	}
 
+	reencryptController := controllers.NewBackupReencrypt(mgr, a.Recorder)
+	if err := reencryptController.Init(stopChannel); err != nil {
+		return err
+	}
+
+	replicateController := controllers.NewBackupReplicate(mgr, a.Recorder)
+	if err := replicateController.Init(stopChannel); err != nil {
+		return err
+	}
+
+	backupLocationController := controllers.NewBackupLocation(mgr, a.Recorder)
+	if err := backupLocationController.Init(stopChannel); err != nil {
+		return err
+	}
+
 	if err := controllers.RegisterDefaultCRDs(); err != nil {
 		return err
 	}
@@ -98,8 +114,10 @@ func CreateCRD() error {
 		return err
 	}
 	if ok {
-		err := k8sutils.CreateCRDV1(resource)
-		if err != nil && !errors.IsAlreadyExists(err) {
+		// The status of backup locations is updated by the validation
+		// without changing the rest of the object
+		err := k8sutils.CreateCRDV1WithStatus(resource)
+		if err != nil {
 			return err
 		}
 		if err := apiextensions.Instance().ValidateCRD(resource.Plural+"."+resource.Group, validateCRDTimeout, validateCRDInterval); err != nil {
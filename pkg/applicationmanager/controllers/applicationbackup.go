@@ -4,6 +4,7 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"io"
 	"math"
 	"os"
 	"path/filepath"
@@ -12,13 +13,12 @@ import (
 	"strings"
 	"time"
 
-	"github.com/aws/aws-sdk-go/service/s3/s3manager"
 	"github.com/libopenstorage/stork/drivers"
 	"github.com/libopenstorage/stork/drivers/volume"
 	"github.com/libopenstorage/stork/pkg/apis/stork"
 	stork_api "github.com/libopenstorage/stork/pkg/apis/stork/v1alpha1"
+	"github.com/libopenstorage/stork/pkg/backupobject"
 	"github.com/libopenstorage/stork/pkg/controllers"
-	"github.com/libopenstorage/stork/pkg/crypto"
 	"github.com/libopenstorage/stork/pkg/errors"
 	"github.com/libopenstorage/stork/pkg/k8sutils"
 	"github.com/libopenstorage/stork/pkg/log"
@@ -35,7 +35,6 @@ import (
 	kdmpShedOps "github.com/portworx/sched-ops/k8s/kdmp"
 	storkops "github.com/portworx/sched-ops/k8s/stork"
 	"github.com/sirupsen/logrus"
-	"gocloud.dev/blob"
 	"gocloud.dev/gcerrors"
 	v1 "k8s.io/api/core/v1"
 	v1networking "k8s.io/api/networking/v1"
@@ -59,10 +58,10 @@ const (
 	validateCRDInterval time.Duration = 5 * time.Second
 	validateCRDTimeout  time.Duration = 1 * time.Minute
 
-	resourceObjectName = "resources.json"
-	crdObjectName      = "crds.json"
-	nsObjectName       = "namespaces.json"
-	metadataObjectName = "metadata.json"
+	resourceObjectName = backupobject.ResourceObjectName
+	crdObjectName      = backupobject.CRDObjectName
+	nsObjectName       = backupobject.NamespacesObjectName
+	metadataObjectName = backupobject.MetadataObjectName
 
 	backupCancelBackoffInitialDelay = 5 * time.Second
 	backupCancelBackoffFactor       = 1
@@ -1348,6 +1347,37 @@ func (a *ApplicationBackupController) uploadObject(
 	backup *stork_api.ApplicationBackup,
 	objectName string,
 	data []byte,
+	manifest *backupobject.Manifest,
+) error {
+	return a.uploadObjectFrom(backup, objectName, stork_api.BackupCompressionNone, manifest, func(w io.Writer) error {
+		_, err := w.Write(data)
+		return err
+	})
+}
+
+// Uploads the JSON encoding of the list to the backup location specified in
+// the backup object without building the whole encoding in memory. The data
+// is compressed with the codec recorded in the backup status.
+func (a *ApplicationBackupController) uploadJSONList(
+	backup *stork_api.ApplicationBackup,
+	objectName string,
+	list interface{},
+	manifest *backupobject.Manifest,
+) error {
+	return a.uploadObjectFrom(backup, objectName, backup.Status.Compression, manifest, func(w io.Writer) error {
+		return backupobject.EncodeJSONList(w, list)
+	})
+}
+
+// Streams the data produced by write to the backup location specified in the
+// backup object. The object is only committed if write succeeds, it is then
+// recorded in the manifest if one is given.
+func (a *ApplicationBackupController) uploadObjectFrom(
+	backup *stork_api.ApplicationBackup,
+	objectName string,
+	compressionType stork_api.BackupCompressionType,
+	manifest *backupobject.Manifest,
+	write func(io.Writer) error,
 ) error {
 	backupLocation, err := storkops.Instance().GetBackupLocation(backup.Spec.BackupLocation, backup.Namespace)
 	if err != nil {
@@ -1357,40 +1387,14 @@ func (a *ApplicationBackupController) uploadObject(
 	if err != nil {
 		return err
 	}
-	if backupLocation.Location.EncryptionKey != "" {
-		return fmt.Errorf("EncryptionKey is deprecated, use EncryptionKeyV2 instead")
-	}
-	if backupLocation.Location.EncryptionV2Key != "" {
-		if data, err = crypto.Encrypt(data, backupLocation.Location.EncryptionV2Key); err != nil {
-			return err
-		}
-	}
-	var options blob.WriterOptions
-	if backupLocation.Location.S3Config != nil {
-		sseType := backupLocation.Location.S3Config.SSE
-		if len(sseType) != 0 {
-			beforeWrite := func(asFunc func(interface{}) bool) error {
-				var input *s3manager.UploadInput
-				if asFunc(&input) {
-					input.ServerSideEncryption = &sseType
-				}
-				return nil
-			}
-			options = blob.WriterOptions{BeforeWrite: beforeWrite}
-		}
-	}
 	objectPath := GetObjectPath(backup)
-	writer, err := bucket.NewWriter(context.TODO(), filepath.Join(objectPath, objectName), &options)
+	writer, err := backupobject.NewWriter(bucket, backupLocation, objectPath, objectName, compressionType, backup.Status.ObjectLock)
 	if err != nil {
 		return err
 	}
 
-	_, err = writer.Write(data)
-	if err != nil {
-		closeErr := writer.Close()
-		if closeErr != nil {
-			log.ApplicationBackupLog(backup).Errorf("Error closing writer for objectstore: %v", closeErr)
-		}
+	if err = write(writer); err != nil {
+		writer.Abort()
 		return err
 	}
 	err = writer.Close()
@@ -1398,6 +1402,9 @@ func (a *ApplicationBackupController) uploadObject(
 		log.ApplicationBackupLog(backup).Errorf("Error closing writer for objectstore: %v", err)
 		return err
 	}
+	if manifest != nil {
+		manifest.Add(writer.ManifestObject())
+	}
 	return nil
 }
 
@@ -1405,27 +1412,35 @@ func (a *ApplicationBackupController) uploadObject(
 func (a *ApplicationBackupController) uploadResources(
 	backup *stork_api.ApplicationBackup,
 	objects []runtime.Unstructured,
+	manifest *backupobject.Manifest,
 ) error {
 	resKinds := make(map[string]string)
 	for _, obj := range objects {
 		gvk := obj.GetObjectKind().GroupVersionKind()
 		resKinds[gvk.Kind] = gvk.Version
 	}
-	if err := a.uploadNamespaces(backup); err != nil {
+	// Record the codec in the status so that it is saved in the metadata
+	// along with the rest of the backup
+	backupLocation, err := storkops.Instance().GetBackupLocation(backup.Spec.BackupLocation, backup.Namespace)
+	if err != nil {
 		return err
 	}
-	// upload CRD to backuplocation
-	if err := a.uploadCRDResources(backup, resKinds); err != nil {
+	backup.Status.Compression = backupLocation.Location.Compression
+	// Retain all the objects of the backup until the same time so that it
+	// can be deleted as a whole once the retention expires
+	if backup.Status.ObjectLock, err = backupobject.GetObjectLock(backupLocation); err != nil {
 		return err
 	}
-	jsonBytes, err := json.MarshalIndent(objects, "", " ")
-	if err != nil {
+	if err := a.uploadNamespaces(backup, manifest); err != nil {
+		return err
+	}
+	// upload CRD to backuplocation
+	if err := a.uploadCRDResources(backup, resKinds, manifest); err != nil {
 		return err
 	}
-	// TODO: Encrypt if requested
-	return a.uploadObject(backup, resourceObjectName, jsonBytes)
+	return a.uploadJSONList(backup, resourceObjectName, objects, manifest)
 }
-func (a *ApplicationBackupController) uploadNamespaces(backup *stork_api.ApplicationBackup) error {
+func (a *ApplicationBackupController) uploadNamespaces(backup *stork_api.ApplicationBackup, manifest *backupobject.Manifest) error {
 	var namespaces []*v1.Namespace
 	for _, namespace := range backup.Spec.Namespaces {
 		ns, err := core.Instance().GetNamespace(namespace)
@@ -1435,17 +1450,13 @@ func (a *ApplicationBackupController) uploadNamespaces(backup *stork_api.Applica
 		ns.ResourceVersion = ""
 		namespaces = append(namespaces, ns)
 	}
-	jsonBytes, err := json.MarshalIndent(namespaces, "", " ")
-	if err != nil {
-		return err
-	}
-	if err := a.uploadObject(backup, nsObjectName, jsonBytes); err != nil {
+	if err := a.uploadJSONList(backup, nsObjectName, namespaces, manifest); err != nil {
 		return err
 	}
 	return nil
 }
 
-func (a *ApplicationBackupController) uploadCRDResources(backup *stork_api.ApplicationBackup, resKinds map[string]string) error {
+func (a *ApplicationBackupController) uploadCRDResources(backup *stork_api.ApplicationBackup, resKinds map[string]string, manifest *backupobject.Manifest) error {
 	crdList, err := storkops.Instance().ListApplicationRegistrations()
 	if err != nil {
 		return err
@@ -1488,11 +1499,7 @@ func (a *ApplicationBackupController) uploadCRDResources(backup *stork_api.Appli
 			}
 
 		}
-		jsonBytes, err := json.MarshalIndent(crds, "", " ")
-		if err != nil {
-			return err
-		}
-		if err := a.uploadObject(backup, crdObjectName, jsonBytes); err != nil {
+		if err := a.uploadJSONList(backup, crdObjectName, crds, manifest); err != nil {
 			return err
 		}
 		return nil
@@ -1527,26 +1534,52 @@ func (a *ApplicationBackupController) uploadCRDResources(backup *stork_api.Appli
 		}
 
 	}
-	jsonBytes, err := json.MarshalIndent(crds, "", " ")
-	if err != nil {
-		return err
-	}
-	if err := a.uploadObject(backup, crdObjectName, jsonBytes); err != nil {
+	if err := a.uploadJSONList(backup, crdObjectName, crds, manifest); err != nil {
 		return err
 	}
 	return nil
 }
 
 // Upload the backup object which should have all the required metadata
+// followed by the manifest of all the uploaded objects. The backup is then
+// added to the catalog of the backup location.
 func (a *ApplicationBackupController) uploadMetadata(
 	backup *stork_api.ApplicationBackup,
+	manifest *backupobject.Manifest,
 ) error {
 	jsonBytes, err := json.MarshalIndent(backup, "", " ")
 	if err != nil {
 		return err
 	}
 
-	return a.uploadObject(backup, metadataObjectName, jsonBytes)
+	if err := a.uploadObject(backup, metadataObjectName, jsonBytes, manifest); err != nil {
+		return err
+	}
+	if manifest != nil {
+		jsonBytes, err = json.MarshalIndent(manifest, "", " ")
+		if err != nil {
+			return err
+		}
+		if err := a.uploadObject(backup, backupobject.ManifestObjectName, jsonBytes, nil); err != nil {
+			return err
+		}
+	}
+	a.addBackupToCatalog(backup)
+	return nil
+}
+
+// addBackupToCatalog adds the backup to the catalog of its backup location.
+// Failing to do so doesn't fail the backup, the backup sync controller adds
+// the backups missing from the catalog when it scans the whole location.
+func (a *ApplicationBackupController) addBackupToCatalog(backup *stork_api.ApplicationBackup) {
+	if err := addToBackupCatalog(backup, backupobject.CatalogOperationAdd); err != nil {
+		message := fmt.Sprintf("Error adding backup to the catalog of the backup location: %v", err)
+		log.ApplicationBackupLog(backup).Warnf(message)
+		a.recorder.Event(backup,
+			v1.EventTypeWarning,
+			string(backup.Status.Status),
+			message)
+	}
 }
 
 func getResourceExportCRName(opsPrefix, crUID, ns string) string {
@@ -1878,6 +1911,9 @@ func (a *ApplicationBackupController) backupResources(
 				for _, vInfo := range backup.Status.Volumes {
 					backup.Status.TotalSize += vInfo.TotalSize
 				}
+				// The objects were uploaded by the resource export job, only
+				// the catalog of the backup location is left to update
+				a.addBackupToCatalog(backup)
 			case kdmpapi.ResourceExportStatusInitial:
 			case kdmpapi.ResourceExportStatusPending:
 			case kdmpapi.ResourceExportStatusInProgress:
@@ -1891,7 +1927,8 @@ func (a *ApplicationBackupController) backupResources(
 		}
 	}
 	// Upload the resources to the backup location
-	if err = a.uploadResources(backup, allObjects); err != nil {
+	manifest := backupobject.NewManifest()
+	if err = a.uploadResources(backup, allObjects, manifest); err != nil {
 		message := fmt.Sprintf("Error uploading resources: %v", err)
 		backup.Status.Status = stork_api.ApplicationBackupStatusFailed
 		backup.Status.Stage = stork_api.ApplicationBackupStageFinal
@@ -1923,7 +1960,7 @@ func (a *ApplicationBackupController) backupResources(
 		backup.Status.TotalSize += vInfo.TotalSize
 	}
 	// Upload the metadata for the backup to the backup location
-	if err = a.uploadMetadata(backup); err != nil {
+	if err = a.uploadMetadata(backup, manifest); err != nil {
 		a.recorder.Event(backup,
 			v1.EventTypeWarning,
 			string(stork_api.ApplicationBackupStatusFailed),
@@ -1949,6 +1986,25 @@ func (a *ApplicationBackupController) deleteBackup(backup *stork_api.Application
 		return true, nil
 	}
 
+	// Objects under retention can't be deleted from the backup location,
+	// keep the backup around until the retention expires
+	if retained, retainUntil := isBackupRetained(backup); retained {
+		message := fmt.Sprintf("Deletion deferred, backup is under object lock retention until %v",
+			retainUntil.UTC().Format(time.RFC3339))
+		if backup.Status.Reason != message {
+			backup.Status.Reason = message
+			a.recorder.Event(backup,
+				v1.EventTypeWarning,
+				reasonObjectLocked,
+				message)
+			log.ApplicationBackupLog(backup).Infof(message)
+			if err := a.client.Update(context.TODO(), backup); err != nil {
+				return false, err
+			}
+		}
+		return false, nil
+	}
+
 	drivers := a.getDriversForBackup(backup)
 	for driverName := range drivers {
 
@@ -1984,13 +2040,14 @@ func (a *ApplicationBackupController) deleteBackup(backup *stork_api.Application
 		}
 		return true, err
 	}
-	// TODO: for nfs type, we need to invoke job based deletion.
-	// For now, skipping it.
-	if backupLocation.Location.Type == stork_api.BackupLocationNFS {
-		return true, nil
-	}
 	bucket, err := objectstore.GetBucket(backupLocation)
 	if err != nil {
+		// The nfs export might not be mounted in the stork pod, nothing can
+		// be deleted from here in that case
+		if backupLocation.Location.Type == stork_api.BackupLocationNFS {
+			log.ApplicationBackupLog(backup).Warnf("Skipping deletion of resources from nfs backup location: %v", err)
+			return true, nil
+		}
 		return true, err
 	}
 
@@ -2011,6 +2068,23 @@ func (a *ApplicationBackupController) deleteBackup(backup *stork_api.Application
 		if err = bucket.Delete(context.TODO(), filepath.Join(objectPath, nsObjectName)); err != nil && gcerrors.Code(err) != gcerrors.NotFound {
 			return true, fmt.Errorf("error deleting namespaces for backup %v/%v: %v", backup.Namespace, backup.Name, err)
 		}
+
+		if err = bucket.Delete(context.TODO(), filepath.Join(objectPath, backupobject.ManifestObjectName)); err != nil && gcerrors.Code(err) != gcerrors.NotFound {
+			return true, fmt.Errorf("error deleting manifest for backup %v/%v: %v", backup.Namespace, backup.Name, err)
+		}
+
+		if err = backupobject.AppendCatalogEntry(bucket, backupLocation, backupobject.NewCatalogEntry(backupobject.CatalogOperationDelete, backup)); err != nil {
+			return true, err
+		}
+	}
+
+	// The copy in the replica location is only cleaned up on a best effort
+	// basis, it might be retained by the replica location or unreachable
+	if backup.Status.Replica != nil && objectPath != "" {
+		if err := deleteReplicaObjects(backup, objectPath); err != nil {
+			log.ApplicationBackupLog(backup).Warnf("Error deleting backup from replica backup location %v: %v",
+				backup.Status.Replica.BackupLocation, err)
+		}
 	}
 
 	return true, nil
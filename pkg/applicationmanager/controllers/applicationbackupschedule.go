@@ -35,7 +35,7 @@ const (
 	annotationPrefix = "stork.libopenstorage.org/"
 	// ApplicationBackupScheduleNameAnnotation Annotation used to specify the name of schedule that
 	// created the backup
-	ApplicationBackupScheduleNameAnnotation = annotationPrefix + "applicationBackupScheduleName"
+	ApplicationBackupScheduleNameAnnotation = utils.ApplicationBackupScheduleNameAnnotation
 	// ApplicationBackupSchedulePolicyTypeAnnotation Annotation used to specify the type of the
 	// policy that triggered the backup
 	ApplicationBackupSchedulePolicyTypeAnnotation = annotationPrefix + "applicationBackupSchedulePolicyType"
@@ -350,6 +350,9 @@ func (s *ApplicationBackupScheduleController) startApplicationBackup(backupSched
 			backup.Spec.BackupType = genericBackupTypeValue
 		}
 	}
+	if backupSchedule.Spec.ReplicaBackupLocation != "" {
+		backup.Spec.ReplicaBackupLocation = backupSchedule.Spec.ReplicaBackupLocation
+	}
 	options, err := schedule.GetOptions(backupSchedule.Spec.SchedulePolicyName, backupSchedule.Namespace, policyType)
 	if err != nil {
 		return err
@@ -489,7 +492,33 @@ func (s *ApplicationBackupScheduleController) pruneApplicationBackups(backupSche
 			failedDeletes := make([]*stork_api.ScheduledApplicationBackupStatus, 0)
 			if numReady > int(retainNum) {
 				for i := 0; i < deleteBefore; i++ {
-					err := storkops.Instance().DeleteApplicationBackup(policyApplicationBackup[i].Name, backupSchedule.Namespace)
+					// Don't delete backups whose objects are still retained
+					// by the backup location, they will be pruned once the
+					// retention expires
+					backup, err := storkops.Instance().GetApplicationBackup(policyApplicationBackup[i].Name, backupSchedule.Namespace)
+					if err == nil {
+						if retained, retainUntil := isBackupRetained(backup); retained {
+							// Only report it the first time the backup
+							// can't be pruned
+							if !policyApplicationBackup[i].RetainedByObjectLock {
+								msg := fmt.Sprintf("Not pruning backup %v, it is under object lock retention until %v",
+									backup.Name, retainUntil.UTC().Format(time.RFC3339))
+								log.ApplicationBackupScheduleLog(backupSchedule).Infof(msg)
+								s.recorder.Event(backupSchedule,
+									v1.EventTypeNormal,
+									reasonObjectLocked,
+									msg)
+								policyApplicationBackup[i].RetainedByObjectLock = true
+							}
+							failedDeletes = append(failedDeletes, policyApplicationBackup[i])
+							continue
+						}
+					} else if !errors.IsNotFound(err) {
+						log.ApplicationBackupScheduleLog(backupSchedule).Warnf("Error getting %v: %v", policyApplicationBackup[i].Name, err)
+						failedDeletes = append(failedDeletes, policyApplicationBackup[i])
+						continue
+					}
+					err = storkops.Instance().DeleteApplicationBackup(policyApplicationBackup[i].Name, backupSchedule.Namespace)
 					if err != nil && !errors.IsNotFound(err) {
 						log.ApplicationBackupScheduleLog(backupSchedule).Warnf("Error deleting %v: %v", policyApplicationBackup[i].Name, err)
 						// Keep a track of the failed deletes
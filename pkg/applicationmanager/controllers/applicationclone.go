@@ -255,6 +255,18 @@ func (a *ApplicationCloneController) handle(ctx context.Context, clone *stork_ap
 				return nil
 			}
 		}
+		// Make sure the transformation is ready if configured
+		if ready, err := isResourceTransformationReady(clone.Spec.TransformSpecs, clone.Spec.SourceNamespace); err != nil {
+			log.ApplicationCloneLog(clone).Errorf(err.Error())
+			a.recorder.Event(clone,
+				v1.EventTypeWarning,
+				string(stork_api.ApplicationCloneStatusFailed),
+				err.Error())
+			return nil
+		} else if !ready {
+			log.ApplicationCloneLog(clone).Infof("Waiting for transformation %v to be validated", clone.Spec.TransformSpecs)
+			return nil
+		}
 		fallthrough
 	case stork_api.ApplicationCloneStagePreExecRule:
 		terminationChannel, err = a.runPreExecRule(clone)
@@ -504,15 +516,19 @@ func (a *ApplicationCloneController) runPostExecRule(clone *stork_api.Applicatio
 func (a *ApplicationCloneController) prepareResources(
 	clone *stork_api.ApplicationClone,
 	objects []runtime.Unstructured,
-) ([]runtime.Unstructured, error) {
+) ([]runtime.Unstructured, map[stork_api.ObjectInfo]string, error) {
 	tempObjects := make([]runtime.Unstructured, 0)
 	pvNameMappings, err := a.getPVNameMappings(clone)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
 	namespaceMapping := make(map[string]string)
 	namespaceMapping[clone.Spec.SourceNamespace] = clone.Spec.DestinationNamespace
+	transform, err := getResourceTransformation(clone.Spec.TransformSpecs, clone.Spec.SourceNamespace)
+	if err != nil {
+		return nil, nil, err
+	}
 
 	for _, o := range objects {
 		if !a.resourceToBeCloned(o) {
@@ -521,19 +537,19 @@ func (a *ApplicationCloneController) prepareResources(
 
 		metadata, err := meta.Accessor(o)
 		if err != nil {
-			return nil, err
+			return nil, nil, err
 		}
 
 		switch o.GetObjectKind().GroupVersionKind().Kind {
 		case "PersistentVolume":
 			err := a.preparePVResource(o)
 			if err != nil {
-				return nil, fmt.Errorf("error preparing PV resource %v: %v", metadata.GetName(), err)
+				return nil, nil, fmt.Errorf("error preparing PV resource %v: %v", metadata.GetName(), err)
 			}
 		case "Service":
 			err := a.prepareServiceResource(o)
 			if err != nil {
-				return nil, fmt.Errorf("error preparing PV resource %v: %v", metadata.GetName(), err)
+				return nil, nil, fmt.Errorf("error preparing PV resource %v: %v", metadata.GetName(), err)
 			}
 		}
 		var opts resourcecollector.Options
@@ -550,11 +566,15 @@ func (a *ApplicationCloneController) prepareResources(
 			"", "",
 		)
 		if err != nil {
-			return nil, err
+			return nil, nil, err
 		}
 		tempObjects = append(tempObjects, o)
 	}
-	return tempObjects, nil
+	transformed, err := transformResources(transform, tempObjects)
+	if err != nil {
+		return nil, nil, err
+	}
+	return tempObjects, transformed, nil
 }
 
 func (a *ApplicationCloneController) prepareServiceResource(
@@ -626,6 +646,7 @@ func (a *ApplicationCloneController) updateResourceStatus(
 	object runtime.Unstructured,
 	status stork_api.ApplicationCloneStatusType,
 	reason string,
+	transformedBy string,
 ) error {
 	metadata, err := meta.Accessor(object)
 	if err != nil {
@@ -633,9 +654,10 @@ func (a *ApplicationCloneController) updateResourceStatus(
 	}
 
 	resourceInfo := &stork_api.ApplicationCloneResourceInfo{
-		Name:   metadata.GetName(),
-		Status: status,
-		Reason: reason,
+		Name:          metadata.GetName(),
+		Status:        status,
+		Reason:        reason,
+		TransformedBy: transformedBy,
 	}
 	gvk := object.GetObjectKind().GroupVersionKind()
 	resourceInfo.Kind = gvk.Kind
@@ -679,6 +701,7 @@ func (a *ApplicationCloneController) resourceToBeCloned(
 func (a *ApplicationCloneController) applyResources(
 	clone *stork_api.ApplicationClone,
 	objects []runtime.Unstructured,
+	transformed map[stork_api.ObjectInfo]string,
 ) error {
 	namespaceMapping := make(map[string]string)
 	namespaceMapping[clone.Spec.SourceNamespace] = clone.Spec.DestinationNamespace
@@ -703,6 +726,12 @@ func (a *ApplicationCloneController) applyResources(
 			return err
 		}
 
+		info, err := getTransformObjectInfo(o)
+		if err != nil {
+			return err
+		}
+		transformedBy := getTransformedBy(transformed, info)
+
 		log.ApplicationCloneLog(clone).Infof("Applying %v %v", objectType.GetKind(), metadata.GetName())
 		retained := false
 		err = a.resourceCollector.ApplyResource(
@@ -728,7 +757,8 @@ func (a *ApplicationCloneController) applyResources(
 				clone,
 				o,
 				stork_api.ApplicationCloneStatusFailed,
-				fmt.Sprintf("Error applying resource: %v", err)); err != nil {
+				fmt.Sprintf("Error applying resource: %v", err),
+				transformedBy); err != nil {
 				return err
 			}
 		} else if retained {
@@ -736,7 +766,8 @@ func (a *ApplicationCloneController) applyResources(
 				clone,
 				o,
 				stork_api.ApplicationCloneStatusRetained,
-				"Resource clone skipped as it was already present and ReplacePolicy is set to Retain"); err != nil {
+				"Resource clone skipped as it was already present and ReplacePolicy is set to Retain",
+				transformedBy); err != nil {
 				return err
 			}
 		} else {
@@ -744,7 +775,8 @@ func (a *ApplicationCloneController) applyResources(
 				clone,
 				o,
 				stork_api.ApplicationCloneStatusSuccessful,
-				fmt.Sprintf("Resource cloned successfully for namespace %v", clone.Spec.DestinationNamespace)); err != nil {
+				fmt.Sprintf("Resource cloned successfully for namespace %v", clone.Spec.DestinationNamespace),
+				transformedBy); err != nil {
 				return err
 			}
 		}
@@ -771,7 +803,8 @@ func (a *ApplicationCloneController) cloneResources(
 	}
 
 	// Do any additional preparation for the resources if required
-	if allObjects, err = a.prepareResources(clone, allObjects); err != nil {
+	allObjects, transformed, err := a.prepareResources(clone, allObjects)
+	if err != nil {
 		a.recorder.Event(clone,
 			v1.EventTypeWarning,
 			string(stork_api.ApplicationCloneStatusFailed),
@@ -780,7 +813,7 @@ func (a *ApplicationCloneController) cloneResources(
 		return err
 	}
 
-	if err = a.applyResources(clone, allObjects); err != nil {
+	if err = a.applyResources(clone, allObjects, transformed); err != nil {
 		return err
 	}
 
@@ -4,22 +4,26 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"io"
 	"path/filepath"
 	"reflect"
+	"sort"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/libopenstorage/stork/drivers/volume"
 	"github.com/libopenstorage/stork/drivers/volume/kdmp"
 	"github.com/libopenstorage/stork/pkg/apis/stork"
 	storkapi "github.com/libopenstorage/stork/pkg/apis/stork/v1alpha1"
+	"github.com/libopenstorage/stork/pkg/backupobject"
 	"github.com/libopenstorage/stork/pkg/controllers"
-	"github.com/libopenstorage/stork/pkg/crypto"
 	"github.com/libopenstorage/stork/pkg/k8sutils"
 	"github.com/libopenstorage/stork/pkg/log"
 	"github.com/libopenstorage/stork/pkg/objectstore"
 	"github.com/libopenstorage/stork/pkg/resourcecollector"
+	"github.com/libopenstorage/stork/pkg/rule"
 	"github.com/libopenstorage/stork/pkg/utils"
 	"github.com/libopenstorage/stork/pkg/version"
 	kdmpapi "github.com/portworx/kdmp/pkg/apis/kdmp/v1alpha1"
@@ -53,6 +57,9 @@ import (
 const (
 	defaultStorageClass = "use-default-storage-class"
 	maxCrUpdateRetries  = 7
+	// ruleReadyTimeout is how long to wait for the restored pods to be ready
+	// before running the PreExecRule and PostExecRule
+	ruleReadyTimeout = 10 * time.Minute
 )
 
 // isStorageClassMappingContainsDefault - will check whether any storageclass has use-default-storage-class
@@ -69,9 +76,10 @@ func isStorageClassMappingContainsDefault(restore *storkapi.ApplicationRestore)
 // NewApplicationRestore creates a new instance of ApplicationRestoreController.
 func NewApplicationRestore(mgr manager.Manager, r record.EventRecorder, rc resourcecollector.ResourceCollector) *ApplicationRestoreController {
 	return &ApplicationRestoreController{
-		client:            mgr.GetClient(),
-		recorder:          r,
-		resourceCollector: rc,
+		client:              mgr.GetClient(),
+		recorder:            r,
+		resourceCollector:   rc,
+		terminationChannels: make(map[string][]chan bool),
 	}
 }
 
@@ -83,6 +91,11 @@ type ApplicationRestoreController struct {
 	resourceCollector     resourcecollector.ResourceCollector
 	dynamicInterface      dynamic.Interface
 	restoreAdminNamespace string
+
+	// terminationChannels stop the background actions of the
+	// PreExecRule of the restores in progress
+	terminationChannels map[string][]chan bool
+	terminationLock     sync.Mutex
 }
 
 // Init Initialize the application restore controller
@@ -93,6 +106,10 @@ func (a *ApplicationRestoreController) Init(mgr manager.Manager, restoreAdminNam
 	}
 
 	a.restoreAdminNamespace = restoreAdminNamespace
+	if err := a.performRuleRecovery(); err != nil {
+		logrus.Errorf("Failed to perform recovery for application restore rules: %v", err)
+		return err
+	}
 
 	config, err := rest.InClusterConfig()
 	if err != nil {
@@ -107,21 +124,60 @@ func (a *ApplicationRestoreController) Init(mgr manager.Manager, restoreAdminNam
 	return controllers.RegisterTo(mgr, "application-restore-controller", a, &storkapi.ApplicationRestore{})
 }
 
+func (a *ApplicationRestoreController) setKind(restore *storkapi.ApplicationRestore) {
+	restore.Kind = "ApplicationRestore"
+	restore.APIVersion = storkapi.SchemeGroupVersion.String()
+}
+
+// performRuleRecovery terminates potential background commands running pods for
+// all applicationRestore objects
+func (a *ApplicationRestoreController) performRuleRecovery() error {
+	applicationRestores, err := storkops.Instance().ListApplicationRestores(v1.NamespaceAll, metav1.ListOptions{})
+	if err != nil {
+		logrus.Errorf("Failed to list all application restores during rule recovery: %v", err)
+		return err
+	}
+
+	if applicationRestores == nil {
+		return nil
+	}
+
+	var lastError error
+	for _, applicationRestore := range applicationRestores.Items {
+		a.setKind(&applicationRestore)
+		err := rule.PerformRuleRecovery(&applicationRestore)
+		if err != nil {
+			lastError = err
+		}
+	}
+	return lastError
+}
+
 func (a *ApplicationRestoreController) setDefaults(restore *storkapi.ApplicationRestore) error {
 	if restore.Spec.ReplacePolicy == "" {
 		restore.Spec.ReplacePolicy = storkapi.ApplicationRestoreReplacePolicyRetain
 	}
+	// Pick the backup to restore if the restore references a schedule
+	if err := resolveRestoreBackupName(restore); err != nil {
+		return err
+	}
 	// If no namespaces mappings are provided add mappings for all of them
-	if len(restore.Spec.NamespaceMapping) == 0 {
-		backup, err := storkops.Instance().GetApplicationBackup(restore.Spec.BackupName, restore.Namespace)
+	if len(restore.Spec.NamespaceMapping) == 0 || len(restore.Spec.Namespaces) != 0 {
+		backup, err := storkops.Instance().GetApplicationBackup(restore.GetBackupName(), restore.Namespace)
 		if err != nil {
 			return fmt.Errorf("error getting backup: %v", err)
 		}
 		if restore.Spec.NamespaceMapping == nil {
 			restore.Spec.NamespaceMapping = make(map[string]string)
 		}
-		for _, ns := range backup.Spec.Namespaces {
-			restore.Spec.NamespaceMapping[ns] = ns
+		if len(restore.Spec.NamespaceMapping) == 0 && len(restore.Spec.Namespaces) == 0 {
+			for _, ns := range backup.Spec.Namespaces {
+				restore.Spec.NamespaceMapping[ns] = ns
+			}
+		}
+		// Only restore the subset of namespaces if one was selected
+		if err := restoreNamespaceSubset(restore, backup); err != nil {
+			return err
 		}
 	}
 
@@ -152,7 +208,7 @@ func (a *ApplicationRestoreController) verifyNamespaces(restore *storkapi.Applic
 	if !a.namespaceRestoreAllowed(restore) {
 		return fmt.Errorf("Spec.Namespaces should only contain the current namespace")
 	}
-	backup, err := storkops.Instance().GetApplicationBackup(restore.Spec.BackupName, restore.Namespace)
+	backup, err := storkops.Instance().GetApplicationBackup(restore.GetBackupName(), restore.Namespace)
 	if err != nil {
 		log.ApplicationRestoreLog(restore).Errorf("Error getting backup: %v", err)
 		return err
@@ -234,6 +290,25 @@ func (a *ApplicationRestoreController) createNamespaces(backup *storkapi.Applica
 						}
 						utils.ParseRancherProjectMapping(annotations, rancherProjectMapping)
 						utils.ParseRancherProjectMapping(labels, rancherProjectMapping)
+					} else if restore.Spec.ReplacePolicy == storkapi.ApplicationRestoreReplacePolicyMerge {
+						// overlay the annotations and labels from the backup on the
+						// existing ones in case of replace policy set to merge
+						annotations = oldNS.GetAnnotations()
+						if annotations == nil {
+							annotations = make(map[string]string)
+						}
+						for k, v := range ns.GetAnnotations() {
+							annotations[k] = v
+						}
+						labels = oldNS.GetLabels()
+						if labels == nil {
+							labels = make(map[string]string)
+						}
+						for k, v := range ns.GetLabels() {
+							labels[k] = v
+						}
+						utils.ParseRancherProjectMapping(annotations, rancherProjectMapping)
+						utils.ParseRancherProjectMapping(labels, rancherProjectMapping)
 					}
 					// delete the px backup CreateByKey Annotation
 					delete(annotations, utils.PxbackupAnnotationCreateByKey)
@@ -314,7 +389,9 @@ func (a *ApplicationRestoreController) Reconcile(ctx context.Context, request re
 // Handle updates for ApplicationRestore objects
 func (a *ApplicationRestoreController) handle(ctx context.Context, restore *storkapi.ApplicationRestore, updateCr chan int) error {
 	if restore.DeletionTimestamp != nil {
-		if controllers.ContainsFinalizer(restore, controllers.FinalizerCleanup) {
+		// Nothing was started for a dry run
+		a.terminateRuleActions(restore)
+		if controllers.ContainsFinalizer(restore, controllers.FinalizerCleanup) && !restore.Spec.DryRun {
 			if err := a.cleanupRestore(restore); err != nil {
 				logrus.Errorf("%s: cleanup: %s", reflect.TypeOf(a), err)
 			}
@@ -338,7 +415,7 @@ func (a *ApplicationRestoreController) handle(ctx context.Context, restore *stor
 		return nil
 	}
 
-	backup, err := storkops.Instance().GetApplicationBackup(restore.Spec.BackupName, restore.Namespace)
+	backup, err := storkops.Instance().GetApplicationBackup(restore.GetBackupName(), restore.Namespace)
 	if err != nil {
 		log.ApplicationRestoreLog(restore).Errorf("Error getting backup: %v", err)
 		return err
@@ -349,7 +426,8 @@ func (a *ApplicationRestoreController) handle(ctx context.Context, restore *stor
 		logrus.Errorf("error in checking backuplocation type")
 	}
 
-	if !nfs {
+	// The namespaces aren't created for a dry run
+	if !nfs && !restore.Spec.DryRun {
 		err = a.verifyNamespaces(restore)
 		if err != nil {
 			log.ApplicationRestoreLog(restore).Errorf(err.Error())
@@ -404,9 +482,70 @@ func (a *ApplicationRestoreController) handle(ctx context.Context, restore *stor
 			return err
 		}
 	}
+	// A dry run only records what the restore would do
+	if restore.Spec.DryRun {
+		if restore.Status.Stage == storkapi.ApplicationRestoreStageFinal {
+			return nil
+		}
+		return a.dryRunRestore(restore)
+	}
 	switch restore.Status.Stage {
 	case storkapi.ApplicationRestoreStageInitial:
-		// Make sure the namespaces exist
+		// Make sure the rules exist if configured
+		if restore.Spec.PreExecRule != "" {
+			_, err := storkops.Instance().GetRule(restore.Spec.PreExecRule, restore.Namespace)
+			if err != nil {
+				message := fmt.Sprintf("Error getting PreExecRule %v: %v", restore.Spec.PreExecRule, err)
+				log.ApplicationRestoreLog(restore).Errorf(message)
+				a.recorder.Event(restore,
+					v1.EventTypeWarning,
+					string(storkapi.ApplicationRestoreStatusFailed),
+					message)
+				return nil
+			}
+		}
+		if restore.Spec.PostExecRule != "" {
+			_, err := storkops.Instance().GetRule(restore.Spec.PostExecRule, restore.Namespace)
+			if err != nil {
+				message := fmt.Sprintf("Error getting PostExecRule %v: %v", restore.Spec.PostExecRule, err)
+				log.ApplicationRestoreLog(restore).Errorf(message)
+				a.recorder.Event(restore,
+					v1.EventTypeWarning,
+					string(storkapi.ApplicationRestoreStatusFailed),
+					message)
+				return nil
+			}
+		}
+		// The resources from NFS backup locations are restored by a job
+		// that doesn't support everything the controller does
+		if nfs {
+			if err := validateNFSRestore(restore); err != nil {
+				message := fmt.Sprintf("Restore from NFS backup location not supported: %v", err)
+				log.ApplicationRestoreLog(restore).Errorf(message)
+				a.recorder.Event(restore,
+					v1.EventTypeWarning,
+					string(storkapi.ApplicationRestoreStatusFailed),
+					message)
+				restore.Status.Stage = storkapi.ApplicationRestoreStageFinal
+				restore.Status.Status = storkapi.ApplicationRestoreStatusFailed
+				restore.Status.Reason = message
+				restore.Status.FinishTimestamp = metav1.Now()
+				restore.Status.LastUpdateTimestamp = metav1.Now()
+				return a.client.Update(context.TODO(), restore)
+			}
+		}
+		// Make sure the transformation is ready if configured
+		if ready, err := isResourceTransformationReady(restore.Spec.TransformSpecs, restore.Namespace); err != nil {
+			log.ApplicationRestoreLog(restore).Errorf(err.Error())
+			a.recorder.Event(restore,
+				v1.EventTypeWarning,
+				string(storkapi.ApplicationRestoreStatusFailed),
+				err.Error())
+			return nil
+		} else if !ready {
+			log.ApplicationRestoreLog(restore).Infof("Waiting for transformation %v to be validated", restore.Spec.TransformSpecs)
+			return nil
+		}
 		fallthrough
 	case storkapi.ApplicationRestoreStageVolumes:
 		err := a.restoreVolumes(restore, updateCr)
@@ -434,6 +573,42 @@ func (a *ApplicationRestoreController) handle(ctx context.Context, restore *stor
 			return nil
 		}
 
+	case storkapi.ApplicationRestoreStagePreExecRule:
+		err := a.runPreExecRule(restore)
+		if err != nil {
+			message := fmt.Sprintf("Error running PreExecRule: %v", err)
+			log.ApplicationRestoreLog(restore).Errorf(message)
+			a.recorder.Event(restore,
+				v1.EventTypeWarning,
+				string(storkapi.ApplicationRestoreStatusFailed),
+				message)
+			restore.Status.Stage = storkapi.ApplicationRestoreStageFinal
+			restore.Status.Status = storkapi.ApplicationRestoreStatusFailed
+			restore.Status.Reason = message
+			restore.Status.FinishTimestamp = metav1.Now()
+			restore.Status.LastUpdateTimestamp = metav1.Now()
+			a.terminateRuleActions(restore)
+			return a.client.Update(context.TODO(), restore)
+		}
+
+	case storkapi.ApplicationRestoreStagePostExecRule:
+		err := a.runPostExecRule(restore)
+		if err != nil {
+			message := fmt.Sprintf("Error running PostExecRule: %v", err)
+			log.ApplicationRestoreLog(restore).Errorf(message)
+			a.recorder.Event(restore,
+				v1.EventTypeWarning,
+				string(storkapi.ApplicationRestoreStatusFailed),
+				message)
+			restore.Status.Stage = storkapi.ApplicationRestoreStageFinal
+			restore.Status.Status = storkapi.ApplicationRestoreStatusFailed
+			restore.Status.Reason = message
+			restore.Status.FinishTimestamp = metav1.Now()
+			restore.Status.LastUpdateTimestamp = metav1.Now()
+			a.terminateRuleActions(restore)
+			return a.client.Update(context.TODO(), restore)
+		}
+
 	case storkapi.ApplicationRestoreStageFinal:
 		// DoNothing
 		return nil
@@ -556,11 +731,23 @@ func (a *ApplicationRestoreController) restoreVolumes(restore *storkapi.Applicat
 	if err != nil {
 		return err
 	}
-	backup, err := storkops.Instance().GetApplicationBackup(restore.Spec.BackupName, restore.Namespace)
+	backup, err := storkops.Instance().GetApplicationBackup(restore.GetBackupName(), restore.Namespace)
 	if err != nil {
 		return fmt.Errorf("error getting backup spec for restore: %v", err)
 	}
 	objectMap := storkapi.CreateObjectsMap(restore.Spec.IncludeResources)
+	// The PVCs selected by labels or types are only known from the
+	// resources in the backup
+	if isRestoreFiltered(restore) {
+		objects, err := a.readResources(backup, restore.Spec.BackupLocation, restore.Namespace)
+		if err != nil {
+			log.ApplicationRestoreLog(restore).Errorf("Error downloading resources: %v", err)
+			return err
+		}
+		if objectMap, err = getRestoreObjectsMap(restore, objects); err != nil {
+			return err
+		}
+	}
 	info := storkapi.ObjectInfo{
 		GroupVersionKind: metav1.GroupVersionKind{
 			Group:   "core",
@@ -584,10 +771,8 @@ func (a *ApplicationRestoreController) restoreVolumes(restore *storkapi.Applicat
 			// this PVC was included
 			info.Name = volumeBackup.PersistentVolumeClaim
 			info.Namespace = volumeBackup.Namespace
-			if len(objectMap) != 0 {
-				if val, present := objectMap[info]; !present || !val {
-					continue
-				}
+			if !isRestoreObjectSelected(restore, objectMap, info) {
+				continue
 			}
 
 			pvcCount++
@@ -643,8 +828,9 @@ func (a *ApplicationRestoreController) restoreVolumes(restore *storkapi.Applicat
 					log.ApplicationRestoreLog(restore).Errorf("Error downloading resources: %v", err)
 					return err
 				}
-				// Skip pv/pvc if replacepolicy is set to retain to avoid creating
-				if restore.Spec.ReplacePolicy == storkapi.ApplicationRestoreReplacePolicyRetain {
+				// Skip pv/pvc if replacepolicy is set to retain or merge to avoid creating
+				if restore.Spec.ReplacePolicy == storkapi.ApplicationRestoreReplacePolicyRetain ||
+					restore.Spec.ReplacePolicy == storkapi.ApplicationRestoreReplacePolicyMerge {
 					backupVolInfos, existingRestoreVolInfos, err = a.skipVolumesFromRestoreList(restore, objects, driver, vInfos)
 					if err != nil {
 						log.ApplicationRestoreLog(restore).Errorf("Error while checking pvcs: %v", err)
@@ -667,10 +853,18 @@ func (a *ApplicationRestoreController) restoreVolumes(restore *storkapi.Applicat
 
 				// Pre-delete resources for CSI driver
 				if (driverName == "csi" || driverName == "kdmp") && restore.Spec.ReplacePolicy == storkapi.ApplicationRestoreReplacePolicyDelete {
-					objectMap := storkapi.CreateObjectsMap(restore.Spec.IncludeResources)
+					objectMap, err := getRestoreObjectsMap(restore, objects)
+					if err != nil {
+						return err
+					}
 					objectBasedOnIncludeResources := make([]runtime.Unstructured, 0)
 					var opts resourcecollector.Options
 					for _, o := range objects {
+						if selected, err := isRestoreResourceSelected(restore, objectMap, o); err != nil {
+							return err
+						} else if !selected {
+							continue
+						}
 						skip, err := a.resourceCollector.PrepareResourceForApply(
 							o,
 							objects,
@@ -1010,6 +1204,165 @@ func (a *ApplicationRestoreController) restoreVolumes(restore *storkapi.Applicat
 	return nil
 }
 
+// getRestoredNamespaces returns the namespaces the resources are restored to
+func getRestoredNamespaces(restore *storkapi.ApplicationRestore) []string {
+	namespaces := make([]string, 0, len(restore.Spec.NamespaceMapping))
+	for _, ns := range restore.Spec.NamespaceMapping {
+		namespaces = append(namespaces, ns)
+	}
+	sort.Strings(namespaces)
+	return namespaces
+}
+
+// runPreExecRule runs the PreExecRule once the pods selected by it in the
+// restored namespaces are ready and moves the restore on to the PostExecRule.
+// The background actions of the rule keep running until the restore reaches
+// its final state.
+func (a *ApplicationRestoreController) runPreExecRule(restore *storkapi.ApplicationRestore) error {
+	r, err := storkops.Instance().GetRule(restore.Spec.PreExecRule, restore.Namespace)
+	if err != nil {
+		return err
+	}
+	namespaces := getRestoredNamespaces(restore)
+	// The rule has already been run if the restore couldn't be updated
+	// afterwards
+	if !a.hasRuleTerminationChannels(restore) {
+		ready, err := a.waitForRulePods(restore, r, namespaces)
+		if err != nil || !ready {
+			return err
+		}
+
+		a.setKind(restore)
+		terminationChannels := make([]chan bool, 0)
+		for _, ns := range namespaces {
+			ch, err := rule.ExecuteRule(r, rule.PreExecRule, restore, ns)
+			if err != nil {
+				for _, channel := range terminationChannels {
+					channel <- true
+				}
+				return fmt.Errorf("error executing PreExecRule for namespace %v: %v", ns, err)
+			}
+			if ch != nil {
+				terminationChannels = append(terminationChannels, ch)
+			}
+		}
+		a.setRuleTerminationChannels(restore, terminationChannels)
+	}
+
+	// Get the latest object since the rules engine could have updated
+	// annotations
+	if err := a.client.Get(context.TODO(), runtimeclient.ObjectKeyFromObject(restore), restore); err != nil {
+		return err
+	}
+	if restore.Spec.PostExecRule == "" {
+		return a.completeRestore(restore)
+	}
+	restore.Status.Stage = storkapi.ApplicationRestoreStagePostExecRule
+	restore.Status.Status = storkapi.ApplicationRestoreStatusInProgress
+	restore.Status.Reason = "Waiting for pods to be ready to run the PostExecRule"
+	restore.Status.LastUpdateTimestamp = metav1.Now()
+	return a.client.Update(context.TODO(), restore)
+}
+
+// runPostExecRule runs the PostExecRule once the pods selected by it in the
+// restored namespaces are ready and completes the restore. The rule is run
+// anyway if the pods aren't ready within postExecRuleReadyTimeout.
+func (a *ApplicationRestoreController) runPostExecRule(restore *storkapi.ApplicationRestore) error {
+	r, err := storkops.Instance().GetRule(restore.Spec.PostExecRule, restore.Namespace)
+	if err != nil {
+		return err
+	}
+	namespaces := getRestoredNamespaces(restore)
+	ready, err := a.waitForRulePods(restore, r, namespaces)
+	if err != nil || !ready {
+		return err
+	}
+
+	a.setKind(restore)
+	for _, ns := range namespaces {
+		_, err = rule.ExecuteRule(r, rule.PostExecRule, restore, ns)
+		if err != nil {
+			return fmt.Errorf("error executing PostExecRule for namespace %v: %v", ns, err)
+		}
+	}
+	// Get the latest object since the rules engine could have updated
+	// annotations
+	if err := a.client.Get(context.TODO(), runtimeclient.ObjectKeyFromObject(restore), restore); err != nil {
+		return err
+	}
+	return a.completeRestore(restore)
+}
+
+// waitForRulePods returns true once the pods selected by the rule in the
+// namespaces are ready, or once ruleReadyTimeout has passed since the restore
+// moved to the current stage
+func (a *ApplicationRestoreController) waitForRulePods(
+	restore *storkapi.ApplicationRestore,
+	r *storkapi.Rule,
+	namespaces []string,
+) (bool, error) {
+	ready, err := rulePodsReady(r, namespaces)
+	if err != nil {
+		return false, err
+	}
+	if !ready {
+		if time.Since(restore.Status.LastUpdateTimestamp.Time) < ruleReadyTimeout {
+			log.ApplicationRestoreLog(restore).Infof("Waiting for pods to be ready to run rule %v", r.Name)
+			return false, nil
+		}
+		log.ApplicationRestoreLog(restore).Warnf("Timed out waiting for pods to be ready, running rule %v", r.Name)
+	}
+	return true, nil
+}
+
+func (a *ApplicationRestoreController) hasRuleTerminationChannels(restore *storkapi.ApplicationRestore) bool {
+	a.terminationLock.Lock()
+	defer a.terminationLock.Unlock()
+	_, ok := a.terminationChannels[string(restore.UID)]
+	return ok
+}
+
+func (a *ApplicationRestoreController) setRuleTerminationChannels(restore *storkapi.ApplicationRestore, channels []chan bool) {
+	a.terminationLock.Lock()
+	defer a.terminationLock.Unlock()
+	a.terminationChannels[string(restore.UID)] = channels
+}
+
+// terminateRuleActions stops the background actions of the PreExecRule of
+// the restore
+func (a *ApplicationRestoreController) terminateRuleActions(restore *storkapi.ApplicationRestore) {
+	a.terminationLock.Lock()
+	defer a.terminationLock.Unlock()
+	for _, channel := range a.terminationChannels[string(restore.UID)] {
+		channel <- true
+	}
+	delete(a.terminationChannels, string(restore.UID))
+}
+
+// rulePodsReady returns true if every item of the rule selects at least one
+// pod in the namespaces and all the selected pods are ready
+func rulePodsReady(r *storkapi.Rule, namespaces []string) (bool, error) {
+	for _, item := range r.Rules {
+		found := false
+		for _, ns := range namespaces {
+			pods, err := core.Instance().GetPods(ns, item.PodSelector)
+			if err != nil {
+				return false, err
+			}
+			for _, pod := range pods.Items {
+				if !core.Instance().IsPodReady(pod) {
+					return false, nil
+				}
+				found = true
+			}
+		}
+		if !found {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
 func (a *ApplicationRestoreController) downloadObject(
 	backup *storkapi.ApplicationBackup,
 	backupLocation string,
@@ -1017,6 +1370,25 @@ func (a *ApplicationRestoreController) downloadObject(
 	objectName string,
 	skipIfNotPresent bool,
 ) ([]byte, error) {
+	reader, err := a.openObject(backup, backupLocation, namespace, objectName, skipIfNotPresent)
+	if err != nil || reader == nil {
+		return nil, err
+	}
+	defer reader.Close()
+
+	return io.ReadAll(reader)
+}
+
+// openObject returns a reader that streams the decrypted object from the
+// backup location. A nil reader is returned if skipIfNotPresent is set and
+// the object doesn't exist.
+func (a *ApplicationRestoreController) openObject(
+	backup *storkapi.ApplicationBackup,
+	backupLocation string,
+	namespace string,
+	objectName string,
+	skipIfNotPresent bool,
+) (io.ReadCloser, error) {
 	restoreLocation, err := storkops.Instance().GetBackupLocation(backup.Spec.BackupLocation, namespace)
 	if err != nil {
 		return nil, err
@@ -1034,23 +1406,53 @@ func (a *ApplicationRestoreController) downloadObject(
 		}
 	}
 
-	data, err := bucket.ReadAll(context.TODO(), filepath.Join(objectPath, objectName))
+	return backupobject.NewReader(bucket, restoreLocation, filepath.Join(objectPath, objectName))
+}
+
+// verifyBackupIntegrity checks the objects of the backup against its manifest
+// before any resources are applied. The restore is failed if the backup is
+// corrupt. Backups without a manifest are restored with a warning.
+func (a *ApplicationRestoreController) verifyBackupIntegrity(
+	restore *storkapi.ApplicationRestore,
+	backup *storkapi.ApplicationBackup,
+) (bool, error) {
+	restoreLocation, err := storkops.Instance().GetBackupLocation(backup.Spec.BackupLocation, restore.Namespace)
 	if err != nil {
-		return nil, err
+		return false, err
 	}
-	if restoreLocation.Location.EncryptionKey != "" {
-		return nil, fmt.Errorf("EncryptionKey is deprecated, use EncryptionKeyV2 instead")
+	bucket, err := objectstore.GetBucket(restoreLocation)
+	if err != nil {
+		return false, err
 	}
-	if restoreLocation.Location.EncryptionV2Key != "" {
-		var decryptData []byte
-		if decryptData, err = crypto.Decrypt(data, restoreLocation.Location.EncryptionV2Key); err != nil {
-			logrus.Errorf("ApplicationRestoreController/downloadObject: decrypt failed :%v, returing data direclty", err)
-			return data, nil
-		}
-		return decryptData, nil
+	defer bucket.Close()
+	integrity, reason, err := backupobject.VerifyObjects(bucket, restoreLocation, backup.Status.BackupPath)
+	if err != nil {
+		return false, fmt.Errorf("error verifying backup objects: %v", err)
 	}
-
-	return data, nil
+	restore.Status.BackupIntegrity = integrity
+	switch integrity {
+	case storkapi.ApplicationBackupIntegrityCorrupt:
+		message := fmt.Sprintf("Backup %v failed integrity verification: %v", backup.Name, reason)
+		log.ApplicationRestoreLog(restore).Errorf(message)
+		a.recorder.Event(restore,
+			v1.EventTypeWarning,
+			string(storkapi.ApplicationRestoreStatusFailed),
+			message)
+		restore.Status.Stage = storkapi.ApplicationRestoreStageFinal
+		restore.Status.Status = storkapi.ApplicationRestoreStatusFailed
+		restore.Status.Reason = message
+		restore.Status.FinishTimestamp = metav1.Now()
+		restore.Status.LastUpdateTimestamp = metav1.Now()
+		return false, a.client.Update(context.TODO(), restore)
+	case storkapi.ApplicationBackupIntegrityUnverified:
+		message := fmt.Sprintf("Backup %v doesn't have a manifest, restoring resources without verifying them", backup.Name)
+		log.ApplicationRestoreLog(restore).Warnf(message)
+		a.recorder.Event(restore,
+			v1.EventTypeWarning,
+			string(storkapi.ApplicationBackupIntegrityUnverified),
+			message)
+	}
+	return true, nil
 }
 
 func (a *ApplicationRestoreController) downloadResources(
@@ -1062,13 +1464,24 @@ func (a *ApplicationRestoreController) downloadResources(
 	if err := a.downloadCRD(backup, backupLocation, namespace); err != nil {
 		return nil, fmt.Errorf("error downloading CRDs: %v", err)
 	}
-	data, err := a.downloadObject(backup, backupLocation, namespace, resourceObjectName, false)
+	return a.readResources(backup, backupLocation, namespace)
+}
+
+// readResources reads the resources of the backup without registering its
+// CRDs
+func (a *ApplicationRestoreController) readResources(
+	backup *storkapi.ApplicationBackup,
+	backupLocation string,
+	namespace string,
+) ([]runtime.Unstructured, error) {
+	reader, err := a.openObject(backup, backupLocation, namespace, resourceObjectName, false)
 	if err != nil {
 		return nil, err
 	}
+	defer reader.Close()
 
 	objects := make([]*unstructured.Unstructured, 0)
-	if err = json.Unmarshal(data, &objects); err != nil {
+	if err = json.NewDecoder(reader).Decode(&objects); err != nil {
 		return nil, err
 	}
 	runtimeObjects := make([]runtime.Unstructured, 0)
@@ -1171,6 +1584,20 @@ func (a *ApplicationRestoreController) updateResourceStatus(
 	status storkapi.ApplicationRestoreStatusType,
 	reason string,
 	tempResourceList []*storkapi.ApplicationRestoreResourceInfo,
+) ([]*storkapi.ApplicationRestoreResourceInfo, error) {
+	return a.updateResourceStatusWithConflicts(restore, object, status, reason, nil, tempResourceList)
+}
+
+// updateResourceStatusWithConflicts updates the status of the resource along
+// with the fields that were in conflict when merging it into an existing
+// resource
+func (a *ApplicationRestoreController) updateResourceStatusWithConflicts(
+	restore *storkapi.ApplicationRestore,
+	object runtime.Unstructured,
+	status storkapi.ApplicationRestoreStatusType,
+	reason string,
+	conflicts []string,
+	tempResourceList []*storkapi.ApplicationRestoreResourceInfo,
 ) ([]*storkapi.ApplicationRestoreResourceInfo, error) {
 	var updatedResource *storkapi.ApplicationRestoreResourceInfo
 	gkv := object.GetObjectKind().GroupVersionKind()
@@ -1212,6 +1639,7 @@ func (a *ApplicationRestoreController) updateResourceStatus(
 
 	updatedResource.Status = status
 	updatedResource.Reason = reason
+	updatedResource.Conflicts = conflicts
 	eventType := v1.EventTypeNormal
 	if status == storkapi.ApplicationRestoreStatusFailed {
 		eventType = v1.EventTypeWarning
@@ -1418,7 +1846,7 @@ func (a *ApplicationRestoreController) skipVolumesFromRestoreList(
 		restoreVolInfo.RestoreVolume = pvc.Spec.VolumeName
 		restoreVolInfo.TotalSize = bkupVolInfo.TotalSize
 		restoreVolInfo.Zones = zones
-		restoreVolInfo.Reason = fmt.Sprintf("Skipped from volume restore as policy is set to %s and pvc already exists", storkapi.ApplicationRestoreReplacePolicyRetain)
+		restoreVolInfo.Reason = fmt.Sprintf("Skipped from volume restore as policy is set to %s and pvc already exists", restore.Spec.ReplacePolicy)
 		existingInfos = append(existingInfos, restoreVolInfo)
 	}
 
@@ -1566,7 +1994,10 @@ func (a *ApplicationRestoreController) applyResources(
 	if err != nil {
 		return err
 	}
-	objectMap := storkapi.CreateObjectsMap(restore.Spec.IncludeResources)
+	objectMap, err := getRestoreObjectsMap(restore, objects)
+	if err != nil {
+		return err
+	}
 	tempObjects := make([]runtime.Unstructured, 0)
 	var opts resourcecollector.Options
 	if len(restore.Spec.RancherProjectMapping) != 0 {
@@ -1601,6 +2032,13 @@ func (a *ApplicationRestoreController) applyResources(
 			updateCr <- utils.UpdateRestoreCrTimestampInPrepareResourcePath
 			startTime = time.Now()
 		}
+		selected, err := isRestoreResourceSelected(restore, objectMap, o)
+		if err != nil {
+			return err
+		}
+		if !selected {
+			continue
+		}
 		skip, err := a.resourceCollector.PrepareResourceForApply(
 			o,
 			objects,
@@ -1623,6 +2061,15 @@ func (a *ApplicationRestoreController) applyResources(
 	}
 	objects = tempObjects
 
+	transform, err := getResourceTransformation(restore.Spec.TransformSpecs, restore.Namespace)
+	if err != nil {
+		return err
+	}
+	transformed, err := transformResources(transform, objects)
+	if err != nil {
+		return err
+	}
+
 	// skip CSI PV/PVCs before applying
 	objects, err = a.removeCSIVolumesBeforeApply(restore, objects)
 	if err != nil {
@@ -1671,18 +2118,30 @@ func (a *ApplicationRestoreController) applyResources(
 
 		log.ApplicationRestoreLog(restore).Infof("Applying %v %v/%v", objectType.GetKind(), metadata.GetNamespace(), metadata.GetName())
 		retained := false
+		var conflicts []string
 
-		err = a.resourceCollector.ApplyResource(
-			a.dynamicInterface,
-			o,
-			&opts,
-		)
+		// PVs and PVCs aren't merged as the existing volumes are retained
+		if restore.Spec.ReplacePolicy == storkapi.ApplicationRestoreReplacePolicyMerge &&
+			objectType.GetKind() != "PersistentVolume" && objectType.GetKind() != "PersistentVolumeClaim" {
+			conflicts, err = a.resourceCollector.MergeResource(
+				a.dynamicInterface,
+				o,
+				&opts,
+				false,
+			)
+		} else {
+			err = a.resourceCollector.ApplyResource(
+				a.dynamicInterface,
+				o,
+				&opts,
+			)
+		}
 		if err != nil && k8s_errors.IsAlreadyExists(err) {
 			switch restore.Spec.ReplacePolicy {
 			case storkapi.ApplicationRestoreReplacePolicyDelete:
 				log.ApplicationRestoreLog(restore).Errorf("Error deleting %v %v during restore: %v", objectType.GetKind(), metadata.GetName(), err)
-			case storkapi.ApplicationRestoreReplacePolicyRetain:
-				log.ApplicationRestoreLog(restore).Warningf("Error deleting %v %v during restore, ReplacePolicy set to Retain: %v", objectType.GetKind(), metadata.GetName(), err)
+			case storkapi.ApplicationRestoreReplacePolicyRetain, storkapi.ApplicationRestoreReplacePolicyMerge:
+				log.ApplicationRestoreLog(restore).Warningf("Error deleting %v %v during restore, ReplacePolicy set to %v: %v", objectType.GetKind(), metadata.GetName(), restore.Spec.ReplacePolicy, err)
 				retained = true
 				err = nil
 			}
@@ -1702,7 +2161,19 @@ func (a *ApplicationRestoreController) applyResources(
 				restore,
 				o,
 				storkapi.ApplicationRestoreStatusRetained,
-				"Resource restore skipped as it was already present and ReplacePolicy is set to Retain",
+				fmt.Sprintf("Resource restore skipped as it was already present and ReplacePolicy is set to %v", restore.Spec.ReplacePolicy),
+				tempResourceList,
+			); err != nil {
+				return err
+			}
+		} else if len(conflicts) != 0 {
+			log.ApplicationRestoreLog(restore).Warningf("Merged %v %v/%v with conflicts: %v", objectType.GetKind(), metadata.GetNamespace(), metadata.GetName(), conflicts)
+			if tempResourceList, err = a.updateResourceStatusWithConflicts(
+				restore,
+				o,
+				storkapi.ApplicationRestoreStatusSuccessful,
+				"Resource merged into the existing resource, conflicting fields managed by others were overwritten",
+				conflicts,
 				tempResourceList,
 			); err != nil {
 				return err
@@ -1723,6 +2194,11 @@ func (a *ApplicationRestoreController) applyResources(
 	// By replacing it can lose the previously added resources.
 	restore.Status.Resources = append(restore.Status.Resources, tempResourceList...)
 	restore.Status.RestoredResourceCount = len(restore.Status.Resources)
+	for _, resource := range restore.Status.Resources {
+		if transformedBy := getTransformedBy(transformed, resource.ObjectInfo); transformedBy != "" {
+			resource.TransformedBy = transformedBy
+		}
+	}
 
 	return nil
 }
@@ -1770,7 +2246,7 @@ func (a *ApplicationRestoreController) restoreResources(
 	updateCr chan int,
 ) error {
 	fn := "restoreResources"
-	backup, err := storkops.Instance().GetApplicationBackup(restore.Spec.BackupName, restore.Namespace)
+	backup, err := storkops.Instance().GetApplicationBackup(restore.GetBackupName(), restore.Namespace)
 	if err != nil {
 		log.ApplicationRestoreLog(restore).Errorf("Error getting backup: %v", err)
 		return err
@@ -1783,6 +2259,10 @@ func (a *ApplicationRestoreController) restoreResources(
 
 	doCleanup := true
 	if !nfs {
+		verified, err := a.verifyBackupIntegrity(restore, backup)
+		if err != nil || !verified {
+			return err
+		}
 		objects, err := a.downloadResources(backup, restore.Spec.BackupLocation, restore.Namespace)
 		if err != nil {
 			log.ApplicationRestoreLog(restore).Errorf("Error downloading resources: %v", err)
@@ -1957,6 +2437,28 @@ func (a *ApplicationRestoreController) restoreResources(
 	// Let's accomodate the PV-PVC counts in RestoredResourceCount, specifically for CSI & kdmp case.
 	restore.Status.RestoredResourceCount = restore.Status.ResourceCount
 
+	// The restore is completed once the rules have been run on the restored
+	// pods
+	if restore.Spec.PreExecRule != "" {
+		restore.Status.Stage = storkapi.ApplicationRestoreStagePreExecRule
+		restore.Status.Status = storkapi.ApplicationRestoreStatusInProgress
+		restore.Status.Reason = "Waiting for pods to be ready to run the PreExecRule"
+		restore.Status.LastUpdateTimestamp = metav1.Now()
+		return a.client.Update(context.TODO(), restore)
+	}
+	if restore.Spec.PostExecRule != "" {
+		restore.Status.Stage = storkapi.ApplicationRestoreStagePostExecRule
+		restore.Status.Status = storkapi.ApplicationRestoreStatusInProgress
+		restore.Status.Reason = "Waiting for pods to be ready to run the PostExecRule"
+		restore.Status.LastUpdateTimestamp = metav1.Now()
+		return a.client.Update(context.TODO(), restore)
+	}
+	return a.completeRestore(restore)
+}
+
+// completeRestore moves the restore to the final stage once the volumes and
+// resources have been restored
+func (a *ApplicationRestoreController) completeRestore(restore *storkapi.ApplicationRestore) error {
 	restore.Status.Stage = storkapi.ApplicationRestoreStageFinal
 	restore.Status.FinishTimestamp = metav1.Now()
 	restore.Status.Status = storkapi.ApplicationRestoreStatusSuccessful
@@ -2004,6 +2506,7 @@ func (a *ApplicationRestoreController) restoreResources(
 		log.ApplicationRestoreLog(restore).Infof("completed applying resources but failed to update restore CR: %v", err)
 		return err
 	}
+	a.terminateRuleActions(restore)
 
 	return nil
 }
@@ -0,0 +1,253 @@
+//go:build unittest
+// +build unittest
+
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	storkapi "github.com/libopenstorage/stork/pkg/apis/stork/v1alpha1"
+	fakeclient "github.com/libopenstorage/stork/pkg/client/clientset/versioned/fake"
+	"github.com/libopenstorage/stork/pkg/k8sutils"
+	"github.com/portworx/sched-ops/k8s/core"
+	"github.com/portworx/sched-ops/k8s/dynamic"
+	storkops "github.com/portworx/sched-ops/k8s/stork"
+	"github.com/stretchr/testify/require"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	fakedynamicclient "k8s.io/client-go/dynamic/fake"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/record"
+	runtimeclient "sigs.k8s.io/controller-runtime/pkg/client"
+	fakeruntimeclient "sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+const (
+	testPreExecRule  = "pre-exec"
+	testPostExecRule = "post-exec"
+)
+
+// setupTestRestoreClients sets up the fake clients used by the restore
+// controller with the backup of the restore and the rules
+func setupTestRestoreClients(t *testing.T, objects ...runtime.Object) *fake.Clientset {
+	kubeClient := fake.NewSimpleClientset(
+		&v1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      k8sutils.StorkControllerConfigMapName,
+				Namespace: "kube-system",
+			},
+		},
+		&v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "ns"}},
+	)
+	core.SetInstance(core.New(kubeClient))
+
+	location := newLocalBackupLocation(t, "location", "")
+	backup := newReplicatedBackup(location)
+	backup.Spec.Namespaces = []string{"ns"}
+	storkObjects := []runtime.Object{location, backup}
+	for _, name := range []string{testPreExecRule, testPostExecRule} {
+		storkObjects = append(storkObjects, &storkapi.Rule{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: "ns",
+			},
+			Rules: []storkapi.RuleItem{
+				{PodSelector: map[string]string{"app": "db"}},
+			},
+		})
+	}
+	storkops.SetInstance(storkops.New(kubeClient, fakeclient.NewSimpleClientset(append(storkObjects, objects...)...), nil))
+
+	// The rules engine updates the restores through the dynamic client
+	scheme := runtime.NewScheme()
+	require.NoError(t, storkapi.AddToScheme(scheme))
+	dynamicObjects := make([]runtime.Object, 0, len(objects))
+	for _, object := range objects {
+		data, err := json.Marshal(object)
+		require.NoError(t, err)
+		dynamicObject := &unstructured.Unstructured{}
+		require.NoError(t, dynamicObject.UnmarshalJSON(data))
+		dynamicObjects = append(dynamicObjects, dynamicObject)
+	}
+	dynamic.SetInstance(dynamic.New(fakedynamicclient.NewSimpleDynamicClient(scheme, dynamicObjects...)))
+	return kubeClient
+}
+
+func newTestRuleRestore(stage storkapi.ApplicationRestoreStageType) *storkapi.ApplicationRestore {
+	return &storkapi.ApplicationRestore{
+		TypeMeta: metav1.TypeMeta{
+			Kind:       "ApplicationRestore",
+			APIVersion: storkapi.SchemeGroupVersion.String(),
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "restore",
+			Namespace: "ns",
+			UID:       "restore-uid",
+		},
+		Spec: storkapi.ApplicationRestoreSpec{
+			BackupName:       "backup",
+			BackupLocation:   "location",
+			NamespaceMapping: map[string]string{"ns": "ns"},
+			ReplacePolicy:    storkapi.ApplicationRestoreReplacePolicyRetain,
+			PreExecRule:      testPreExecRule,
+			PostExecRule:     testPostExecRule,
+		},
+		Status: storkapi.ApplicationRestoreStatus{
+			Stage:               stage,
+			Status:              storkapi.ApplicationRestoreStatusInProgress,
+			LastUpdateTimestamp: metav1.Now(),
+		},
+	}
+}
+
+func newTestRestoreController(t *testing.T, restore *storkapi.ApplicationRestore) *ApplicationRestoreController {
+	scheme := runtime.NewScheme()
+	require.NoError(t, storkapi.AddToScheme(scheme))
+	return &ApplicationRestoreController{
+		client:              fakeruntimeclient.NewClientBuilder().WithScheme(scheme).WithObjects(restore).Build(),
+		recorder:            record.NewFakeRecorder(10),
+		terminationChannels: make(map[string][]chan bool),
+	}
+}
+
+func createTestRulePod(t *testing.T, kubeClient *fake.Clientset, ready bool) {
+	_, err := kubeClient.CoreV1().Pods("ns").Create(context.TODO(), &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "db",
+			Namespace: "ns",
+			Labels:    map[string]string{"app": "db"},
+		},
+		Status: v1.PodStatus{
+			Phase: v1.PodRunning,
+			ContainerStatuses: []v1.ContainerStatus{
+				{
+					Name:  "db",
+					Ready: ready,
+					State: v1.ContainerState{Running: &v1.ContainerStateRunning{}},
+				},
+			},
+		},
+	}, metav1.CreateOptions{})
+	require.NoError(t, err)
+}
+
+// handleTestRestore runs the restore through the controller and returns the
+// restore as saved by it
+func handleTestRestore(t *testing.T, a *ApplicationRestoreController, restore *storkapi.ApplicationRestore) *storkapi.ApplicationRestore {
+	updated := &storkapi.ApplicationRestore{}
+	require.NoError(t, a.client.Get(context.TODO(), runtimeclient.ObjectKeyFromObject(restore), updated))
+	require.NoError(t, a.handle(context.TODO(), updated, make(chan int, 10)), "Error handling restore")
+	require.NoError(t, a.client.Get(context.TODO(), runtimeclient.ObjectKeyFromObject(restore), updated))
+	return updated
+}
+
+func TestRestoreExecRules(t *testing.T) {
+	kubeClient := setupTestRestoreClients(t)
+	createTestRulePod(t, kubeClient, true)
+	restore := newTestRuleRestore(storkapi.ApplicationRestoreStagePreExecRule)
+	a := newTestRestoreController(t, restore)
+
+	restore = handleTestRestore(t, a, restore)
+	require.Equal(t, storkapi.ApplicationRestoreStagePostExecRule, restore.Status.Stage, restore.Status.Reason)
+	require.Equal(t, storkapi.ApplicationRestoreStatusInProgress, restore.Status.Status)
+	require.True(t, a.hasRuleTerminationChannels(restore), "PreExecRule should be tracked until the restore completes")
+
+	restore = handleTestRestore(t, a, restore)
+	require.Equal(t, storkapi.ApplicationRestoreStageFinal, restore.Status.Stage, restore.Status.Reason)
+	require.Equal(t, storkapi.ApplicationRestoreStatusSuccessful, restore.Status.Status)
+	require.False(t, a.hasRuleTerminationChannels(restore), "PreExecRule should be terminated once the restore completes")
+}
+
+func TestRestoreExecRuleWaitsForPods(t *testing.T) {
+	for _, stage := range []storkapi.ApplicationRestoreStageType{
+		storkapi.ApplicationRestoreStagePreExecRule,
+		storkapi.ApplicationRestoreStagePostExecRule,
+	} {
+		t.Run(string(stage), func(t *testing.T) {
+			kubeClient := setupTestRestoreClients(t)
+			createTestRulePod(t, kubeClient, false)
+			restore := newTestRuleRestore(stage)
+			a := newTestRestoreController(t, restore)
+
+			// The rule isn't run while the pods aren't ready
+			updated := handleTestRestore(t, a, restore)
+			require.Equal(t, stage, updated.Status.Stage, updated.Status.Reason)
+			require.False(t, a.hasRuleTerminationChannels(updated), "Rule shouldn't have been run")
+
+			// The rule is run anyway once the pods haven't been ready for
+			// ruleReadyTimeout
+			updated.Status.LastUpdateTimestamp = metav1.NewTime(time.Now().Add(-ruleReadyTimeout - time.Minute))
+			require.NoError(t, a.client.Update(context.TODO(), updated))
+			updated = handleTestRestore(t, a, updated)
+			require.NotEqual(t, stage, updated.Status.Stage, "Rule should have been run after the timeout")
+			require.NotEqual(t, storkapi.ApplicationRestoreStatusFailed, updated.Status.Status, updated.Status.Reason)
+		})
+	}
+}
+
+func TestRestoreExecRuleFailure(t *testing.T) {
+	for _, stage := range []storkapi.ApplicationRestoreStageType{
+		storkapi.ApplicationRestoreStagePreExecRule,
+		storkapi.ApplicationRestoreStagePostExecRule,
+	} {
+		t.Run(string(stage), func(t *testing.T) {
+			setupTestRestoreClients(t)
+			restore := newTestRuleRestore(stage)
+			restore.Spec.PreExecRule = "missing"
+			restore.Spec.PostExecRule = "missing"
+			a := newTestRestoreController(t, restore)
+			// The background actions of the PreExecRule are stopped when the
+			// restore fails
+			terminationChannel := make(chan bool, 1)
+			a.setRuleTerminationChannels(restore, []chan bool{terminationChannel})
+
+			restore = handleTestRestore(t, a, restore)
+			require.Equal(t, storkapi.ApplicationRestoreStageFinal, restore.Status.Stage)
+			require.Equal(t, storkapi.ApplicationRestoreStatusFailed, restore.Status.Status)
+			require.Contains(t, restore.Status.Reason, "missing")
+			require.False(t, a.hasRuleTerminationChannels(restore))
+			select {
+			case terminate := <-terminationChannel:
+				require.True(t, terminate)
+			default:
+				require.Fail(t, "Background actions of the PreExecRule weren't terminated")
+			}
+		})
+	}
+}
+
+func TestRestorePerformRuleRecovery(t *testing.T) {
+	tracker, err := json.Marshal(map[string]interface{}{
+		"taskID":    "task",
+		"container": "db",
+		"pods":      []map[string]string{{"uid": "deleted-pod", "namespace": "ns"}},
+	})
+	require.NoError(t, err)
+	restore := newTestRuleRestore(storkapi.ApplicationRestoreStageFinal)
+	restore.Annotations = map[string]string{
+		"stork.libopenstorage.org/pods-with-running-cmds": string(tracker),
+	}
+	idle := newTestRuleRestore(storkapi.ApplicationRestoreStageFinal)
+	idle.Name = "idle"
+	idle.UID = "idle-uid"
+	setupTestRestoreClients(t, restore, idle)
+	a := newTestRestoreController(t, restore)
+
+	// Pods that have been deleted since the rule was run are skipped and
+	// dropped from the restore
+	require.NoError(t, a.performRuleRecovery(), "Error recovering rules")
+	recovered, err := dynamic.Instance().GetObject(restore)
+	require.NoError(t, err)
+	annotations := recovered.(metav1.Object).GetAnnotations()
+	require.NotContains(t, annotations, "stork.libopenstorage.org/pods-with-running-cmds")
+
+	// The tracker of the running commands is invalid
+	restore.Annotations["stork.libopenstorage.org/pods-with-running-cmds"] = "invalid"
+	setupTestRestoreClients(t, restore, idle)
+	require.Error(t, a.performRuleRecovery(), "Expected error parsing the running commands")
+}
@@ -0,0 +1,23 @@
+package controllers
+
+import (
+	stork_api "github.com/libopenstorage/stork/pkg/apis/stork/v1alpha1"
+	"github.com/libopenstorage/stork/pkg/backupobject"
+	"github.com/libopenstorage/stork/pkg/objectstore"
+	storkops "github.com/portworx/sched-ops/k8s/stork"
+)
+
+// addToBackupCatalog records the change to the backup in the catalog of its
+// backup location
+func addToBackupCatalog(backup *stork_api.ApplicationBackup, operation backupobject.CatalogOperation) error {
+	backupLocation, err := storkops.Instance().GetBackupLocation(backup.Spec.BackupLocation, backup.Namespace)
+	if err != nil {
+		return err
+	}
+	bucket, err := objectstore.GetBucket(backupLocation)
+	if err != nil {
+		return err
+	}
+	defer bucket.Close()
+	return backupobject.AppendCatalogEntry(bucket, backupLocation, backupobject.NewCatalogEntry(operation, backup))
+}
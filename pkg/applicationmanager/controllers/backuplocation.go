@@ -0,0 +1,393 @@
+package controllers
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	stork_api "github.com/libopenstorage/stork/pkg/apis/stork/v1alpha1"
+	"github.com/libopenstorage/stork/pkg/backupobject"
+	"github.com/libopenstorage/stork/pkg/conditions"
+	"github.com/libopenstorage/stork/pkg/crypto"
+	"github.com/libopenstorage/stork/pkg/k8sutils"
+	"github.com/libopenstorage/stork/pkg/log"
+	"github.com/libopenstorage/stork/pkg/objectstore"
+	storkops "github.com/portworx/sched-ops/k8s/stork"
+	"github.com/sirupsen/logrus"
+	"gocloud.dev/blob"
+	"gocloud.dev/gcerrors"
+	k8s_errors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+	runtimeclient "sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+)
+
+const (
+	backupLocationValidationInterval = 5 * time.Minute
+	backupLocationValidationTimeout  = 2 * time.Minute
+	// The status is updated at least this often even if the conditions
+	// haven't changed so that LastChecked doesn't get too old
+	backupLocationStatusRefreshInterval = 1 * time.Hour
+	backupLocationValidationWorkers     = 10
+	// Probe objects are written under this prefix, which can't clash with
+	// the namespace directories the backups are written to
+	backupLocationProbePrefix = ".stork-probe"
+
+	reasonValid            = "Valid"
+	reasonNotChecked       = "NotChecked"
+	reasonValidationFailed = "ValidationFailed"
+	reasonValidated        = "Validated"
+)
+
+// Error codes returned by S3 when the credentials are rejected
+var s3CredentialErrorCodes = []string{
+	"AccessDenied",
+	"ExpiredToken",
+	"InvalidAccessKeyId",
+	"InvalidToken",
+	"SignatureDoesNotMatch",
+}
+
+// NewBackupLocation creates a new instance of BackupLocationController.
+func NewBackupLocation(mgr manager.Manager, r record.EventRecorder) *BackupLocationController {
+	return &BackupLocationController{
+		client:   mgr.GetClient(),
+		recorder: r,
+	}
+}
+
+// BackupLocationController periodically validates the backup locations and
+// records the results as conditions in their status, so that a wrong key or a
+// deleted bucket shows up before a backup fails.
+type BackupLocationController struct {
+	client runtimeclient.Client
+
+	recorder    record.EventRecorder
+	stopChannel chan os.Signal
+}
+
+// Init Initializes the backup location controller
+func (b *BackupLocationController) Init(stopChannel chan os.Signal) error {
+	b.stopChannel = stopChannel
+	go b.startValidation()
+	return nil
+}
+
+func (b *BackupLocationController) startValidation() {
+	for {
+		b.validateBackupLocations()
+		select {
+		case <-time.After(backupLocationValidationInterval):
+		case <-b.stopChannel:
+			return
+		}
+	}
+}
+
+// validateBackupLocations validates all the backup locations, a few at a
+// time so that a slow objectstore doesn't hold up the others
+func (b *BackupLocationController) validateBackupLocations() {
+	// List the backup locations without the config from the secrets merged
+	// in since their status is updated
+	backupLocations := &stork_api.BackupLocationList{}
+	if err := b.client.List(context.TODO(), backupLocations); err != nil {
+		logrus.Errorf("Error getting backup locations to validate: %v", err)
+		return
+	}
+	workers := make(chan struct{}, backupLocationValidationWorkers)
+	var wg sync.WaitGroup
+	for i := range backupLocations.Items {
+		wg.Add(1)
+		workers <- struct{}{}
+		go func(backupLocation *stork_api.BackupLocation) {
+			defer func() {
+				<-workers
+				wg.Done()
+			}()
+			b.updateBackupLocationStatus(backupLocation)
+		}(&backupLocations.Items[i])
+	}
+	wg.Wait()
+}
+
+func (b *BackupLocationController) updateBackupLocationStatus(backupLocation *stork_api.BackupLocation) {
+	ctx, cancel := context.WithTimeout(context.Background(), backupLocationValidationTimeout)
+	defer cancel()
+	previous := backupLocation.Status.DeepCopy()
+	for _, condition := range validateBackupLocationWithTimeout(ctx, backupLocation) {
+		conditions.Set(&backupLocation.Status.Conditions, condition)
+	}
+	if !conditions.Changed(previous.Conditions, backupLocation.Status.Conditions) &&
+		time.Since(previous.LastChecked.Time) < backupLocationStatusRefreshInterval {
+		return
+	}
+	backupLocation.Status.LastChecked = metav1.Now()
+	err := b.client.Status().Update(context.TODO(), backupLocation)
+	if k8s_errors.IsNotFound(err) {
+		// The custom resource doesn't have the status subresource if it
+		// was registered with v1beta1
+		err = b.client.Update(context.TODO(), backupLocation)
+	}
+	if err != nil {
+		// The backup location will be validated again at the next interval
+		// if it was updated in the meantime
+		if !k8s_errors.IsConflict(err) && !k8s_errors.IsNotFound(err) {
+			log.BackupLocationLog(backupLocation).Errorf("Error updating backup location status: %v", err)
+		}
+		return
+	}
+
+	// Only raise events when the conditions that failed change
+	if message := conditions.RecordEvents(b.recorder, backupLocation, previous.Conditions, backupLocation.Status.Conditions, conditions.Events{
+		FailedReason:   reasonValidationFailed,
+		FailedMessage:  "Backup location validation failed",
+		HealthyReason:  reasonValidated,
+		HealthyMessage: "Backup location validated successfully",
+	}); message != "" {
+		log.BackupLocationLog(backupLocation).Warnf(message)
+	}
+}
+
+// validateBackupLocationWithTimeout validates the backup location and reports
+// all the conditions as unknown if it doesn't finish before ctx is done. Not
+// all the objectstore calls take a context so the validation is left to
+// finish in the background.
+func validateBackupLocationWithTimeout(
+	ctx context.Context,
+	backupLocation *stork_api.BackupLocation,
+) []stork_api.Condition {
+	result := make(chan []stork_api.Condition, 1)
+	go func() {
+		result <- validateBackupLocation(ctx, backupLocation)
+	}()
+	select {
+	case results := <-result:
+		return results
+	case <-ctx.Done():
+		message := fmt.Sprintf("Validation did not finish in %v", backupLocationValidationTimeout)
+		results := make([]stork_api.Condition, 0)
+		for _, conditionType := range []stork_api.ConditionType{
+			stork_api.BackupLocationConditionCredentials,
+			stork_api.BackupLocationConditionBucket,
+			stork_api.BackupLocationConditionReadWrite,
+			stork_api.BackupLocationConditionEncryptionKey,
+			stork_api.BackupLocationConditionObjectLock,
+		} {
+			results = append(results, conditions.New(conditionType, metav1.ConditionUnknown, "Timeout", message))
+		}
+		return results
+	}
+}
+
+// validateBackupLocation checks the credentials, bucket, permissions,
+// encryption keys and object lock settings of the backup location. Checks
+// that depend on a failed one are reported as unknown.
+func validateBackupLocation(ctx context.Context, backupLocation *stork_api.BackupLocation) []stork_api.Condition {
+	notChecked := func(conditionType stork_api.ConditionType, message string) stork_api.Condition {
+		return conditions.New(conditionType, metav1.ConditionUnknown, reasonNotChecked, message)
+	}
+
+	// Get the backup location with the config from the secrets merged in
+	location, err := storkops.Instance().GetBackupLocation(backupLocation.Name, backupLocation.Namespace)
+	if err != nil {
+		message := "Credentials could not be resolved"
+		return []stork_api.Condition{
+			conditions.New(stork_api.BackupLocationConditionCredentials, metav1.ConditionFalse, "SecretError", err.Error()),
+			notChecked(stork_api.BackupLocationConditionBucket, message),
+			notChecked(stork_api.BackupLocationConditionReadWrite, message),
+			notChecked(stork_api.BackupLocationConditionEncryptionKey, message),
+			notChecked(stork_api.BackupLocationConditionObjectLock, message),
+		}
+	}
+
+	results := []stork_api.Condition{validateEncryptionKey(location)}
+	bucket, err := objectstore.GetBucket(location)
+	if err != nil {
+		if location.Location.Type == stork_api.BackupLocationNFS {
+			message := fmt.Sprintf("NFS export could not be mounted in stork: %v", err)
+			return append(results,
+				notChecked(stork_api.BackupLocationConditionCredentials, message),
+				notChecked(stork_api.BackupLocationConditionBucket, message),
+				notChecked(stork_api.BackupLocationConditionReadWrite, message),
+				notChecked(stork_api.BackupLocationConditionObjectLock, message),
+			)
+		}
+		message := "Bucket could not be opened"
+		return append(results,
+			conditions.New(stork_api.BackupLocationConditionCredentials, metav1.ConditionFalse, "InvalidConfig", err.Error()),
+			notChecked(stork_api.BackupLocationConditionBucket, message),
+			notChecked(stork_api.BackupLocationConditionReadWrite, message),
+			notChecked(stork_api.BackupLocationConditionObjectLock, message),
+		)
+	}
+	defer bucket.Close()
+
+	credentials, bucketCondition := validateBucket(ctx, bucket)
+	results = append(results, credentials, bucketCondition)
+	if bucketCondition.Status != metav1.ConditionTrue {
+		message := "Bucket is not available"
+		return append(results,
+			notChecked(stork_api.BackupLocationConditionReadWrite, message),
+			notChecked(stork_api.BackupLocationConditionObjectLock, message),
+		)
+	}
+
+	objectLock, lockEnabled := validateObjectLock(location)
+	results = append(results, objectLock)
+	if lockEnabled {
+		return append(results, notChecked(stork_api.BackupLocationConditionReadWrite,
+			"Probe object is not written to buckets with object lock since it can't be deleted"))
+	}
+	return append(results, validateReadWrite(ctx, bucket, location))
+}
+
+// validateEncryptionKey checks that the active encryption key of the backup
+// location can be found and used to encrypt and decrypt data
+func validateEncryptionKey(backupLocation *stork_api.BackupLocation) stork_api.Condition {
+	invalid := func(reason string, message string) stork_api.Condition {
+		return conditions.New(stork_api.BackupLocationConditionEncryptionKey, metav1.ConditionFalse, reason, message)
+	}
+	if backupLocation.Location.EncryptionKey != "" {
+		return invalid("Deprecated", "EncryptionKey is deprecated, use EncryptionKeyV2 instead")
+	}
+	keyID, encryptionKey, err := backupLocation.GetActiveEncryptionKey()
+	if err != nil {
+		return invalid("KeyNotFound", err.Error())
+	}
+	if encryptionKey == "" {
+		return conditions.New(stork_api.BackupLocationConditionEncryptionKey, metav1.ConditionTrue,
+			"NotEncrypted", "Backups are not encrypted")
+	}
+
+	probe := []byte(backupLocation.Namespace + "/" + backupLocation.Name)
+	var encrypted bytes.Buffer
+	writer, err := crypto.NewKeyEncryptWriter(&encrypted, keyID, encryptionKey)
+	if err == nil {
+		if _, err = writer.Write(probe); err == nil {
+			err = writer.Close()
+		}
+	}
+	if err != nil {
+		return invalid("EncryptFailed", err.Error())
+	}
+	reader, err := crypto.NewKeyringDecryptReader(&encrypted, objectstore.GetEncryptionKeyring(backupLocation))
+	if err != nil {
+		return invalid("DecryptFailed", err.Error())
+	}
+	decrypted, err := io.ReadAll(reader)
+	if err != nil {
+		return invalid("DecryptFailed", err.Error())
+	}
+	if !bytes.Equal(decrypted, probe) {
+		return invalid("DecryptFailed", "decrypted data doesn't match the encrypted data")
+	}
+	message := "Backups are encrypted"
+	if keyID != "" {
+		message = fmt.Sprintf("Backups are encrypted with key %v", keyID)
+	}
+	return conditions.New(stork_api.BackupLocationConditionEncryptionKey, metav1.ConditionTrue, reasonValid, message)
+}
+
+// validateBucket lists the bucket to check that it exists and that the
+// credentials are accepted
+func validateBucket(ctx context.Context, bucket *blob.Bucket) (stork_api.Condition, stork_api.Condition) {
+	iterator := bucket.List(&blob.ListOptions{Prefix: backupLocationProbePrefix})
+	_, err := iterator.Next(ctx)
+	if err == nil || err == io.EOF {
+		return conditions.New(stork_api.BackupLocationConditionCredentials, metav1.ConditionTrue, reasonValid, ""),
+			conditions.New(stork_api.BackupLocationConditionBucket, metav1.ConditionTrue, reasonValid, "")
+	}
+
+	var awsErr awserr.Error
+	isAWSError := bucket.ErrorAs(err, &awsErr)
+	if gcerrors.Code(err) == gcerrors.PermissionDenied || (isAWSError && isS3CredentialError(awsErr)) {
+		return conditions.New(stork_api.BackupLocationConditionCredentials, metav1.ConditionFalse, "Unauthorized", err.Error()),
+			conditions.New(stork_api.BackupLocationConditionBucket, metav1.ConditionUnknown, reasonNotChecked, "Credentials were rejected")
+	}
+	if gcerrors.Code(err) == gcerrors.NotFound || (isAWSError && awsErr.Code() == "NoSuchBucket") {
+		return conditions.New(stork_api.BackupLocationConditionCredentials, metav1.ConditionTrue, reasonValid, ""),
+			conditions.New(stork_api.BackupLocationConditionBucket, metav1.ConditionFalse, "NotFound", err.Error())
+	}
+	return conditions.New(stork_api.BackupLocationConditionCredentials, metav1.ConditionUnknown, reasonNotChecked, "Bucket could not be listed"),
+		conditions.New(stork_api.BackupLocationConditionBucket, metav1.ConditionFalse, "Unavailable", err.Error())
+}
+
+func isS3CredentialError(err awserr.Error) bool {
+	for _, code := range s3CredentialErrorCodes {
+		if err.Code() == code {
+			return true
+		}
+	}
+	return false
+}
+
+// validateObjectLock checks that a bucket with object lock enabled has a
+// default retention that backups can be scheduled with. It also returns
+// whether object lock is enabled on the bucket.
+func validateObjectLock(backupLocation *stork_api.BackupLocation) (stork_api.Condition, bool) {
+	invalid := func(reason string, message string) stork_api.Condition {
+		return conditions.New(stork_api.BackupLocationConditionObjectLock, metav1.ConditionFalse, reason, message)
+	}
+	objLockInfo, err := objectstore.GetObjLockInfo(backupLocation)
+	if err != nil {
+		return invalid("Error", err.Error()), false
+	}
+	if !objLockInfo.LockEnabled {
+		return conditions.New(stork_api.BackupLocationConditionObjectLock, metav1.ConditionTrue,
+			"NotEnabled", "Object lock is not enabled"), false
+	}
+	if backupLocation.Location.Type != stork_api.BackupLocationS3 {
+		return invalid("NotSupported",
+			fmt.Sprintf("Object lock is not supported for backup locations of type %v", backupLocation.Location.Type)), true
+	}
+	if !objLockInfo.HasRetention() {
+		return invalid("NoDefaultRetention",
+			"Object lock is enabled but no default retention is set in compliance or governance mode"), true
+	}
+	if objLockInfo.RetentionPeriodDays != 0 {
+		valid, minRetentionPeriod, err := k8sutils.IsValidBucketRetentionPeriod(objLockInfo.RetentionPeriodDays)
+		if err != nil {
+			return conditions.New(stork_api.BackupLocationConditionObjectLock, metav1.ConditionUnknown,
+				reasonNotChecked, err.Error()), true
+		}
+		if !valid {
+			return invalid("RetentionTooShort",
+				fmt.Sprintf("Retention period of %v days is less than the minimum of %v days", objLockInfo.RetentionPeriodDays, minRetentionPeriod)), true
+		}
+		return conditions.New(stork_api.BackupLocationConditionObjectLock, metav1.ConditionTrue, reasonValid,
+			fmt.Sprintf("Objects are retained for %v days in %v mode", objLockInfo.RetentionPeriodDays, objLockInfo.LockMode)), true
+	}
+	return conditions.New(stork_api.BackupLocationConditionObjectLock, metav1.ConditionTrue, reasonValid,
+		fmt.Sprintf("Objects are retained for %v years in %v mode", objLockInfo.RetentionPeriodYears, objLockInfo.LockMode)), true
+}
+
+// validateReadWrite writes a probe object to the bucket, reads it back and
+// deletes it
+func validateReadWrite(ctx context.Context, bucket *blob.Bucket, backupLocation *stork_api.BackupLocation) stork_api.Condition {
+	invalid := func(reason string, err error) stork_api.Condition {
+		return conditions.New(stork_api.BackupLocationConditionReadWrite, metav1.ConditionFalse, reason, err.Error())
+	}
+	key := strings.Join([]string{backupLocationProbePrefix, backupLocation.Namespace, backupLocation.Name}, "/")
+	probe := []byte(time.Now().UTC().Format(time.RFC3339Nano))
+	if err := bucket.WriteAll(ctx, key, probe, backupobject.GetWriterOptions(backupLocation, nil)); err != nil {
+		return invalid("WriteFailed", err)
+	}
+	data, err := bucket.ReadAll(ctx, key)
+	if err != nil {
+		return invalid("ReadFailed", err)
+	}
+	if !bytes.Equal(data, probe) {
+		return invalid("ReadFailed", fmt.Errorf("probe object read back doesn't match what was written"))
+	}
+	if err := bucket.Delete(ctx, key); err != nil {
+		return invalid("DeleteFailed", err)
+	}
+	return conditions.New(stork_api.BackupLocationConditionReadWrite, metav1.ConditionTrue, reasonValid, "")
+}
@@ -0,0 +1,478 @@
+package controllers
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	stork_api "github.com/libopenstorage/stork/pkg/apis/stork/v1alpha1"
+	"github.com/libopenstorage/stork/pkg/backupobject"
+	"github.com/libopenstorage/stork/pkg/compression"
+	"github.com/libopenstorage/stork/pkg/crypto"
+	"github.com/libopenstorage/stork/pkg/log"
+	"github.com/libopenstorage/stork/pkg/objectstore"
+	storkops "github.com/portworx/sched-ops/k8s/stork"
+	"github.com/sirupsen/logrus"
+	"gocloud.dev/blob"
+	"gocloud.dev/gcerrors"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/tools/record"
+	runtimeclient "sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+)
+
+const (
+	// BackupLocationReencryptAnnotation requests the backups in a backup
+	// location to be re-encrypted with the active encryption key of the
+	// location. It is removed once all the backups have been re-encrypted.
+	BackupLocationReencryptAnnotation = annotationPrefix + "reencrypt-backups"
+	// BackupLocationReencryptedKeyAnnotation records the ID of the key that
+	// the backups in a backup location were last re-encrypted with
+	BackupLocationReencryptedKeyAnnotation = annotationPrefix + "reencrypted-key-id"
+
+	reencryptInterval = 1 * time.Minute
+)
+
+type reencryptResult int
+
+const (
+	reencryptResultSkipped reencryptResult = iota
+	reencryptResultUnchanged
+	reencryptResultRewritten
+)
+
+// NewBackupReencrypt creates a new instance of BackupReencryptController.
+func NewBackupReencrypt(mgr manager.Manager, r record.EventRecorder) *BackupReencryptController {
+	return &BackupReencryptController{
+		client:   mgr.GetClient(),
+		recorder: r,
+	}
+}
+
+// BackupReencryptController rewrites the backups in a backup location with the
+// active encryption key of the location when it is annotated with
+// BackupLocationReencryptAnnotation. Objects that are already encrypted with
+// the active key are left as is, so an interrupted run is resumed the next
+// time around.
+type BackupReencryptController struct {
+	client runtimeclient.Client
+
+	recorder    record.EventRecorder
+	stopChannel chan os.Signal
+}
+
+// Init Initializes the backup re-encrypt controller
+func (b *BackupReencryptController) Init(stopChannel chan os.Signal) error {
+	b.stopChannel = stopChannel
+	go b.startReencrypt()
+	return nil
+}
+
+func (b *BackupReencryptController) startReencrypt() {
+	for {
+		select {
+		case <-time.After(reencryptInterval):
+			// List the backup locations without the config from the secrets
+			// merged in since they are updated once done
+			backupLocations := &stork_api.BackupLocationList{}
+			if err := b.client.List(context.TODO(), backupLocations); err != nil {
+				logrus.Errorf("Error getting backup locations to re-encrypt: %v", err)
+				continue
+			}
+			for i := range backupLocations.Items {
+				backupLocation := &backupLocations.Items[i]
+				if backupLocation.Annotations[BackupLocationReencryptAnnotation] != "true" {
+					continue
+				}
+				b.reencryptBackupLocation(backupLocation)
+			}
+		case <-b.stopChannel:
+			return
+		}
+	}
+}
+
+func (b *BackupReencryptController) reencryptBackupLocation(backupLocation *stork_api.BackupLocation) {
+	keyID, rewritten, skipped, failed, err := b.reencryptBackups(backupLocation)
+	if err != nil {
+		message := fmt.Sprintf("Error re-encrypting backups: %v", err)
+		log.BackupLocationLog(backupLocation).Errorf(message)
+		b.recorder.Event(backupLocation,
+			v1.EventTypeWarning,
+			"ReencryptFailed",
+			message)
+		return
+	}
+
+	message := fmt.Sprintf("Re-encrypted %v objects with encryption key %v", rewritten, keyID)
+	if skipped > 0 {
+		message = fmt.Sprintf("%v, skipped %v objects that couldn't be decrypted with the keyring", message, skipped)
+	}
+	if failed > 0 {
+		// Keep the annotation so that the failed backups are retried, the
+		// ones that were re-encrypted are left as is the next time around
+		message = fmt.Sprintf("%v, failed to re-encrypt %v backups", message, failed)
+		log.BackupLocationLog(backupLocation).Errorf(message)
+		b.recorder.Event(backupLocation,
+			v1.EventTypeWarning,
+			"ReencryptFailed",
+			message)
+		return
+	}
+	// Objects that couldn't be decrypted won't be on a retry either, so the
+	// run is finished but reported as a warning
+	eventType := v1.EventTypeNormal
+	if skipped > 0 {
+		eventType = v1.EventTypeWarning
+		log.BackupLocationLog(backupLocation).Warnf(message)
+	} else {
+		log.BackupLocationLog(backupLocation).Infof(message)
+	}
+	delete(backupLocation.Annotations, BackupLocationReencryptAnnotation)
+	backupLocation.Annotations[BackupLocationReencryptedKeyAnnotation] = keyID
+	if err := b.client.Update(context.TODO(), backupLocation); err != nil {
+		log.BackupLocationLog(backupLocation).Errorf("Error updating backup location after re-encrypting backups: %v", err)
+		return
+	}
+	b.recorder.Event(backupLocation,
+		eventType,
+		"Reencrypted",
+		message)
+}
+
+// reencryptBackups re-encrypts all the backups in the backup location. A
+// backup that fails to be re-encrypted is reported and counted in the
+// returned number of failed backups without stopping the others.
+func (b *BackupReencryptController) reencryptBackups(
+	backupLocation *stork_api.BackupLocation,
+) (string, int, int, int, error) {
+	location, err := storkops.Instance().GetBackupLocation(backupLocation.Name, backupLocation.Namespace)
+	if err != nil {
+		return "", 0, 0, 0, err
+	}
+	if location.Location.ActiveEncryptionKeyID == "" {
+		return "", 0, 0, 0, fmt.Errorf("activeEncryptionKeyID needs to be set to re-encrypt backups")
+	}
+	keyID, _, err := location.GetActiveEncryptionKey()
+	if err != nil {
+		return "", 0, 0, 0, err
+	}
+	bucket, err := objectstore.GetBucket(location)
+	if err != nil {
+		return "", 0, 0, 0, err
+	}
+	defer bucket.Close()
+	backupPaths, err := listBackupPaths(bucket, location)
+	if err != nil {
+		return "", 0, 0, 0, err
+	}
+
+	rewritten, skipped, failed := 0, 0, 0
+	for _, backupPath := range backupPaths {
+		backupRewritten, backupSkipped, err := reencryptBackup(bucket, location, keyID, backupPath)
+		rewritten += backupRewritten
+		skipped += backupSkipped
+		if err != nil {
+			failed++
+			message := fmt.Sprintf("Error re-encrypting backup %v: %v", backupPath, err)
+			log.BackupLocationLog(backupLocation).Errorf(message)
+			b.recorder.Event(backupLocation,
+				v1.EventTypeWarning,
+				"ReencryptFailed",
+				message)
+		}
+	}
+	return keyID, rewritten, skipped, failed, nil
+}
+
+// listBackupPaths returns the paths of all the backups from the namespace of
+// the location, <namespace>/<backup name>/<backup uid>/
+func listBackupPaths(bucket *blob.Bucket, location *stork_api.BackupLocation) ([]string, error) {
+	backupPaths := make([]string, 0)
+	backupNames, err := listDirs(bucket, location.Namespace+"/")
+	if err != nil {
+		return nil, err
+	}
+	for _, backupName := range backupNames {
+		// The catalog isn't encrypted
+		if backupName == backupobject.GetCatalogPath(location)+"/" {
+			continue
+		}
+		paths, err := listDirs(bucket, backupName)
+		if err != nil {
+			return nil, err
+		}
+		backupPaths = append(backupPaths, paths...)
+	}
+	return backupPaths, nil
+}
+
+func listDirs(bucket *blob.Bucket, prefix string) ([]string, error) {
+	iterator := bucket.List(&blob.ListOptions{
+		Prefix:    prefix,
+		Delimiter: "/",
+	})
+	dirs := make([]string, 0)
+	for {
+		object, err := iterator.Next(context.TODO())
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if object.IsDir {
+			dirs = append(dirs, object.Key)
+		}
+	}
+	return dirs, nil
+}
+
+// readBackupObjectLock returns the retention of the backup at objectPath from
+// its metadata, or nil if it isn't retained anymore
+func readBackupObjectLock(
+	bucket *blob.Bucket,
+	backupLocation *stork_api.BackupLocation,
+	objectPath string,
+) (*stork_api.ApplicationBackupObjectLock, error) {
+	reader, err := backupobject.NewReader(bucket, backupLocation, filepath.Join(objectPath, metadataObjectName))
+	if err != nil {
+		if gcerrors.Code(err) == gcerrors.NotFound {
+			return nil, nil
+		}
+		return nil, err
+	}
+	defer reader.Close()
+	backup := &stork_api.ApplicationBackup{}
+	if err := json.NewDecoder(reader).Decode(backup); err != nil {
+		return nil, fmt.Errorf("error parsing backup metadata: %v", err)
+	}
+	objectLock := backup.Status.ObjectLock
+	if objectLock == nil || !objectLock.RetainUntil.Time.After(time.Now()) {
+		return nil, nil
+	}
+	return objectLock, nil
+}
+
+// reencryptBackup re-encrypts the objects of the backup at objectPath and
+// rewrites its manifest with the new checksums. The rewritten objects are
+// retained like the original ones.
+func reencryptBackup(
+	bucket *blob.Bucket,
+	backupLocation *stork_api.BackupLocation,
+	keyID string,
+	objectPath string,
+) (int, int, error) {
+	manifest, err := backupobject.ReadManifest(bucket, backupLocation, objectPath)
+	if err != nil {
+		return 0, 0, err
+	}
+	objectLock, err := readBackupObjectLock(bucket, backupLocation, objectPath)
+	if err != nil {
+		return 0, 0, err
+	}
+	rewritten, skipped := 0, 0
+	updateManifest := false
+	if manifest == nil {
+		for _, name := range backupobject.ObjectNames {
+			result, _, err := reencryptObject(bucket, backupLocation, keyID, objectPath, name, nil, objectLock)
+			if err != nil {
+				return 0, 0, err
+			}
+			if result == reencryptResultRewritten {
+				rewritten++
+			} else if result == reencryptResultSkipped {
+				skipped++
+			}
+		}
+		return rewritten, skipped, nil
+	}
+
+	for i := range manifest.Objects {
+		result, object, err := reencryptObject(bucket, backupLocation, keyID, objectPath, manifest.Objects[i].Name, &manifest.Objects[i], objectLock)
+		if err != nil {
+			return 0, 0, err
+		}
+		if result == reencryptResultRewritten {
+			rewritten++
+		} else if result == reencryptResultSkipped {
+			skipped++
+		}
+		if object != nil {
+			manifest.Objects[i] = *object
+			updateManifest = true
+		}
+	}
+	manifestKeyID, _, err := getObjectKeyID(bucket, filepath.Join(objectPath, backupobject.ManifestObjectName))
+	if err != nil {
+		return 0, 0, err
+	}
+	if !updateManifest && manifestKeyID == keyID {
+		return rewritten, skipped, nil
+	}
+
+	jsonBytes, err := json.MarshalIndent(manifest, "", " ")
+	if err != nil {
+		return 0, 0, err
+	}
+	writer, err := backupobject.NewWriter(bucket, backupLocation, objectPath, backupobject.ManifestObjectName, stork_api.BackupCompressionNone, objectLock)
+	if err != nil {
+		return 0, 0, err
+	}
+	if _, err := writer.Write(jsonBytes); err != nil {
+		writer.Abort()
+		return 0, 0, err
+	}
+	if err := writer.Close(); err != nil {
+		return 0, 0, err
+	}
+	return rewritten, skipped, nil
+}
+
+// getObjectKeyID returns the ID of the key recorded in the object at
+// objectPath. The second value is false if the object isn't in the encrypted
+// stream format.
+func getObjectKeyID(bucket *blob.Bucket, objectPath string) (string, bool, error) {
+	reader, err := bucket.NewRangeReader(context.TODO(), objectPath, 0, int64(crypto.MaxStreamHeaderLen), nil)
+	if err != nil {
+		return "", false, err
+	}
+	defer reader.Close()
+	header, err := io.ReadAll(reader)
+	if err != nil {
+		return "", false, err
+	}
+	if !crypto.IsStreamEncrypted(header) {
+		return "", false, nil
+	}
+	keyID, err := crypto.StreamKeyID(header)
+	if err != nil {
+		return "", false, err
+	}
+	return keyID, true, nil
+}
+
+// isPlaintextObject checks if the data of an object that isn't in the stream
+// format was uploaded without being encrypted. All the backup objects are
+// JSON, compressed or not.
+func isPlaintextObject(data []byte) bool {
+	reader, err := compression.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return false
+	}
+	defer reader.Close()
+	plainData, err := io.ReadAll(reader)
+	return err == nil && json.Valid(plainData)
+}
+
+// reencryptObject rewrites the object name under objectPath with the key
+// keyID. If expected is set the object is verified against it before being
+// rewritten and the new manifest entry is returned. Objects that can't be
+// decrypted with the keyring are skipped rather than risking to encrypt data
+// that is still encrypted with an unknown key, objects that were never
+// encrypted are encrypted with the key. The rewritten object is
+// retained as described by objectLock if it is set.
+func reencryptObject(
+	bucket *blob.Bucket,
+	backupLocation *stork_api.BackupLocation,
+	keyID string,
+	objectPath string,
+	name string,
+	expected *backupobject.ManifestObject,
+	objectLock *stork_api.ApplicationBackupObjectLock,
+) (reencryptResult, *backupobject.ManifestObject, error) {
+	path := filepath.Join(objectPath, name)
+	keyring := objectstore.GetEncryptionKeyring(backupLocation)
+	currentKeyID, streamEncrypted, err := getObjectKeyID(bucket, path)
+	if err != nil {
+		if gcerrors.Code(err) == gcerrors.NotFound && expected == nil {
+			return reencryptResultUnchanged, nil, nil
+		}
+		return reencryptResultSkipped, nil, err
+	}
+
+	if streamEncrypted && currentKeyID == keyID {
+		if expected == nil {
+			return reencryptResultUnchanged, nil, nil
+		}
+		size, checksum, err := backupobject.GetObjectChecksum(bucket, path)
+		if err != nil {
+			return reencryptResultSkipped, nil, err
+		}
+		if size == expected.Size && checksum == expected.SHA256 {
+			return reencryptResultUnchanged, nil, nil
+		}
+		// The object was rewritten by a run that was interrupted before the
+		// manifest was updated. Make sure it decrypts with the active key
+		// before recording its new checksum.
+		reader, err := backupobject.OpenDecrypted(bucket, backupLocation, path)
+		if err != nil {
+			return reencryptResultSkipped, nil, err
+		}
+		defer reader.Close()
+		if _, err := io.Copy(io.Discard, reader); err != nil {
+			return reencryptResultSkipped, nil, fmt.Errorf("object %v doesn't match the manifest: %v", name, err)
+		}
+		return reencryptResultUnchanged, &backupobject.ManifestObject{Name: name, Size: size, SHA256: checksum}, nil
+	}
+
+	if expected != nil {
+		size, checksum, err := backupobject.GetObjectChecksum(bucket, path)
+		if err != nil {
+			return reencryptResultSkipped, nil, err
+		}
+		if size != expected.Size || checksum != expected.SHA256 {
+			return reencryptResultSkipped, nil, fmt.Errorf("object %v doesn't match the manifest", name)
+		}
+	}
+
+	blobReader, err := bucket.NewReader(context.TODO(), path, nil)
+	if err != nil {
+		return reencryptResultSkipped, nil, err
+	}
+	defer blobReader.Close()
+	bufReader := bufio.NewReader(blobReader)
+	var plainReader io.Reader
+	if streamEncrypted {
+		plainReader, err = crypto.NewKeyringStreamDecryptReader(bufReader, keyring)
+		if err != nil {
+			return reencryptResultSkipped, nil, err
+		}
+	} else {
+		data, err := io.ReadAll(bufReader)
+		if err != nil {
+			return reencryptResultSkipped, nil, err
+		}
+		decryptData, err := crypto.DecryptWithKeyring(data, keyring)
+		if err != nil {
+			if !isPlaintextObject(data) {
+				log.BackupLocationLog(backupLocation).Warnf("Skipping re-encryption of %v: %v", path, err)
+				return reencryptResultSkipped, nil, nil
+			}
+			// The object was uploaded before the location had a key
+			decryptData = data
+		}
+		plainReader = bytes.NewReader(decryptData)
+	}
+
+	// The data is copied as is, it is already compressed if it needs to be
+	writer, err := backupobject.NewWriter(bucket, backupLocation, objectPath, name, stork_api.BackupCompressionNone, objectLock)
+	if err != nil {
+		return reencryptResultSkipped, nil, err
+	}
+	if _, err := io.Copy(writer, plainReader); err != nil {
+		writer.Abort()
+		return reencryptResultSkipped, nil, err
+	}
+	if err := writer.Close(); err != nil {
+		return reencryptResultSkipped, nil, err
+	}
+	object := writer.ManifestObject()
+	return reencryptResultRewritten, &object, nil
+}
@@ -0,0 +1,120 @@
+//go:build unittest
+// +build unittest
+
+package controllers
+
+import (
+	"path/filepath"
+	"testing"
+
+	stork_api "github.com/libopenstorage/stork/pkg/apis/stork/v1alpha1"
+	"github.com/libopenstorage/stork/pkg/backupobject"
+	"github.com/libopenstorage/stork/pkg/objectstore"
+	"github.com/stretchr/testify/require"
+	"gocloud.dev/blob"
+)
+
+var testReencryptObjects = map[string][]byte{
+	resourceObjectName: []byte(`[{"kind":"ConfigMap"}]`),
+	nsObjectName:       []byte(`[{"kind":"Namespace"}]`),
+	metadataObjectName: []byte(`{"kind":"ApplicationBackup"}`),
+}
+
+func setActiveEncryptionKey(location *stork_api.BackupLocation, id, key string) {
+	location.Location.EncryptionKeys = append(location.Location.EncryptionKeys,
+		stork_api.BackupEncryptionKey{ID: id, Key: key})
+	location.Location.ActiveEncryptionKeyID = id
+}
+
+// requireReencrypted checks that all the objects of the test backup are
+// encrypted with the key and still match the manifest and the original data
+func requireReencrypted(t *testing.T, bucket *blob.Bucket, location *stork_api.BackupLocation, keyID string) {
+	for name, data := range testReencryptObjects {
+		objectKeyID, streamEncrypted, err := getObjectKeyID(bucket, filepath.Join(testObjectPath, name))
+		require.NoError(t, err)
+		require.True(t, streamEncrypted, "%v isn't encrypted", name)
+		require.Equal(t, keyID, objectKeyID, "%v isn't encrypted with the active key", name)
+		require.Equal(t, data, readDecryptedObject(t, location, name), "%v doesn't match after re-encryption", name)
+	}
+	manifestKeyID, _, err := getObjectKeyID(bucket, filepath.Join(testObjectPath, backupobject.ManifestObjectName))
+	require.NoError(t, err)
+	require.Equal(t, keyID, manifestKeyID, "Manifest isn't encrypted with the active key")
+	integrity, reason, err := backupobject.VerifyObjects(bucket, location, testObjectPath)
+	require.NoError(t, err, "Error verifying backup")
+	require.Equal(t, stork_api.ApplicationBackupIntegrityVerified, integrity, reason)
+}
+
+func TestReencryptPlaintextBackup(t *testing.T) {
+	location := newLocalBackupLocation(t, "location", "")
+	writeTestBackup(t, location, testReencryptObjects)
+	bucket, err := objectstore.GetBucket(location)
+	require.NoError(t, err, "Error opening bucket")
+	defer bucket.Close()
+
+	// Adding a key to the location encrypts the backups taken before
+	setActiveEncryptionKey(location, "key1", "passphrase1")
+	rewritten, skipped, err := reencryptBackup(bucket, location, "key1", testObjectPath)
+	require.NoError(t, err, "Error re-encrypting backup")
+	require.Equal(t, len(testReencryptObjects), rewritten)
+	require.Equal(t, 0, skipped)
+	requireReencrypted(t, bucket, location, "key1")
+}
+
+func TestReencryptBackupResume(t *testing.T) {
+	location := newLocalBackupLocation(t, "location", "")
+	setActiveEncryptionKey(location, "key1", "passphrase1")
+	writeTestBackup(t, location, testReencryptObjects)
+	bucket, err := objectstore.GetBucket(location)
+	require.NoError(t, err, "Error opening bucket")
+	defer bucket.Close()
+
+	// A run is interrupted after rewriting an object, before the manifest
+	// is updated with its new checksum
+	setActiveEncryptionKey(location, "key2", "passphrase2")
+	manifest, err := backupobject.ReadManifest(bucket, location, testObjectPath)
+	require.NoError(t, err, "Error reading manifest")
+	for i := range manifest.Objects {
+		if manifest.Objects[i].Name != resourceObjectName {
+			continue
+		}
+		result, _, err := reencryptObject(bucket, location, "key2", testObjectPath, resourceObjectName, &manifest.Objects[i], nil)
+		require.NoError(t, err, "Error re-encrypting object")
+		require.Equal(t, reencryptResultRewritten, result)
+	}
+	integrity, _, err := backupobject.VerifyObjects(bucket, location, testObjectPath)
+	require.NoError(t, err)
+	require.Equal(t, stork_api.ApplicationBackupIntegrityCorrupt, integrity, "Manifest shouldn't match before the run is resumed")
+
+	rewritten, skipped, err := reencryptBackup(bucket, location, "key2", testObjectPath)
+	require.NoError(t, err, "Error resuming re-encryption")
+	require.Equal(t, len(testReencryptObjects)-1, rewritten, "Object rewritten by the interrupted run rewritten again")
+	require.Equal(t, 0, skipped)
+	requireReencrypted(t, bucket, location, "key2")
+
+	// Nothing is left to do once the backup is re-encrypted
+	rewritten, skipped, err = reencryptBackup(bucket, location, "key2", testObjectPath)
+	require.NoError(t, err)
+	require.Equal(t, 0, rewritten)
+	require.Equal(t, 0, skipped)
+}
+
+func TestReencryptUnknownKey(t *testing.T) {
+	location := newLocalBackupLocation(t, "location", "unknown")
+	writeTestBackup(t, location, testReencryptObjects)
+	bucket, err := objectstore.GetBucket(location)
+	require.NoError(t, err, "Error opening bucket")
+	defer bucket.Close()
+
+	// Objects encrypted with a key that isn't in the keyring are left as
+	// is rather than being encrypted again
+	location.Location.EncryptionV2Key = ""
+	setActiveEncryptionKey(location, "key1", "passphrase1")
+	_, _, err = reencryptBackup(bucket, location, "key1", testObjectPath)
+	require.Error(t, err, "Expected error for objects encrypted with an unknown key")
+	for name := range testReencryptObjects {
+		keyID, streamEncrypted, err := getObjectKeyID(bucket, filepath.Join(testObjectPath, name))
+		require.NoError(t, err)
+		require.True(t, streamEncrypted)
+		require.Equal(t, "", keyID, "%v encrypted again", name)
+	}
+}
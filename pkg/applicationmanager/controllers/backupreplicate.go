@@ -0,0 +1,455 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	stork_api "github.com/libopenstorage/stork/pkg/apis/stork/v1alpha1"
+	"github.com/libopenstorage/stork/pkg/backupobject"
+	"github.com/libopenstorage/stork/pkg/log"
+	"github.com/libopenstorage/stork/pkg/objectstore"
+	"github.com/libopenstorage/stork/pkg/utils"
+	storkops "github.com/portworx/sched-ops/k8s/stork"
+	"github.com/sirupsen/logrus"
+	"gocloud.dev/blob"
+	"gocloud.dev/gcerrors"
+	v1 "k8s.io/api/core/v1"
+	k8s_errors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+	runtimeclient "sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+)
+
+const (
+	// ApplicationBackupReplicaOfAnnotation is set on the copy of a backup in
+	// its replica backup location to the name of the location it was copied
+	// from
+	ApplicationBackupReplicaOfAnnotation = annotationPrefix + "replica-of"
+	// ApplicationBackupSyncedFromAnnotation is set on the backups synced from
+	// a backup location to the name of that location
+	ApplicationBackupSyncedFromAnnotation = utils.ApplicationBackupSyncedFromAnnotation
+
+	replicateInterval = 1 * time.Minute
+
+	reasonReplicated        = "Replicated"
+	reasonReplicationFailed = "ReplicationFailed"
+)
+
+// NewBackupReplicate creates a new instance of BackupReplicateController.
+func NewBackupReplicate(mgr manager.Manager, r record.EventRecorder) *BackupReplicateController {
+	return &BackupReplicateController{
+		client:   mgr.GetClient(),
+		recorder: r,
+	}
+}
+
+// BackupReplicateController copies completed backups to the replica backup
+// location set on the backup or on its backup location. The data of the
+// volumes is copied for the drivers that keep it in the backup location, the
+// others are reported in the per-volume status of the replica. The metadata
+// of the backup is copied last so that it only shows up in the replica
+// location once the copy is complete.
+type BackupReplicateController struct {
+	client runtimeclient.Client
+
+	recorder    record.EventRecorder
+	stopChannel chan os.Signal
+}
+
+// Init Initializes the backup replicate controller
+func (b *BackupReplicateController) Init(stopChannel chan os.Signal) error {
+	b.stopChannel = stopChannel
+	go b.startReplication()
+	return nil
+}
+
+func (b *BackupReplicateController) startReplication() {
+	for {
+		select {
+		case <-time.After(replicateInterval):
+			backups := &stork_api.ApplicationBackupList{}
+			if err := b.client.List(context.TODO(), backups); err != nil {
+				logrus.Errorf("Error getting backups to replicate: %v", err)
+				continue
+			}
+			// Cache the backup locations for this pass, most backups share
+			// the same few locations
+			locations := make(map[string]*stork_api.BackupLocation)
+			for i := range backups.Items {
+				backup := &backups.Items[i]
+				if !needsReplication(backup) {
+					continue
+				}
+				b.replicateBackup(backup, locations)
+			}
+		case <-b.stopChannel:
+			return
+		}
+	}
+}
+
+// needsReplication returns true for completed backups that were created on
+// this cluster and haven't been copied to their replica location yet
+func needsReplication(backup *stork_api.ApplicationBackup) bool {
+	if backup.DeletionTimestamp != nil || backup.Status.Stage != stork_api.ApplicationBackupStageFinal {
+		return false
+	}
+	if backup.Status.Status != stork_api.ApplicationBackupStatusSuccessful &&
+		backup.Status.Status != stork_api.ApplicationBackupStatusPartialSuccess {
+		return false
+	}
+	if _, ok := backup.Annotations[ApplicationBackupSyncedFromAnnotation]; ok {
+		return false
+	}
+	if backup.Status.Replica == nil {
+		return true
+	}
+	return backup.Status.Replica.Status == stork_api.ApplicationBackupStatusPending ||
+		backup.Status.Replica.Status == stork_api.ApplicationBackupStatusInProgress
+}
+
+func getCachedBackupLocation(
+	locations map[string]*stork_api.BackupLocation,
+	name string,
+	namespace string,
+) (*stork_api.BackupLocation, error) {
+	key := namespace + "/" + name
+	if location, ok := locations[key]; ok {
+		return location, nil
+	}
+	location, err := storkops.Instance().GetBackupLocation(name, namespace)
+	if err != nil {
+		return nil, err
+	}
+	locations[key] = location
+	return location, nil
+}
+
+func (b *BackupReplicateController) replicateBackup(
+	backup *stork_api.ApplicationBackup,
+	locations map[string]*stork_api.BackupLocation,
+) {
+	location, err := getCachedBackupLocation(locations, backup.Spec.BackupLocation, backup.Namespace)
+	if err != nil {
+		// Nothing to do if the backup location was deleted
+		log.ApplicationBackupLog(backup).Debugf("Error getting backup location to replicate backup: %v", err)
+		return
+	}
+	replicaName := backup.Spec.ReplicaBackupLocation
+	if replicaName == "" {
+		replicaName = location.Location.ReplicaBackupLocation
+	}
+	if replicaName == "" {
+		return
+	}
+	if replicaName == location.Name {
+		b.updateReplicaStatus(backup, replicaName, stork_api.ApplicationBackupStatusFailed,
+			"Replica backup location is the same as the backup location", nil)
+		return
+	}
+	if location.Location.Type == stork_api.BackupLocationNFS {
+		b.updateReplicaStatus(backup, replicaName, stork_api.ApplicationBackupStatusFailed,
+			"Backups can't be replicated from nfs backup locations", nil)
+		return
+	}
+	replica, err := getCachedBackupLocation(locations, replicaName, backup.Namespace)
+	if err != nil {
+		b.updateReplicaStatus(backup, replicaName, stork_api.ApplicationBackupStatusPending,
+			fmt.Sprintf("Error getting replica backup location: %v", err), nil)
+		return
+	}
+
+	integrity, volumeInfos, err := copyBackupObjects(backup, location, replica)
+	if err != nil {
+		b.updateReplicaStatus(backup, replicaName, stork_api.ApplicationBackupStatusPending,
+			fmt.Sprintf("Error copying backup: %v", err), nil)
+		return
+	}
+	if integrity == stork_api.ApplicationBackupIntegrityCorrupt {
+		b.updateReplicaStatus(backup, replicaName, stork_api.ApplicationBackupStatusFailed,
+			"Backup failed integrity verification and wasn't copied", nil)
+		return
+	}
+	failed := 0
+	for _, volumeInfo := range volumeInfos {
+		if volumeInfo.Status != stork_api.ApplicationBackupStatusSuccessful {
+			failed++
+		}
+	}
+	if failed != 0 {
+		b.updateReplicaStatus(backup, replicaName, stork_api.ApplicationBackupStatusPartialSuccess,
+			fmt.Sprintf("Resources were replicated, the data of %v out of %v volumes couldn't be replicated", failed, len(volumeInfos)),
+			volumeInfos)
+		return
+	}
+	b.updateReplicaStatus(backup, replicaName, stork_api.ApplicationBackupStatusSuccessful,
+		"Backup was replicated successfully", volumeInfos)
+}
+
+// updateReplicaStatus updates the replica status of the backup, along with
+// the status of its volumes, if it changed and raises an event for it
+func (b *BackupReplicateController) updateReplicaStatus(
+	backup *stork_api.ApplicationBackup,
+	replicaName string,
+	status stork_api.ApplicationBackupStatusType,
+	reason string,
+	volumeInfos []*stork_api.ApplicationBackupReplicaVolumeInfo,
+) {
+	if backup.Status.Replica != nil &&
+		backup.Status.Replica.BackupLocation == replicaName &&
+		backup.Status.Replica.Status == status &&
+		backup.Status.Replica.Reason == reason {
+		return
+	}
+	backup.Status.Replica = &stork_api.ApplicationBackupReplicaStatus{
+		BackupLocation:      replicaName,
+		Status:              status,
+		Reason:              reason,
+		LastUpdateTimestamp: metav1.Now(),
+		Volumes:             volumeInfos,
+	}
+	if err := b.client.Update(context.TODO(), backup); err != nil {
+		log.ApplicationBackupLog(backup).Errorf("Error updating replica status: %v", err)
+		return
+	}
+
+	message := fmt.Sprintf("Replica %v: %v", replicaName, reason)
+	switch status {
+	case stork_api.ApplicationBackupStatusSuccessful:
+		log.ApplicationBackupLog(backup).Infof(message)
+		b.recorder.Event(backup, v1.EventTypeNormal, reasonReplicated, message)
+	case stork_api.ApplicationBackupStatusInProgress:
+		log.ApplicationBackupLog(backup).Infof(message)
+	case stork_api.ApplicationBackupStatusPartialSuccess:
+		log.ApplicationBackupLog(backup).Warnf(message)
+		b.recorder.Event(backup, v1.EventTypeWarning, reasonReplicated, message)
+	default:
+		log.ApplicationBackupLog(backup).Errorf(message)
+		b.recorder.Event(backup, v1.EventTypeWarning, reasonReplicationFailed, message)
+	}
+}
+
+// copyBackupObjects copies the objects of the backup from its backup location
+// to the replica location. The objects are re-encrypted with the active key of
+// the replica location, the metadata is updated to point to the replica
+// location and a new manifest is written last. The data of the volumes is
+// copied as is for the drivers that keep it in the backup location, the
+// status of the copy of each volume is returned. The backup isn't copied if
+// it fails the integrity verification.
+func copyBackupObjects(
+	backup *stork_api.ApplicationBackup,
+	location *stork_api.BackupLocation,
+	replica *stork_api.BackupLocation,
+) (stork_api.ApplicationBackupIntegrityType, []*stork_api.ApplicationBackupReplicaVolumeInfo, error) {
+	srcBucket, err := objectstore.GetBucket(location)
+	if err != nil {
+		return stork_api.ApplicationBackupIntegrityUnknown, nil, err
+	}
+	defer srcBucket.Close()
+	dstBucket, err := objectstore.GetBucket(replica)
+	if err != nil {
+		return stork_api.ApplicationBackupIntegrityUnknown, nil, err
+	}
+	defer dstBucket.Close()
+	objectLock, err := backupobject.GetObjectLock(replica)
+	if err != nil {
+		return stork_api.ApplicationBackupIntegrityUnknown, nil, err
+	}
+
+	objectPath := backup.Status.BackupPath
+	integrity, reason, err := backupobject.VerifyObjects(srcBucket, location, objectPath)
+	if err != nil {
+		return stork_api.ApplicationBackupIntegrityUnknown, nil, err
+	}
+	if integrity == stork_api.ApplicationBackupIntegrityCorrupt {
+		log.ApplicationBackupLog(backup).Errorf("Not replicating corrupt backup: %v", reason)
+		return integrity, nil, nil
+	}
+	manifest, err := backupobject.ReadManifest(srcBucket, location, objectPath)
+	if err != nil {
+		return integrity, nil, err
+	}
+	names := backupobject.ObjectNames
+	if manifest != nil {
+		names = make([]string, 0, len(manifest.Objects))
+		for _, object := range manifest.Objects {
+			names = append(names, object.Name)
+		}
+	}
+
+	// The objects written by the drivers in the backup path are copied with
+	// the volumes
+	skip := map[string]bool{backupobject.ManifestObjectName: true, metadataObjectName: true}
+	for _, name := range names {
+		skip[name] = true
+	}
+	volumeInfos, err := copyVolumes(backup, srcBucket, dstBucket, replica, objectLock)
+	if err != nil {
+		return integrity, nil, err
+	}
+	if len(backup.Status.Volumes) != 0 {
+		if err := backupobject.CopyDriverObjects(srcBucket, dstBucket, replica, objectPath, skip, objectLock); err != nil {
+			return integrity, nil, fmt.Errorf("error copying volume driver objects: %v", err)
+		}
+	}
+
+	replicaManifest := backupobject.NewManifest()
+	for _, name := range names {
+		// The metadata is copied last, once everything it refers to is in
+		// the replica location
+		if name == metadataObjectName {
+			continue
+		}
+		object, err := copyBackupObject(srcBucket, location, dstBucket, replica, objectPath, name, objectLock)
+		if err != nil {
+			if gcerrors.Code(err) == gcerrors.NotFound && manifest == nil {
+				continue
+			}
+			return integrity, nil, fmt.Errorf("error copying %v: %v", name, err)
+		}
+		replicaManifest.Add(*object)
+	}
+
+	metadata, err := backupobject.ReadMetadata(srcBucket, location, filepath.Join(objectPath, metadataObjectName))
+	if err != nil {
+		return integrity, nil, err
+	}
+	if metadata.Annotations == nil {
+		metadata.Annotations = make(map[string]string)
+	}
+	metadata.Annotations[ApplicationBackupReplicaOfAnnotation] = location.Name
+	metadata.Spec.BackupLocation = replica.Name
+	metadata.Spec.ReplicaBackupLocation = ""
+	metadata.Status.Replica = nil
+	metadata.Status.ObjectLock = objectLock
+	jsonBytes, err := json.MarshalIndent(metadata, "", " ")
+	if err != nil {
+		return integrity, nil, err
+	}
+	object, err := backupobject.WriteObject(dstBucket, replica, objectPath, metadataObjectName, jsonBytes, objectLock)
+	if err != nil {
+		return integrity, nil, err
+	}
+	replicaManifest.Add(*object)
+
+	jsonBytes, err = json.MarshalIndent(replicaManifest, "", " ")
+	if err != nil {
+		return integrity, nil, err
+	}
+	if _, err := backupobject.WriteObject(dstBucket, replica, objectPath, backupobject.ManifestObjectName, jsonBytes, objectLock); err != nil {
+		return integrity, nil, err
+	}
+	return integrity, volumeInfos, backupobject.AppendCatalogEntry(dstBucket, replica, backupobject.NewCatalogEntry(backupobject.CatalogOperationAdd, metadata))
+}
+
+// copyVolumes copies the repositories holding the data of the volumes of the
+// backup to the replica location and returns the status of each volume. The
+// volumes of the drivers that keep their data outside of the backup location,
+// like cloudsnaps, are reported as failed.
+func copyVolumes(
+	backup *stork_api.ApplicationBackup,
+	srcBucket *blob.Bucket,
+	dstBucket *blob.Bucket,
+	replica *stork_api.BackupLocation,
+	objectLock *stork_api.ApplicationBackupObjectLock,
+) ([]*stork_api.ApplicationBackupReplicaVolumeInfo, error) {
+	volumeInfos := make([]*stork_api.ApplicationBackupReplicaVolumeInfo, 0)
+	copied := make(map[string]bool)
+	for _, volume := range backup.Status.Volumes {
+		volumeInfo := &stork_api.ApplicationBackupReplicaVolumeInfo{
+			PersistentVolumeClaim: volume.PersistentVolumeClaim,
+			Namespace:             volume.Namespace,
+			Volume:                volume.Volume,
+			DriverName:            volume.DriverName,
+		}
+		volumeInfos = append(volumeInfos, volumeInfo)
+		repository := backupobject.GetVolumeRepository(volume)
+		if repository == "" {
+			volumeInfo.Status = stork_api.ApplicationBackupStatusFailed
+			volumeInfo.Reason = fmt.Sprintf("Data of volumes backed up by driver %v isn't kept in the backup location", volume.DriverName)
+			continue
+		}
+		if !copied[repository] {
+			if err := backupobject.CopyVolumeRepository(srcBucket, dstBucket, replica, repository, objectLock); err != nil {
+				return nil, fmt.Errorf("error copying data of volume %v: %v", volume.Volume, err)
+			}
+			copied[repository] = true
+		}
+		volumeInfo.Status = stork_api.ApplicationBackupStatusSuccessful
+		volumeInfo.Reason = "Volume data was replicated successfully"
+	}
+	return volumeInfos, nil
+}
+
+// copyBackupObject decrypts the object name under objectPath with the keyring
+// of the source location and writes it to the destination location encrypted
+// with its active key
+func copyBackupObject(
+	srcBucket *blob.Bucket,
+	srcLocation *stork_api.BackupLocation,
+	dstBucket *blob.Bucket,
+	dstLocation *stork_api.BackupLocation,
+	objectPath string,
+	name string,
+	objectLock *stork_api.ApplicationBackupObjectLock,
+) (*backupobject.ManifestObject, error) {
+	reader, err := backupobject.OpenDecrypted(srcBucket, srcLocation, filepath.Join(objectPath, name))
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+	// The data is copied as is, it is already compressed if it needs to be
+	writer, err := backupobject.NewWriter(dstBucket, dstLocation, objectPath, name, stork_api.BackupCompressionNone, objectLock)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := io.Copy(writer, reader); err != nil {
+		writer.Abort()
+		return nil, err
+	}
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+	object := writer.ManifestObject()
+	return &object, nil
+}
+
+// deleteReplicaObjects deletes all the objects of the backup from its replica
+// backup location
+func deleteReplicaObjects(backup *stork_api.ApplicationBackup, objectPath string) error {
+	replica, err := storkops.Instance().GetBackupLocation(backup.Status.Replica.BackupLocation, backup.Namespace)
+	if err != nil {
+		if k8s_errors.IsNotFound(err) {
+			return nil
+		}
+		return err
+	}
+	bucket, err := objectstore.GetBucket(replica)
+	if err != nil {
+		return err
+	}
+	defer bucket.Close()
+	iterator := bucket.List(&blob.ListOptions{Prefix: strings.TrimSuffix(objectPath, "/") + "/"})
+	for {
+		object, err := iterator.Next(context.TODO())
+		if err == io.EOF {
+			return backupobject.AppendCatalogEntry(bucket, replica, backupobject.NewCatalogEntry(backupobject.CatalogOperationDelete, backup))
+		}
+		if err != nil {
+			return err
+		}
+		if object.IsDir {
+			continue
+		}
+		if err := bucket.Delete(context.TODO(), object.Key); err != nil && gcerrors.Code(err) != gcerrors.NotFound {
+			return err
+		}
+	}
+}
@@ -0,0 +1,201 @@
+//go:build unittest
+// +build unittest
+
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"path/filepath"
+	"testing"
+
+	"github.com/libopenstorage/stork/drivers/volume"
+	stork_api "github.com/libopenstorage/stork/pkg/apis/stork/v1alpha1"
+	"github.com/libopenstorage/stork/pkg/backupobject"
+	"github.com/libopenstorage/stork/pkg/objectstore"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
+	runtimeclient "sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+const testObjectPath = "ns/backup/uid"
+
+// writeTestBackup writes the objects and the manifest of a backup the way a
+// backup does
+func writeTestBackup(t *testing.T, location *stork_api.BackupLocation, objects map[string][]byte) {
+	bucket, err := objectstore.GetBucket(location)
+	require.NoError(t, err, "Error opening bucket")
+	defer bucket.Close()
+	manifest := backupobject.NewManifest()
+	for name, data := range objects {
+		object, err := backupobject.WriteObject(bucket, location, testObjectPath, name, data, nil)
+		require.NoError(t, err, "Error writing %v", name)
+		manifest.Add(*object)
+	}
+	data, err := json.Marshal(manifest)
+	require.NoError(t, err, "Error encoding manifest")
+	_, err = backupobject.WriteObject(bucket, location, testObjectPath, backupobject.ManifestObjectName, data, nil)
+	require.NoError(t, err, "Error writing manifest")
+}
+
+func newLocalBackupLocation(t *testing.T, name string, encryptionKey string) *stork_api.BackupLocation {
+	return &stork_api.BackupLocation{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: "ns",
+		},
+		Location: stork_api.BackupLocationItem{
+			Type:            stork_api.BackupLocationLocal,
+			Path:            t.TempDir(),
+			EncryptionV2Key: encryptionKey,
+		},
+	}
+}
+
+func newReplicatedBackup(location *stork_api.BackupLocation) *stork_api.ApplicationBackup {
+	return &stork_api.ApplicationBackup{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "backup",
+			Namespace: location.Namespace,
+			UID:       "uid",
+		},
+		Spec: stork_api.ApplicationBackupSpec{
+			BackupLocation: location.Name,
+		},
+		Status: stork_api.ApplicationBackupStatus{
+			Stage:      stork_api.ApplicationBackupStageFinal,
+			Status:     stork_api.ApplicationBackupStatusSuccessful,
+			BackupPath: testObjectPath,
+		},
+	}
+}
+
+func readDecryptedObject(t *testing.T, location *stork_api.BackupLocation, name string) []byte {
+	bucket, err := objectstore.GetBucket(location)
+	require.NoError(t, err, "Error opening bucket")
+	defer bucket.Close()
+	reader, err := backupobject.NewReader(bucket, location, filepath.Join(testObjectPath, name))
+	require.NoError(t, err, "Error opening %v", name)
+	defer reader.Close()
+	data, err := io.ReadAll(reader)
+	require.NoError(t, err, "Error reading %v", name)
+	return data
+}
+
+func TestCopyBackupObjects(t *testing.T) {
+	location := newLocalBackupLocation(t, "source", "sourcekey")
+	replica := newLocalBackupLocation(t, "replica", "replicakey")
+	backup := newReplicatedBackup(location)
+
+	metadata, err := json.Marshal(backup)
+	require.NoError(t, err)
+	objects := map[string][]byte{
+		resourceObjectName: []byte(`[{"kind":"ConfigMap"}]`),
+		nsObjectName:       []byte(`[{"kind":"Namespace"}]`),
+		metadataObjectName: metadata,
+	}
+	writeTestBackup(t, location, objects)
+
+	integrity, volumeInfos, err := copyBackupObjects(backup, location, replica)
+	require.NoError(t, err, "Error copying backup")
+	require.Equal(t, stork_api.ApplicationBackupIntegrityVerified, integrity)
+	require.Empty(t, volumeInfos)
+
+	dstBucket, err := objectstore.GetBucket(replica)
+	require.NoError(t, err, "Error opening replica bucket")
+	defer dstBucket.Close()
+	integrity, reason, err := backupobject.VerifyObjects(dstBucket, replica, testObjectPath)
+	require.NoError(t, err, "Error verifying replica")
+	require.Equal(t, stork_api.ApplicationBackupIntegrityVerified, integrity, reason)
+
+	// The objects are re-encrypted with the key of the replica location
+	for _, name := range []string{resourceObjectName, nsObjectName} {
+		require.Equal(t, objects[name], readDecryptedObject(t, replica, name), "Replica of %v doesn't match", name)
+		keyID, streamEncrypted, err := getObjectKeyID(dstBucket, filepath.Join(testObjectPath, name))
+		require.NoError(t, err)
+		require.True(t, streamEncrypted, "Replica of %v isn't encrypted", name)
+		require.Equal(t, "", keyID)
+		reader, err := backupobject.NewReader(dstBucket, location, filepath.Join(testObjectPath, name))
+		if err == nil {
+			_, err = io.ReadAll(reader)
+			reader.Close()
+		}
+		require.Error(t, err, "Replica of %v shouldn't decrypt with the source key", name)
+	}
+
+	replicaMetadata, err := backupobject.ReadMetadata(dstBucket, replica, filepath.Join(testObjectPath, metadataObjectName))
+	require.NoError(t, err, "Error reading replica metadata")
+	require.Equal(t, replica.Name, replicaMetadata.Spec.BackupLocation)
+	require.Equal(t, location.Name, replicaMetadata.Annotations[ApplicationBackupReplicaOfAnnotation])
+}
+
+func TestReplicateBackupWithVolumes(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, stork_api.AddToScheme(scheme))
+	location := newLocalBackupLocation(t, "source", "")
+	location.Location.ReplicaBackupLocation = "replica"
+	replica := newLocalBackupLocation(t, "replica", "")
+	backup := newReplicatedBackup(location)
+	backup.Status.Volumes = []*stork_api.ApplicationBackupVolumeInfo{
+		{Volume: "vol1", Namespace: "ns", PersistentVolumeClaim: "pvc1", DriverName: volume.KDMPDriverName},
+		{Volume: "vol2", Namespace: "ns", PersistentVolumeClaim: "pvc2", DriverName: "pxd"},
+	}
+	require.True(t, needsReplication(backup), "Completed backup should need replication")
+
+	metadata, err := json.Marshal(backup)
+	require.NoError(t, err)
+	writeTestBackup(t, location, map[string][]byte{
+		resourceObjectName: []byte(`[{"kind":"ConfigMap"}]`),
+		metadataObjectName: metadata,
+	})
+	// The kdmp repository of the volume and an object written by the driver
+	// in the backup path
+	volumeObjects := map[string][]byte{
+		"generic-backup/ns-pvc1/kopia.repository": []byte("format"),
+		"generic-backup/ns-pvc1/p0123":            []byte("data"),
+		testObjectPath + "/volumes.json":          []byte("driver"),
+	}
+	srcBucket, err := objectstore.GetBucket(location)
+	require.NoError(t, err, "Error opening bucket")
+	defer srcBucket.Close()
+	for key, data := range volumeObjects {
+		require.NoError(t, srcBucket.WriteAll(context.TODO(), key, data, nil))
+	}
+
+	b := &BackupReplicateController{
+		client:   fake.NewClientBuilder().WithScheme(scheme).WithObjects(backup).Build(),
+		recorder: record.NewFakeRecorder(10),
+	}
+	locations := map[string]*stork_api.BackupLocation{"ns/source": location, "ns/replica": replica}
+	b.replicateBackup(backup, locations)
+	require.NotNil(t, backup.Status.Replica, "Replica status not set")
+	require.Equal(t, stork_api.ApplicationBackupStatusPartialSuccess, backup.Status.Replica.Status, backup.Status.Replica.Reason)
+	require.Equal(t, "replica", backup.Status.Replica.BackupLocation)
+	require.False(t, needsReplication(backup), "Partially replicated backup shouldn't be retried")
+	require.Len(t, backup.Status.Replica.Volumes, 2)
+	require.Equal(t, "vol1", backup.Status.Replica.Volumes[0].Volume)
+	require.Equal(t, stork_api.ApplicationBackupStatusSuccessful, backup.Status.Replica.Volumes[0].Status)
+	require.Equal(t, "vol2", backup.Status.Replica.Volumes[1].Volume)
+	require.Equal(t, stork_api.ApplicationBackupStatusFailed, backup.Status.Replica.Volumes[1].Status)
+
+	dstBucket, err := objectstore.GetBucket(replica)
+	require.NoError(t, err, "Error opening replica bucket")
+	defer dstBucket.Close()
+	for key, data := range volumeObjects {
+		replicaData, err := dstBucket.ReadAll(context.TODO(), key)
+		require.NoError(t, err, "Volume object %v not replicated", key)
+		require.Equal(t, data, replicaData, "Volume object %v doesn't match", key)
+	}
+	integrity, reason, err := backupobject.VerifyObjects(dstBucket, replica, testObjectPath)
+	require.NoError(t, err, "Error verifying replica")
+	require.Equal(t, stork_api.ApplicationBackupIntegrityVerified, integrity, reason)
+
+	updated := &stork_api.ApplicationBackup{}
+	require.NoError(t, b.client.Get(context.TODO(), runtimeclient.ObjectKeyFromObject(backup), updated))
+	require.Equal(t, stork_api.ApplicationBackupStatusPartialSuccess, updated.Status.Replica.Status, "Replica status not saved")
+	require.Len(t, updated.Status.Replica.Volumes, 2, "Volume status of the replica not saved")
+}
@@ -0,0 +1,57 @@
+package controllers
+
+import (
+	"strconv"
+	"time"
+
+	stork_api "github.com/libopenstorage/stork/pkg/apis/stork/v1alpha1"
+)
+
+const (
+	// reasonObjectLocked is the event reason used when the deletion of a
+	// backup is deferred because its objects are still under retention
+	reasonObjectLocked = "ObjectLocked"
+)
+
+// getBackupRetainUntil returns the time until which the objects of the backup
+// are retained in the backup location. Backups uploaded before the retention
+// was recorded in the status fall back to the retention period annotation
+// added by the backup schedule. A zero time is returned if the backup isn't
+// retained.
+func getBackupRetainUntil(backup *stork_api.ApplicationBackup) time.Time {
+	if backup.Status.ObjectLock != nil {
+		return backup.Status.ObjectLock.RetainUntil.Time
+	}
+	if backup.Status.BackupPath == "" {
+		return time.Time{}
+	}
+	value, ok := backup.Annotations[ApplicationBackupObjectLockRetentionAnnotation]
+	if !ok {
+		return time.Time{}
+	}
+	days, err := strconv.Atoi(value)
+	if err != nil || days <= 0 {
+		return time.Time{}
+	}
+	start := backup.Status.FinishTimestamp.Time
+	if start.IsZero() {
+		start = backup.CreationTimestamp.Time
+	}
+	return start.AddDate(0, 0, days)
+}
+
+// isBackupRetained returns true if the objects of the backup can't be deleted
+// from the backup location yet, along with the time until which they are
+// retained. Successful backups with the Retain reclaim policy don't delete
+// their objects so they are never considered retained.
+func isBackupRetained(backup *stork_api.ApplicationBackup) (bool, time.Time) {
+	if backup.Spec.ReclaimPolicy != stork_api.ApplicationBackupReclaimPolicyDelete &&
+		backup.Status.Status == stork_api.ApplicationBackupStatusSuccessful {
+		return false, time.Time{}
+	}
+	retainUntil := getBackupRetainUntil(backup)
+	if retainUntil.IsZero() || !time.Now().Before(retainUntil) {
+		return false, time.Time{}
+	}
+	return true, retainUntil
+}
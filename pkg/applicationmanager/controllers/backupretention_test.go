@@ -0,0 +1,30 @@
+//go:build unittest
+// +build unittest
+
+package controllers
+
+import (
+	"testing"
+	"time"
+
+	stork_api "github.com/libopenstorage/stork/pkg/apis/stork/v1alpha1"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestBackupRetainUntil(t *testing.T) {
+	retainUntil := time.Now().Add(time.Hour).Truncate(time.Second)
+	backup := &stork_api.ApplicationBackup{}
+	backup.Spec.ReclaimPolicy = stork_api.ApplicationBackupReclaimPolicyDelete
+	retained, _ := isBackupRetained(backup)
+	require.False(t, retained, "Backup without object lock shouldn't be retained")
+
+	backup.Status.ObjectLock = &stork_api.ApplicationBackupObjectLock{RetainUntil: metav1.NewTime(retainUntil)}
+	retained, until := isBackupRetained(backup)
+	require.True(t, retained, "Backup should be retained")
+	require.Equal(t, retainUntil, until)
+
+	backup.Status.ObjectLock.RetainUntil = metav1.NewTime(time.Now().Add(-time.Hour))
+	retained, _ = isBackupRetained(backup)
+	require.False(t, retained, "Backup shouldn't be retained after the retention expired")
+}
@@ -2,22 +2,25 @@ package controllers
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
 	"io"
 	"os"
 	"path/filepath"
+	"strings"
 	"time"
 
 	storkv1 "github.com/libopenstorage/stork/pkg/apis/stork/v1alpha1"
-	"github.com/libopenstorage/stork/pkg/crypto"
+	"github.com/libopenstorage/stork/pkg/backupobject"
 	"github.com/libopenstorage/stork/pkg/log"
 	"github.com/libopenstorage/stork/pkg/objectstore"
 	storkops "github.com/portworx/sched-ops/k8s/stork"
 	"github.com/sirupsen/logrus"
 	"gocloud.dev/blob"
+	"gocloud.dev/gcerrors"
+	v1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/tools/record"
 )
 
@@ -25,7 +28,16 @@ import (
 type BackupSyncController struct {
 	Recorder     record.EventRecorder
 	SyncInterval time.Duration
-	stopChannel  chan os.Signal
+	// FullSyncInterval is how often the backups are synced by walking the
+	// location even if its catalog is complete. This picks up the backups
+	// that were written without being added to the catalog, by older
+	// versions of stork or when adding them failed.
+	FullSyncInterval time.Duration
+	stopChannel      chan os.Signal
+	// catalogs are the catalogs read from each backup location
+	catalogs map[types.UID]*backupobject.Catalog
+	// lastFullSync is when each backup location was last walked
+	lastFullSync map[types.UID]time.Time
 }
 
 // Init Initializes the backup sync controller
@@ -62,10 +74,89 @@ func (b *BackupSyncController) syncBackupsFromLocation(location *storkv1.BackupL
 	if !location.Location.Sync {
 		return nil
 	}
+	if location.Location.EncryptionKey != "" {
+		return fmt.Errorf("EncryptionKey is deprecated, use EncryptionKeyV2 instead")
+	}
 	bucket, err := objectstore.GetBucket(location)
 	if err != nil {
 		return err
 	}
+	defer bucket.Close()
+
+	complete, err := backupobject.IsCatalogComplete(bucket, location)
+	if err != nil {
+		return err
+	}
+	if complete && !b.isFullSyncDue(location) {
+		return b.syncBackupsFromCatalog(bucket, location)
+	}
+	if err := b.syncBackupsFromBucket(bucket, location, complete); err != nil {
+		return err
+	}
+	if b.lastFullSync == nil {
+		b.lastFullSync = make(map[types.UID]time.Time)
+	}
+	b.lastFullSync[location.UID] = time.Now()
+	return nil
+}
+
+// isFullSyncDue returns true if the location needs to be walked instead of
+// only reading its catalog
+func (b *BackupSyncController) isFullSyncDue(location *storkv1.BackupLocation) bool {
+	lastFullSync, ok := b.lastFullSync[location.UID]
+	return !ok || time.Since(lastFullSync) >= b.FullSyncInterval
+}
+
+// getCatalog returns the catalog read from the location with the entries
+// added since the last sync
+func (b *BackupSyncController) getCatalog(bucket *blob.Bucket, location *storkv1.BackupLocation) (*backupobject.Catalog, error) {
+	if b.catalogs == nil {
+		b.catalogs = make(map[types.UID]*backupobject.Catalog)
+	}
+	catalog, ok := b.catalogs[location.UID]
+	if !ok {
+		catalog = backupobject.NewCatalog()
+		b.catalogs[location.UID] = catalog
+	}
+	if err := catalog.Update(bucket, location); err != nil {
+		return nil, fmt.Errorf("error reading backup catalog: %v", err)
+	}
+	return catalog, nil
+}
+
+// syncBackupsFromCatalog syncs the backups listed in the catalog of the
+// location. Only the entries added since the last sync are read, the
+// metadata of a backup is only read if it hasn't been synced yet.
+func (b *BackupSyncController) syncBackupsFromCatalog(bucket *blob.Bucket, location *storkv1.BackupLocation) error {
+	catalog, err := b.getCatalog(bucket, location)
+	if err != nil {
+		return err
+	}
+
+	for backupPath, entry := range catalog.Backups() {
+		if b.isBackupSynced(entry.Backup()) {
+			continue
+		}
+		backupInfo, err := backupobject.ReadMetadata(bucket, location, filepath.Join(backupPath, metadataObjectName))
+		if err != nil {
+			log.BackupLocationLog(location).Errorf("Error syncing backup %v: %v", backupPath, err)
+			continue
+		}
+		if err := b.syncBackup(bucket, location, backupPath, backupInfo); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// syncBackupsFromBucket syncs the backups by walking the location. The
+// backups that were found and are missing from the catalog of the location
+// are then added to it so that the following syncs can use it.
+func (b *BackupSyncController) syncBackupsFromBucket(
+	bucket *blob.Bucket,
+	location *storkv1.BackupLocation,
+	catalogComplete bool,
+) error {
 	iterator := bucket.List(&blob.ListOptions{
 		Prefix:    location.Namespace + "/",
 		Delimiter: "/",
@@ -83,7 +174,12 @@ func (b *BackupSyncController) syncBackupsFromLocation(location *storkv1.BackupL
 			backups[object.Key] = true
 		}
 	}
+	catalogEntries := make([]*backupobject.CatalogEntry, 0)
+	allBackupsRead := true
 	for backupName := range backups {
+		if backupName == backupobject.GetCatalogPath(location)+"/" {
+			continue
+		}
 		iterator := bucket.List(&blob.ListOptions{
 			Prefix:    backupName,
 			Delimiter: "/",
@@ -97,68 +193,106 @@ func (b *BackupSyncController) syncBackupsFromLocation(location *storkv1.BackupL
 				return err
 			}
 			if object.IsDir {
-				data, err := bucket.ReadAll(context.TODO(), filepath.Join(object.Key, metadataObjectName))
+				backupInfo, err := backupobject.ReadMetadata(bucket, location, filepath.Join(object.Key, metadataObjectName))
 				if err != nil {
-					log.BackupLocationLog(location).Errorf("Error syncing backup %v: %v", backupName, err)
-					continue
-				}
-				if location.Location.EncryptionKey != "" {
-					return fmt.Errorf("EncryptionKey is deprecated, use EncryptionKeyV2 instead")
-				}
-				if location.Location.EncryptionV2Key != "" {
-					if decryptData, err := crypto.Decrypt(data, location.Location.EncryptionV2Key); err != nil {
-						log.BackupLocationLog(location).Errorf("Error decrypting backup %v during sync: %v", backupName, err)
-					} else {
-						data = decryptData
+					// Backups that are still in progress don't have any
+					// metadata yet, they are added to the catalog when they
+					// complete
+					if gcerrors.Code(err) != gcerrors.NotFound {
+						allBackupsRead = false
 					}
-				}
-				backupInfo := storkv1.ApplicationBackup{}
-				if err = json.Unmarshal(data, &backupInfo); err != nil {
-					log.BackupLocationLog(location).Errorf("Error parsing backup %v during sync: %v", backupName, err)
-					continue
-				}
-
-				localBackupInfo, err := storkops.Instance().GetApplicationBackup(backupInfo.Name, backupInfo.Namespace)
-				if err == nil {
-					// The UIDs will match if it was originally created on this
-					// cluster. We don't want to sync those backups
-					if localBackupInfo.UID == backupInfo.UID {
-						continue
-					}
-				} else if !errors.IsNotFound(err) {
-					// Ignore any other error except NotFound
+					log.BackupLocationLog(location).Errorf("Error syncing backup %v: %v", backupName, err)
 					continue
 				}
+				catalogEntry := backupobject.NewCatalogEntry(backupobject.CatalogOperationAdd, backupInfo)
+				catalogEntry.BackupPath = strings.TrimSuffix(object.Key, "/")
+				catalogEntries = append(catalogEntries, catalogEntry)
 
-				// Now check if we've synced this backup to this cluster
-				// already using the generated name
-				syncedBackupName := b.getSyncedBackupName(&backupInfo)
-				_, err = storkops.Instance().GetApplicationBackup(syncedBackupName, backupInfo.Namespace)
-				if !errors.IsNotFound(err) {
-					// If we get anything other than NotFound ignore it
+				if b.isBackupSynced(backupInfo) {
 					continue
 				}
-
-				backupInfo.Name = syncedBackupName
-				backupInfo.UID = ""
-				backupInfo.ResourceVersion = ""
-				backupInfo.OwnerReferences = nil
-				backupInfo.Spec.ReclaimPolicy = storkv1.ApplicationBackupReclaimPolicyRetain
-				_, err = storkops.Instance().CreateApplicationBackup(&backupInfo)
-				if err != nil {
+				if err := b.syncBackup(bucket, location, object.Key, backupInfo); err != nil {
 					return err
 				}
-
 			}
 		}
 	}
+	if !allBackupsRead {
+		return nil
+	}
+
+	catalog, err := b.getCatalog(bucket, location)
+	if err != nil {
+		return err
+	}
+	added := 0
+	for _, catalogEntry := range catalogEntries {
+		if catalog.Contains(catalogEntry.BackupPath) {
+			continue
+		}
+		if err := backupobject.AppendCatalogEntry(bucket, location, catalogEntry); err != nil {
+			return err
+		}
+		added++
+	}
+	if !catalogComplete {
+		if err := backupobject.MarkCatalogComplete(bucket, location); err != nil {
+			return fmt.Errorf("error marking backup catalog complete: %v", err)
+		}
+	}
+	if added > 0 {
+		log.BackupLocationLog(location).Infof("Added %v backups to the backup catalog", added)
+	}
 	return nil
 }
 
-func (b *BackupSyncController) getSyncedBackupName(backup *storkv1.ApplicationBackup) string {
-	// For scheduled backups use the original name
-	if _, ok := backup.Annotations[ApplicationBackupScheduleNameAnnotation]; ok {
-		return backup.Name
+// isBackupSynced returns true if the backup doesn't need to be synced to
+// this cluster
+func (b *BackupSyncController) isBackupSynced(backupInfo *storkv1.ApplicationBackup) bool {
+	localBackupInfo, err := storkops.Instance().GetApplicationBackup(backupInfo.Name, backupInfo.Namespace)
+	if err == nil {
+		// The UIDs will match if it was originally created on this
+		// cluster. We don't want to sync those backups
+		if localBackupInfo.UID == backupInfo.UID {
+			return true
+		}
+	} else if !errors.IsNotFound(err) {
+		// Ignore any other error except NotFound
+		return true
+	}
+
+	// Now check if we've synced this backup to this cluster
+	// already using the generated name
+	syncedBackupName := backupobject.GetSyncedBackupName(backupInfo)
+	_, err = storkops.Instance().GetApplicationBackup(syncedBackupName, backupInfo.Namespace)
+	// If we get anything other than NotFound ignore it
+	return !errors.IsNotFound(err)
+}
+
+// syncBackup creates the backup read from the metadata at objectPath on this
+// cluster
+func (b *BackupSyncController) syncBackup(
+	bucket *blob.Bucket,
+	location *storkv1.BackupLocation,
+	objectPath string,
+	backupInfo *storkv1.ApplicationBackup,
+) error {
+	integrity, reason, err := backupobject.VerifyObjects(bucket, location, objectPath)
+	if err != nil {
+		log.BackupLocationLog(location).Errorf("Error verifying backup %v: %v", objectPath, err)
+		return nil
 	}
-	return backup.Name + "-" + backup.Status.TriggerTimestamp.Time.Format(nameTimeSuffixFormat)
+
+	backupobject.PrepareSyncedBackup(backupInfo, location, integrity, reason)
+	backupInfo, err = storkops.Instance().CreateApplicationBackup(backupInfo)
+	if err != nil {
+		return err
+	}
+	if integrity == storkv1.ApplicationBackupIntegrityCorrupt {
+		b.Recorder.Event(backupInfo,
+			v1.EventTypeWarning,
+			string(storkv1.ApplicationBackupIntegrityCorrupt),
+			backupInfo.Status.Reason)
+	}
+	return nil
 }
@@ -0,0 +1,105 @@
+package controllers
+
+import (
+	"fmt"
+
+	stork_api "github.com/libopenstorage/stork/pkg/apis/stork/v1alpha1"
+	"github.com/libopenstorage/stork/pkg/resourcecollector"
+	storkops "github.com/portworx/sched-ops/k8s/stork"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// getResourceTransformation returns the ResourceTransformation from the
+// transform specs. nil is returned if no transform spec was provided. The
+// transformation is returned even if it hasn't been validated yet, an error is
+// only returned if it failed validation.
+func getResourceTransformation(transformSpecs []string, namespace string) (*stork_api.ResourceTransformation, error) {
+	if len(transformSpecs) == 0 {
+		return nil, nil
+	}
+	if len(transformSpecs) > 1 {
+		return nil, fmt.Errorf("providing multiple transformation specs is not supported in this release %v", transformSpecs)
+	}
+	transform, err := storkops.Instance().GetResourceTransformation(transformSpecs[0], namespace)
+	if err != nil {
+		return nil, fmt.Errorf("unable to retrieve transformation %s: %v", transformSpecs[0], err)
+	}
+	if transform.Status.Status == stork_api.ResourceTransformationStatusFailed {
+		return nil, fmt.Errorf("transformation %s failed validation", transform.Name)
+	}
+	return transform, nil
+}
+
+// isResourceTransformationReady returns true once the ResourceTransformation
+// from the transform specs has been validated, or if no transform spec was
+// provided. It doesn't wait for the validation so that the caller can check
+// again on the next reconcile.
+func isResourceTransformationReady(transformSpecs []string, namespace string) (bool, error) {
+	transform, err := getResourceTransformation(transformSpecs, namespace)
+	if err != nil {
+		return false, err
+	}
+	return transform == nil || transform.Status.Status == stork_api.ResourceTransformationStatusReady, nil
+}
+
+// transformResources applies the transformation to the objects it selects.
+// It returns the name of the transformation for each object that was patched.
+func transformResources(
+	transform *stork_api.ResourceTransformation,
+	objects []runtime.Unstructured,
+) (map[stork_api.ObjectInfo]string, error) {
+	transformed := make(map[stork_api.ObjectInfo]string)
+	if transform == nil {
+		return transformed, nil
+	}
+	if transform.Status.Status != stork_api.ResourceTransformationStatusReady {
+		return nil, fmt.Errorf("transformation %s is not in ready state: %s", transform.Name, transform.Status.Status)
+	}
+	for _, o := range objects {
+		patches, err := resourcecollector.GetTransformPatches(transform, o)
+		if err != nil {
+			return nil, err
+		}
+		if len(patches) == 0 {
+			continue
+		}
+		info, err := getTransformObjectInfo(o)
+		if err != nil {
+			return nil, err
+		}
+		if err := resourcecollector.TransformResources(o, patches, info.Name, info.Namespace); err != nil {
+			return nil, fmt.Errorf("error transforming %v %v/%v: %v", info.Kind, info.Namespace, info.Name, err)
+		}
+		transformed[info] = transform.Name
+	}
+	return transformed, nil
+}
+
+// getTransformedBy returns the name of the transformation that
+// transformResources applied to the object
+func getTransformedBy(transformed map[stork_api.ObjectInfo]string, info stork_api.ObjectInfo) string {
+	// core Group is reported with a name in the status of the resources
+	if info.Group == "core" {
+		info.Group = ""
+	}
+	return transformed[info]
+}
+
+func getTransformObjectInfo(object runtime.Unstructured) (stork_api.ObjectInfo, error) {
+	metadata, err := meta.Accessor(object)
+	if err != nil {
+		return stork_api.ObjectInfo{}, err
+	}
+	gvk := object.GetObjectKind().GroupVersionKind()
+	return stork_api.ObjectInfo{
+		Name:      metadata.GetName(),
+		Namespace: metadata.GetNamespace(),
+		GroupVersionKind: metav1.GroupVersionKind{
+			Group:   gvk.Group,
+			Version: gvk.Version,
+			Kind:    gvk.Kind,
+		},
+	}, nil
+}
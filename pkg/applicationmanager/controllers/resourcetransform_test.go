@@ -0,0 +1,125 @@
+//go:build unittest
+// +build unittest
+
+package controllers
+
+import (
+	"testing"
+
+	stork_api "github.com/libopenstorage/stork/pkg/apis/stork/v1alpha1"
+	"github.com/libopenstorage/stork/pkg/resourcecollector"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+func newTestTransformObject(apiVersion, kind, name string, labels map[string]string, annotations map[string]string) *unstructured.Unstructured {
+	o := &unstructured.Unstructured{}
+	o.SetAPIVersion(apiVersion)
+	o.SetKind(kind)
+	o.SetName(name)
+	o.SetNamespace("ns")
+	o.SetLabels(labels)
+	o.SetAnnotations(annotations)
+	return o
+}
+
+func newTestResourceTransformation(status stork_api.ResourceTransformationStatusType) *stork_api.ResourceTransformation {
+	return &stork_api.ResourceTransformation{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "staging",
+			Namespace: "ns",
+		},
+		Spec: stork_api.ResourceTransformationSpec{
+			Objects: []stork_api.TransformSpecs{
+				{
+					Resource:  "apps/v1/Deployment",
+					Selectors: map[string]string{"app": "web"},
+					Paths: []stork_api.ResourcePaths{
+						{
+							Path:      "spec.replicas",
+							Value:     "1",
+							Type:      stork_api.IntResourceType,
+							Operation: stork_api.ModifyResourcePathValue,
+						},
+					},
+				},
+				{
+					Resource: "core/v1/ConfigMap",
+					Paths: []stork_api.ResourcePaths{
+						{
+							Path:      "data.host",
+							Value:     "staging.example.com",
+							Type:      stork_api.StringResourceType,
+							Operation: stork_api.AddResourcePath,
+						},
+					},
+				},
+			},
+		},
+		Status: stork_api.ResourceTransformationStatus{
+			Status: status,
+		},
+	}
+}
+
+func TestTransformResources(t *testing.T) {
+	web := newTestTransformObject("apps/v1", "Deployment", "web", map[string]string{"app": "web"}, nil)
+	// Transformed by an earlier migration, but not selected by this
+	// transformation
+	db := newTestTransformObject("apps/v1", "Deployment", "db", map[string]string{"app": "db"},
+		map[string]string{resourcecollector.TransformedResourceName: "true"})
+	config := newTestTransformObject("v1", "ConfigMap", "config", nil, nil)
+	objects := []runtime.Unstructured{web, db, config}
+
+	transformed, err := transformResources(newTestResourceTransformation(stork_api.ResourceTransformationStatusReady), objects)
+	require.NoError(t, err, "Error transforming resources")
+	require.Len(t, transformed, 2, "Unexpected number of transformed objects")
+
+	replicas, _, err := unstructured.NestedInt64(web.Object, "spec", "replicas")
+	require.NoError(t, err)
+	require.Equal(t, int64(1), replicas, "Deployment not transformed")
+	_, found, err := unstructured.NestedFieldNoCopy(db.Object, "spec", "replicas")
+	require.NoError(t, err)
+	require.False(t, found, "Deployment not selected shouldn't be transformed")
+	host, _, err := unstructured.NestedString(config.Object, "data", "host")
+	require.NoError(t, err)
+	require.Equal(t, "staging.example.com", host, "ConfigMap not transformed")
+
+	for _, tc := range []struct {
+		info     stork_api.ObjectInfo
+		expected string
+	}{
+		{
+			info: stork_api.ObjectInfo{Name: "web", Namespace: "ns",
+				GroupVersionKind: metav1.GroupVersionKind{Group: "apps", Version: "v1", Kind: "Deployment"}},
+			expected: "staging",
+		},
+		{
+			info: stork_api.ObjectInfo{Name: "db", Namespace: "ns",
+				GroupVersionKind: metav1.GroupVersionKind{Group: "apps", Version: "v1", Kind: "Deployment"}},
+			expected: "",
+		},
+		{
+			info: stork_api.ObjectInfo{Name: "config", Namespace: "ns",
+				GroupVersionKind: metav1.GroupVersionKind{Group: "core", Version: "v1", Kind: "ConfigMap"}},
+			expected: "staging",
+		},
+	} {
+		require.Equal(t, tc.expected, getTransformedBy(transformed, tc.info), "Unexpected TransformedBy for %v", tc.info.Name)
+	}
+}
+
+func TestTransformResourcesNotReady(t *testing.T) {
+	web := newTestTransformObject("apps/v1", "Deployment", "web", map[string]string{"app": "web"}, nil)
+	_, err := transformResources(newTestResourceTransformation(stork_api.ResourceTransformationStatusInProgress), []runtime.Unstructured{web})
+	require.Error(t, err, "Expected error for transformation that isn't ready")
+	_, found, err := unstructured.NestedFieldNoCopy(web.Object, "spec", "replicas")
+	require.NoError(t, err)
+	require.False(t, found, "Deployment shouldn't be transformed")
+
+	transformed, err := transformResources(nil, []runtime.Unstructured{web})
+	require.NoError(t, err, "Error without transformation")
+	require.Empty(t, transformed, "No objects should be transformed without transformation")
+}
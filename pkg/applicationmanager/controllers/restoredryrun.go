@@ -0,0 +1,434 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+
+	"github.com/libopenstorage/stork/drivers/volume"
+	storkapi "github.com/libopenstorage/stork/pkg/apis/stork/v1alpha1"
+	"github.com/libopenstorage/stork/pkg/log"
+	"github.com/libopenstorage/stork/pkg/resourcecollector"
+	"github.com/portworx/sched-ops/k8s/core"
+	storkops "github.com/portworx/sched-ops/k8s/stork"
+	v1 "k8s.io/api/core/v1"
+	k8s_errors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// dryRunRestore records in the status what the restore would do with each
+// namespace, volume and resource of the backup. Everything is only read from
+// the cluster, the restore is done once the status has been recorded.
+func (a *ApplicationRestoreController) dryRunRestore(restore *storkapi.ApplicationRestore) error {
+	backup, err := storkops.Instance().GetApplicationBackup(restore.GetBackupName(), restore.Namespace)
+	if err != nil {
+		return fmt.Errorf("error getting backup: %v", err)
+	}
+	if !a.namespaceRestoreAllowed(restore) {
+		return a.failDryRun(restore, fmt.Errorf("Spec.Namespaces should only contain the current namespace"))
+	}
+	if ok, err := a.verifyBackupIntegrity(restore, backup); err != nil || !ok {
+		return err
+	}
+	if ready, err := isResourceTransformationReady(restore.Spec.TransformSpecs, restore.Namespace); err != nil {
+		return a.failDryRun(restore, err)
+	} else if !ready {
+		log.ApplicationRestoreLog(restore).Infof("Waiting for transformation %v to be validated", restore.Spec.TransformSpecs)
+		return nil
+	}
+
+	restore.Status.Resources = make([]*storkapi.ApplicationRestoreResourceInfo, 0)
+	restore.Status.Volumes = make([]*storkapi.ApplicationRestoreVolumeInfo, 0)
+	if err := a.dryRunNamespaces(restore, backup); err != nil {
+		return a.failDryRun(restore, err)
+	}
+	objects, err := a.readResources(backup, restore.Spec.BackupLocation, restore.Namespace)
+	if err != nil {
+		return a.failDryRun(restore, fmt.Errorf("error downloading resources: %v", err))
+	}
+	objectMap, err := getRestoreObjectsMap(restore, objects)
+	if err != nil {
+		return a.failDryRun(restore, err)
+	}
+	if err := a.dryRunVolumes(restore, backup, objectMap); err != nil {
+		return a.failDryRun(restore, err)
+	}
+	if err := a.dryRunResources(restore, objects, objectMap); err != nil {
+		return a.failDryRun(restore, err)
+	}
+
+	actions := make(map[storkapi.ApplicationRestoreDryRunActionType]int)
+	for _, resource := range restore.Status.Resources {
+		actions[resource.DryRunAction]++
+	}
+	restoredVolumes := 0
+	for _, volumeInfo := range restore.Status.Volumes {
+		if volumeInfo.DryRunAction != storkapi.ApplicationRestoreDryRunActionSkip &&
+			volumeInfo.DryRunAction != storkapi.ApplicationRestoreDryRunActionConflict {
+			restoredVolumes++
+		}
+	}
+	message := fmt.Sprintf("Dry run completed, resources to create: %v, to replace: %v, to skip: %v, conflicting: %v, volumes to restore: %v",
+		actions[storkapi.ApplicationRestoreDryRunActionCreate],
+		actions[storkapi.ApplicationRestoreDryRunActionReplace],
+		actions[storkapi.ApplicationRestoreDryRunActionSkip],
+		actions[storkapi.ApplicationRestoreDryRunActionConflict],
+		restoredVolumes)
+	log.ApplicationRestoreLog(restore).Infof(message)
+	a.recorder.Event(restore,
+		v1.EventTypeNormal,
+		string(storkapi.ApplicationRestoreStatusSuccessful),
+		message)
+	restore.Status.ResourceCount = len(restore.Status.Resources)
+	restore.Status.Stage = storkapi.ApplicationRestoreStageFinal
+	restore.Status.Status = storkapi.ApplicationRestoreStatusSuccessful
+	restore.Status.Reason = message
+	restore.Status.FinishTimestamp = metav1.Now()
+	restore.Status.LastUpdateTimestamp = metav1.Now()
+	return a.client.Update(context.TODO(), restore)
+}
+
+func (a *ApplicationRestoreController) failDryRun(restore *storkapi.ApplicationRestore, err error) error {
+	message := fmt.Sprintf("Dry run failed: %v", err)
+	log.ApplicationRestoreLog(restore).Errorf(message)
+	a.recorder.Event(restore,
+		v1.EventTypeWarning,
+		string(storkapi.ApplicationRestoreStatusFailed),
+		message)
+	restore.Status.Stage = storkapi.ApplicationRestoreStageFinal
+	restore.Status.Status = storkapi.ApplicationRestoreStatusFailed
+	restore.Status.Reason = message
+	restore.Status.FinishTimestamp = metav1.Now()
+	restore.Status.LastUpdateTimestamp = metav1.Now()
+	return a.client.Update(context.TODO(), restore)
+}
+
+func addDryRunResource(
+	restore *storkapi.ApplicationRestore,
+	info storkapi.ObjectInfo,
+	action storkapi.ApplicationRestoreDryRunActionType,
+	reason string,
+) {
+	restore.Status.Resources = append(restore.Status.Resources, &storkapi.ApplicationRestoreResourceInfo{
+		ObjectInfo:   info,
+		Reason:       reason,
+		DryRunAction: action,
+	})
+}
+
+// dryRunNamespaces checks which of the namespaces being restored to would be
+// created
+func (a *ApplicationRestoreController) dryRunNamespaces(
+	restore *storkapi.ApplicationRestore,
+	backup *storkapi.ApplicationBackup,
+) error {
+	namespaces := make([]string, 0)
+	nsData, err := a.downloadObject(backup, restore.Spec.BackupLocation, restore.Namespace, nsObjectName, true)
+	if err != nil {
+		return err
+	}
+	if nsData != nil {
+		var backupNamespaces []*v1.Namespace
+		if err = json.Unmarshal(nsData, &backupNamespaces); err != nil {
+			return err
+		}
+		for _, ns := range backupNamespaces {
+			if restoreNS, ok := restore.Spec.NamespaceMapping[ns.Name]; ok {
+				namespaces = append(namespaces, restoreNS)
+			}
+		}
+	} else {
+		for _, restoreNS := range restore.Spec.NamespaceMapping {
+			namespaces = append(namespaces, restoreNS)
+		}
+	}
+
+	for _, ns := range namespaces {
+		info := storkapi.ObjectInfo{
+			Name: ns,
+			GroupVersionKind: metav1.GroupVersionKind{
+				Group:   "core",
+				Version: "v1",
+				Kind:    "Namespace",
+			},
+		}
+		_, err := core.Instance().GetNamespace(ns)
+		if err != nil {
+			if !k8s_errors.IsNotFound(err) {
+				return err
+			}
+			addDryRunResource(restore, info, storkapi.ApplicationRestoreDryRunActionCreate,
+				"Namespace would be created")
+		} else if restore.Spec.ReplacePolicy == storkapi.ApplicationRestoreReplacePolicyDelete {
+			addDryRunResource(restore, info, storkapi.ApplicationRestoreDryRunActionReplace,
+				"Namespace already exists, its labels and annotations would be replaced")
+		} else if restore.Spec.ReplacePolicy == storkapi.ApplicationRestoreReplacePolicyMerge {
+			addDryRunResource(restore, info, storkapi.ApplicationRestoreDryRunActionReplace,
+				"Namespace already exists, labels and annotations from the backup would be merged into it")
+		} else {
+			addDryRunResource(restore, info, storkapi.ApplicationRestoreDryRunActionSkip,
+				"Namespace already exists, only missing labels and annotations would be added")
+		}
+	}
+	return nil
+}
+
+// dryRunVolumes checks which volumes would be restored and by which driver
+func (a *ApplicationRestoreController) dryRunVolumes(
+	restore *storkapi.ApplicationRestore,
+	backup *storkapi.ApplicationBackup,
+	objectMap map[storkapi.ObjectInfo]bool,
+) error {
+	info := storkapi.ObjectInfo{
+		GroupVersionKind: metav1.GroupVersionKind{
+			Group:   "core",
+			Version: "v1",
+			Kind:    "PersistentVolumeClaim",
+		},
+	}
+	for _, volumeBackup := range backup.Status.Volumes {
+		restoreNamespace, ok := restore.Spec.NamespaceMapping[volumeBackup.Namespace]
+		if !ok {
+			continue
+		}
+		info.Name = volumeBackup.PersistentVolumeClaim
+		info.Namespace = volumeBackup.Namespace
+		if !isRestoreObjectSelected(restore, objectMap, info) {
+			continue
+		}
+
+		driverName := volumeBackup.DriverName
+		if driverName == "" {
+			driverName = volume.GetDefaultDriverName()
+		}
+		volumeInfo := &storkapi.ApplicationRestoreVolumeInfo{
+			PersistentVolumeClaim:    volumeBackup.PersistentVolumeClaim,
+			PersistentVolumeClaimUID: volumeBackup.PersistentVolumeClaimUID,
+			SourceNamespace:          volumeBackup.Namespace,
+			SourceVolume:             volumeBackup.Volume,
+			DriverName:               driverName,
+			Zones:                    volumeBackup.Zones,
+			TotalSize:                volumeBackup.TotalSize,
+		}
+		restore.Status.Volumes = append(restore.Status.Volumes, volumeInfo)
+
+		if _, err := volume.Get(driverName); err != nil {
+			volumeInfo.DryRunAction = storkapi.ApplicationRestoreDryRunActionConflict
+			volumeInfo.Reason = fmt.Sprintf("Volume driver %v isn't available: %v", driverName, err)
+			continue
+		}
+		storageClass := volumeBackup.StorageClass
+		if newStorageClass, ok := restore.Spec.StorageClassMapping[storageClass]; ok && newStorageClass != "" {
+			storageClass = newStorageClass
+		}
+
+		_, err := core.Instance().GetPersistentVolumeClaim(volumeBackup.PersistentVolumeClaim, restoreNamespace)
+		if err != nil {
+			if !k8s_errors.IsNotFound(err) {
+				return err
+			}
+			volumeInfo.DryRunAction = storkapi.ApplicationRestoreDryRunActionCreate
+			volumeInfo.Reason = fmt.Sprintf("Volume would be restored to %v/%v by driver %v with storage class %q",
+				restoreNamespace, volumeBackup.PersistentVolumeClaim, driverName, storageClass)
+		} else if restore.Spec.ReplacePolicy == storkapi.ApplicationRestoreReplacePolicyDelete {
+			volumeInfo.DryRunAction = storkapi.ApplicationRestoreDryRunActionReplace
+			volumeInfo.Reason = fmt.Sprintf("Existing PVC %v/%v would be replaced with a volume restored by driver %v with storage class %q",
+				restoreNamespace, volumeBackup.PersistentVolumeClaim, driverName, storageClass)
+		} else {
+			volumeInfo.DryRunAction = storkapi.ApplicationRestoreDryRunActionSkip
+			volumeInfo.Reason = fmt.Sprintf("PVC %v/%v already exists and ReplacePolicy is set to %v",
+				restoreNamespace, volumeBackup.PersistentVolumeClaim, restore.Spec.ReplacePolicy)
+		}
+	}
+	return nil
+}
+
+// dryRunResources prepares the resources the same way they are prepared for
+// apply and checks what applying them would do with the existing resources
+func (a *ApplicationRestoreController) dryRunResources(
+	restore *storkapi.ApplicationRestore,
+	objects []runtime.Unstructured,
+	objectMap map[storkapi.ObjectInfo]bool,
+) error {
+	var opts resourcecollector.Options
+	if len(restore.Spec.RancherProjectMapping) != 0 {
+		opts = resourcecollector.Options{
+			RancherProjectMappings: getRancherProjectMapping(restore),
+		}
+	}
+	transform, err := getResourceTransformation(restore.Spec.TransformSpecs, restore.Namespace)
+	if err != nil {
+		return err
+	}
+
+	for _, o := range objects {
+		metadata, err := meta.Accessor(o)
+		if err != nil {
+			return err
+		}
+		// Resources from namespaces that aren't being restored aren't
+		// reported
+		if metadata.GetNamespace() != "" {
+			if _, ok := restore.Spec.NamespaceMapping[metadata.GetNamespace()]; !ok {
+				continue
+			}
+		}
+		gvk := o.GetObjectKind().GroupVersionKind()
+		info := storkapi.ObjectInfo{
+			Name:      metadata.GetName(),
+			Namespace: metadata.GetNamespace(),
+			GroupVersionKind: metav1.GroupVersionKind{
+				Group:   gvk.Group,
+				Version: gvk.Version,
+				Kind:    gvk.Kind,
+			},
+		}
+
+		selected, err := isRestoreResourceSelected(restore, objectMap, o)
+		if err != nil {
+			return err
+		}
+		if !selected {
+			addDryRunResource(restore, info, storkapi.ApplicationRestoreDryRunActionSkip,
+				"Resource isn't selected for the restore")
+			continue
+		}
+		// The restored volumes aren't known yet so the PVs are skipped, they
+		// are reported with the volumes
+		skip, err := a.resourceCollector.PrepareResourceForApply(
+			o,
+			objects,
+			objectMap,
+			restore.Spec.NamespaceMapping,
+			restore.Spec.StorageClassMapping,
+			nil,
+			restore.Spec.IncludeOptionalResourceTypes,
+			restore.Status.Volumes,
+			&opts,
+			restore.Spec.BackupLocation,
+			restore.Namespace,
+		)
+		if err != nil {
+			addDryRunResource(restore, info, storkapi.ApplicationRestoreDryRunActionConflict,
+				fmt.Sprintf("Error preparing resource: %v", err))
+			continue
+		}
+		if skip {
+			if gvk.Kind == "PersistentVolume" {
+				continue
+			}
+			addDryRunResource(restore, info, storkapi.ApplicationRestoreDryRunActionSkip,
+				"Resource isn't selected for the restore")
+			continue
+		}
+		// Report the resource with its namespace after the mapping
+		info.Namespace = metadata.GetNamespace()
+		// Compare the resource with the existing one as it would be applied
+		if _, err := transformResources(transform, []runtime.Unstructured{o}); err != nil {
+			addDryRunResource(restore, info, storkapi.ApplicationRestoreDryRunActionConflict,
+				fmt.Sprintf("Error transforming resource: %v", err))
+			continue
+		}
+
+		existing, err := a.resourceCollector.GetExistingResource(a.dynamicInterface, o)
+		if err != nil {
+			addDryRunResource(restore, info, storkapi.ApplicationRestoreDryRunActionConflict,
+				fmt.Sprintf("Error checking for existing resource: %v", err))
+			continue
+		}
+		switch {
+		case existing == nil:
+			addDryRunResource(restore, info, storkapi.ApplicationRestoreDryRunActionCreate,
+				"Resource would be created")
+		case a.resourceCollector.MergeSupported(o, &opts):
+			addDryRunResource(restore, info, storkapi.ApplicationRestoreDryRunActionReplace,
+				"Resource would be merged with the existing resource")
+		case restore.Spec.ReplacePolicy == storkapi.ApplicationRestoreReplacePolicyMerge &&
+			gvk.Kind != "PersistentVolumeClaim":
+			a.dryRunMergeResource(restore, info, o, &opts)
+		case restore.Spec.ReplacePolicy == storkapi.ApplicationRestoreReplacePolicyDelete:
+			addDryRunResource(restore, info, storkapi.ApplicationRestoreDryRunActionReplace,
+				"Existing resource would be deleted and recreated")
+		case !isContentSubset(o.UnstructuredContent(), existing.UnstructuredContent()):
+			addDryRunResource(restore, info, storkapi.ApplicationRestoreDryRunActionConflict,
+				fmt.Sprintf("Existing resource differs from the backup and would be retained as ReplacePolicy is set to %v",
+					restore.Spec.ReplacePolicy))
+		default:
+			addDryRunResource(restore, info, storkapi.ApplicationRestoreDryRunActionSkip,
+				"Resource already exists and would be retained")
+		}
+	}
+	return nil
+}
+
+// dryRunMergeResource does a server-side dry run of merging the resource into
+// the existing one and reports the fields that would be in conflict
+func (a *ApplicationRestoreController) dryRunMergeResource(
+	restore *storkapi.ApplicationRestore,
+	info storkapi.ObjectInfo,
+	object runtime.Unstructured,
+	opts *resourcecollector.Options,
+) {
+	conflicts, err := a.resourceCollector.MergeResource(a.dynamicInterface, object, opts, true)
+	if err != nil {
+		addDryRunResource(restore, info, storkapi.ApplicationRestoreDryRunActionConflict,
+			fmt.Sprintf("Error merging resource: %v", err))
+		return
+	}
+	if len(conflicts) != 0 {
+		addDryRunResource(restore, info, storkapi.ApplicationRestoreDryRunActionConflict,
+			"Resource would be merged into the existing resource, conflicting fields managed by others would be overwritten")
+		restore.Status.Resources[len(restore.Status.Resources)-1].Conflicts = conflicts
+		return
+	}
+	addDryRunResource(restore, info, storkapi.ApplicationRestoreDryRunActionReplace,
+		"Resource would be merged into the existing resource")
+}
+
+// isContentSubset returns true if all the fields of the backed up resource
+// other than its metadata and status are set to the same values in the
+// existing resource. Fields that are only set on the existing resource, like
+// defaults, are ignored.
+func isContentSubset(backup map[string]interface{}, existing map[string]interface{}) bool {
+	for key, value := range backup {
+		switch key {
+		case "metadata", "status", "apiVersion", "kind":
+			continue
+		}
+		if !isValueSubset(value, existing[key]) {
+			return false
+		}
+	}
+	return true
+}
+
+func isValueSubset(backup interface{}, existing interface{}) bool {
+	switch backupValue := backup.(type) {
+	case map[string]interface{}:
+		existingValue, ok := existing.(map[string]interface{})
+		if !ok {
+			return false
+		}
+		for key, value := range backupValue {
+			if !isValueSubset(value, existingValue[key]) {
+				return false
+			}
+		}
+		return true
+	case []interface{}:
+		existingValue, ok := existing.([]interface{})
+		if !ok || len(existingValue) != len(backupValue) {
+			return false
+		}
+		for i := range backupValue {
+			if !isValueSubset(backupValue[i], existingValue[i]) {
+				return false
+			}
+		}
+		return true
+	}
+	return reflect.DeepEqual(backup, existing)
+}
@@ -0,0 +1,182 @@
+//go:build unittest
+// +build unittest
+
+package controllers
+
+import (
+	"testing"
+
+	storkapi "github.com/libopenstorage/stork/pkg/apis/stork/v1alpha1"
+	"github.com/stretchr/testify/require"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	"k8s.io/client-go/tools/record"
+)
+
+func newTestConfigMap(namespace, name string, data map[string]string) *v1.ConfigMap {
+	return &v1.ConfigMap{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: "v1",
+			Kind:       "ConfigMap",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: namespace,
+		},
+		Data: data,
+	}
+}
+
+func newTestBackupObject(t *testing.T, namespace, name string, data map[string]string) runtime.Unstructured {
+	content, err := runtime.DefaultUnstructuredConverter.ToUnstructured(newTestConfigMap(namespace, name, data))
+	require.NoError(t, err)
+	return &unstructured.Unstructured{Object: content}
+}
+
+func TestDryRunResources(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, v1.AddToScheme(scheme))
+
+	tests := []struct {
+		name          string
+		replacePolicy storkapi.ApplicationRestoreReplacePolicyType
+		actions       map[string]storkapi.ApplicationRestoreDryRunActionType
+	}{
+		{
+			name:          "retain",
+			replacePolicy: storkapi.ApplicationRestoreReplacePolicyRetain,
+			actions: map[string]storkapi.ApplicationRestoreDryRunActionType{
+				"new":        storkapi.ApplicationRestoreDryRunActionCreate,
+				"same":       storkapi.ApplicationRestoreDryRunActionSkip,
+				"changed":    storkapi.ApplicationRestoreDryRunActionConflict,
+				"unselected": storkapi.ApplicationRestoreDryRunActionSkip,
+			},
+		},
+		{
+			name:          "delete",
+			replacePolicy: storkapi.ApplicationRestoreReplacePolicyDelete,
+			actions: map[string]storkapi.ApplicationRestoreDryRunActionType{
+				"new":        storkapi.ApplicationRestoreDryRunActionCreate,
+				"same":       storkapi.ApplicationRestoreDryRunActionReplace,
+				"changed":    storkapi.ApplicationRestoreDryRunActionReplace,
+				"unselected": storkapi.ApplicationRestoreDryRunActionSkip,
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// The existing resources have fields that aren't in the backup,
+			// they are ignored when comparing them
+			existing := newTestConfigMap("dest", "same", map[string]string{"key": "value"})
+			existing.Labels = map[string]string{"app": "test"}
+			dynamicClient := dynamicfake.NewSimpleDynamicClient(scheme,
+				existing,
+				newTestConfigMap("dest", "changed", map[string]string{"key": "other"}),
+			)
+			a := &ApplicationRestoreController{
+				recorder:         record.NewFakeRecorder(10),
+				dynamicInterface: dynamicClient,
+			}
+			restore := &storkapi.ApplicationRestore{}
+			restore.Spec.ReplacePolicy = test.replacePolicy
+			restore.Spec.NamespaceMapping = map[string]string{"source": "dest"}
+
+			objects := []runtime.Unstructured{
+				newTestBackupObject(t, "source", "new", map[string]string{"key": "value"}),
+				newTestBackupObject(t, "source", "same", map[string]string{"key": "value"}),
+				newTestBackupObject(t, "source", "changed", map[string]string{"key": "value"}),
+				newTestBackupObject(t, "source", "unselected", map[string]string{"key": "value"}),
+				// Resources from namespaces that aren't restored aren't
+				// reported
+				newTestBackupObject(t, "other", "other", map[string]string{"key": "value"}),
+			}
+			objectMap := make(map[storkapi.ObjectInfo]bool)
+			for _, name := range []string{"new", "same", "changed", "other"} {
+				namespace := "source"
+				if name == "other" {
+					namespace = "other"
+				}
+				objectMap[storkapi.ObjectInfo{
+					Name:      name,
+					Namespace: namespace,
+					GroupVersionKind: metav1.GroupVersionKind{
+						Group:   "core",
+						Version: "v1",
+						Kind:    "ConfigMap",
+					},
+				}] = true
+			}
+
+			require.NoError(t, a.dryRunResources(restore, objects, objectMap), "Error running dry run")
+			actions := make(map[string]storkapi.ApplicationRestoreDryRunActionType)
+			for _, resource := range restore.Status.Resources {
+				require.NotEmpty(t, resource.Reason, "Reason not set for %v", resource.Name)
+				require.Equal(t, "ConfigMap", resource.Kind)
+				if resource.Name != "unselected" {
+					require.Equal(t, "dest", resource.Namespace, "Resource should be reported in the restored namespace")
+				}
+				actions[resource.Name] = resource.DryRunAction
+			}
+			require.Equal(t, test.actions, actions)
+
+			// The dry run only reads from the cluster
+			for _, action := range dynamicClient.Actions() {
+				require.Equal(t, "get", action.GetVerb(), "Unexpected %v of %v", action.GetVerb(), action.GetResource())
+			}
+			require.Len(t, dynamicClient.Actions(), 3, "Every selected resource should be looked up")
+		})
+	}
+}
+
+func TestIsContentSubset(t *testing.T) {
+	existing := map[string]interface{}{
+		"metadata": map[string]interface{}{"name": "existing"},
+		"spec": map[string]interface{}{
+			"replicas": int64(1),
+			"ports":    []interface{}{int64(80), int64(443)},
+			"default":  "value",
+		},
+	}
+	tests := []struct {
+		name   string
+		backup map[string]interface{}
+		subset bool
+	}{
+		{
+			name: "metadata ignored",
+			backup: map[string]interface{}{
+				"metadata": map[string]interface{}{"name": "backup"},
+				"status":   map[string]interface{}{"ready": true},
+			},
+			subset: true,
+		},
+		{
+			name: "defaults ignored",
+			backup: map[string]interface{}{
+				"spec": map[string]interface{}{"replicas": int64(1), "ports": []interface{}{int64(80), int64(443)}},
+			},
+			subset: true,
+		},
+		{
+			name:   "changed value",
+			backup: map[string]interface{}{"spec": map[string]interface{}{"replicas": int64(2)}},
+			subset: false,
+		},
+		{
+			name:   "changed list",
+			backup: map[string]interface{}{"spec": map[string]interface{}{"ports": []interface{}{int64(80)}}},
+			subset: false,
+		},
+		{
+			name:   "missing field",
+			backup: map[string]interface{}{"data": map[string]interface{}{"key": "value"}},
+			subset: false,
+		},
+	}
+	for _, test := range tests {
+		require.Equal(t, test.subset, isContentSubset(test.backup, existing), test.name)
+	}
+}
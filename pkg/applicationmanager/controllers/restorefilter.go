@@ -0,0 +1,188 @@
+package controllers
+
+import (
+	"fmt"
+
+	storkapi "github.com/libopenstorage/stork/pkg/apis/stork/v1alpha1"
+	"github.com/libopenstorage/stork/pkg/resourcecollector"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// restoreNamespaceSubset limits the namespace mapping of the restore to the
+// namespaces selected in Spec.Namespaces. Selected namespaces without a
+// mapping are restored to the same namespace.
+func restoreNamespaceSubset(restore *storkapi.ApplicationRestore, backup *storkapi.ApplicationBackup) error {
+	if len(restore.Spec.Namespaces) == 0 {
+		return nil
+	}
+	selected := make(map[string]bool)
+	for _, ns := range restore.Spec.Namespaces {
+		found := false
+		for _, backupNamespace := range backup.Spec.Namespaces {
+			if ns == backupNamespace {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return fmt.Errorf("namespace %v selected for restore isn't part of backup %v", ns, backup.Name)
+		}
+		selected[ns] = true
+		if _, ok := restore.Spec.NamespaceMapping[ns]; !ok {
+			restore.Spec.NamespaceMapping[ns] = ns
+		}
+	}
+	for ns := range restore.Spec.NamespaceMapping {
+		if !selected[ns] {
+			delete(restore.Spec.NamespaceMapping, ns)
+		}
+	}
+	return nil
+}
+
+// isRestoreFiltered returns true if the resources to restore are selected by
+// their labels or types
+func isRestoreFiltered(restore *storkapi.ApplicationRestore) bool {
+	return len(restore.Spec.Selectors) != 0 ||
+		len(restore.Spec.ExcludeSelectors) != 0 ||
+		len(restore.Spec.ResourceTypes) != 0 ||
+		len(restore.Spec.ExcludeResourceTypes) != 0
+}
+
+// validateNFSRestore returns an error if the restore uses options that the
+// resource restore job for NFS backup locations doesn't support. The job only
+// restores the objects in IncludeResources and applies them as they were
+// backed up.
+func validateNFSRestore(restore *storkapi.ApplicationRestore) error {
+	if isRestoreFiltered(restore) {
+		return fmt.Errorf("selectors and resource types can't be used to select the resources to restore, use includeResources instead")
+	}
+	if len(restore.Spec.TransformSpecs) != 0 {
+		return fmt.Errorf("transformSpecs can't be used to transform the resources to restore")
+	}
+	// The job reads the backup to restore from the spec
+	if restore.Spec.BackupScheduleName != "" {
+		return fmt.Errorf("backupScheduleName can't be used to pick the backup to restore, use backupName instead")
+	}
+	// The job replaces existing resources instead of merging into them
+	if restore.Spec.ReplacePolicy == storkapi.ApplicationRestoreReplacePolicyMerge {
+		return fmt.Errorf("replacePolicy %v isn't supported", restore.Spec.ReplacePolicy)
+	}
+	return nil
+}
+
+// getRestoreObjectsMap returns the map of objects from the backup to be
+// restored, to be passed to PrepareResourceForApply. Without selectors or
+// resource types this is the map of IncludeResources. Otherwise every object
+// matching them, and IncludeResources if set, is added to the map. The map
+// is empty if nothing matches, so isRestoreObjectSelected needs to be used
+// to check if an object is selected.
+func getRestoreObjectsMap(
+	restore *storkapi.ApplicationRestore,
+	objects []runtime.Unstructured,
+) (map[storkapi.ObjectInfo]bool, error) {
+	includeObjects := storkapi.CreateObjectsMap(restore.Spec.IncludeResources)
+	if !isRestoreFiltered(restore) {
+		return includeObjects, nil
+	}
+
+	objectMap := make(map[storkapi.ObjectInfo]bool)
+	for _, o := range objects {
+		metadata, err := meta.Accessor(o)
+		if err != nil {
+			return nil, err
+		}
+		gvk := o.GetObjectKind().GroupVersionKind()
+		info := getRestoreObjectInfo(o, metadata)
+		if len(includeObjects) != 0 {
+			if val, present := includeObjects[info]; !present || !val {
+				continue
+			}
+		}
+		if !isResourceTypeSelected(gvk.Kind, restore.Spec.ResourceTypes, restore.Spec.ExcludeResourceTypes) {
+			continue
+		}
+		if len(restore.Spec.Selectors) != 0 &&
+			!labels.SelectorFromSet(restore.Spec.Selectors).Matches(labels.Set(metadata.GetLabels())) {
+			continue
+		}
+		if resourcecollector.SkipBasedOnExcludeSelectorsLabel(metadata.GetLabels(), restore.Spec.ExcludeSelectors) {
+			continue
+		}
+		objectMap[info] = true
+	}
+	return objectMap, nil
+}
+
+// isRestoreObjectSelected returns true if the object is selected by the map
+// returned by getRestoreObjectsMap. An empty map only selects every object if
+// the restore doesn't have selectors or resource types.
+func isRestoreObjectSelected(
+	restore *storkapi.ApplicationRestore,
+	objectMap map[storkapi.ObjectInfo]bool,
+	info storkapi.ObjectInfo,
+) bool {
+	if len(objectMap) == 0 {
+		return !isRestoreFiltered(restore)
+	}
+	return objectMap[info]
+}
+
+// isRestoreResourceSelected returns true if the resource from the backup
+// needs to be passed to PrepareResourceForApply. PrepareResourceForApply
+// applies every resource when the map is empty, so the resources are checked
+// before. PVs are always passed, they are restored with the PVCs bound to
+// them.
+func isRestoreResourceSelected(
+	restore *storkapi.ApplicationRestore,
+	objectMap map[storkapi.ObjectInfo]bool,
+	o runtime.Unstructured,
+) (bool, error) {
+	if o.GetObjectKind().GroupVersionKind().Kind == "PersistentVolume" {
+		return true, nil
+	}
+	metadata, err := meta.Accessor(o)
+	if err != nil {
+		return false, err
+	}
+	return isRestoreObjectSelected(restore, objectMap, getRestoreObjectInfo(o, metadata)), nil
+}
+
+// getRestoreObjectInfo returns the ObjectInfo of the resource, used as the
+// key in the map of objects to restore
+func getRestoreObjectInfo(o runtime.Unstructured, metadata metav1.Object) storkapi.ObjectInfo {
+	gvk := o.GetObjectKind().GroupVersionKind()
+	info := storkapi.ObjectInfo{
+		GroupVersionKind: metav1.GroupVersionKind{
+			Group:   gvk.Group,
+			Version: gvk.Version,
+			Kind:    gvk.Kind,
+		},
+		Name:      metadata.GetName(),
+		Namespace: metadata.GetNamespace(),
+	}
+	if info.Group == "" {
+		info.Group = "core"
+	}
+	return info
+}
+
+func isResourceTypeSelected(kind string, resourceTypes []string, excludeResourceTypes []string) bool {
+	for _, excludeType := range excludeResourceTypes {
+		if kind == excludeType {
+			return false
+		}
+	}
+	if len(resourceTypes) == 0 {
+		return true
+	}
+	for _, resourceType := range resourceTypes {
+		if kind == resourceType {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,259 @@
+//go:build unittest
+// +build unittest
+
+package controllers
+
+import (
+	"testing"
+
+	storkapi "github.com/libopenstorage/stork/pkg/apis/stork/v1alpha1"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+func TestValidateNFSRestore(t *testing.T) {
+	tests := []struct {
+		name      string
+		spec      storkapi.ApplicationRestoreSpec
+		supported bool
+	}{
+		{
+			name:      "no options",
+			supported: true,
+		},
+		{
+			name:      "replace policy delete",
+			spec:      storkapi.ApplicationRestoreSpec{ReplacePolicy: storkapi.ApplicationRestoreReplacePolicyDelete},
+			supported: true,
+		},
+		{
+			name:      "replace policy retain",
+			spec:      storkapi.ApplicationRestoreSpec{ReplacePolicy: storkapi.ApplicationRestoreReplacePolicyRetain},
+			supported: true,
+		},
+		{
+			name: "replace policy merge",
+			spec: storkapi.ApplicationRestoreSpec{ReplacePolicy: storkapi.ApplicationRestoreReplacePolicyMerge},
+		},
+		{
+			name:      "backup name",
+			spec:      storkapi.ApplicationRestoreSpec{BackupName: "backup"},
+			supported: true,
+		},
+		{
+			name: "backup schedule name",
+			spec: storkapi.ApplicationRestoreSpec{BackupScheduleName: "schedule"},
+		},
+		{
+			name: "transformation",
+			spec: storkapi.ApplicationRestoreSpec{TransformSpecs: []string{"staging"}},
+		},
+		{
+			name: "selectors",
+			spec: storkapi.ApplicationRestoreSpec{Selectors: map[string]string{"app": "web"}},
+		},
+		{
+			name: "resource types",
+			spec: storkapi.ApplicationRestoreSpec{ResourceTypes: []string{"Deployment"}},
+		},
+		{
+			name:      "include resources",
+			spec:      storkapi.ApplicationRestoreSpec{IncludeResources: []storkapi.ObjectInfo{{Name: "web"}}},
+			supported: true,
+		},
+	}
+	for _, test := range tests {
+		restore := &storkapi.ApplicationRestore{Spec: test.spec}
+		if test.supported {
+			require.NoError(t, validateNFSRestore(restore), test.name)
+		} else {
+			require.Error(t, validateNFSRestore(restore), test.name)
+		}
+	}
+}
+
+func newTestObjectInfo(group, kind, name string) storkapi.ObjectInfo {
+	return storkapi.ObjectInfo{
+		Name:      name,
+		Namespace: "ns",
+		GroupVersionKind: metav1.GroupVersionKind{
+			Group:   group,
+			Version: "v1",
+			Kind:    kind,
+		},
+	}
+}
+
+func TestGetRestoreObjectsMap(t *testing.T) {
+	objects := []runtime.Unstructured{
+		newTestTransformObject("apps/v1", "Deployment", "web", map[string]string{"app": "web"}, nil),
+		newTestTransformObject("v1", "ConfigMap", "web-config", map[string]string{"app": "web", "tier": "cache"}, nil),
+		newTestTransformObject("v1", "PersistentVolumeClaim", "db-data", map[string]string{"app": "db"}, nil),
+		newTestTransformObject("v1", "PersistentVolume", "pv-db-data", nil, nil),
+	}
+	deployment := newTestObjectInfo("apps", "Deployment", "web")
+	configMap := newTestObjectInfo("core", "ConfigMap", "web-config")
+	pvc := newTestObjectInfo("core", "PersistentVolumeClaim", "db-data")
+	pv := newTestObjectInfo("core", "PersistentVolume", "pv-db-data")
+
+	tests := []struct {
+		name           string
+		spec           storkapi.ApplicationRestoreSpec
+		selected       []storkapi.ObjectInfo
+		volumeSelected bool
+	}{
+		{
+			name:           "everything",
+			selected:       []storkapi.ObjectInfo{deployment, configMap, pvc, pv},
+			volumeSelected: true,
+		},
+		{
+			name: "include resources",
+			spec: storkapi.ApplicationRestoreSpec{
+				IncludeResources: []storkapi.ObjectInfo{configMap},
+			},
+			selected: []storkapi.ObjectInfo{configMap, pv},
+		},
+		{
+			name: "selectors",
+			spec: storkapi.ApplicationRestoreSpec{
+				Selectors: map[string]string{"app": "web"},
+			},
+			selected: []storkapi.ObjectInfo{deployment, configMap, pv},
+		},
+		{
+			name: "selectors with include resources",
+			spec: storkapi.ApplicationRestoreSpec{
+				Selectors:        map[string]string{"app": "web"},
+				IncludeResources: []storkapi.ObjectInfo{deployment, pvc},
+			},
+			selected: []storkapi.ObjectInfo{deployment, pv},
+		},
+		{
+			name: "exclude selectors",
+			spec: storkapi.ApplicationRestoreSpec{
+				ExcludeSelectors: map[string]string{"tier": "cache"},
+			},
+			selected:       []storkapi.ObjectInfo{deployment, pvc, pv},
+			volumeSelected: true,
+		},
+		{
+			name: "resource types",
+			spec: storkapi.ApplicationRestoreSpec{
+				ResourceTypes: []string{"Deployment", "PersistentVolumeClaim"},
+			},
+			selected:       []storkapi.ObjectInfo{deployment, pvc, pv},
+			volumeSelected: true,
+		},
+		{
+			name: "exclude resource types",
+			spec: storkapi.ApplicationRestoreSpec{
+				ExcludeResourceTypes: []string{"ConfigMap"},
+			},
+			selected:       []storkapi.ObjectInfo{deployment, pvc, pv},
+			volumeSelected: true,
+		},
+		{
+			name: "nothing matches",
+			spec: storkapi.ApplicationRestoreSpec{
+				Selectors: map[string]string{"app": "other"},
+			},
+			selected: []storkapi.ObjectInfo{pv},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			restore := &storkapi.ApplicationRestore{Spec: test.spec}
+			objectMap, err := getRestoreObjectsMap(restore, objects)
+			require.NoError(t, err, "Error getting objects to restore")
+
+			selected := make([]storkapi.ObjectInfo, 0)
+			for _, o := range objects {
+				ok, err := isRestoreResourceSelected(restore, objectMap, o)
+				require.NoError(t, err)
+				if ok {
+					selected = append(selected, getRestoreObjectInfo(o, o.(metav1.Object)))
+				}
+			}
+			// PVs are restored with the PVCs bound to them, they are only
+			// skipped by PrepareResourceForApply
+			require.ElementsMatch(t, test.selected, selected)
+			// The volume of the PVC is only restored if it is selected
+			require.Equal(t, test.volumeSelected, isRestoreObjectSelected(restore, objectMap, pvc))
+		})
+	}
+}
+
+func TestIsResourceTypeSelected(t *testing.T) {
+	tests := []struct {
+		name                 string
+		resourceTypes        []string
+		excludeResourceTypes []string
+		selected             bool
+	}{
+		{name: "no types", selected: true},
+		{name: "selected type", resourceTypes: []string{"ConfigMap", "Secret"}, selected: true},
+		{name: "other type", resourceTypes: []string{"Secret"}, selected: false},
+		{name: "excluded type", excludeResourceTypes: []string{"ConfigMap"}, selected: false},
+		{name: "other excluded type", excludeResourceTypes: []string{"Secret"}, selected: true},
+		{
+			name:                 "exclude wins",
+			resourceTypes:        []string{"ConfigMap"},
+			excludeResourceTypes: []string{"ConfigMap"},
+			selected:             false,
+		},
+	}
+	for _, test := range tests {
+		require.Equal(t, test.selected, isResourceTypeSelected("ConfigMap", test.resourceTypes, test.excludeResourceTypes), test.name)
+	}
+}
+
+func TestRestoreNamespaceSubset(t *testing.T) {
+	backup := &storkapi.ApplicationBackup{}
+	backup.Name = "backup"
+	backup.Spec.Namespaces = []string{"ns1", "ns2", "ns3"}
+	tests := []struct {
+		name             string
+		namespaces       []string
+		namespaceMapping map[string]string
+		expectedMapping  map[string]string
+		expectErr        bool
+	}{
+		{
+			name:             "all namespaces",
+			namespaceMapping: map[string]string{"ns1": "ns1", "ns2": "new-ns2", "ns3": "ns3"},
+			expectedMapping:  map[string]string{"ns1": "ns1", "ns2": "new-ns2", "ns3": "ns3"},
+		},
+		{
+			name:             "subset keeps mapping",
+			namespaces:       []string{"ns2"},
+			namespaceMapping: map[string]string{"ns1": "ns1", "ns2": "new-ns2", "ns3": "ns3"},
+			expectedMapping:  map[string]string{"ns2": "new-ns2"},
+		},
+		{
+			name:             "subset without mapping",
+			namespaces:       []string{"ns1", "ns3"},
+			namespaceMapping: map[string]string{"ns2": "new-ns2"},
+			expectedMapping:  map[string]string{"ns1": "ns1", "ns3": "ns3"},
+		},
+		{
+			name:             "namespace not in backup",
+			namespaces:       []string{"ns4"},
+			namespaceMapping: map[string]string{"ns1": "ns1"},
+			expectErr:        true,
+		},
+	}
+	for _, test := range tests {
+		restore := &storkapi.ApplicationRestore{}
+		restore.Spec.Namespaces = test.namespaces
+		restore.Spec.NamespaceMapping = test.namespaceMapping
+		err := restoreNamespaceSubset(restore, backup)
+		if test.expectErr {
+			require.Error(t, err, test.name)
+			continue
+		}
+		require.NoError(t, err, test.name)
+		require.Equal(t, test.expectedMapping, restore.Spec.NamespaceMapping, test.name)
+	}
+}
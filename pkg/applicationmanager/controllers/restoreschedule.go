@@ -0,0 +1,110 @@
+package controllers
+
+import (
+	"fmt"
+	"sort"
+
+	storkapi "github.com/libopenstorage/stork/pkg/apis/stork/v1alpha1"
+	storkops "github.com/portworx/sched-ops/k8s/stork"
+	k8s_errors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// scheduledBackup is a backup triggered by an ApplicationBackupSchedule that
+// could be restored
+type scheduledBackup struct {
+	name             string
+	triggerTimestamp metav1.Time
+}
+
+// resolveRestoreBackupName picks the backup to restore from the
+// BackupScheduleName of the restore if no BackupName was provided. The
+// resolved backup is recorded in the status so that later backups triggered
+// by the schedule don't change the backup being restored.
+func resolveRestoreBackupName(restore *storkapi.ApplicationRestore) error {
+	if restore.Spec.BackupName != "" || restore.Spec.BackupScheduleName == "" ||
+		restore.Status.BackupName != "" {
+		return nil
+	}
+	backupName, err := getLatestScheduledBackup(restore)
+	if err != nil {
+		return err
+	}
+	restore.Status.BackupName = backupName
+	return nil
+}
+
+// getLatestScheduledBackup returns the latest successful backup triggered by
+// the schedule of the restore at or before its PointInTime. The backups in
+// the status of the schedule are used if it exists on this cluster, otherwise
+// the backups synced from the backup location for the schedule are used.
+func getLatestScheduledBackup(restore *storkapi.ApplicationRestore) (string, error) {
+	scheduleName := restore.Spec.BackupScheduleName
+	backups := make([]*scheduledBackup, 0)
+	schedule, err := storkops.Instance().GetApplicationBackupSchedule(scheduleName, restore.Namespace)
+	if err != nil {
+		if !k8s_errors.IsNotFound(err) {
+			return "", fmt.Errorf("error getting backup schedule %v: %v", scheduleName, err)
+		}
+		backups, err = getSyncedScheduledBackups(restore)
+		if err != nil {
+			return "", err
+		}
+	} else {
+		for _, policyItems := range schedule.Status.Items {
+			for _, item := range policyItems {
+				if item.Status != storkapi.ApplicationBackupStatusSuccessful {
+					continue
+				}
+				backups = append(backups, &scheduledBackup{
+					name:             item.Name,
+					triggerTimestamp: item.CreationTimestamp,
+				})
+			}
+		}
+	}
+
+	sort.Slice(backups, func(i, j int) bool {
+		return backups[i].triggerTimestamp.After(backups[j].triggerTimestamp.Time)
+	})
+	for _, backup := range backups {
+		if restore.Spec.PointInTime != nil && backup.triggerTimestamp.After(restore.Spec.PointInTime.Time) {
+			continue
+		}
+		// Backups deleted by the retention of the schedule are skipped
+		if _, err := storkops.Instance().GetApplicationBackup(backup.name, restore.Namespace); err != nil {
+			if k8s_errors.IsNotFound(err) {
+				continue
+			}
+			return "", fmt.Errorf("error getting backup %v: %v", backup.name, err)
+		}
+		return backup.name, nil
+	}
+	if restore.Spec.PointInTime != nil {
+		return "", fmt.Errorf("no successful backup found for schedule %v at or before %v",
+			scheduleName, restore.Spec.PointInTime.Time)
+	}
+	return "", fmt.Errorf("no successful backup found for schedule %v", scheduleName)
+}
+
+// getSyncedScheduledBackups returns the successful backups of the schedule of
+// the restore that were synced from its backup location
+func getSyncedScheduledBackups(restore *storkapi.ApplicationRestore) ([]*scheduledBackup, error) {
+	backupList, err := storkops.Instance().ListApplicationBackups(restore.Namespace, metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("error listing backups: %v", err)
+	}
+	backups := make([]*scheduledBackup, 0)
+	for _, backup := range backupList.Items {
+		if backup.Annotations[ApplicationBackupScheduleNameAnnotation] != restore.Spec.BackupScheduleName ||
+			backup.Spec.BackupLocation != restore.Spec.BackupLocation ||
+			backup.Status.Status != storkapi.ApplicationBackupStatusSuccessful {
+			continue
+		}
+		backups = append(backups, &scheduledBackup{
+			name:             backup.Name,
+			triggerTimestamp: backup.Status.TriggerTimestamp,
+		})
+	}
+	return backups, nil
+}
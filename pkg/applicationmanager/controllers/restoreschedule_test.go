@@ -0,0 +1,170 @@
+//go:build unittest
+// +build unittest
+
+package controllers
+
+import (
+	"testing"
+	"time"
+
+	storkapi "github.com/libopenstorage/stork/pkg/apis/stork/v1alpha1"
+	fakeclient "github.com/libopenstorage/stork/pkg/client/clientset/versioned/fake"
+	storkops "github.com/portworx/sched-ops/k8s/stork"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func newTestScheduledBackup(name string, triggerTimestamp time.Time, status storkapi.ApplicationBackupStatusType) *storkapi.ApplicationBackup {
+	return &storkapi.ApplicationBackup{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        name,
+			Namespace:   "ns",
+			Annotations: map[string]string{ApplicationBackupScheduleNameAnnotation: "schedule"},
+		},
+		Spec: storkapi.ApplicationBackupSpec{
+			BackupLocation: "location",
+		},
+		Status: storkapi.ApplicationBackupStatus{
+			Status:           status,
+			TriggerTimestamp: metav1.NewTime(triggerTimestamp),
+		},
+	}
+}
+
+func newTestScheduleRestore(pointInTime *time.Time) *storkapi.ApplicationRestore {
+	restore := &storkapi.ApplicationRestore{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "restore",
+			Namespace: "ns",
+		},
+		Spec: storkapi.ApplicationRestoreSpec{
+			BackupLocation:     "location",
+			BackupScheduleName: "schedule",
+		},
+	}
+	if pointInTime != nil {
+		restore.Spec.PointInTime = &metav1.Time{Time: *pointInTime}
+	}
+	return restore
+}
+
+func TestGetLatestScheduledBackup(t *testing.T) {
+	now := time.Now()
+	backups := []*storkapi.ApplicationBackup{
+		newTestScheduledBackup("hourly-1", now.Add(-3*time.Hour), storkapi.ApplicationBackupStatusSuccessful),
+		newTestScheduledBackup("daily-1", now.Add(-2*time.Hour), storkapi.ApplicationBackupStatusSuccessful),
+		newTestScheduledBackup("hourly-2", now.Add(-1*time.Hour), storkapi.ApplicationBackupStatusFailed),
+		// Deleted by the retention of the schedule, only listed in its
+		// status
+		newTestScheduledBackup("hourly-3", now.Add(-30*time.Minute), storkapi.ApplicationBackupStatusSuccessful),
+	}
+	schedule := &storkapi.ApplicationBackupSchedule{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "schedule",
+			Namespace: "ns",
+		},
+		Status: storkapi.ApplicationBackupScheduleStatus{
+			Items: map[storkapi.SchedulePolicyType][]*storkapi.ScheduledApplicationBackupStatus{},
+		},
+	}
+	for _, backup := range backups {
+		policyType := storkapi.SchedulePolicyTypeInterval
+		if backup.Name == "daily-1" {
+			policyType = storkapi.SchedulePolicyTypeDaily
+		}
+		// The creation time of the items is when the backups were triggered
+		schedule.Status.Items[policyType] = append(schedule.Status.Items[policyType], &storkapi.ScheduledApplicationBackupStatus{
+			Name:              backup.Name,
+			CreationTimestamp: backup.Status.TriggerTimestamp,
+			Status:            backup.Status.Status,
+		})
+	}
+
+	// Synced backups are only used if the schedule doesn't exist on this
+	// cluster
+	otherLocation := newTestScheduledBackup("other-location", now.Add(-10*time.Minute), storkapi.ApplicationBackupStatusSuccessful)
+	otherLocation.Spec.BackupLocation = "other"
+	otherSchedule := newTestScheduledBackup("other-schedule", now.Add(-10*time.Minute), storkapi.ApplicationBackupStatusSuccessful)
+	otherSchedule.Annotations[ApplicationBackupScheduleNameAnnotation] = "other"
+
+	beforeDaily := now.Add(-150 * time.Minute)
+	beforeAll := now.Add(-4 * time.Hour)
+	tests := []struct {
+		name        string
+		schedule    bool
+		pointInTime *time.Time
+		backup      string
+	}{
+		{
+			name:     "latest",
+			schedule: true,
+			backup:   "daily-1",
+		},
+		{
+			name:        "point in time",
+			schedule:    true,
+			pointInTime: &beforeDaily,
+			backup:      "hourly-1",
+		},
+		{
+			name:        "point in time before all backups",
+			schedule:    true,
+			pointInTime: &beforeAll,
+		},
+		{
+			name:   "synced backups",
+			backup: "daily-1",
+		},
+		{
+			name:        "synced backups point in time",
+			pointInTime: &beforeDaily,
+			backup:      "hourly-1",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			objects := []runtime.Object{otherLocation, otherSchedule}
+			for _, backup := range backups[:3] {
+				objects = append(objects, backup)
+			}
+			if test.schedule {
+				objects = append(objects, schedule)
+			}
+			storkops.SetInstance(storkops.New(fake.NewSimpleClientset(), fakeclient.NewSimpleClientset(objects...), nil))
+
+			backupName, err := getLatestScheduledBackup(newTestScheduleRestore(test.pointInTime))
+			if test.backup == "" {
+				require.Error(t, err, "Expected no backup to be found")
+				return
+			}
+			require.NoError(t, err, "Error getting latest scheduled backup")
+			require.Equal(t, test.backup, backupName)
+		})
+	}
+}
+
+func TestResolveRestoreBackupName(t *testing.T) {
+	backup := newTestScheduledBackup("backup", time.Now(), storkapi.ApplicationBackupStatusSuccessful)
+	storkops.SetInstance(storkops.New(fake.NewSimpleClientset(), fakeclient.NewSimpleClientset(backup), nil))
+
+	// The backup resolved from the schedule is only recorded in the status
+	restore := newTestScheduleRestore(nil)
+	require.NoError(t, resolveRestoreBackupName(restore))
+	require.Empty(t, restore.Spec.BackupName)
+	require.Equal(t, "backup", restore.Status.BackupName)
+	require.Equal(t, "backup", restore.GetBackupName())
+
+	// The backup isn't resolved again once it has been recorded
+	restore.Status.BackupName = "previous"
+	require.NoError(t, resolveRestoreBackupName(restore))
+	require.Equal(t, "previous", restore.GetBackupName())
+
+	// Restores of a single backup don't use the schedule
+	restore = newTestScheduleRestore(nil)
+	restore.Spec.BackupName = "named"
+	require.NoError(t, resolveRestoreBackupName(restore))
+	require.Empty(t, restore.Status.BackupName)
+	require.Equal(t, "named", restore.GetBackupName())
+}
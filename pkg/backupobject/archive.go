@@ -0,0 +1,556 @@
+package backupobject
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/libopenstorage/stork/drivers/volume"
+	stork_api "github.com/libopenstorage/stork/pkg/apis/stork/v1alpha1"
+	"github.com/libopenstorage/stork/pkg/objectstore"
+	"gocloud.dev/blob"
+	"gocloud.dev/gcerrors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	backupArchiveVersion1 = 1
+
+	archiveInfoName   = "archive.json"
+	archiveBackupName = "backup.json"
+	// Objects written by stork, decrypted
+	archiveObjectsDir = "objects/"
+	// Objects written by the volume drivers in the backup path, as is
+	archiveDriverDir = "driver/"
+	// Objects of the volume repositories, as is
+	archiveVolumesDir = "volumes/"
+
+	// kdmpRepositoryPrefix is the directory that the kdmp driver keeps the
+	// repositories of the volume backups in, one per namespace and pvc
+	kdmpRepositoryPrefix = "generic-backup"
+	// kopiaRepositoryFormatObject describes the format of a repository. The
+	// other objects of a repository are content addressed.
+	kopiaRepositoryFormatObject = "kopia.repository"
+)
+
+// backupArchiveInfo is the first entry of a backup archive and describes its
+// content
+type backupArchiveInfo struct {
+	Version              int         `json:"version"`
+	Namespace            string      `json:"namespace"`
+	Name                 string      `json:"name"`
+	BackupPath           string      `json:"backupPath"`
+	SourceBackupLocation string      `json:"sourceBackupLocation"`
+	CreationTimestamp    metav1.Time `json:"creationTimestamp"`
+	// Objects are the names of the objects written by stork for the backup
+	Objects []string `json:"objects"`
+	// VolumeRepositories are the paths of the repositories holding the
+	// volume data of the backup. They are encrypted with the repository
+	// password of the source backup location.
+	VolumeRepositories []string `json:"volumeRepositories,omitempty"`
+}
+
+// GetVolumeRepository returns the path of the kdmp repository that holds the
+// data of the volume backup. It is empty for the drivers that keep the volume
+// data outside of the backup location.
+func GetVolumeRepository(volumeInfo *stork_api.ApplicationBackupVolumeInfo) string {
+	if volumeInfo.DriverName != volume.KDMPDriverName {
+		return ""
+	}
+	return fmt.Sprintf("%s/%s-%s/", kdmpRepositoryPrefix, volumeInfo.Namespace, volumeInfo.PersistentVolumeClaim)
+}
+
+// CopyVolumeRepository copies the objects of the volume repository to the
+// destination backup location. The objects are copied as is, so the volume
+// data can only be restored from a backup location with the same repository
+// password. Objects that already exist in the destination are kept.
+func CopyVolumeRepository(
+	srcBucket *blob.Bucket,
+	dstBucket *blob.Bucket,
+	dstLocation *stork_api.BackupLocation,
+	repository string,
+	objectLock *stork_api.ApplicationBackupObjectLock,
+) error {
+	return copyRawObjects(srcBucket, repository, nil, func(r io.Reader, key string) error {
+		return importVolumeObject(r, dstBucket, dstLocation, key, objectLock)
+	})
+}
+
+// CopyDriverObjects copies the objects written by the volume drivers in the
+// backup path, all of them except the ones in skip, as is to the destination
+// backup location
+func CopyDriverObjects(
+	srcBucket *blob.Bucket,
+	dstBucket *blob.Bucket,
+	dstLocation *stork_api.BackupLocation,
+	objectPath string,
+	skip map[string]bool,
+	objectLock *stork_api.ApplicationBackupObjectLock,
+) error {
+	return copyRawObjects(srcBucket, objectPath, skip, func(r io.Reader, key string) error {
+		return importRawObject(r, dstBucket, dstLocation, key, objectLock)
+	})
+}
+
+// copyRawObjects calls copy with the data of every object under prefix,
+// except the ones in skip named relative to the prefix
+func copyRawObjects(
+	bucket *blob.Bucket,
+	prefix string,
+	skip map[string]bool,
+	copy func(io.Reader, string) error,
+) error {
+	prefix = strings.TrimSuffix(prefix, "/") + "/"
+	iterator := bucket.List(&blob.ListOptions{Prefix: prefix})
+	for {
+		object, err := iterator.Next(context.TODO())
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if object.IsDir || skip[strings.TrimPrefix(object.Key, prefix)] {
+			continue
+		}
+		reader, err := bucket.NewReader(context.TODO(), object.Key, nil)
+		if err != nil {
+			return err
+		}
+		err = copy(reader, object.Key)
+		_ = reader.Close()
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// getVolumeRepositories returns the paths of the kdmp repositories that hold
+// the volume data of the backup
+func getVolumeRepositories(backup *stork_api.ApplicationBackup) []string {
+	repositories := make([]string, 0)
+	seen := make(map[string]bool)
+	for _, volumeInfo := range backup.Status.Volumes {
+		repository := GetVolumeRepository(volumeInfo)
+		if repository == "" {
+			continue
+		}
+		if !seen[repository] {
+			seen[repository] = true
+			repositories = append(repositories, repository)
+		}
+	}
+	return repositories
+}
+
+// Export writes a completed backup as a tar archive to w. The objects
+// written by stork are decrypted so that the archive can be imported into a
+// backup location with different keys, they stay compressed. The volume data
+// of kdmp backups is copied as is and can only be restored from a backup
+// location with the same repository password.
+func Export(
+	backup *stork_api.ApplicationBackup,
+	location *stork_api.BackupLocation,
+	w io.Writer,
+) error {
+	if backup.Status.Status != stork_api.ApplicationBackupStatusSuccessful &&
+		backup.Status.Status != stork_api.ApplicationBackupStatusPartialSuccess {
+		return fmt.Errorf("backup %v/%v hasn't completed successfully", backup.Namespace, backup.Name)
+	}
+	objectPath := backup.Status.BackupPath
+	if objectPath == "" {
+		return fmt.Errorf("backup path not set for backup %v/%v", backup.Namespace, backup.Name)
+	}
+	bucket, err := objectstore.GetBucket(location)
+	if err != nil {
+		return err
+	}
+	defer bucket.Close()
+
+	integrity, reason, err := VerifyObjects(bucket, location, objectPath)
+	if err != nil {
+		return err
+	}
+	if integrity == stork_api.ApplicationBackupIntegrityCorrupt {
+		return fmt.Errorf("backup failed integrity verification: %v", reason)
+	}
+	manifest, err := ReadManifest(bucket, location, objectPath)
+	if err != nil {
+		return err
+	}
+	names := ObjectNames
+	if manifest != nil {
+		names = make([]string, 0, len(manifest.Objects))
+		for _, object := range manifest.Objects {
+			names = append(names, object.Name)
+		}
+	}
+	// The metadata is regenerated from the backup when it is imported
+	skip := map[string]bool{ManifestObjectName: true, MetadataObjectName: true}
+	objects := make([]string, 0, len(names))
+	for _, name := range names {
+		skip[name] = true
+		if name != MetadataObjectName {
+			objects = append(objects, name)
+		}
+	}
+
+	info := &backupArchiveInfo{
+		Version:              backupArchiveVersion1,
+		Namespace:            backup.Namespace,
+		Name:                 backup.Name,
+		BackupPath:           objectPath,
+		SourceBackupLocation: location.Name,
+		CreationTimestamp:    metav1.Now(),
+		Objects:              objects,
+		VolumeRepositories:   getVolumeRepositories(backup),
+	}
+	archiveBackup := backup.DeepCopy()
+	archiveBackup.ManagedFields = nil
+	archiveBackup.ResourceVersion = ""
+
+	tw := tar.NewWriter(w)
+	if err := writeArchiveJSON(tw, archiveInfoName, info); err != nil {
+		return err
+	}
+	if err := writeArchiveJSON(tw, archiveBackupName, archiveBackup); err != nil {
+		return err
+	}
+	for _, repository := range info.VolumeRepositories {
+		if err := writeArchiveObjects(tw, bucket, repository, archiveVolumesDir, nil); err != nil {
+			return fmt.Errorf("error exporting volume repository %v: %v", repository, err)
+		}
+	}
+	if err := writeArchiveObjects(tw, bucket, objectPath, archiveDriverDir, skip); err != nil {
+		return err
+	}
+	for _, name := range objects {
+		if err := writeArchiveObject(tw, bucket, location, objectPath, name); err != nil {
+			if gcerrors.Code(err) == gcerrors.NotFound && manifest == nil {
+				continue
+			}
+			return fmt.Errorf("error exporting %v: %v", name, err)
+		}
+	}
+	return tw.Close()
+}
+
+// writeArchiveObject adds the decrypted object name to the archive. The size
+// of an entry has to be written before its data, so encrypted objects are
+// decrypted to a temporary file first instead of being read into memory.
+func writeArchiveObject(
+	tw *tar.Writer,
+	bucket *blob.Bucket,
+	location *stork_api.BackupLocation,
+	objectPath string,
+	name string,
+) error {
+	reader, err := OpenDecrypted(bucket, location, filepath.Join(objectPath, name))
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+	if blobReader, ok := reader.(*blob.Reader); ok {
+		return writeArchiveEntry(tw, archiveObjectsDir+name, blobReader.Size(), blobReader)
+	}
+
+	file, err := os.CreateTemp("", "backup-object-")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = file.Close()
+		_ = os.Remove(file.Name())
+	}()
+	size, err := io.Copy(file, reader)
+	if err != nil {
+		return err
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	return writeArchiveEntry(tw, archiveObjectsDir+name, size, file)
+}
+
+func writeArchiveEntry(tw *tar.Writer, name string, size int64, r io.Reader) error {
+	header := &tar.Header{
+		Name:    name,
+		Mode:    0644,
+		Size:    size,
+		ModTime: time.Now(),
+	}
+	if err := tw.WriteHeader(header); err != nil {
+		return err
+	}
+	_, err := io.Copy(tw, r)
+	return err
+}
+
+func writeArchiveJSON(tw *tar.Writer, name string, v interface{}) error {
+	data, err := json.MarshalIndent(v, "", " ")
+	if err != nil {
+		return err
+	}
+	return writeArchiveEntry(tw, name, int64(len(data)), bytes.NewReader(data))
+}
+
+// writeArchiveObjects adds the objects under prefix to the archive as is. The
+// keys of the volume repositories are kept as they are, the keys of the
+// objects in the backup path are relative to it.
+func writeArchiveObjects(
+	tw *tar.Writer,
+	bucket *blob.Bucket,
+	prefix string,
+	dir string,
+	skip map[string]bool,
+) error {
+	prefix = strings.TrimSuffix(prefix, "/") + "/"
+	iterator := bucket.List(&blob.ListOptions{Prefix: prefix})
+	for {
+		object, err := iterator.Next(context.TODO())
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if object.IsDir {
+			continue
+		}
+		name := object.Key
+		if dir == archiveDriverDir {
+			name = strings.TrimPrefix(object.Key, prefix)
+		}
+		if skip[name] {
+			continue
+		}
+		reader, err := bucket.NewReader(context.TODO(), object.Key, nil)
+		if err != nil {
+			return err
+		}
+		err = writeArchiveEntry(tw, dir+name, reader.Size(), reader)
+		_ = reader.Close()
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// Import writes the backup from a tar archive created by Export
+// to the backup location and returns the ApplicationBackup to create for it.
+// The backup is written under the namespace of the backup location and is
+// prepared the same way as the backups synced from a backup location.
+func Import(r io.Reader, location *stork_api.BackupLocation) (*stork_api.ApplicationBackup, error) {
+	tr := tar.NewReader(r)
+	info := &backupArchiveInfo{}
+	if err := readArchiveJSON(tr, archiveInfoName, info); err != nil {
+		return nil, err
+	}
+	if info.Version != backupArchiveVersion1 {
+		return nil, fmt.Errorf("unsupported backup archive version %v", info.Version)
+	}
+	backup := &stork_api.ApplicationBackup{}
+	if err := readArchiveJSON(tr, archiveBackupName, backup); err != nil {
+		return nil, err
+	}
+
+	bucket, err := objectstore.GetBucket(location)
+	if err != nil {
+		return nil, err
+	}
+	defer bucket.Close()
+	objectLock, err := GetObjectLock(location)
+	if err != nil {
+		return nil, err
+	}
+	objectPath := filepath.Join(location.Namespace, backup.Name, string(backup.UID))
+	manifest := NewManifest()
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+		name := path.Clean(header.Name)
+		if strings.HasPrefix(name, "../") || path.IsAbs(name) {
+			return nil, fmt.Errorf("invalid entry %v in backup archive", header.Name)
+		}
+		switch {
+		case strings.HasPrefix(name, archiveObjectsDir):
+			object, err := importBackupObject(tr, bucket, location, objectPath, strings.TrimPrefix(name, archiveObjectsDir), objectLock)
+			if err != nil {
+				return nil, err
+			}
+			manifest.Add(*object)
+		case strings.HasPrefix(name, archiveDriverDir):
+			key := filepath.Join(objectPath, strings.TrimPrefix(name, archiveDriverDir))
+			if err := importRawObject(tr, bucket, location, key, objectLock); err != nil {
+				return nil, err
+			}
+		case strings.HasPrefix(name, archiveVolumesDir):
+			if err := importVolumeObject(tr, bucket, location, strings.TrimPrefix(name, archiveVolumesDir), objectLock); err != nil {
+				return nil, err
+			}
+		default:
+			return nil, fmt.Errorf("unexpected entry %v in backup archive", header.Name)
+		}
+	}
+
+	// Write the metadata pointing to the new location, followed by the
+	// manifest, the same way a backup is uploaded
+	backup.Namespace = location.Namespace
+	backup.Spec.BackupLocation = location.Name
+	backup.Spec.ReplicaBackupLocation = ""
+	backup.Status.BackupPath = objectPath
+	backup.Status.Replica = nil
+	backup.Status.ObjectLock = objectLock
+	jsonBytes, err := json.MarshalIndent(backup, "", " ")
+	if err != nil {
+		return nil, err
+	}
+	object, err := WriteObject(bucket, location, objectPath, MetadataObjectName, jsonBytes, objectLock)
+	if err != nil {
+		return nil, err
+	}
+	manifest.Add(*object)
+	jsonBytes, err = json.MarshalIndent(manifest, "", " ")
+	if err != nil {
+		return nil, err
+	}
+	if _, err := WriteObject(bucket, location, objectPath, ManifestObjectName, jsonBytes, objectLock); err != nil {
+		return nil, err
+	}
+
+	if err := AppendCatalogEntry(bucket, location, NewCatalogEntry(CatalogOperationAdd, backup)); err != nil {
+		return nil, err
+	}
+
+	integrity, reason, err := VerifyObjects(bucket, location, objectPath)
+	if err != nil {
+		return nil, err
+	}
+	PrepareSyncedBackup(backup, location, integrity, reason)
+	return backup, nil
+}
+
+func readArchiveJSON(tr *tar.Reader, name string, v interface{}) error {
+	header, err := tr.Next()
+	if err != nil {
+		return fmt.Errorf("error reading %v from backup archive: %v", name, err)
+	}
+	if header.Name != name {
+		return fmt.Errorf("expected %v in backup archive, found %v", name, header.Name)
+	}
+	if err := json.NewDecoder(tr).Decode(v); err != nil {
+		return fmt.Errorf("error parsing %v from backup archive: %v", name, err)
+	}
+	return nil
+}
+
+func importBackupObject(
+	r io.Reader,
+	bucket *blob.Bucket,
+	location *stork_api.BackupLocation,
+	objectPath string,
+	name string,
+	objectLock *stork_api.ApplicationBackupObjectLock,
+) (*ManifestObject, error) {
+	// The data is already compressed if it needs to be
+	writer, err := NewWriter(bucket, location, objectPath, name, stork_api.BackupCompressionNone, objectLock)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := io.Copy(writer, r); err != nil {
+		writer.Abort()
+		return nil, fmt.Errorf("error importing %v: %v", name, err)
+	}
+	if err := writer.Close(); err != nil {
+		return nil, fmt.Errorf("error importing %v: %v", name, err)
+	}
+	object := writer.ManifestObject()
+	return &object, nil
+}
+
+func importRawObject(
+	r io.Reader,
+	bucket *blob.Bucket,
+	location *stork_api.BackupLocation,
+	key string,
+	objectLock *stork_api.ApplicationBackupObjectLock,
+) error {
+	ctx, cancel := context.WithCancel(context.TODO())
+	defer cancel()
+	writer, err := bucket.NewWriter(ctx, key, GetWriterOptions(location, objectLock))
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(writer, r); err != nil {
+		// Cancelling the context before closing discards the object
+		cancel()
+		_ = writer.Close()
+		return fmt.Errorf("error importing %v: %v", key, err)
+	}
+	return writer.Close()
+}
+
+// importVolumeObject writes an object of a volume repository unless it
+// already exists. Repositories are shared by all the backups of a volume and
+// their objects are content addressed, except for the format of the
+// repository which has to match for the backups to be restored.
+func importVolumeObject(
+	r io.Reader,
+	bucket *blob.Bucket,
+	location *stork_api.BackupLocation,
+	key string,
+	objectLock *stork_api.ApplicationBackupObjectLock,
+) error {
+	if !strings.HasPrefix(key, kdmpRepositoryPrefix+"/") {
+		return fmt.Errorf("unexpected volume object %v in backup archive", key)
+	}
+	exists, err := bucket.Exists(context.TODO(), key)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return importRawObject(r, bucket, location, key, objectLock)
+	}
+	if path.Base(key) != kopiaRepositoryFormatObject {
+		return nil
+	}
+	checksum, err := getChecksum(r)
+	if err != nil {
+		return err
+	}
+	_, existing, err := GetObjectChecksum(bucket, key)
+	if err != nil {
+		return err
+	}
+	if checksum != existing {
+		return fmt.Errorf("volume repository %v already exists in backup location %v with a different format",
+			path.Dir(key), location.Name)
+	}
+	return nil
+}
+
+func getChecksum(r io.Reader) (string, error) {
+	hash := sha256.New()
+	if _, err := io.Copy(hash, r); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
@@ -0,0 +1,295 @@
+package backupobject
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/storage"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+	stork_api "github.com/libopenstorage/stork/pkg/apis/stork/v1alpha1"
+	"github.com/libopenstorage/stork/pkg/utils"
+	"gocloud.dev/blob"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+const (
+	// The catalog of a backup location lives next to the backups of its
+	// namespace. Backup names can't start with a '.' so it can't clash with
+	// a backup.
+	backupCatalogDir = ".catalog"
+	// Every change to the catalog is written as a new entry object so that
+	// the catalog is only ever appended to, even with multiple writers and
+	// object lock enabled on the bucket
+	backupCatalogEntriesDir = "entries"
+	// The complete marker is written once the catalog lists every backup in
+	// the location. Locations without it are synced by walking the bucket.
+	backupCatalogCompleteMarker = "complete"
+
+	// catalogTimestampLen is the length of the timestamp at the start of the
+	// name of the catalog entries
+	catalogTimestampLen = 20
+	// catalogLookback is how far behind the newest entry read the catalog is
+	// listed again for entries that were written late or by a cluster whose
+	// clock is behind
+	catalogLookback = 1 * time.Hour
+)
+
+// CatalogOperation is the change to the backups in a location recorded
+// by a catalog entry
+type CatalogOperation string
+
+const (
+	// CatalogOperationAdd records a backup being added to the location
+	CatalogOperationAdd CatalogOperation = "Add"
+	// CatalogOperationDelete records a backup being deleted from the location
+	CatalogOperationDelete CatalogOperation = "Delete"
+)
+
+// CatalogEntry records a backup being added to or deleted from a backup
+// location. It holds enough of the backup to decide whether it needs to be
+// synced without reading its metadata.
+type CatalogEntry struct {
+	Operation        CatalogOperation `json:"operation"`
+	Name             string           `json:"name"`
+	Namespace        string           `json:"namespace"`
+	UID              types.UID        `json:"uid"`
+	BackupPath       string           `json:"backupPath"`
+	TriggerTimestamp metav1.Time      `json:"triggerTimestamp"`
+	ScheduleName     string           `json:"scheduleName,omitempty"`
+	Timestamp        metav1.Time      `json:"timestamp"`
+}
+
+// NewCatalogEntry returns the entry recording the operation on the backup
+func NewCatalogEntry(
+	operation CatalogOperation,
+	backup *stork_api.ApplicationBackup,
+) *CatalogEntry {
+	return &CatalogEntry{
+		Operation:        operation,
+		Name:             backup.Name,
+		Namespace:        backup.Namespace,
+		UID:              backup.UID,
+		BackupPath:       backup.Status.BackupPath,
+		TriggerTimestamp: backup.Status.TriggerTimestamp,
+		ScheduleName:     backup.Annotations[utils.ApplicationBackupScheduleNameAnnotation],
+		Timestamp:        metav1.Now(),
+	}
+}
+
+// Backup returns the fields of the backup recorded in the entry
+func (e *CatalogEntry) Backup() *stork_api.ApplicationBackup {
+	backup := &stork_api.ApplicationBackup{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      e.Name,
+			Namespace: e.Namespace,
+			UID:       e.UID,
+		},
+		Status: stork_api.ApplicationBackupStatus{
+			BackupPath:       e.BackupPath,
+			TriggerTimestamp: e.TriggerTimestamp,
+		},
+	}
+	if e.ScheduleName != "" {
+		backup.Annotations = map[string]string{
+			utils.ApplicationBackupScheduleNameAnnotation: e.ScheduleName,
+		}
+	}
+	return backup
+}
+
+// GetCatalogPath returns the path of the catalog of the location
+func GetCatalogPath(location *stork_api.BackupLocation) string {
+	return path.Join(location.Namespace, backupCatalogDir)
+}
+
+// AppendCatalogEntry adds the entry to the catalog of the location. The
+// keys of the entries start with the time they were written so that listing
+// them returns them roughly in order. The entries are written unencrypted so
+// that the catalog can still be read after the encryption keys of the
+// location have been rotated. Besides the paths of the backup objects they
+// hold the name, UID, trigger time and schedule of the backup but none of
+// its resources.
+func AppendCatalogEntry(
+	bucket *blob.Bucket,
+	location *stork_api.BackupLocation,
+	entry *CatalogEntry,
+) error {
+	jsonBytes, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	key := path.Join(getCatalogEntriesPath(location), fmt.Sprintf("%020d-%v-%v.json",
+		entry.Timestamp.UnixNano(), entry.UID, strings.ToLower(string(entry.Operation))))
+	if err := bucket.WriteAll(context.TODO(), key, jsonBytes, GetWriterOptions(location, nil)); err != nil {
+		return fmt.Errorf("error adding backup %v/%v to catalog: %v", entry.Namespace, entry.Name, err)
+	}
+	return nil
+}
+
+// IsCatalogComplete returns true if the catalog of the location lists
+// all the backups in it
+func IsCatalogComplete(bucket *blob.Bucket, location *stork_api.BackupLocation) (bool, error) {
+	return bucket.Exists(context.TODO(), path.Join(GetCatalogPath(location), backupCatalogCompleteMarker))
+}
+
+// MarkCatalogComplete records that the catalog of the location lists all
+// the backups in it
+func MarkCatalogComplete(bucket *blob.Bucket, location *stork_api.BackupLocation) error {
+	return bucket.WriteAll(context.TODO(), path.Join(GetCatalogPath(location), backupCatalogCompleteMarker),
+		[]byte(time.Now().UTC().Format(time.RFC3339)), GetWriterOptions(location, nil))
+}
+
+func getCatalogEntriesPath(location *stork_api.BackupLocation) string {
+	return path.Join(GetCatalogPath(location), backupCatalogEntriesDir)
+}
+
+// Catalog are the backups in a backup location as read from its catalog. It
+// is updated incrementally, only the entries that haven't been read yet are
+// downloaded.
+type Catalog struct {
+	// lastKey is the key of the newest entry read so far
+	lastKey string
+	// seen are the backup UIDs and operations of the entries read so far
+	seen map[string]bool
+	// backups in the location by their path
+	backups map[string]*CatalogEntry
+	// deleted are the paths of the backups deleted from the location
+	deleted map[string]bool
+}
+
+// NewCatalog returns an empty catalog
+func NewCatalog() *Catalog {
+	return &Catalog{
+		seen:    make(map[string]bool),
+		backups: make(map[string]*CatalogEntry),
+		deleted: make(map[string]bool),
+	}
+}
+
+// Backups returns the backups in the location by their path
+func (c *Catalog) Backups() map[string]*CatalogEntry {
+	return c.backups
+}
+
+// Contains returns true if the catalog has an entry for the backup at
+// backupPath, either adding or deleting it
+func (c *Catalog) Contains(backupPath string) bool {
+	_, ok := c.backups[backupPath]
+	return ok || c.deleted[backupPath]
+}
+
+// Update reads the entries added to the catalog of the location since the
+// last update. The keys of the entries are ordered by the clock of the
+// cluster that wrote them, so an entry can show up behind the newest one
+// that has already been read. The entries written up to catalogLookback
+// before the newest one are listed again on every update to pick those up,
+// entries for a backup operation that has already been read are skipped.
+func (c *Catalog) Update(bucket *blob.Bucket, location *stork_api.BackupLocation) error {
+	prefix := getCatalogEntriesPath(location) + "/"
+	startAfter := ""
+	if c.lastKey != "" {
+		timestamp, err := strconv.ParseInt(path.Base(c.lastKey)[:catalogTimestampLen], 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid catalog entry %v: %v", c.lastKey, err)
+		}
+		startAfter = prefix + fmt.Sprintf("%020d", timestamp-catalogLookback.Nanoseconds())
+	}
+
+	iterator := bucket.List(&blob.ListOptions{
+		Prefix:     prefix,
+		BeforeList: listStartAfter(startAfter),
+	})
+	keys := make([]string, 0)
+	for {
+		object, err := iterator.Next(context.TODO())
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		// Not every bucket can start listing after a key
+		if object.IsDir || object.Key <= startAfter {
+			continue
+		}
+		if c.seen[getCatalogEntryOperation(object.Key)] {
+			continue
+		}
+		keys = append(keys, object.Key)
+	}
+
+	// Keys are listed in lexicographical order
+	for _, key := range keys {
+		data, err := bucket.ReadAll(context.TODO(), key)
+		if err != nil {
+			return err
+		}
+		entry := &CatalogEntry{}
+		if err := json.Unmarshal(data, entry); err != nil {
+			return fmt.Errorf("error parsing catalog entry %v: %v", key, err)
+		}
+		c.add(entry)
+		c.seen[getCatalogEntryOperation(key)] = true
+		if key > c.lastKey {
+			c.lastKey = key
+		}
+	}
+	return nil
+}
+
+// add applies the entry to the backups in the catalog. Deleted backups are
+// remembered so that the entries can be applied in any order.
+func (c *Catalog) add(entry *CatalogEntry) {
+	switch entry.Operation {
+	case CatalogOperationAdd:
+		if !c.deleted[entry.BackupPath] {
+			c.backups[entry.BackupPath] = entry
+		}
+	case CatalogOperationDelete:
+		c.deleted[entry.BackupPath] = true
+		delete(c.backups, entry.BackupPath)
+	}
+}
+
+// getCatalogEntryOperation returns the UID and operation of the backup from
+// the key of a catalog entry
+func getCatalogEntryOperation(key string) string {
+	name := path.Base(key)
+	if len(name) <= catalogTimestampLen {
+		return name
+	}
+	return strings.TrimSuffix(name[catalogTimestampLen+1:], ".json")
+}
+
+// listStartAfter returns the function to start listing the objects of a
+// bucket after key for the buckets that support it
+func listStartAfter(key string) func(func(interface{}) bool) error {
+	return func(asFunc func(interface{}) bool) error {
+		if key == "" {
+			return nil
+		}
+		var s3Input *s3.ListObjectsV2Input
+		var s3LegacyInput *s3.ListObjectsInput
+		var gcsQuery *storage.Query
+		switch {
+		case asFunc(&s3Input):
+			s3Input.StartAfter = aws.String(key)
+		case asFunc(&s3LegacyInput):
+			// The marker is used for the following pages
+			if s3LegacyInput.Marker == nil {
+				s3LegacyInput.Marker = aws.String(key)
+			}
+		case asFunc(&gcsQuery):
+			gcsQuery.StartOffset = key
+		}
+		return nil
+	}
+}
@@ -0,0 +1,198 @@
+//go:build unittest
+// +build unittest
+
+package backupobject
+
+import (
+	"context"
+	"path"
+	"testing"
+	"time"
+
+	stork_api "github.com/libopenstorage/stork/pkg/apis/stork/v1alpha1"
+	"github.com/stretchr/testify/require"
+	"gocloud.dev/blob"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+func newTestCatalogEntry(
+	operation CatalogOperation,
+	name string,
+	timestamp time.Time,
+) *CatalogEntry {
+	backup := &stork_api.ApplicationBackup{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: "ns",
+			UID:       types.UID(name + "-uid"),
+		},
+	}
+	backup.Status.BackupPath = path.Join("ns", name, string(backup.UID))
+	entry := NewCatalogEntry(operation, backup)
+	entry.Timestamp = metav1.NewTime(timestamp)
+	return entry
+}
+
+func appendTestCatalogEntries(
+	t *testing.T,
+	bucket *blob.Bucket,
+	location *stork_api.BackupLocation,
+	entries ...*CatalogEntry,
+) {
+	for _, entry := range entries {
+		require.NoError(t, AppendCatalogEntry(bucket, location, entry), "Error appending entry for %v", entry.Name)
+	}
+}
+
+func requireCatalogBackups(t *testing.T, catalog *Catalog, names ...string) {
+	backups := make([]string, 0)
+	for _, entry := range catalog.Backups() {
+		backups = append(backups, entry.Name)
+	}
+	require.ElementsMatch(t, names, backups, "Unexpected backups in catalog")
+}
+
+func TestCatalogAppend(t *testing.T) {
+	bucket := newTestBucket(t)
+	location := newTestBackupLocation("testkey")
+	location.Namespace = "ns"
+	now := time.Now()
+	appendTestCatalogEntries(t, bucket, location,
+		newTestCatalogEntry(CatalogOperationAdd, "backup1", now),
+		newTestCatalogEntry(CatalogOperationAdd, "backup2", now.Add(time.Second)),
+	)
+
+	catalog := NewCatalog()
+	require.NoError(t, catalog.Update(bucket, location), "Error updating catalog")
+	requireCatalogBackups(t, catalog, "backup1", "backup2")
+	entry := catalog.Backups()["ns/backup1/backup1-uid"]
+	require.NotNil(t, entry, "Entries should be keyed by backup path")
+	require.Equal(t, types.UID("backup1-uid"), entry.UID)
+
+	complete, err := IsCatalogComplete(bucket, location)
+	require.NoError(t, err)
+	require.False(t, complete, "Catalog shouldn't be complete")
+	require.NoError(t, MarkCatalogComplete(bucket, location), "Error marking catalog complete")
+	complete, err = IsCatalogComplete(bucket, location)
+	require.NoError(t, err)
+	require.True(t, complete, "Catalog should be complete")
+}
+
+func TestCatalogUpdateFromCursor(t *testing.T) {
+	bucket := newTestBucket(t)
+	location := newTestBackupLocation("")
+	location.Namespace = "ns"
+	now := time.Now()
+	appendTestCatalogEntries(t, bucket, location, newTestCatalogEntry(CatalogOperationAdd, "backup1", now))
+
+	catalog := NewCatalog()
+	require.NoError(t, catalog.Update(bucket, location), "Error updating catalog")
+	requireCatalogBackups(t, catalog, "backup1")
+
+	// Entries that have already been read aren't downloaded again
+	iterator := bucket.List(&blob.ListOptions{Prefix: getCatalogEntriesPath(location) + "/"})
+	object, err := iterator.Next(context.TODO())
+	require.NoError(t, err)
+	require.NoError(t, bucket.WriteAll(context.TODO(), object.Key, []byte("invalid"), nil))
+
+	appendTestCatalogEntries(t, bucket, location, newTestCatalogEntry(CatalogOperationAdd, "backup2", now.Add(time.Second)))
+	require.NoError(t, catalog.Update(bucket, location), "Error updating catalog")
+	requireCatalogBackups(t, catalog, "backup1", "backup2")
+
+	// A new catalog reads all the entries
+	require.Error(t, NewCatalog().Update(bucket, location), "Expected error parsing invalid entry")
+}
+
+func TestCatalogDelete(t *testing.T) {
+	bucket := newTestBucket(t)
+	location := newTestBackupLocation("")
+	location.Namespace = "ns"
+	now := time.Now()
+	appendTestCatalogEntries(t, bucket, location,
+		newTestCatalogEntry(CatalogOperationAdd, "backup1", now),
+		newTestCatalogEntry(CatalogOperationAdd, "backup2", now.Add(time.Second)),
+	)
+	catalog := NewCatalog()
+	require.NoError(t, catalog.Update(bucket, location), "Error updating catalog")
+	requireCatalogBackups(t, catalog, "backup1", "backup2")
+
+	appendTestCatalogEntries(t, bucket, location, newTestCatalogEntry(CatalogOperationDelete, "backup1", now.Add(2*time.Second)))
+	require.NoError(t, catalog.Update(bucket, location), "Error updating catalog")
+	requireCatalogBackups(t, catalog, "backup2")
+
+	// The deletion is remembered even if the backup is added again by a
+	// late entry
+	appendTestCatalogEntries(t, bucket, location, newTestCatalogEntry(CatalogOperationAdd, "backup1", now.Add(3*time.Second)))
+	require.NoError(t, catalog.Update(bucket, location), "Error updating catalog")
+	requireCatalogBackups(t, catalog, "backup2")
+
+	// A deletion listed before the addition of the backup removes it too
+	appendTestCatalogEntries(t, bucket, location,
+		newTestCatalogEntry(CatalogOperationDelete, "backup3", now.Add(4*time.Second)),
+		newTestCatalogEntry(CatalogOperationAdd, "backup3", now.Add(5*time.Second)),
+	)
+	fresh := NewCatalog()
+	require.NoError(t, fresh.Update(bucket, location), "Error updating catalog")
+	requireCatalogBackups(t, fresh, "backup2")
+
+	// Deleted backups are still contained in the catalog so that a full
+	// sync doesn't add them again
+	for _, name := range []string{"backup1", "backup2", "backup3"} {
+		require.True(t, fresh.Contains(path.Join("ns", name, name+"-uid")), "Backup %v missing from catalog", name)
+	}
+	require.False(t, fresh.Contains(path.Join("ns", "backup4", "backup4-uid")), "Unexpected backup in catalog")
+}
+
+func TestCatalogOutOfOrder(t *testing.T) {
+	bucket := newTestBucket(t)
+	location := newTestBackupLocation("")
+	location.Namespace = "ns"
+	now := time.Now()
+	appendTestCatalogEntries(t, bucket, location, newTestCatalogEntry(CatalogOperationAdd, "backup1", now))
+	catalog := NewCatalog()
+	require.NoError(t, catalog.Update(bucket, location), "Error updating catalog")
+	requireCatalogBackups(t, catalog, "backup1")
+
+	// Written after the last update by a cluster whose clock is behind
+	appendTestCatalogEntries(t, bucket, location,
+		newTestCatalogEntry(CatalogOperationAdd, "backup2", now.Add(-time.Minute)),
+		newTestCatalogEntry(CatalogOperationAdd, "backup3", now.Add(-catalogLookback/2)),
+	)
+	require.NoError(t, catalog.Update(bucket, location), "Error updating catalog")
+	requireCatalogBackups(t, catalog, "backup1", "backup2", "backup3")
+
+	// Entries further behind than the lookback are only read by a new
+	// catalog
+	appendTestCatalogEntries(t, bucket, location, newTestCatalogEntry(CatalogOperationAdd, "backup4", now.Add(-2*catalogLookback)))
+	require.NoError(t, catalog.Update(bucket, location), "Error updating catalog")
+	requireCatalogBackups(t, catalog, "backup1", "backup2", "backup3")
+	fresh := NewCatalog()
+	require.NoError(t, fresh.Update(bucket, location), "Error updating catalog")
+	requireCatalogBackups(t, fresh, "backup1", "backup2", "backup3", "backup4")
+}
+
+func TestCatalogKeyRotation(t *testing.T) {
+	bucket := newTestBucket(t)
+	location := newTestBackupLocation("oldkey")
+	location.Namespace = "ns"
+	now := time.Now()
+	appendTestCatalogEntries(t, bucket, location, newTestCatalogEntry(CatalogOperationAdd, "backup1", now))
+	require.NoError(t, MarkCatalogComplete(bucket, location), "Error marking catalog complete")
+
+	// The old key is removed once the backups have been re-encrypted
+	location.Location.EncryptionV2Key = "newkey"
+	appendTestCatalogEntries(t, bucket, location, newTestCatalogEntry(CatalogOperationAdd, "backup2", now.Add(time.Second)))
+	catalog := NewCatalog()
+	require.NoError(t, catalog.Update(bucket, location), "Error updating catalog after key rotation")
+	requireCatalogBackups(t, catalog, "backup1", "backup2")
+	complete, err := IsCatalogComplete(bucket, location)
+	require.NoError(t, err)
+	require.True(t, complete, "Catalog should be complete")
+
+	// The entries are read without the keys of the location
+	location.Location.EncryptionV2Key = ""
+	catalog = NewCatalog()
+	require.NoError(t, catalog.Update(bucket, location), "Error updating catalog without key")
+	requireCatalogBackups(t, catalog, "backup1", "backup2")
+}
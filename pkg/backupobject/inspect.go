@@ -0,0 +1,89 @@
+package backupobject
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+
+	stork_api "github.com/libopenstorage/stork/pkg/apis/stork/v1alpha1"
+	"github.com/libopenstorage/stork/pkg/objectstore"
+	"gocloud.dev/blob"
+	"gocloud.dev/gcerrors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// Contents are the objects stored for a backup in a backup location
+type Contents struct {
+	// Backup is the backup read from the metadata of the backup. It is nil
+	// if the metadata hasn't been uploaded.
+	Backup     *stork_api.ApplicationBackup
+	Namespaces []*unstructured.Unstructured
+	CRDs       []*unstructured.Unstructured
+	Resources  []*unstructured.Unstructured
+	// Integrity is the result of verifying the objects against the manifest
+	// of the backup, IntegrityReason explains why a backup is corrupt
+	Integrity       stork_api.ApplicationBackupIntegrityType
+	IntegrityReason string
+}
+
+// Inspect reads the objects of the backup at objectPath from the backup
+// location without restoring them
+func Inspect(location *stork_api.BackupLocation, objectPath string) (*Contents, error) {
+	if location.Location.EncryptionKey != "" {
+		return nil, fmt.Errorf("EncryptionKey is deprecated, use EncryptionKeyV2 instead")
+	}
+	bucket, err := objectstore.GetBucket(location)
+	if err != nil {
+		return nil, err
+	}
+	defer bucket.Close()
+
+	contents := &Contents{}
+	contents.Integrity, contents.IntegrityReason, err = VerifyObjects(bucket, location, objectPath)
+	if err != nil {
+		return nil, fmt.Errorf("error verifying backup objects: %v", err)
+	}
+	contents.Backup, err = ReadMetadata(bucket, location, filepath.Join(objectPath, MetadataObjectName))
+	if err != nil && gcerrors.Code(err) != gcerrors.NotFound {
+		return nil, err
+	}
+	if contents.Namespaces, err = readBackupObjectList(bucket, location, objectPath, NamespacesObjectName, true); err != nil {
+		return nil, err
+	}
+	if contents.CRDs, err = readBackupObjectList(bucket, location, objectPath, CRDObjectName, true); err != nil {
+		return nil, err
+	}
+	if contents.Resources, err = readBackupObjectList(bucket, location, objectPath, ResourceObjectName, false); err != nil {
+		return nil, err
+	}
+	return contents, nil
+}
+
+// readBackupObjectList reads a list of objects uploaded for a backup. An
+// empty list is returned if skipIfNotPresent is set and the object doesn't
+// exist.
+func readBackupObjectList(
+	bucket *blob.Bucket,
+	location *stork_api.BackupLocation,
+	objectPath string,
+	objectName string,
+	skipIfNotPresent bool,
+) ([]*unstructured.Unstructured, error) {
+	objects := make([]*unstructured.Unstructured, 0)
+	reader, err := NewReader(bucket, location, filepath.Join(objectPath, objectName))
+	if err != nil {
+		if gcerrors.Code(err) == gcerrors.NotFound {
+			if skipIfNotPresent {
+				return objects, nil
+			}
+			return nil, fmt.Errorf("%v not found", objectName)
+		}
+		return nil, fmt.Errorf("error reading %v: %v", objectName, err)
+	}
+	defer reader.Close()
+
+	if err := json.NewDecoder(reader).Decode(&objects); err != nil {
+		return nil, fmt.Errorf("error parsing %v: %v", objectName, err)
+	}
+	return objects, nil
+}
@@ -0,0 +1,140 @@
+package backupobject
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path/filepath"
+
+	stork_api "github.com/libopenstorage/stork/pkg/apis/stork/v1alpha1"
+	"gocloud.dev/blob"
+	"gocloud.dev/gcerrors"
+)
+
+const (
+	// ManifestObjectName is the object with the manifest of a backup
+	ManifestObjectName = "manifest.json"
+	manifestVersion1   = 1
+)
+
+// Manifest lists the size and SHA-256 of every object uploaded for a
+// backup. It is uploaded after all the other objects so that its presence
+// means the backup was completely written. The checksums are of the data as
+// stored in the bucket, after compression and encryption, so the objects
+// aren't decrypted to verify them. The manifest itself is written like the
+// other objects though, so the encryption key of the location is needed to
+// read it.
+type Manifest struct {
+	Version int              `json:"version"`
+	Objects []ManifestObject `json:"objects"`
+}
+
+// ManifestObject is the size and SHA-256 of an object of the backup, named
+// relative to the backup path
+type ManifestObject struct {
+	Name   string `json:"name"`
+	Size   int64  `json:"size"`
+	SHA256 string `json:"sha256"`
+}
+
+// NewManifest returns an empty manifest with the current version
+func NewManifest() *Manifest {
+	return &Manifest{
+		Version: manifestVersion1,
+		Objects: make([]ManifestObject, 0),
+	}
+}
+
+// Add records the object in the manifest, replacing any previous entry with
+// the same name
+func (m *Manifest) Add(object ManifestObject) {
+	for i := range m.Objects {
+		if m.Objects[i].Name == object.Name {
+			m.Objects[i] = object
+			return
+		}
+	}
+	m.Objects = append(m.Objects, object)
+}
+
+// ReadManifest returns the manifest of the backup at objectPath, or nil
+// if the backup doesn't have one
+func ReadManifest(
+	bucket *blob.Bucket,
+	backupLocation *stork_api.BackupLocation,
+	objectPath string,
+) (*Manifest, error) {
+	reader, err := NewReader(bucket, backupLocation, filepath.Join(objectPath, ManifestObjectName))
+	if err != nil {
+		if gcerrors.Code(err) == gcerrors.NotFound {
+			return nil, nil
+		}
+		return nil, err
+	}
+	defer reader.Close()
+
+	manifest := &Manifest{}
+	if err := json.NewDecoder(reader).Decode(manifest); err != nil {
+		return nil, fmt.Errorf("error parsing backup manifest: %v", err)
+	}
+	if manifest.Version != manifestVersion1 {
+		return nil, fmt.Errorf("unsupported backup manifest version %v", manifest.Version)
+	}
+	return manifest, nil
+}
+
+// VerifyObjects checks the objects of the backup at objectPath against
+// its manifest. The returned reason explains why a backup is corrupt. An
+// error is only returned if the verification couldn't be completed.
+func VerifyObjects(
+	bucket *blob.Bucket,
+	backupLocation *stork_api.BackupLocation,
+	objectPath string,
+) (stork_api.ApplicationBackupIntegrityType, string, error) {
+	manifest, err := ReadManifest(bucket, backupLocation, objectPath)
+	if err != nil {
+		return stork_api.ApplicationBackupIntegrityUnknown, "", err
+	}
+	if manifest == nil {
+		return stork_api.ApplicationBackupIntegrityUnverified, "", nil
+	}
+	for _, object := range manifest.Objects {
+		size, checksum, err := GetObjectChecksum(bucket, filepath.Join(objectPath, object.Name))
+		if err != nil {
+			if gcerrors.Code(err) == gcerrors.NotFound {
+				return stork_api.ApplicationBackupIntegrityCorrupt,
+					fmt.Sprintf("object %v is missing", object.Name), nil
+			}
+			return stork_api.ApplicationBackupIntegrityUnknown, "", err
+		}
+		if size != object.Size {
+			return stork_api.ApplicationBackupIntegrityCorrupt,
+				fmt.Sprintf("object %v has size %v, expected %v", object.Name, size, object.Size), nil
+		}
+		if checksum != object.SHA256 {
+			return stork_api.ApplicationBackupIntegrityCorrupt,
+				fmt.Sprintf("object %v has checksum %v, expected %v", object.Name, checksum, object.SHA256), nil
+		}
+	}
+	return stork_api.ApplicationBackupIntegrityVerified, "", nil
+}
+
+// GetObjectChecksum returns the size and SHA-256 of the object as stored in
+// the bucket
+func GetObjectChecksum(bucket *blob.Bucket, objectPath string) (int64, string, error) {
+	reader, err := bucket.NewReader(context.TODO(), objectPath, nil)
+	if err != nil {
+		return 0, "", err
+	}
+	defer reader.Close()
+
+	hash := sha256.New()
+	size, err := io.Copy(hash, reader)
+	if err != nil {
+		return 0, "", err
+	}
+	return size, hex.EncodeToString(hash.Sum(nil)), nil
+}
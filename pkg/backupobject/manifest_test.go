@@ -0,0 +1,184 @@
+//go:build unittest
+// +build unittest
+
+package backupobject
+
+import (
+	"context"
+	"encoding/json"
+	"path/filepath"
+	"testing"
+
+	stork_api "github.com/libopenstorage/stork/pkg/apis/stork/v1alpha1"
+	"github.com/stretchr/testify/require"
+	"gocloud.dev/blob"
+	"gocloud.dev/blob/fileblob"
+)
+
+const testObjectPath = "ns/backup/uid"
+
+func newTestBucket(t *testing.T) *blob.Bucket {
+	bucket, err := fileblob.OpenBucket(t.TempDir(), nil)
+	require.NoError(t, err, "Error opening bucket")
+	t.Cleanup(func() { _ = bucket.Close() })
+	return bucket
+}
+
+func newTestBackupLocation(encryptionKey string) *stork_api.BackupLocation {
+	return &stork_api.BackupLocation{
+		Location: stork_api.BackupLocationItem{
+			Type:            stork_api.BackupLocationNFS,
+			EncryptionV2Key: encryptionKey,
+		},
+	}
+}
+
+// writeTestBackup writes the objects the way a backup does and returns the
+// manifest uploaded after them
+func writeTestBackup(
+	t *testing.T,
+	bucket *blob.Bucket,
+	backupLocation *stork_api.BackupLocation,
+	compressionType stork_api.BackupCompressionType,
+	objects map[string][]byte,
+) *Manifest {
+	manifest := NewManifest()
+	for name, data := range objects {
+		writer, err := NewWriter(bucket, backupLocation, testObjectPath, name, compressionType, nil)
+		require.NoError(t, err, "Error creating writer for %v", name)
+		_, err = writer.Write(data)
+		require.NoError(t, err, "Error writing %v", name)
+		require.NoError(t, writer.Close(), "Error closing writer for %v", name)
+		manifest.Add(writer.ManifestObject())
+	}
+	data, err := json.Marshal(manifest)
+	require.NoError(t, err, "Error encoding manifest")
+	writer, err := NewWriter(bucket, backupLocation, testObjectPath, ManifestObjectName, stork_api.BackupCompressionNone, nil)
+	require.NoError(t, err, "Error creating writer for manifest")
+	_, err = writer.Write(data)
+	require.NoError(t, err, "Error writing manifest")
+	require.NoError(t, writer.Close(), "Error closing writer for manifest")
+	return manifest
+}
+
+func testBackupObjects() map[string][]byte {
+	return map[string][]byte{
+		ResourceObjectName:   []byte(`[{"kind":"ConfigMap"}]`),
+		NamespacesObjectName: []byte(`[{"kind":"Namespace"}]`),
+		MetadataObjectName:   []byte(`{}`),
+	}
+}
+
+func TestBackupManifestVerified(t *testing.T) {
+	for _, tc := range []struct {
+		name            string
+		encryptionKey   string
+		compressionType stork_api.BackupCompressionType
+	}{
+		{name: "plain"},
+		{name: "compressed", compressionType: stork_api.BackupCompressionGzip},
+		{name: "encrypted", encryptionKey: "testkey"},
+		{name: "compressed and encrypted", encryptionKey: "testkey", compressionType: stork_api.BackupCompressionZstd},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			bucket := newTestBucket(t)
+			backupLocation := newTestBackupLocation(tc.encryptionKey)
+			written := writeTestBackup(t, bucket, backupLocation, tc.compressionType, testBackupObjects())
+
+			manifest, err := ReadManifest(bucket, backupLocation, testObjectPath)
+			require.NoError(t, err, "Error reading manifest")
+			require.ElementsMatch(t, written.Objects, manifest.Objects, "Manifest mismatch")
+
+			integrity, reason, err := VerifyObjects(bucket, backupLocation, testObjectPath)
+			require.NoError(t, err, "Error verifying backup")
+			require.Equal(t, stork_api.ApplicationBackupIntegrityVerified, integrity, reason)
+		})
+	}
+}
+
+func TestBackupManifestUnverified(t *testing.T) {
+	bucket := newTestBucket(t)
+	backupLocation := newTestBackupLocation("")
+	// Backups taken before manifests were added only have the objects
+	for name, data := range testBackupObjects() {
+		require.NoError(t, bucket.WriteAll(context.TODO(), filepath.Join(testObjectPath, name), data, nil))
+	}
+	manifest, err := ReadManifest(bucket, backupLocation, testObjectPath)
+	require.NoError(t, err, "Error reading missing manifest")
+	require.Nil(t, manifest, "Expected no manifest")
+
+	integrity, _, err := VerifyObjects(bucket, backupLocation, testObjectPath)
+	require.NoError(t, err, "Error verifying backup")
+	require.Equal(t, stork_api.ApplicationBackupIntegrityUnverified, integrity)
+}
+
+func TestBackupManifestCorrupt(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		corrupt func(t *testing.T, bucket *blob.Bucket, objectPath string)
+		reason  string
+	}{
+		{
+			name: "missing",
+			corrupt: func(t *testing.T, bucket *blob.Bucket, objectPath string) {
+				require.NoError(t, bucket.Delete(context.TODO(), objectPath))
+			},
+			reason: "is missing",
+		},
+		{
+			name: "truncated",
+			corrupt: func(t *testing.T, bucket *blob.Bucket, objectPath string) {
+				data, err := bucket.ReadAll(context.TODO(), objectPath)
+				require.NoError(t, err)
+				require.NoError(t, bucket.WriteAll(context.TODO(), objectPath, data[:len(data)-1], nil))
+			},
+			reason: "has size",
+		},
+		{
+			name: "modified",
+			corrupt: func(t *testing.T, bucket *blob.Bucket, objectPath string) {
+				data, err := bucket.ReadAll(context.TODO(), objectPath)
+				require.NoError(t, err)
+				data[len(data)/2] ^= 0xff
+				require.NoError(t, bucket.WriteAll(context.TODO(), objectPath, data, nil))
+			},
+			reason: "has checksum",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			bucket := newTestBucket(t)
+			backupLocation := newTestBackupLocation("testkey")
+			writeTestBackup(t, bucket, backupLocation, stork_api.BackupCompressionGzip, testBackupObjects())
+			tc.corrupt(t, bucket, filepath.Join(testObjectPath, ResourceObjectName))
+
+			integrity, reason, err := VerifyObjects(bucket, backupLocation, testObjectPath)
+			require.NoError(t, err, "Error verifying backup")
+			require.Equal(t, stork_api.ApplicationBackupIntegrityCorrupt, integrity)
+			require.Contains(t, reason, ResourceObjectName)
+			require.Contains(t, reason, tc.reason)
+		})
+	}
+}
+
+func TestBackupManifestInvalid(t *testing.T) {
+	bucket := newTestBucket(t)
+	backupLocation := newTestBackupLocation("")
+	require.NoError(t, bucket.WriteAll(context.TODO(), filepath.Join(testObjectPath, ManifestObjectName), []byte(`{"version":2}`), nil))
+	_, err := ReadManifest(bucket, backupLocation, testObjectPath)
+	require.Error(t, err, "Expected error for unsupported manifest version")
+
+	integrity, _, err := VerifyObjects(bucket, backupLocation, testObjectPath)
+	require.Error(t, err, "Expected error for unsupported manifest version")
+	require.Equal(t, stork_api.ApplicationBackupIntegrityUnknown, integrity)
+}
+
+func TestBackupManifestAdd(t *testing.T) {
+	manifest := NewManifest()
+	manifest.Add(ManifestObject{Name: "a", Size: 1, SHA256: "1"})
+	manifest.Add(ManifestObject{Name: "b", Size: 2, SHA256: "2"})
+	manifest.Add(ManifestObject{Name: "a", Size: 3, SHA256: "3"})
+	require.Equal(t, []ManifestObject{
+		{Name: "a", Size: 3, SHA256: "3"},
+		{Name: "b", Size: 2, SHA256: "2"},
+	}, manifest.Objects, "Replaced entry should keep its position")
+}
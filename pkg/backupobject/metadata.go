@@ -0,0 +1,66 @@
+package backupobject
+
+import (
+	"encoding/json"
+	"fmt"
+
+	stork_api "github.com/libopenstorage/stork/pkg/apis/stork/v1alpha1"
+	"github.com/libopenstorage/stork/pkg/utils"
+	"gocloud.dev/blob"
+)
+
+const nameTimeSuffixFormat = "2006-01-02-150405"
+
+// ReadMetadata streams and decodes the backup object stored in the
+// metadata object of a backup
+func ReadMetadata(
+	bucket *blob.Bucket,
+	location *stork_api.BackupLocation,
+	objectPath string,
+) (*stork_api.ApplicationBackup, error) {
+	reader, err := NewReader(bucket, location, objectPath)
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+
+	backupInfo := &stork_api.ApplicationBackup{}
+	if err = json.NewDecoder(reader).Decode(backupInfo); err != nil {
+		return nil, fmt.Errorf("error parsing backup metadata: %v", err)
+	}
+	return backupInfo, nil
+}
+
+// GetSyncedBackupName returns the name of the backup when it is synced from a
+// backup location
+func GetSyncedBackupName(backup *stork_api.ApplicationBackup) string {
+	// For scheduled backups use the original name
+	if _, ok := backup.Annotations[utils.ApplicationBackupScheduleNameAnnotation]; ok {
+		return backup.Name
+	}
+	return backup.Name + "-" + backup.Status.TriggerTimestamp.Time.Format(nameTimeSuffixFormat)
+}
+
+// PrepareSyncedBackup turns the backup read from the metadata in a backup
+// location into a backup that can be created on this cluster. The objects in
+// the location are retained when it is deleted.
+func PrepareSyncedBackup(
+	backupInfo *stork_api.ApplicationBackup,
+	location *stork_api.BackupLocation,
+	integrity stork_api.ApplicationBackupIntegrityType,
+	reason string,
+) {
+	backupInfo.Name = GetSyncedBackupName(backupInfo)
+	backupInfo.UID = ""
+	backupInfo.ResourceVersion = ""
+	backupInfo.OwnerReferences = nil
+	backupInfo.Spec.ReclaimPolicy = stork_api.ApplicationBackupReclaimPolicyRetain
+	if backupInfo.Annotations == nil {
+		backupInfo.Annotations = make(map[string]string)
+	}
+	backupInfo.Annotations[utils.ApplicationBackupSyncedFromAnnotation] = location.Name
+	backupInfo.Status.Integrity = integrity
+	if integrity == stork_api.ApplicationBackupIntegrityCorrupt {
+		backupInfo.Status.Reason = fmt.Sprintf("Backup failed integrity verification: %v", reason)
+	}
+}
@@ -0,0 +1,375 @@
+// Package backupobject reads and writes the objects that stork stores for an
+// application backup in a backup location
+package backupobject
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"hash"
+	"io"
+	"path/filepath"
+	"reflect"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/s3/s3manager"
+	stork_api "github.com/libopenstorage/stork/pkg/apis/stork/v1alpha1"
+	"github.com/libopenstorage/stork/pkg/compression"
+	"github.com/libopenstorage/stork/pkg/crypto"
+	"github.com/libopenstorage/stork/pkg/log"
+	"github.com/libopenstorage/stork/pkg/objectstore"
+	"gocloud.dev/blob"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// ResourceObjectName is the object with the resources of a backup
+	ResourceObjectName = "resources.json"
+	// CRDObjectName is the object with the CRDs of a backup
+	CRDObjectName = "crds.json"
+	// NamespacesObjectName is the object with the namespaces of a backup
+	NamespacesObjectName = "namespaces.json"
+	// MetadataObjectName is the object with the ApplicationBackup itself
+	MetadataObjectName = "metadata.json"
+)
+
+// ObjectNames are the objects written by stork for backups taken before
+// manifests were added
+var ObjectNames = []string{NamespacesObjectName, CRDObjectName, ResourceObjectName, MetadataObjectName}
+
+// Writer writes an object to the backup location, compressing and
+// encrypting it on the way if requested. Nothing is committed to the bucket
+// until Close is called.
+type Writer struct {
+	io.Writer
+	name           string
+	compressWriter io.WriteCloser
+	encryptWriter  io.WriteCloser
+	checksumWriter *checksumWriter
+	blobWriter     *blob.Writer
+	cancel         context.CancelFunc
+}
+
+// checksumWriter tracks the size and SHA-256 of the data written to the
+// bucket so that they can be recorded in the backup manifest
+type checksumWriter struct {
+	w    io.Writer
+	hash hash.Hash
+	size int64
+}
+
+func newChecksumWriter(w io.Writer) *checksumWriter {
+	return &checksumWriter{w: w, hash: sha256.New()}
+}
+
+func (c *checksumWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.hash.Write(p[:n])
+	c.size += int64(n)
+	return n, err
+}
+
+func (c *checksumWriter) manifestObject(name string) ManifestObject {
+	return ManifestObject{
+		Name:   name,
+		Size:   c.size,
+		SHA256: hex.EncodeToString(c.hash.Sum(nil)),
+	}
+}
+
+// Close flushes the compressed data and the last encrypted chunk and commits
+// the object
+func (w *Writer) Close() error {
+	defer w.cancel()
+	if w.compressWriter != nil {
+		if err := w.compressWriter.Close(); err != nil {
+			w.Abort()
+			return err
+		}
+	}
+	if w.encryptWriter != nil {
+		if err := w.encryptWriter.Close(); err != nil {
+			w.Abort()
+			return err
+		}
+	}
+	return w.blobWriter.Close()
+}
+
+// ManifestObject returns the manifest entry for the object. It is only valid
+// after the writer has been closed successfully.
+func (w *Writer) ManifestObject() ManifestObject {
+	return w.checksumWriter.manifestObject(w.name)
+}
+
+// Abort discards everything written so far without committing the object
+func (w *Writer) Abort() {
+	w.cancel()
+	_ = w.blobWriter.Close()
+}
+
+// GetWriterOptions returns the options to write an object to the
+// backup location. For S3 the objects are encrypted with the configured SSE
+// type and retained as described by objectLock if it is set.
+func GetWriterOptions(
+	backupLocation *stork_api.BackupLocation,
+	objectLock *stork_api.ApplicationBackupObjectLock,
+) *blob.WriterOptions {
+	var options blob.WriterOptions
+	if backupLocation.Location.S3Config != nil {
+		sseType := backupLocation.Location.S3Config.SSE
+		if len(sseType) != 0 || objectLock != nil {
+			beforeWrite := func(asFunc func(interface{}) bool) error {
+				var input *s3manager.UploadInput
+				if asFunc(&input) {
+					if len(sseType) != 0 {
+						input.ServerSideEncryption = &sseType
+					}
+					if objectLock != nil {
+						input.ObjectLockMode = aws.String(objectLock.Mode)
+						input.ObjectLockRetainUntilDate = aws.Time(objectLock.RetainUntil.Time)
+					}
+				}
+				return nil
+			}
+			options = blob.WriterOptions{BeforeWrite: beforeWrite}
+		}
+	}
+	return &options
+}
+
+// NewWriter returns a writer for the object name under
+// objectPath that compresses the data with compressionType. If objectLock is
+// set the object is retained until objectLock.RetainUntil.
+func NewWriter(
+	bucket *blob.Bucket,
+	backupLocation *stork_api.BackupLocation,
+	objectPath string,
+	name string,
+	compressionType stork_api.BackupCompressionType,
+	objectLock *stork_api.ApplicationBackupObjectLock,
+) (*Writer, error) {
+	if backupLocation.Location.EncryptionKey != "" {
+		return nil, fmt.Errorf("EncryptionKey is deprecated, use EncryptionKeyV2 instead")
+	}
+	// Retention can only be set on the objects of S3 buckets
+	if objectLock != nil && backupLocation.Location.Type != stork_api.BackupLocationS3 {
+		return nil, fmt.Errorf("object lock is not supported for backup locations of type %v", backupLocation.Location.Type)
+	}
+	ctx, cancel := context.WithCancel(context.TODO())
+	blobWriter, err := bucket.NewWriter(ctx, filepath.Join(objectPath, name), GetWriterOptions(backupLocation, objectLock))
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	w := &Writer{
+		name:           name,
+		checksumWriter: newChecksumWriter(blobWriter),
+		blobWriter:     blobWriter,
+		cancel:         cancel,
+	}
+	w.Writer = w.checksumWriter
+	keyID, encryptionKey, err := backupLocation.GetActiveEncryptionKey()
+	if err != nil {
+		w.Abort()
+		return nil, err
+	}
+	if encryptionKey != "" {
+		w.encryptWriter, err = crypto.NewKeyEncryptWriter(w.checksumWriter, keyID, encryptionKey)
+		if err != nil {
+			w.Abort()
+			return nil, err
+		}
+		w.Writer = w.encryptWriter
+	}
+	if compressionType != stork_api.BackupCompressionNone {
+		w.compressWriter, err = compression.NewWriter(w.Writer, compressionType)
+		if err != nil {
+			w.Abort()
+			return nil, err
+		}
+		w.Writer = w.compressWriter
+	}
+	return w, nil
+}
+
+// WriteObject writes data as the object name under objectPath and returns its
+// manifest entry
+func WriteObject(
+	bucket *blob.Bucket,
+	backupLocation *stork_api.BackupLocation,
+	objectPath string,
+	name string,
+	data []byte,
+	objectLock *stork_api.ApplicationBackupObjectLock,
+) (*ManifestObject, error) {
+	writer, err := NewWriter(bucket, backupLocation, objectPath, name, stork_api.BackupCompressionNone, objectLock)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := writer.Write(data); err != nil {
+		writer.Abort()
+		return nil, err
+	}
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+	object := writer.ManifestObject()
+	return &object, nil
+}
+
+// GetObjectLock returns the retention to set on the objects of a backup
+// uploaded now to the backup location, or nil if the bucket doesn't retain
+// objects in compliance or governance mode. Retention is only supported for
+// S3 buckets. Credentials that aren't allowed to read the object lock
+// configuration of the bucket are assumed not to need retention.
+func GetObjectLock(backupLocation *stork_api.BackupLocation) (*stork_api.ApplicationBackupObjectLock, error) {
+	objLockInfo, err := objectstore.GetObjLockInfo(backupLocation)
+	if err != nil {
+		if awsErr, ok := err.(awserr.Error); !ok || awsErr.Code() != "AccessDenied" {
+			return nil, err
+		}
+		log.BackupLocationLog(backupLocation).Warnf("Not retaining backup objects, the object lock configuration of the bucket can't be read: %v", err)
+		return nil, nil
+	}
+	if !objLockInfo.HasRetention() {
+		return nil, nil
+	}
+	if backupLocation.Location.Type != stork_api.BackupLocationS3 {
+		return nil, fmt.Errorf("object lock is not supported for backup locations of type %v", backupLocation.Location.Type)
+	}
+	return &stork_api.ApplicationBackupObjectLock{
+		Mode:        objLockInfo.LockMode,
+		RetainUntil: metav1.NewTime(objLockInfo.RetainUntil(time.Now())),
+	}, nil
+}
+
+type objectReader struct {
+	io.Reader
+	closers []io.Closer
+}
+
+func (r *objectReader) Close() error {
+	var err error
+	for _, closer := range r.closers {
+		if closeErr := closer.Close(); closeErr != nil && err == nil {
+			err = closeErr
+		}
+	}
+	return err
+}
+
+// NewReader returns a reader for the object at objectPath that
+// decrypts and decompresses it as it is read. Uncompressed objects are
+// returned as is.
+func NewReader(
+	bucket *blob.Bucket,
+	backupLocation *stork_api.BackupLocation,
+	objectPath string,
+) (io.ReadCloser, error) {
+	reader, err := OpenDecrypted(bucket, backupLocation, objectPath)
+	if err != nil {
+		return nil, err
+	}
+	decompressReader, err := compression.NewReader(reader)
+	if err != nil {
+		_ = reader.Close()
+		return nil, err
+	}
+	return &objectReader{
+		Reader:  decompressReader,
+		closers: []io.Closer{decompressReader, reader},
+	}, nil
+}
+
+// OpenDecrypted returns a reader for the object at objectPath,
+// decrypting it if the location has encryption keys. Objects in the chunked
+// stream format are decrypted as they are read with the key recorded in
+// them. Objects in the older single blob format are read fully and, as
+// before, returned as is if they fail to decrypt so that backups taken before
+// a key was set can still be read.
+func OpenDecrypted(
+	bucket *blob.Bucket,
+	backupLocation *stork_api.BackupLocation,
+	objectPath string,
+) (io.ReadCloser, error) {
+	if backupLocation.Location.EncryptionKey != "" {
+		return nil, fmt.Errorf("EncryptionKey is deprecated, use EncryptionKeyV2 instead")
+	}
+	blobReader, err := bucket.NewReader(context.TODO(), objectPath, nil)
+	if err != nil {
+		return nil, err
+	}
+	keyring := objectstore.GetEncryptionKeyring(backupLocation)
+	if keyring.IsEmpty() {
+		return blobReader, nil
+	}
+
+	bufReader := bufio.NewReader(blobReader)
+	header, err := bufReader.Peek(crypto.StreamHeaderLen)
+	if err != nil && err != io.EOF {
+		_ = blobReader.Close()
+		return nil, err
+	}
+	if crypto.IsStreamEncrypted(header) {
+		decryptReader, err := crypto.NewKeyringStreamDecryptReader(bufReader, keyring)
+		if err != nil {
+			_ = blobReader.Close()
+			return nil, err
+		}
+		return &objectReader{Reader: decryptReader, closers: []io.Closer{blobReader}}, nil
+	}
+
+	data, err := io.ReadAll(bufReader)
+	if err != nil {
+		_ = blobReader.Close()
+		return nil, err
+	}
+	decryptData, err := crypto.DecryptWithKeyring(data, keyring)
+	if err != nil {
+		log.BackupLocationLog(backupLocation).Errorf("Decrypt failed for %v: %v, returning data directly", objectPath, err)
+		decryptData = data
+	}
+	return &objectReader{Reader: bytes.NewReader(decryptData), closers: []io.Closer{blobReader}}, nil
+}
+
+// EncodeJSONList writes the JSON encoding of list to w. Slices are encoded one
+// element at a time so that only a single element is held in memory while
+// encoding. The output is the same as json.MarshalIndent(list, "", " ").
+func EncodeJSONList(w io.Writer, list interface{}) error {
+	value := reflect.ValueOf(list)
+	if value.Kind() != reflect.Slice || value.IsNil() || value.Len() == 0 {
+		data, err := json.MarshalIndent(list, "", " ")
+		if err != nil {
+			return err
+		}
+		_, err = w.Write(data)
+		return err
+	}
+
+	if _, err := io.WriteString(w, "[\n "); err != nil {
+		return err
+	}
+	for i := 0; i < value.Len(); i++ {
+		if i > 0 {
+			if _, err := io.WriteString(w, ",\n "); err != nil {
+				return err
+			}
+		}
+		data, err := json.MarshalIndent(value.Index(i).Interface(), " ", " ")
+		if err != nil {
+			return err
+		}
+		if _, err := w.Write(data); err != nil {
+			return err
+		}
+	}
+	_, err := io.WriteString(w, "\n]")
+	return err
+}
@@ -0,0 +1,71 @@
+//go:build unittest
+// +build unittest
+
+package backupobject
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	stork_api "github.com/libopenstorage/stork/pkg/apis/stork/v1alpha1"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestObjectLockNotSupported(t *testing.T) {
+	bucket := newTestBucket(t)
+	objectLock := &stork_api.ApplicationBackupObjectLock{
+		Mode:        "COMPLIANCE",
+		RetainUntil: metav1.NewTime(time.Now().Add(time.Hour)),
+	}
+	for _, locationType := range []stork_api.BackupLocationType{
+		stork_api.BackupLocationAzure,
+		stork_api.BackupLocationGoogle,
+		stork_api.BackupLocationNFS,
+	} {
+		backupLocation := newTestBackupLocation("")
+		backupLocation.Location.Type = locationType
+		_, err := NewWriter(bucket, backupLocation, testObjectPath, MetadataObjectName, stork_api.BackupCompressionNone, objectLock)
+		require.Error(t, err, "Expected error for object lock with %v", locationType)
+
+		writer, err := NewWriter(bucket, backupLocation, testObjectPath, MetadataObjectName, stork_api.BackupCompressionNone, nil)
+		require.NoError(t, err, "Error creating writer without object lock for %v", locationType)
+		writer.Abort()
+	}
+}
+
+// newTestS3Location returns an S3 backup location whose requests for the
+// object lock configuration of the bucket fail with the error code
+func newTestS3Location(t *testing.T, statusCode int, code string) *stork_api.BackupLocation {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(statusCode)
+		fmt.Fprintf(w, "<Error><Code>%v</Code><Message>%v</Message></Error>", code, code)
+	}))
+	t.Cleanup(server.Close)
+	backupLocation := newTestBackupLocation("")
+	backupLocation.Location.Type = stork_api.BackupLocationS3
+	backupLocation.Location.Path = "bucket"
+	backupLocation.Location.S3Config = &stork_api.S3Config{
+		Endpoint:        strings.TrimPrefix(server.URL, "http://"),
+		AccessKeyID:     "access",
+		SecretAccessKey: "secret",
+		Region:          "us-east-1",
+		DisableSSL:      true,
+	}
+	return backupLocation
+}
+
+func TestGetObjectLockAccessDenied(t *testing.T) {
+	// Credentials without the permission to read the object lock
+	// configuration don't fail the backups
+	objectLock, err := GetObjectLock(newTestS3Location(t, http.StatusForbidden, "AccessDenied"))
+	require.NoError(t, err, "Expected no error when object lock configuration can't be read")
+	require.Nil(t, objectLock)
+
+	_, err = GetObjectLock(newTestS3Location(t, http.StatusBadRequest, "InvalidRequest"))
+	require.Error(t, err, "Expected error when object lock configuration can't be read")
+}
@@ -0,0 +1,84 @@
+package compression
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+
+	"github.com/klauspost/compress/zstd"
+	stork_api "github.com/libopenstorage/stork/pkg/apis/stork/v1alpha1"
+)
+
+var (
+	gzipMagic = []byte{0x1f, 0x8b}
+	zstdMagic = []byte{0x28, 0xb5, 0x2f, 0xfd}
+)
+
+type nopWriteCloser struct {
+	io.Writer
+}
+
+func (nopWriteCloser) Close() error {
+	return nil
+}
+
+// NewWriter returns a writer that compresses everything written to it with
+// the given compression type and writes it to w. Close must be called to
+// flush the compressed data, it does not close w.
+func NewWriter(w io.Writer, compressionType stork_api.BackupCompressionType) (io.WriteCloser, error) {
+	switch compressionType {
+	case stork_api.BackupCompressionNone:
+		return nopWriteCloser{w}, nil
+	case stork_api.BackupCompressionGzip:
+		return gzip.NewWriter(w), nil
+	case stork_api.BackupCompressionZstd:
+		return zstd.NewWriter(w)
+	default:
+		return nil, fmt.Errorf("invalid compression type: %v", compressionType)
+	}
+}
+
+// Detect returns the compression type of data from its first few bytes
+func Detect(data []byte) stork_api.BackupCompressionType {
+	if bytes.HasPrefix(data, gzipMagic) {
+		return stork_api.BackupCompressionGzip
+	}
+	if bytes.HasPrefix(data, zstdMagic) {
+		return stork_api.BackupCompressionZstd
+	}
+	return stork_api.BackupCompressionNone
+}
+
+type zstdReadCloser struct {
+	*zstd.Decoder
+}
+
+func (z zstdReadCloser) Close() error {
+	z.Decoder.Close()
+	return nil
+}
+
+// NewReader returns a reader that decompresses the data read from r. The
+// compression type is detected from the data so that uncompressed data is
+// returned as is. Closing the reader does not close r.
+func NewReader(r io.Reader) (io.ReadCloser, error) {
+	br := bufio.NewReader(r)
+	magic, err := br.Peek(len(zstdMagic))
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	switch Detect(magic) {
+	case stork_api.BackupCompressionGzip:
+		return gzip.NewReader(br)
+	case stork_api.BackupCompressionZstd:
+		decoder, err := zstd.NewReader(br)
+		if err != nil {
+			return nil, err
+		}
+		return zstdReadCloser{decoder}, nil
+	default:
+		return io.NopCloser(br), nil
+	}
+}
@@ -0,0 +1,57 @@
+//go:build unittest
+// +build unittest
+
+package compression
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	stork_api "github.com/libopenstorage/stork/pkg/apis/stork/v1alpha1"
+	"github.com/stretchr/testify/require"
+)
+
+func compress(t *testing.T, data []byte, compressionType stork_api.BackupCompressionType) []byte {
+	var buf bytes.Buffer
+	w, err := NewWriter(&buf, compressionType)
+	require.NoError(t, err, "Error creating writer")
+	_, err = w.Write(data)
+	require.NoError(t, err, "Error writing data")
+	require.NoError(t, w.Close(), "Error closing writer")
+	return buf.Bytes()
+}
+
+func decompress(t *testing.T, data []byte) []byte {
+	r, err := NewReader(bytes.NewReader(data))
+	require.NoError(t, err, "Error creating reader")
+	defer r.Close()
+	out, err := io.ReadAll(r)
+	require.NoError(t, err, "Error reading data")
+	return out
+}
+
+func TestCompressDecompress(t *testing.T) {
+	originalData := bytes.Repeat([]byte(`{"kind": "ConfigMap", "apiVersion": "v1"}`), 1024)
+	for _, compressionType := range []stork_api.BackupCompressionType{
+		stork_api.BackupCompressionNone,
+		stork_api.BackupCompressionGzip,
+		stork_api.BackupCompressionZstd,
+	} {
+		compressedData := compress(t, originalData, compressionType)
+		require.Equal(t, compressionType, Detect(compressedData), "Wrong compression type detected")
+		if compressionType != stork_api.BackupCompressionNone {
+			require.Less(t, len(compressedData), len(originalData), "Data wasn't compressed")
+		}
+		require.Equal(t, originalData, decompress(t, compressedData), "Data mismatch for %v", compressionType)
+	}
+}
+
+func TestDecompressEmpty(t *testing.T) {
+	require.Empty(t, decompress(t, []byte{}), "Expected empty data")
+}
+
+func TestInvalidCompressionType(t *testing.T) {
+	_, err := NewWriter(&bytes.Buffer{}, "lz4")
+	require.Error(t, err, "Expected error for invalid compression type")
+}
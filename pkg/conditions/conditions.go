@@ -0,0 +1,130 @@
+package conditions
+
+import (
+	"fmt"
+	"strings"
+
+	stork_api "github.com/libopenstorage/stork/pkg/apis/stork/v1alpha1"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
+)
+
+// Events are the events raised for an object when the conditions that failed
+// change
+type Events struct {
+	// FailedReason is the reason of the warning event raised when
+	// conditions fail
+	FailedReason string
+	// FailedMessage is prefixed to the messages of the failed conditions
+	FailedMessage string
+	// HealthyReason is the reason of the normal event raised once no
+	// condition fails anymore
+	HealthyReason string
+	// HealthyMessage is the message of the normal event
+	HealthyMessage string
+}
+
+// New returns a condition that transitioned now
+func New(
+	conditionType stork_api.ConditionType,
+	status metav1.ConditionStatus,
+	reason string,
+	message string,
+) stork_api.Condition {
+	return stork_api.Condition{
+		Type:               conditionType,
+		Status:             status,
+		Reason:             reason,
+		Message:            message,
+		LastTransitionTime: metav1.Now(),
+	}
+}
+
+// Get returns the condition of the given type, or nil if the object hasn't
+// been checked for it
+func Get(conditions []stork_api.Condition, conditionType stork_api.ConditionType) *stork_api.Condition {
+	for i := range conditions {
+		if conditions[i].Type == conditionType {
+			return &conditions[i]
+		}
+	}
+	return nil
+}
+
+// Set adds or replaces the condition with the same type. The transition time
+// is only updated if the status changed.
+func Set(conditions *[]stork_api.Condition, condition stork_api.Condition) {
+	existing := Get(*conditions, condition.Type)
+	if existing == nil {
+		*conditions = append(*conditions, condition)
+		return
+	}
+	if existing.Status == condition.Status {
+		condition.LastTransitionTime = existing.LastTransitionTime
+	}
+	*existing = condition
+}
+
+// Changed returns true if any of the conditions has a different status,
+// reason or message
+func Changed(previous []stork_api.Condition, current []stork_api.Condition) bool {
+	if len(previous) != len(current) {
+		return true
+	}
+	for _, condition := range current {
+		existing := Get(previous, condition.Type)
+		if existing == nil ||
+			existing.Status != condition.Status ||
+			existing.Reason != condition.Reason ||
+			existing.Message != condition.Message {
+			return true
+		}
+	}
+	return false
+}
+
+// GetFailed returns the types and the messages of the false conditions
+func GetFailed(conditions []stork_api.Condition) (string, string) {
+	types := make([]string, 0)
+	messages := make([]string, 0)
+	for _, condition := range conditions {
+		if condition.Status == metav1.ConditionFalse {
+			types = append(types, string(condition.Type))
+			messages = append(messages, fmt.Sprintf("%v: %v", condition.Type, condition.Message))
+		}
+	}
+	return strings.Join(types, ","), strings.Join(messages, ", ")
+}
+
+// RecordEvents raises a warning event for the object if the conditions that
+// failed changed, or a normal event once none of them fail anymore. The
+// message of the warning event is returned, or an empty string if none was
+// raised.
+func RecordEvents(
+	recorder record.EventRecorder,
+	object runtime.Object,
+	previous []stork_api.Condition,
+	current []stork_api.Condition,
+	events Events,
+) string {
+	previousFailures, _ := GetFailed(previous)
+	failures, messages := GetFailed(current)
+	if failures == previousFailures {
+		return ""
+	}
+	if failures != "" {
+		message := fmt.Sprintf("%v: %v", events.FailedMessage, messages)
+		recorder.Event(object,
+			v1.EventTypeWarning,
+			events.FailedReason,
+			message)
+		return message
+	}
+	recorder.Event(object,
+		v1.EventTypeNormal,
+		events.HealthyReason,
+		events.HealthyMessage)
+	return ""
+}
@@ -0,0 +1,90 @@
+//go:build unittest
+// +build unittest
+
+package conditions
+
+import (
+	"testing"
+
+	stork_api "github.com/libopenstorage/stork/pkg/apis/stork/v1alpha1"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+)
+
+const reasonValid = "Valid"
+
+func TestSet(t *testing.T) {
+	current := make([]stork_api.Condition, 0)
+	Set(&current, New(stork_api.BackupLocationConditionBucket, metav1.ConditionTrue, reasonValid, ""))
+	transitioned := metav1.NewTime(current[0].LastTransitionTime.Add(-1))
+	current[0].LastTransitionTime = transitioned
+
+	Set(&current, New(stork_api.BackupLocationConditionBucket, metav1.ConditionTrue, reasonValid, "new message"))
+	require.Len(t, current, 1, "Condition with the same type should be replaced")
+	require.Equal(t, "new message", current[0].Message)
+	require.Equal(t, transitioned, current[0].LastTransitionTime, "Transition time shouldn't change with the status")
+
+	Set(&current, New(stork_api.BackupLocationConditionBucket, metav1.ConditionFalse, "NotFound", ""))
+	require.NotEqual(t, transitioned, current[0].LastTransitionTime, "Transition time should change with the status")
+	require.Equal(t, metav1.ConditionFalse, Get(current, stork_api.BackupLocationConditionBucket).Status)
+	require.Nil(t, Get(current, stork_api.BackupLocationConditionReadWrite))
+}
+
+func TestChanged(t *testing.T) {
+	previous := make([]stork_api.Condition, 0)
+	Set(&previous, New(stork_api.BackupLocationConditionBucket, metav1.ConditionTrue, reasonValid, ""))
+	Set(&previous, New(stork_api.BackupLocationConditionReadWrite, metav1.ConditionTrue, reasonValid, ""))
+	copyConditions := func() []stork_api.Condition {
+		return append([]stork_api.Condition{}, previous...)
+	}
+
+	current := copyConditions()
+	Set(&current, New(stork_api.BackupLocationConditionBucket, metav1.ConditionTrue, reasonValid, ""))
+	require.False(t, Changed(previous, current), "Checking again with the same result shouldn't be a change")
+
+	Set(&current, New(stork_api.BackupLocationConditionBucket, metav1.ConditionFalse, "NotFound", "bucket not found"))
+	require.True(t, Changed(previous, current), "Failed condition should be a change")
+
+	current = copyConditions()
+	Set(&current, New(stork_api.BackupLocationConditionReadWrite, metav1.ConditionTrue, reasonValid, "new message"))
+	require.True(t, Changed(previous, current), "New message should be a change")
+
+	current = copyConditions()
+	Set(&current, New(stork_api.BackupLocationConditionObjectLock, metav1.ConditionTrue, reasonValid, ""))
+	require.True(t, Changed(previous, current), "New condition should be a change")
+}
+
+func TestRecordEvents(t *testing.T) {
+	recorder := record.NewFakeRecorder(10)
+	object := &stork_api.BackupLocation{}
+	events := Events{
+		FailedReason:   "ValidationFailed",
+		FailedMessage:  "Validation failed",
+		HealthyReason:  "Validated",
+		HealthyMessage: "Validated successfully",
+	}
+	healthy := []stork_api.Condition{
+		New(stork_api.BackupLocationConditionBucket, metav1.ConditionTrue, reasonValid, ""),
+		New(stork_api.BackupLocationConditionReadWrite, metav1.ConditionUnknown, "NotChecked", ""),
+	}
+	require.Empty(t, RecordEvents(recorder, object, nil, healthy, events), "Warning raised for healthy conditions")
+	require.Len(t, recorder.Events, 0, "Events shouldn't be raised while no condition fails")
+
+	failed := []stork_api.Condition{
+		New(stork_api.BackupLocationConditionBucket, metav1.ConditionFalse, "NotFound", "bucket not found"),
+	}
+	message := RecordEvents(recorder, object, healthy, failed, events)
+	require.Equal(t, "Validation failed: BucketAvailable: bucket not found", message)
+	require.Equal(t, "Warning ValidationFailed "+message, <-recorder.Events)
+
+	// Only a change in the conditions that failed raises events
+	stillFailed := []stork_api.Condition{
+		New(stork_api.BackupLocationConditionBucket, metav1.ConditionFalse, "NotFound", "bucket still not found"),
+	}
+	require.Empty(t, RecordEvents(recorder, object, failed, stillFailed, events))
+	require.Len(t, recorder.Events, 0, "Unexpected events raised")
+
+	require.Empty(t, RecordEvents(recorder, object, stillFailed, healthy, events))
+	require.Equal(t, "Normal Validated Validated successfully", <-recorder.Events)
+}
@@ -4,6 +4,7 @@
 package crypto
 
 import (
+	"bytes"
 	"crypto/rand"
 	"io"
 	"testing"
@@ -58,3 +59,139 @@ func TestDecryptInvalidiData(t *testing.T) {
 	require.Error(t, err, "Decrypting data should have failed")
 	require.Nil(t, decryptedData, "Decrypted data should be nil on error")
 }
+
+func encryptStream(t *testing.T, data []byte, passphrase string, chunkSize int) []byte {
+	var buf bytes.Buffer
+	writer, err := NewEncryptWriterSize(&buf, passphrase, chunkSize)
+	require.NoError(t, err, "Error creating encrypt writer")
+	_, err = writer.Write(data)
+	require.NoError(t, err, "Error writing to encrypt writer")
+	require.NoError(t, writer.Close(), "Error closing encrypt writer")
+	return buf.Bytes()
+}
+
+func TestStreamEncryptDecrypt(t *testing.T) {
+	passphrase := "testkey"
+	for _, size := range []int{0, 1, 1023, 1024, 1025, 4096, 10000} {
+		originalData := make([]byte, size)
+		_, err := io.ReadFull(rand.Reader, originalData)
+		require.NoError(t, err, "Error generating test data")
+
+		encryptedData := encryptStream(t, originalData, passphrase, 1024)
+		require.True(t, IsStreamEncrypted(encryptedData), "Encrypted data should have stream header")
+
+		reader, err := NewDecryptReader(bytes.NewReader(encryptedData), passphrase)
+		require.NoError(t, err, "Error creating decrypt reader")
+		decryptedData, err := io.ReadAll(reader)
+		require.NoError(t, err, "Error decrypting data of size %v", size)
+		require.Equal(t, originalData, decryptedData, "Original and descrypted data mismatch for size %v", size)
+	}
+}
+
+func TestStreamDecryptLegacyData(t *testing.T) {
+	passphrase := "testkey"
+	originalData := make([]byte, 128)
+	_, err := io.ReadFull(rand.Reader, originalData)
+	require.NoError(t, err, "Error generating test data")
+
+	encryptedData, err := Encrypt(originalData, passphrase)
+	require.NoError(t, err, "Error encrypting data")
+
+	reader, err := NewDecryptReader(bytes.NewReader(encryptedData), passphrase)
+	require.NoError(t, err, "Error decrypting legacy data")
+	decryptedData, err := io.ReadAll(reader)
+	require.NoError(t, err, "Error reading legacy data")
+	require.Equal(t, originalData, decryptedData, "Original and descrypted data mismatch")
+}
+
+func TestStreamDecryptInvalidKey(t *testing.T) {
+	originalData := make([]byte, 4096)
+	_, err := io.ReadFull(rand.Reader, originalData)
+	require.NoError(t, err, "Error generating test data")
+
+	encryptedData := encryptStream(t, originalData, "testkey", 1024)
+	reader, err := NewDecryptReader(bytes.NewReader(encryptedData), "invalidKey")
+	require.NoError(t, err, "Error creating decrypt reader")
+	_, err = io.ReadAll(reader)
+	require.Error(t, err, "Decrypting data should have failed")
+}
+
+func TestStreamDecryptTruncatedData(t *testing.T) {
+	passphrase := "testkey"
+	originalData := make([]byte, 4096)
+	_, err := io.ReadFull(rand.Reader, originalData)
+	require.NoError(t, err, "Error generating test data")
+
+	encryptedData := encryptStream(t, originalData, passphrase, 1024)
+	// Drop the last chunk, the previous one isn't marked as last so it
+	// should fail to decrypt
+	chunkLen := 1024 + 16
+	truncatedData := encryptedData[:len(encryptedData)-chunkLen]
+	reader, err := NewDecryptReader(bytes.NewReader(truncatedData), passphrase)
+	require.NoError(t, err, "Error creating decrypt reader")
+	_, err = io.ReadAll(reader)
+	require.Error(t, err, "Decrypting truncated data should have failed")
+
+	truncatedData = encryptedData[:len(encryptedData)-1]
+	reader, err = NewDecryptReader(bytes.NewReader(truncatedData), passphrase)
+	require.NoError(t, err, "Error creating decrypt reader")
+	_, err = io.ReadAll(reader)
+	require.Error(t, err, "Decrypting truncated data should have failed")
+}
+
+func TestKeyringDecrypt(t *testing.T) {
+	originalData := make([]byte, 100000)
+	_, err := io.ReadFull(rand.Reader, originalData)
+	require.NoError(t, err, "Error generating test data")
+	keyring := &Keyring{
+		Default: "defaultkey",
+		Keys: map[string]string{
+			"key1": "oldkey",
+			"key2": "newkey",
+		},
+	}
+
+	// Stream with a key ID
+	var buf bytes.Buffer
+	writer, err := NewKeyEncryptWriter(&buf, "key2", "newkey")
+	require.NoError(t, err, "Error creating encrypt writer")
+	_, err = writer.Write(originalData)
+	require.NoError(t, err, "Error writing to encrypt writer")
+	require.NoError(t, writer.Close(), "Error closing encrypt writer")
+	keyID, err := StreamKeyID(buf.Bytes())
+	require.NoError(t, err, "Error getting key ID")
+	require.Equal(t, "key2", keyID)
+	reader, err := NewKeyringDecryptReader(bytes.NewReader(buf.Bytes()), keyring)
+	require.NoError(t, err, "Error creating decrypt reader")
+	decryptedData, err := io.ReadAll(reader)
+	require.NoError(t, err, "Error decrypting stream with key ID")
+	require.Equal(t, originalData, decryptedData, "Decrypted data doesn't match original")
+
+	// Stream without a key ID encrypted with a key from the keyring
+	encryptedData := encryptStream(t, originalData, "oldkey", 1024)
+	keyID, err = StreamKeyID(encryptedData)
+	require.NoError(t, err, "Error getting key ID")
+	require.Empty(t, keyID)
+	reader, err = NewKeyringDecryptReader(bytes.NewReader(encryptedData), keyring)
+	require.NoError(t, err, "Error creating decrypt reader")
+	decryptedData, err = io.ReadAll(reader)
+	require.NoError(t, err, "Error decrypting stream without key ID")
+	require.Equal(t, originalData, decryptedData, "Decrypted data doesn't match original")
+
+	// Single blob format
+	encryptedData, err = Encrypt(originalData, "oldkey")
+	require.NoError(t, err, "Error encrypting data")
+	decryptedData, err = DecryptWithKeyring(encryptedData, keyring)
+	require.NoError(t, err, "Error decrypting data")
+	require.Equal(t, originalData, decryptedData, "Decrypted data doesn't match original")
+
+	// Key not in the keyring
+	buf.Reset()
+	writer, err = NewKeyEncryptWriter(&buf, "key3", "otherkey")
+	require.NoError(t, err, "Error creating encrypt writer")
+	require.NoError(t, writer.Close(), "Error closing encrypt writer")
+	_, err = NewKeyringDecryptReader(bytes.NewReader(buf.Bytes()), keyring)
+	require.Error(t, err, "Expected error for unknown key ID")
+	_, err = DecryptWithKeyring(encryptedData, &Keyring{Keys: map[string]string{"key3": "otherkey"}})
+	require.Error(t, err, "Expected error when no key matches")
+}
@@ -0,0 +1,68 @@
+package crypto
+
+import (
+	"fmt"
+	"sort"
+)
+
+// Keyring holds the passphrases that can be used to decrypt data
+type Keyring struct {
+	// Default is the passphrase used before keyrings were introduced. Data
+	// that doesn't record the ID of its key is tried with it first.
+	Default string
+	// Keys maps key IDs to their passphrases
+	Keys map[string]string
+}
+
+// IsEmpty returns true if the keyring doesn't have any passphrase
+func (k *Keyring) IsEmpty() bool {
+	return k == nil || (k.Default == "" && len(k.Keys) == 0)
+}
+
+// passphrases returns the passphrases to try for data encrypted with the key
+// keyID. Data without a key ID could have been encrypted with any key.
+func (k *Keyring) passphrases(keyID string) ([]string, error) {
+	if k.IsEmpty() {
+		return nil, fmt.Errorf("no encryption key provided")
+	}
+	if keyID != "" {
+		passphrase, ok := k.Keys[keyID]
+		if !ok {
+			return nil, fmt.Errorf("encryption key %v not found in keyring", keyID)
+		}
+		return []string{passphrase}, nil
+	}
+
+	passphrases := make([]string, 0, len(k.Keys)+1)
+	if k.Default != "" {
+		passphrases = append(passphrases, k.Default)
+	}
+	ids := make([]string, 0, len(k.Keys))
+	for id := range k.Keys {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	for _, id := range ids {
+		if k.Keys[id] != k.Default {
+			passphrases = append(passphrases, k.Keys[id])
+		}
+	}
+	return passphrases, nil
+}
+
+// DecryptWithKeyring decrypts data in the single blob format written by
+// Encrypt, trying every passphrase in the keyring
+func DecryptWithKeyring(data []byte, keyring *Keyring) ([]byte, error) {
+	passphrases, err := keyring.passphrases("")
+	if err != nil {
+		return nil, err
+	}
+	for _, passphrase := range passphrases {
+		var decryptData []byte
+		decryptData, err = Decrypt(data, passphrase)
+		if err == nil {
+			return decryptData, nil
+		}
+	}
+	return nil, err
+}
@@ -0,0 +1,371 @@
+package crypto
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+// The stream format splits the plaintext into fixed size chunks and seals
+// each one with AES-GCM. Every encrypted stream starts with a header:
+//
+//	magic (8 bytes) | version (1 byte) | chunk size (4 bytes) | nonce prefix (7 bytes)
+//
+// Version 2 adds the ID of the key used to encrypt the stream after the
+// chunk size:
+//
+//	magic | version | chunk size | key ID length (1 byte) | key ID | nonce prefix
+//
+// The nonce of a chunk is the nonce prefix followed by the chunk counter
+// (4 bytes) and a flag byte that is set only for the last chunk, so
+// reordering, dropping or truncating chunks fails authentication. The header
+// is passed as additional data to every chunk.
+const (
+	// StreamVersion1 is the first version of the chunked stream format
+	StreamVersion1 byte = 1
+	// StreamVersion2 records the ID of the encryption key in the header
+	StreamVersion2 byte = 2
+	// DefaultChunkSize is the plaintext size of each chunk in the stream
+	DefaultChunkSize = 64 * 1024
+	// MaxChunkSize is the largest chunk size accepted when decrypting
+	MaxChunkSize = 16 * 1024 * 1024
+	// MaxKeyIDLen is the longest key ID that can be recorded in a stream
+	MaxKeyIDLen = 255
+
+	// StreamHeaderLen is the size of the header at the start of a version 1
+	// stream, it is enough to check if data is in the stream format
+	StreamHeaderLen = streamHeaderPrefixLen + streamNoncePrefixLen
+	// MaxStreamHeaderLen is the size of the largest possible header
+	MaxStreamHeaderLen = StreamHeaderLen + 1 + MaxKeyIDLen
+
+	streamMagic           = "STORKENC"
+	streamHeaderPrefixLen = len(streamMagic) + 1 + 4
+	streamNoncePrefixLen  = 7
+	streamLastChunk       = 1
+)
+
+type streamHeader struct {
+	version     byte
+	chunkSize   uint32
+	keyID       string
+	noncePrefix [streamNoncePrefixLen]byte
+}
+
+func (h *streamHeader) marshal() []byte {
+	buf := make([]byte, 0, MaxStreamHeaderLen)
+	buf = append(buf, streamMagic...)
+	buf = append(buf, h.version)
+	buf = binary.BigEndian.AppendUint32(buf, h.chunkSize)
+	if h.version >= StreamVersion2 {
+		buf = append(buf, byte(len(h.keyID)))
+		buf = append(buf, h.keyID...)
+	}
+	return append(buf, h.noncePrefix[:]...)
+}
+
+// readStreamHeader reads the header at the start of r. It also returns the
+// raw header which is used as the additional data of every chunk.
+func readStreamHeader(r io.Reader) (*streamHeader, []byte, error) {
+	raw := make([]byte, streamHeaderPrefixLen, MaxStreamHeaderLen)
+	if _, err := io.ReadFull(r, raw); err != nil {
+		return nil, nil, fmt.Errorf("error reading encrypted stream header: %v", err)
+	}
+	if !IsStreamEncrypted(raw) {
+		return nil, nil, fmt.Errorf("invalid header for encrypted stream")
+	}
+	h := &streamHeader{}
+	offset := len(streamMagic)
+	h.version = raw[offset]
+	offset++
+	if h.version != StreamVersion1 && h.version != StreamVersion2 {
+		return nil, nil, fmt.Errorf("unsupported encrypted stream version %v", h.version)
+	}
+	h.chunkSize = binary.BigEndian.Uint32(raw[offset:])
+	if h.chunkSize == 0 || h.chunkSize > MaxChunkSize {
+		return nil, nil, fmt.Errorf("invalid chunk size %v in encrypted stream header", h.chunkSize)
+	}
+	if h.version >= StreamVersion2 {
+		raw = raw[:len(raw)+1]
+		if _, err := io.ReadFull(r, raw[len(raw)-1:]); err != nil {
+			return nil, nil, fmt.Errorf("error reading encrypted stream header: %v", err)
+		}
+		keyIDLen := int(raw[len(raw)-1])
+		raw = raw[:len(raw)+keyIDLen]
+		if _, err := io.ReadFull(r, raw[len(raw)-keyIDLen:]); err != nil {
+			return nil, nil, fmt.Errorf("error reading encrypted stream header: %v", err)
+		}
+		h.keyID = string(raw[len(raw)-keyIDLen:])
+	}
+	raw = raw[:len(raw)+streamNoncePrefixLen]
+	if _, err := io.ReadFull(r, raw[len(raw)-streamNoncePrefixLen:]); err != nil {
+		return nil, nil, fmt.Errorf("error reading encrypted stream header: %v", err)
+	}
+	copy(h.noncePrefix[:], raw[len(raw)-streamNoncePrefixLen:])
+	return h, raw, nil
+}
+
+func (h *streamHeader) nonce(gcm cipher.AEAD, counter uint32, last bool) []byte {
+	nonce := make([]byte, 0, gcm.NonceSize())
+	nonce = append(nonce, h.noncePrefix[:]...)
+	nonce = binary.BigEndian.AppendUint32(nonce, counter)
+	if last {
+		return append(nonce, streamLastChunk)
+	}
+	return append(nonce, 0)
+}
+
+// IsStreamEncrypted returns true if data starts with the header of the
+// chunked stream format
+func IsStreamEncrypted(data []byte) bool {
+	return len(data) >= len(streamMagic) && string(data[:len(streamMagic)]) == streamMagic
+}
+
+// StreamKeyID returns the ID of the key recorded in the header at the start
+// of data. It is empty for streams encrypted without a key ID.
+func StreamKeyID(data []byte) (string, error) {
+	h, _, err := readStreamHeader(bytes.NewReader(data))
+	if err != nil {
+		return "", err
+	}
+	return h.keyID, nil
+}
+
+type encryptWriter struct {
+	w       io.Writer
+	gcm     cipher.AEAD
+	header  *streamHeader
+	aad     []byte
+	buf     []byte
+	counter uint32
+	closed  bool
+}
+
+// NewEncryptWriter returns a writer that encrypts everything written to it
+// with the passphrase and writes it to w using the chunked stream format.
+// Close must be called to flush the last chunk, it does not close w.
+func NewEncryptWriter(w io.Writer, passphrase string) (io.WriteCloser, error) {
+	return NewEncryptWriterSize(w, passphrase, DefaultChunkSize)
+}
+
+// NewEncryptWriterSize is like NewEncryptWriter but uses the given chunk size
+func NewEncryptWriterSize(w io.Writer, passphrase string, chunkSize int) (io.WriteCloser, error) {
+	return newEncryptWriter(w, "", passphrase, chunkSize)
+}
+
+// NewKeyEncryptWriter is like NewEncryptWriter but also records the ID of the
+// key in the header so that the right key can be picked from a keyring when
+// decrypting
+func NewKeyEncryptWriter(w io.Writer, keyID string, passphrase string) (io.WriteCloser, error) {
+	return newEncryptWriter(w, keyID, passphrase, DefaultChunkSize)
+}
+
+func newEncryptWriter(w io.Writer, keyID string, passphrase string, chunkSize int) (io.WriteCloser, error) {
+	if chunkSize <= 0 || chunkSize > MaxChunkSize {
+		return nil, fmt.Errorf("invalid chunk size %v for encrypted stream", chunkSize)
+	}
+	if len(keyID) > MaxKeyIDLen {
+		return nil, fmt.Errorf("encryption key ID %v is longer than %v bytes", keyID, MaxKeyIDLen)
+	}
+	gcm, err := getCipher(passphrase)
+	if err != nil {
+		return nil, err
+	}
+	header := &streamHeader{
+		version:   StreamVersion1,
+		chunkSize: uint32(chunkSize),
+		keyID:     keyID,
+	}
+	if keyID != "" {
+		header.version = StreamVersion2
+	}
+	if _, err := io.ReadFull(rand.Reader, header.noncePrefix[:]); err != nil {
+		return nil, fmt.Errorf("error generating nonce for encryption: %v", err)
+	}
+	aad := header.marshal()
+	if _, err := w.Write(aad); err != nil {
+		return nil, err
+	}
+	return &encryptWriter{
+		w:      w,
+		gcm:    gcm,
+		header: header,
+		aad:    aad,
+		buf:    make([]byte, 0, chunkSize),
+	}, nil
+}
+
+func (e *encryptWriter) Write(p []byte) (int, error) {
+	if e.closed {
+		return 0, fmt.Errorf("write to closed encrypted stream")
+	}
+	written := 0
+	for len(p) > 0 {
+		// Only seal a full chunk once more data arrives, the last chunk has
+		// to be sealed with the last chunk flag in Close
+		if len(e.buf) == cap(e.buf) {
+			if err := e.seal(false); err != nil {
+				return written, err
+			}
+		}
+		n := copy(e.buf[len(e.buf):cap(e.buf)], p)
+		e.buf = e.buf[:len(e.buf)+n]
+		p = p[n:]
+		written += n
+	}
+	return written, nil
+}
+
+func (e *encryptWriter) seal(last bool) error {
+	if e.counter == ^uint32(0) {
+		return fmt.Errorf("encrypted stream is too large")
+	}
+	sealed := e.gcm.Seal(nil, e.header.nonce(e.gcm, e.counter, last), e.buf, e.aad)
+	if _, err := e.w.Write(sealed); err != nil {
+		return err
+	}
+	e.counter++
+	e.buf = e.buf[:0]
+	return nil
+}
+
+// Close seals and writes the last chunk
+func (e *encryptWriter) Close() error {
+	if e.closed {
+		return nil
+	}
+	e.closed = true
+	return e.seal(true)
+}
+
+type decryptReader struct {
+	r *bufio.Reader
+	// gcms are the ciphers of the candidate keys, the one that opens the
+	// first chunk is used for the rest of the stream
+	gcms    []cipher.AEAD
+	header  *streamHeader
+	aad     []byte
+	chunk   []byte
+	buf     []byte
+	plain   []byte
+	counter uint32
+	done    bool
+}
+
+// NewDecryptReader returns a reader that decrypts the data read from r with
+// the passphrase. Data in the chunked stream format is decrypted one chunk at
+// a time. Anything else is treated as the single blob format written by
+// Encrypt and is read completely before being decrypted.
+func NewDecryptReader(r io.Reader, passphrase string) (io.Reader, error) {
+	return NewKeyringDecryptReader(r, &Keyring{Default: passphrase})
+}
+
+// NewKeyringDecryptReader is like NewDecryptReader but picks the passphrase
+// from the keyring
+func NewKeyringDecryptReader(r io.Reader, keyring *Keyring) (io.Reader, error) {
+	br := bufio.NewReader(r)
+	magic, err := br.Peek(len(streamMagic))
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	if !IsStreamEncrypted(magic) {
+		data, err := io.ReadAll(br)
+		if err != nil {
+			return nil, err
+		}
+		decryptData, err := DecryptWithKeyring(data, keyring)
+		if err != nil {
+			return nil, err
+		}
+		return bytes.NewReader(decryptData), nil
+	}
+	return NewKeyringStreamDecryptReader(br, keyring)
+}
+
+// NewStreamDecryptReader returns a reader that decrypts data which must be in
+// the chunked stream format
+func NewStreamDecryptReader(r io.Reader, passphrase string) (io.Reader, error) {
+	return NewKeyringStreamDecryptReader(r, &Keyring{Default: passphrase})
+}
+
+// NewKeyringStreamDecryptReader is like NewStreamDecryptReader but picks the
+// passphrase from the keyring. Streams with a key ID are decrypted with that
+// key, for older streams every key in the keyring is tried.
+func NewKeyringStreamDecryptReader(r io.Reader, keyring *Keyring) (io.Reader, error) {
+	header, aad, err := readStreamHeader(r)
+	if err != nil {
+		return nil, err
+	}
+	passphrases, err := keyring.passphrases(header.keyID)
+	if err != nil {
+		return nil, err
+	}
+	gcms := make([]cipher.AEAD, 0, len(passphrases))
+	for _, passphrase := range passphrases {
+		gcm, err := getCipher(passphrase)
+		if err != nil {
+			return nil, err
+		}
+		gcms = append(gcms, gcm)
+	}
+	br, ok := r.(*bufio.Reader)
+	if !ok {
+		br = bufio.NewReader(r)
+	}
+	return &decryptReader{
+		r:      br,
+		gcms:   gcms,
+		header: header,
+		aad:    aad,
+		chunk:  make([]byte, int(header.chunkSize)+gcms[0].Overhead()),
+		buf:    make([]byte, 0, header.chunkSize),
+	}, nil
+}
+
+func (d *decryptReader) Read(p []byte) (int, error) {
+	for len(d.plain) == 0 {
+		if d.done {
+			return 0, io.EOF
+		}
+		if err := d.open(); err != nil {
+			return 0, err
+		}
+	}
+	n := copy(p, d.plain)
+	d.plain = d.plain[n:]
+	return n, nil
+}
+
+func (d *decryptReader) open() error {
+	n, err := io.ReadFull(d.r, d.chunk)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return err
+	}
+	last := n < len(d.chunk)
+	if !last {
+		// A full chunk is the last one only if nothing follows it
+		if _, err := d.r.Peek(1); err == io.EOF {
+			last = true
+		} else if err != nil {
+			return err
+		}
+	}
+	for i, gcm := range d.gcms {
+		// Open into a separate buffer, a failed Open clears its output
+		// which would otherwise destroy the chunk for the next key
+		plain, openErr := gcm.Open(d.buf[:0], d.header.nonce(gcm, d.counter, last), d.chunk[:n], d.aad)
+		if openErr == nil {
+			// Stick to the key that worked for the rest of the stream
+			d.gcms = d.gcms[i : i+1]
+			d.counter++
+			d.plain = plain
+			d.done = last
+			return nil
+		}
+		err = openErr
+	}
+	return fmt.Errorf("error decrypting chunk %v of encrypted stream: %v", d.counter, err)
+}
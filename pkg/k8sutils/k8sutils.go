@@ -238,6 +238,39 @@ func CreateCRDV1(resource apiextensions.CustomResource) error {
 	return nil
 }
 
+// CreateCRDV1WithStatus creates the given custom resource for
+// apiextensionsV1 with the status subresource enabled. The subresource is
+// added to the custom resource if it already exists without it.
+func CreateCRDV1WithStatus(resource apiextensions.CustomResource) error {
+	err := CreateCRDV1(resource)
+	if err != nil && !errors.IsAlreadyExists(err) {
+		return err
+	}
+	crdName := fmt.Sprintf("%s.%s", resource.Plural, resource.Group)
+	crd, err := apiextensions.Instance().GetCRD(crdName, metav1.GetOptions{})
+	if err != nil {
+		return err
+	}
+	updated := false
+	for i := range crd.Spec.Versions {
+		if crd.Spec.Versions[i].Name != resource.Version {
+			continue
+		}
+		if crd.Spec.Versions[i].Subresources == nil {
+			crd.Spec.Versions[i].Subresources = &apiextensionsv1.CustomResourceSubresources{}
+		}
+		if crd.Spec.Versions[i].Subresources.Status == nil {
+			crd.Spec.Versions[i].Subresources.Status = &apiextensionsv1.CustomResourceSubresourceStatus{}
+			updated = true
+		}
+	}
+	if !updated {
+		return nil
+	}
+	_, err = apiextensions.Instance().UpdateCRD(crd)
+	return err
+}
+
 // CreateCRDWithAdditionalPrinterColumns creates the given custom resource with customer resource column definition
 func CreateCRDWithAdditionalPrinterColumns(resource apiextensions.CustomResource, crColumnDefinition []apiextensionsv1.CustomResourceColumnDefinition) error {
 	scope := apiextensionsv1.NamespaceScoped
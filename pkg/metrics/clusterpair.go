@@ -5,6 +5,7 @@ import (
 
 	stork_api "github.com/libopenstorage/stork/pkg/apis/stork/v1alpha1"
 	"github.com/prometheus/client_golang/prometheus"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 )
 
@@ -18,6 +19,19 @@ var (
 		Name: "stork_clusterpair_storage_status",
 		Help: "Status of storage clusterpair",
 	}, []string{metricName, metricNamespace})
+	// clusterpairConditionCounter for the conditions from the clusterpair probe
+	clusterpairConditionCounter = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "stork_clusterpair_condition",
+		Help: "Condition of clusterpair from the last probe, 1 if true, 0 if false and -1 if unknown",
+	}, []string{metricName, metricNamespace, metricCondition})
+	clusterpairAPILatencyCounter = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "stork_clusterpair_api_latency_seconds",
+		Help: "Latency of the remote cluster API server from the last clusterpair probe",
+	}, []string{metricName, metricNamespace})
+	clusterpairCredentialsExpiryCounter = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "stork_clusterpair_credentials_expiry_timestamp_seconds",
+		Help: "Expiry time of the clusterpair credentials in unix seconds",
+	}, []string{metricName, metricNamespace})
 )
 
 var (
@@ -31,6 +45,12 @@ var (
 		stork_api.ClusterPairStatusDeleting:    5,
 		stork_api.ClusterPairStatusNotProvided: 6,
 	}
+	// clusterpairConditionStatus map of clusterpair condition status
+	clusterpairConditionStatus = map[metav1.ConditionStatus]float64{
+		metav1.ConditionTrue:    1,
+		metav1.ConditionFalse:   0,
+		metav1.ConditionUnknown: -1,
+	}
 )
 
 func watchclusterpairCR(object runtime.Object) error {
@@ -45,15 +65,39 @@ func watchclusterpairCR(object runtime.Object) error {
 	if clusterpair.DeletionTimestamp != nil {
 		clusterpairSchedStatusCounter.Delete(labels)
 		clusterpairStorageStatusCounter.Delete(labels)
+		clusterpairConditionCounter.DeletePartialMatch(labels)
+		clusterpairAPILatencyCounter.Delete(labels)
+		clusterpairCredentialsExpiryCounter.Delete(labels)
 		return nil
 	}
 	// Set clusterpair Status counter
 	clusterpairSchedStatusCounter.With(labels).Set(clusterpairStatus[clusterpair.Status.SchedulerStatus])
 	clusterpairStorageStatusCounter.With(labels).Set(clusterpairStatus[clusterpair.Status.StorageStatus])
+	for _, condition := range clusterpair.Status.Conditions {
+		conditionLabels := prometheus.Labels{
+			metricName:      clusterpair.Name,
+			metricNamespace: clusterpair.Namespace,
+			metricCondition: string(condition.Type),
+		}
+		clusterpairConditionCounter.With(conditionLabels).Set(clusterpairConditionStatus[condition.Status])
+	}
+	if clusterpair.Status.APILatency != nil {
+		clusterpairAPILatencyCounter.With(labels).Set(clusterpair.Status.APILatency.Seconds())
+	} else {
+		clusterpairAPILatencyCounter.Delete(labels)
+	}
+	if clusterpair.Status.CredentialsExpiry != nil {
+		clusterpairCredentialsExpiryCounter.With(labels).Set(float64(clusterpair.Status.CredentialsExpiry.Unix()))
+	} else {
+		clusterpairCredentialsExpiryCounter.Delete(labels)
+	}
 	return nil
 }
 
 func init() {
 	prometheus.MustRegister(clusterpairSchedStatusCounter)
 	prometheus.MustRegister(clusterpairStorageStatusCounter)
+	prometheus.MustRegister(clusterpairConditionCounter)
+	prometheus.MustRegister(clusterpairAPILatencyCounter)
+	prometheus.MustRegister(clusterpairCredentialsExpiryCounter)
 }
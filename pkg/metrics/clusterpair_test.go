@@ -12,6 +12,7 @@ import (
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/testutil"
 	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
 func createClusterPair(name, ns string, storage, sched storkv1.ClusterPairStatusType) (*storkv1.ClusterPair, error) {
@@ -59,3 +60,34 @@ func TestClusterPairFailureMetrics(t *testing.T) {
 	err = stork.Instance().DeleteClusterPair("test", "test-fail")
 	require.NoError(t, err)
 }
+
+func TestClusterPairConditionMetrics(t *testing.T) {
+	defer resetTest()
+	pair, err := createClusterPair("test", "test-probe", storkv1.ClusterPairStatusReady, storkv1.ClusterPairStatusReady)
+	require.NoError(t, err)
+	expiry := metav1.NewTime(time.Now().Add(24 * time.Hour))
+	pair.Status.APILatency = &metav1.Duration{Duration: 250 * time.Millisecond}
+	pair.Status.CredentialsExpiry = &expiry
+	pair.Status.Conditions = append(pair.Status.Conditions,
+		storkv1.Condition{Type: storkv1.ClusterPairConditionAPIReachable, Status: metav1.ConditionTrue},
+		storkv1.Condition{Type: storkv1.ClusterPairConditionAuthorized, Status: metav1.ConditionFalse},
+		storkv1.Condition{Type: storkv1.ClusterPairConditionStoragePaired, Status: metav1.ConditionUnknown},
+	)
+	_, err = stork.Instance().UpdateClusterPair(pair)
+	require.NoError(t, err)
+	time.Sleep(3 * time.Second)
+
+	conditionLabels := func(condition storkv1.ConditionType) prometheus.Labels {
+		return prometheus.Labels{metricName: "test", metricNamespace: "test-probe", metricCondition: string(condition)}
+	}
+	require.Equal(t, float64(1), testutil.ToFloat64(clusterpairConditionCounter.With(conditionLabels(storkv1.ClusterPairConditionAPIReachable))), "clusterpair_condition does not matched")
+	require.Equal(t, float64(0), testutil.ToFloat64(clusterpairConditionCounter.With(conditionLabels(storkv1.ClusterPairConditionAuthorized))), "clusterpair_condition does not matched")
+	require.Equal(t, float64(-1), testutil.ToFloat64(clusterpairConditionCounter.With(conditionLabels(storkv1.ClusterPairConditionStoragePaired))), "clusterpair_condition does not matched")
+
+	labels := prometheus.Labels{metricName: "test", metricNamespace: "test-probe"}
+	require.Equal(t, 0.25, testutil.ToFloat64(clusterpairAPILatencyCounter.With(labels)), "clusterpair_api_latency does not matched")
+	require.Equal(t, float64(expiry.Unix()), testutil.ToFloat64(clusterpairCredentialsExpiryCounter.With(labels)), "clusterpair_credentials_expiry does not matched")
+
+	err = stork.Instance().DeleteClusterPair("test", "test-probe")
+	require.NoError(t, err)
+}
@@ -22,6 +22,8 @@ const (
 	metricSchedule = "schedule"
 	// metricPolicy for stork prometheus metrics
 	metricPolicy = "policy"
+	// metricCondition for stork prometheus metrics
+	metricCondition = "condition"
 	// waitInterval to wait for crd registration
 	waitInterval = 5 * time.Second
 )
@@ -3,6 +3,7 @@ package controllers
 import (
 	"context"
 	"fmt"
+	"os"
 	"reflect"
 	"strings"
 	"time"
@@ -48,18 +49,24 @@ func NewClusterPair(mgr manager.Manager, d volume.Driver, r record.EventRecorder
 type ClusterPairController struct {
 	client runtimeclient.Client
 
-	volDriver volume.Driver
-	recorder  record.EventRecorder
+	volDriver   volume.Driver
+	recorder    record.EventRecorder
+	stopChannel chan os.Signal
 }
 
 // Init initialize the cluster pair controller
-func (c *ClusterPairController) Init(mgr manager.Manager) error {
+func (c *ClusterPairController) Init(mgr manager.Manager, stopChannel chan os.Signal) error {
+	c.stopChannel = stopChannel
 	err := c.createCRD()
 	if err != nil {
 		return err
 	}
 
-	return controllers.RegisterTo(mgr, "cluster-pair-controller", c, &stork_api.ClusterPair{})
+	if err := controllers.RegisterTo(mgr, "cluster-pair-controller", c, &stork_api.ClusterPair{}); err != nil {
+		return err
+	}
+	go c.startProbing()
+	return nil
 }
 
 // Reconcile manages ClusterPair resources.
@@ -152,7 +159,7 @@ func (c *ClusterPairController) handle(ctx context.Context, clusterPair *stork_a
 	}
 	if clusterPair.Status.SchedulerStatus != stork_api.ClusterPairStatusReady {
 		clusterPair.Status.SchedulerStatus = stork_api.ClusterPairStatusError
-		remoteConfig, err := getClusterPairSchedulerConfig(clusterPair.Name, clusterPair.Namespace)
+		remoteConfig, err := GetClusterPairSchedulerConfig(clusterPair.Name, clusterPair.Namespace)
 		if err != nil {
 			return err
 		}
@@ -183,11 +190,24 @@ func (c *ClusterPairController) handle(ctx context.Context, clusterPair *stork_a
 	return nil
 }
 
-func getClusterPairSchedulerConfig(clusterPairName string, namespace string) (*restclient.Config, error) {
+// GetClusterPairSchedulerConfig returns the config to access the remote
+// cluster of the ClusterPair
+func GetClusterPairSchedulerConfig(clusterPairName string, namespace string) (*restclient.Config, error) {
 	clusterPair, err := storkops.Instance().GetClusterPair(clusterPairName, namespace)
 	if err != nil {
 		return nil, fmt.Errorf("error getting clusterpair (%v/%v): %v", namespace, clusterPairName, err)
 	}
+	config, err := getClusterPairConfig(clusterPair)
+	if err != nil {
+		return nil, err
+	}
+	if rateLimiter := getRemoteRateLimiter(clusterPair); rateLimiter != nil {
+		config.RateLimiter = rateLimiter
+	}
+	return config, nil
+}
+
+func getClusterPairConfig(clusterPair *stork_api.ClusterPair) (*restclient.Config, error) {
 	remoteClientConfig := clientcmd.NewNonInteractiveClientConfig(
 		clusterPair.Spec.Config,
 		clusterPair.Spec.Config.CurrentContext,
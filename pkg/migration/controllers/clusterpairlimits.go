@@ -0,0 +1,203 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	stork_api "github.com/libopenstorage/stork/pkg/apis/stork/v1alpha1"
+	"github.com/libopenstorage/stork/pkg/conditions"
+	"github.com/libopenstorage/stork/pkg/errors"
+	"github.com/libopenstorage/stork/pkg/log"
+	storkops "github.com/portworx/sched-ops/k8s/stork"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/util/flowcontrol"
+)
+
+// remoteRateLimiter is shared by the clients of all the migrations using a
+// cluster pair so that the QPS limit applies to all of them
+type remoteRateLimiter struct {
+	qps     int32
+	burst   int32
+	limiter flowcontrol.RateLimiter
+}
+
+var (
+	remoteRateLimiters     = make(map[string]*remoteRateLimiter)
+	remoteRateLimitersLock sync.Mutex
+	// migrationAdmissionLocks has a lock for each cluster pair, keyed by its
+	// namespace and name
+	migrationAdmissionLocks sync.Map
+)
+
+// getRemoteRateLimiter returns the rate limiter for the requests sent to the
+// remote cluster of the cluster pair, or nil if they aren't limited
+func getRemoteRateLimiter(clusterPair *stork_api.ClusterPair) flowcontrol.RateLimiter {
+	key := clusterPair.Namespace + "/" + clusterPair.Name
+	remoteRateLimitersLock.Lock()
+	defer remoteRateLimitersLock.Unlock()
+	limits := clusterPair.Spec.Limits
+	if limits == nil || limits.RemoteAPIQPS <= 0 {
+		delete(remoteRateLimiters, key)
+		return nil
+	}
+	burst := limits.RemoteAPIBurst
+	if burst <= 0 {
+		burst = limits.RemoteAPIQPS
+	}
+	// Keep the limiter, and the requests it has already accounted for, as
+	// long as the limits don't change
+	if rateLimiter, ok := remoteRateLimiters[key]; ok && rateLimiter.qps == limits.RemoteAPIQPS && rateLimiter.burst == burst {
+		return rateLimiter.limiter
+	}
+	rateLimiter := &remoteRateLimiter{
+		qps:     limits.RemoteAPIQPS,
+		burst:   burst,
+		limiter: flowcontrol.NewTokenBucketRateLimiter(float32(limits.RemoteAPIQPS), int(burst)),
+	}
+	remoteRateLimiters[key] = rateLimiter
+	return rateLimiter.limiter
+}
+
+// getMigrationAdmissionLock returns the lock used to admit the migrations
+// using a cluster pair one at a time
+func getMigrationAdmissionLock(migration *stork_api.Migration) *sync.Mutex {
+	lock, _ := migrationAdmissionLocks.LoadOrStore(migration.Namespace+"/"+migration.Spec.ClusterPair, &sync.Mutex{})
+	return lock.(*sync.Mutex)
+}
+
+// queueMigration moves the migration to the Pending stage if the cluster pair
+// already has the maximum number of migrations running, and out of it once
+// the migrations that were queued before it have started. The migrations that
+// are started are marked as admitted before any of their work is done so that
+// they are counted even while they are still in the Initial stage. Returns
+// true if the migration needs to keep waiting.
+func (m *MigrationController) queueMigration(ctx context.Context, migration *stork_api.Migration) (bool, error) {
+	if _, ok := migration.Annotations[StorkMigrationAdmittedAnnotation]; ok {
+		return false, nil
+	}
+	clusterPair, err := storkops.Instance().GetClusterPair(migration.Spec.ClusterPair, migration.Namespace)
+	if err != nil {
+		// The error is reported when the migration is started
+		return false, nil
+	}
+	maxMigrations := int32(0)
+	if clusterPair.Spec.Limits != nil {
+		maxMigrations = clusterPair.Spec.Limits.MaxConcurrentMigrations
+	}
+	if maxMigrations <= 0 {
+		if migration.Status.Stage == stork_api.MigrationStagePending {
+			log.MigrationLog(migration).Infof("Starting queued migration")
+			migration.Status.Stage = stork_api.MigrationStageInitial
+			migration.Status.Status = stork_api.MigrationStatusInitial
+		}
+		return false, nil
+	}
+
+	// The admitted migrations need to be counted by the next migration using
+	// the cluster pair, so they are admitted one at a time
+	lock := getMigrationAdmissionLock(migration)
+	lock.Lock()
+	defer lock.Unlock()
+	migrations, err := storkops.Instance().ListMigrations(migration.Namespace)
+	if err != nil {
+		return true, err
+	}
+	running := int32(0)
+	queued := int32(0)
+	for _, other := range migrations.Items {
+		// The migrations of the waves are counted instead of the
+		// migration that created them
+		if other.UID == migration.UID ||
+			other.Spec.ClusterPair != migration.Spec.ClusterPair ||
+			len(other.Spec.Waves) != 0 {
+			continue
+		}
+		_, admitted := other.Annotations[StorkMigrationAdmittedAnnotation]
+		switch other.Status.Stage {
+		case stork_api.MigrationStageFinal:
+		case stork_api.MigrationStageInitial:
+			if admitted {
+				running++
+			}
+		case stork_api.MigrationStagePending:
+			if other.CreationTimestamp.Before(&migration.CreationTimestamp) ||
+				(other.CreationTimestamp.Equal(&migration.CreationTimestamp) && other.Name < migration.Name) {
+				queued++
+			}
+		default:
+			running++
+		}
+	}
+	if running+queued >= maxMigrations {
+		if migration.Status.Stage == stork_api.MigrationStagePending {
+			return true, nil
+		}
+		message := fmt.Sprintf("Waiting for migrations using ClusterPair %v to finish, %v running and %v queued",
+			clusterPair.Name, running, queued)
+		log.MigrationLog(migration).Infof(message)
+		m.recorder.Event(migration,
+			v1.EventTypeNormal,
+			string(stork_api.MigrationStatusPending),
+			message)
+		migration.Status.Stage = stork_api.MigrationStagePending
+		migration.Status.Status = stork_api.MigrationStatusPending
+		return true, m.updateMigrationCR(ctx, migration)
+	}
+
+	if migration.Status.Stage == stork_api.MigrationStagePending {
+		log.MigrationLog(migration).Infof("Starting queued migration")
+		migration.Status.Stage = stork_api.MigrationStageInitial
+		migration.Status.Status = stork_api.MigrationStatusInitial
+	}
+	if migration.Annotations == nil {
+		migration.Annotations = make(map[string]string)
+	}
+	migration.Annotations[StorkMigrationAdmittedAnnotation] = "true"
+	if err := m.updateMigrationCR(ctx, migration); err != nil {
+		return true, err
+	}
+	return false, nil
+}
+
+// setVolumeBandwidthLimit applies the bandwidth limit of the cluster pair
+// before its volumes are migrated. Whether the storage driver supports it is
+// reported in the conditions of the cluster pair.
+func (m *MigrationController) setVolumeBandwidthLimit(migration *stork_api.Migration) error {
+	clusterPair, err := storkops.Instance().GetClusterPair(migration.Spec.ClusterPair, migration.Namespace)
+	if err != nil {
+		return err
+	}
+	if clusterPair.Spec.Limits == nil || clusterPair.Spec.Limits.VolumeBandwidthMbps == 0 {
+		return nil
+	}
+	limit := clusterPair.Spec.Limits.VolumeBandwidthMbps
+	condition := conditions.New(stork_api.ClusterPairConditionVolumeBandwidthLimited, metav1.ConditionTrue, "Applied",
+		fmt.Sprintf("Volume bandwidth is limited to %v Mbps", limit))
+	err = m.volDriver.SetMigrationBandwidthLimit(clusterPair, limit)
+	if err != nil {
+		if _, ok := err.(*errors.ErrNotSupported); !ok {
+			return err
+		}
+		condition = conditions.New(stork_api.ClusterPairConditionVolumeBandwidthLimited, metav1.ConditionFalse, "NotSupported",
+			fmt.Sprintf("Volume bandwidth limit isn't supported by the storage driver: %v", err))
+	}
+
+	previous := conditions.Get(clusterPair.Status.Conditions, condition.Type)
+	if previous != nil && previous.Status == condition.Status && previous.Message == condition.Message {
+		return nil
+	}
+	conditions.Set(&clusterPair.Status.Conditions, condition)
+	if _, err := storkops.Instance().UpdateClusterPair(clusterPair); err != nil {
+		// The condition is updated again by the next migration
+		log.MigrationLog(migration).Warnf("Error updating conditions of ClusterPair %v: %v", clusterPair.Name, err)
+	}
+	if condition.Status == metav1.ConditionFalse {
+		m.recorder.Event(clusterPair,
+			v1.EventTypeWarning,
+			condition.Reason,
+			condition.Message)
+	}
+	return nil
+}
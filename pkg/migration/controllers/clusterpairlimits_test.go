@@ -0,0 +1,261 @@
+//go:build unittest
+// +build unittest
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/libopenstorage/stork/drivers/volume"
+	stork_api "github.com/libopenstorage/stork/pkg/apis/stork/v1alpha1"
+	"github.com/libopenstorage/stork/pkg/conditions"
+	"github.com/libopenstorage/stork/pkg/errors"
+	storkops "github.com/portworx/sched-ops/k8s/stork"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	runtimeclient "sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// storkopsClient updates the migrations through storkops so that the
+// migrations listed by the controller see the updates, after a delay like
+// the API server would
+type storkopsClient struct {
+	runtimeclient.Client
+}
+
+func (c *storkopsClient) Update(ctx context.Context, obj runtimeclient.Object, opts ...runtimeclient.UpdateOption) error {
+	migration, ok := obj.(*stork_api.Migration)
+	if !ok {
+		return c.Client.Update(ctx, obj, opts...)
+	}
+	time.Sleep(10 * time.Millisecond)
+	updated, err := storkops.Instance().UpdateMigration(migration)
+	if err != nil {
+		return err
+	}
+	updated.DeepCopyInto(migration)
+	return nil
+}
+
+func newTestLimitsController(t *testing.T, maxMigrations int32) *MigrationController {
+	setupTestWaveClients()
+	_, err := storkops.Instance().CreateClusterPair(&stork_api.ClusterPair{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "clusterpair",
+			Namespace: "ns",
+		},
+		Spec: stork_api.ClusterPairSpec{
+			Limits: &stork_api.ClusterPairLimits{
+				MaxConcurrentMigrations: maxMigrations,
+			},
+		},
+	})
+	require.NoError(t, err)
+	m := newTestMigrationController(t)
+	m.client = &storkopsClient{Client: m.client}
+	return m
+}
+
+func createTestLimitsMigration(t *testing.T, name string, created time.Time, stage stork_api.MigrationStageType) *stork_api.Migration {
+	migration, err := storkops.Instance().CreateMigration(&stork_api.Migration{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:              name,
+			Namespace:         "ns",
+			UID:               types.UID(name),
+			CreationTimestamp: metav1.NewTime(created),
+		},
+		Spec: stork_api.MigrationSpec{
+			ClusterPair: "clusterpair",
+		},
+		Status: stork_api.MigrationStatus{
+			Stage: stage,
+		},
+	})
+	require.NoError(t, err)
+	return migration
+}
+
+func requireQueued(t *testing.T, m *MigrationController, name string, expected bool) {
+	migration, err := storkops.Instance().GetMigration(name, "ns")
+	require.NoError(t, err)
+	queued, err := m.queueMigration(context.TODO(), migration)
+	require.NoError(t, err)
+	require.Equal(t, expected, queued, "Unexpected queueing for migration %v", name)
+
+	migration, err = storkops.Instance().GetMigration(name, "ns")
+	require.NoError(t, err)
+	_, admitted := migration.Annotations[StorkMigrationAdmittedAnnotation]
+	require.Equal(t, !expected, admitted, "Unexpected admission for migration %v", name)
+	if expected {
+		require.Equal(t, stork_api.MigrationStagePending, migration.Status.Stage)
+	} else {
+		require.Equal(t, stork_api.MigrationStageInitial, migration.Status.Stage)
+	}
+}
+
+func TestQueueMigrationOrder(t *testing.T) {
+	m := newTestLimitsController(t, 1)
+	now := time.Now().Truncate(time.Second)
+	running := createTestLimitsMigration(t, "running", now.Add(-time.Hour), stork_api.MigrationStageVolumes)
+	createTestLimitsMigration(t, "first", now.Add(-time.Minute), stork_api.MigrationStageInitial)
+	createTestLimitsMigration(t, "second", now, stork_api.MigrationStageInitial)
+	createTestLimitsMigration(t, "third", now, stork_api.MigrationStageInitial)
+
+	requireQueued(t, m, "third", true)
+	requireQueued(t, m, "second", true)
+	requireQueued(t, m, "first", true)
+
+	running.Status.Stage = stork_api.MigrationStageFinal
+	_, err := storkops.Instance().UpdateMigration(running)
+	require.NoError(t, err)
+
+	// The migrations are started in the order they were created, by name if
+	// they were created at the same time
+	requireQueued(t, m, "third", true)
+	requireQueued(t, m, "second", true)
+	requireQueued(t, m, "first", false)
+	// The admitted migration is counted while it's still in the Initial stage
+	requireQueued(t, m, "second", true)
+	requireQueued(t, m, "first", false)
+}
+
+func TestQueueMigrationNotLimited(t *testing.T) {
+	m := newTestLimitsController(t, 0)
+	createTestLimitsMigration(t, "running", time.Now(), stork_api.MigrationStageVolumes)
+	migration := createTestLimitsMigration(t, "migration", time.Now(), stork_api.MigrationStageInitial)
+	queued, err := m.queueMigration(context.TODO(), migration)
+	require.NoError(t, err)
+	require.False(t, queued)
+}
+
+func TestQueueMigrationConcurrent(t *testing.T) {
+	maxMigrations := 2
+	m := newTestLimitsController(t, int32(maxMigrations))
+	migrations := make([]*stork_api.Migration, 0)
+	for i := 0; i < 10; i++ {
+		migrations = append(migrations,
+			createTestLimitsMigration(t, fmt.Sprintf("migration-%v", i), time.Now(), stork_api.MigrationStageInitial))
+	}
+
+	var wg sync.WaitGroup
+	for _, migration := range migrations {
+		wg.Add(1)
+		go func(migration *stork_api.Migration) {
+			defer wg.Done()
+			_, err := m.queueMigration(context.TODO(), migration)
+			require.NoError(t, err)
+		}(migration)
+	}
+	wg.Wait()
+
+	admitted := 0
+	list, err := storkops.Instance().ListMigrations("ns")
+	require.NoError(t, err)
+	for _, migration := range list.Items {
+		if _, ok := migration.Annotations[StorkMigrationAdmittedAnnotation]; ok {
+			admitted++
+		} else {
+			require.Equal(t, stork_api.MigrationStagePending, migration.Status.Stage)
+		}
+	}
+	require.Equal(t, maxMigrations, admitted)
+}
+
+// bandwidthTestDriver records the bandwidth limits set by the migrations
+type bandwidthTestDriver struct {
+	volume.Driver
+	limits []uint64
+	err    error
+}
+
+func (d *bandwidthTestDriver) SetMigrationBandwidthLimit(clusterPair *stork_api.ClusterPair, limit uint64) error {
+	d.limits = append(d.limits, limit)
+	return d.err
+}
+
+func TestSetVolumeBandwidthLimit(t *testing.T) {
+	tests := []struct {
+		name      string
+		limit     uint64
+		driverErr error
+		limits    []uint64
+		status    metav1.ConditionStatus
+		reason    string
+		expectErr bool
+	}{
+		{
+			name: "not limited",
+		},
+		{
+			name:   "applied",
+			limit:  100,
+			limits: []uint64{100, 100},
+			status: metav1.ConditionTrue,
+			reason: "Applied",
+		},
+		{
+			name:      "not supported",
+			limit:     100,
+			driverErr: &errors.ErrNotSupported{Feature: "Migration bandwidth limit"},
+			limits:    []uint64{100, 100},
+			status:    metav1.ConditionFalse,
+			reason:    "NotSupported",
+		},
+		{
+			name:      "driver error",
+			limit:     100,
+			driverErr: fmt.Errorf("driver error"),
+			limits:    []uint64{100, 100},
+			expectErr: true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			m := newTestLimitsController(t, 0)
+			clusterPair, err := storkops.Instance().GetClusterPair("clusterpair", "ns")
+			require.NoError(t, err)
+			clusterPair.Spec.Limits.VolumeBandwidthMbps = test.limit
+			_, err = storkops.Instance().UpdateClusterPair(clusterPair)
+			require.NoError(t, err)
+			driver := &bandwidthTestDriver{err: test.driverErr}
+			m.volDriver = driver
+			recorder := m.recorder.(*record.FakeRecorder)
+			migration := createTestLimitsMigration(t, "migration", time.Now(), stork_api.MigrationStageVolumes)
+
+			// The condition is only updated and the warning only raised
+			// when the result changes
+			for i := 0; i < 2; i++ {
+				err = m.setVolumeBandwidthLimit(migration)
+				if test.expectErr {
+					require.Error(t, err)
+				} else {
+					require.NoError(t, err)
+				}
+			}
+			require.Equal(t, test.limits, driver.limits)
+
+			clusterPair, err = storkops.Instance().GetClusterPair("clusterpair", "ns")
+			require.NoError(t, err)
+			condition := conditions.Get(clusterPair.Status.Conditions, stork_api.ClusterPairConditionVolumeBandwidthLimited)
+			if test.status == "" {
+				require.Nil(t, condition, "Condition shouldn't be set")
+				require.Empty(t, recorder.Events)
+				return
+			}
+			require.NotNil(t, condition, "Condition not set")
+			require.Equal(t, test.status, condition.Status)
+			require.Equal(t, test.reason, condition.Reason)
+			if test.status == metav1.ConditionFalse {
+				require.Len(t, recorder.Events, 1, "Warning should be raised once")
+			} else {
+				require.Empty(t, recorder.Events)
+			}
+		})
+	}
+}
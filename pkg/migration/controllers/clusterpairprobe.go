@@ -0,0 +1,338 @@
+package controllers
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"strings"
+	"time"
+
+	stork_api "github.com/libopenstorage/stork/pkg/apis/stork/v1alpha1"
+	"github.com/libopenstorage/stork/pkg/conditions"
+	"github.com/libopenstorage/stork/pkg/errors"
+	"github.com/sirupsen/logrus"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	k8s_errors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	clusterPairProbeInterval = 1 * time.Minute
+	// Timeout for each request made to the remote cluster by the probe
+	clusterPairProbeTimeout = 10 * time.Second
+	// The remote cluster is reported as slow if it takes longer than this
+	// to respond
+	clusterPairLatencyThreshold = 5 * time.Second
+	// Credentials are reported as expiring soon within this duration of
+	// their expiry
+	clusterPairCredentialsExpiryWarning = 7 * 24 * time.Hour
+
+	reasonProbeFailed = "ProbeFailed"
+	reasonHealthy     = "Healthy"
+	reasonNotChecked  = "NotChecked"
+	reasonUnreachable = "Unreachable"
+	reasonRejected    = "Rejected"
+)
+
+// accessCheck is an operation stork performs on the remote cluster.
+// Operations on namespaced resources are checked in the namespace of the
+// cluster pair.
+type accessCheck struct {
+	group      string
+	resource   string
+	verb       string
+	namespaced bool
+}
+
+// Operations on the remote cluster needed to migrate applications and to
+// failover, switchover and failback
+var clusterPairAccessChecks = []accessCheck{
+	{"", "namespaces", "create", false},
+	{"", "namespaces", "get", false},
+	{"", "persistentvolumes", "create", false},
+	{"", "persistentvolumeclaims", "create", true},
+	{"", "persistentvolumeclaims", "delete", true},
+	{"apps", "deployments", "update", true},
+	{"apps", "statefulsets", "update", true},
+	{stork_api.SchemeGroupVersion.Group, "migrationschedules", "create", true},
+	{stork_api.SchemeGroupVersion.Group, "actions", "create", true},
+}
+
+// startProbing probes the cluster pairs every clusterPairProbeInterval until
+// the stop channel is signalled
+func (c *ClusterPairController) startProbing() {
+	ticker := time.NewTicker(clusterPairProbeInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			c.probeClusterPairs()
+		case <-c.stopChannel:
+			return
+		}
+	}
+}
+
+func (c *ClusterPairController) probeClusterPairs() {
+	clusterPairs := &stork_api.ClusterPairList{}
+	if err := c.client.List(context.TODO(), clusterPairs); err != nil {
+		logrus.Errorf("Error getting cluster pairs to probe: %v", err)
+		return
+	}
+	for i := range clusterPairs.Items {
+		if clusterPairs.Items[i].DeletionTimestamp != nil {
+			continue
+		}
+		c.updateClusterPairHealth(&clusterPairs.Items[i])
+	}
+}
+
+func (c *ClusterPairController) updateClusterPairHealth(clusterPair *stork_api.ClusterPair) {
+	previous := clusterPair.Status.DeepCopy()
+	for _, condition := range c.probeClusterPair(clusterPair) {
+		conditions.Set(&clusterPair.Status.Conditions, condition)
+	}
+	clusterPair.Status.LastProbed = metav1.Now()
+
+	// Have the scheduler pairing checked again by the next reconcile if the
+	// remote cluster couldn't be reached or rejected the credentials. Issues
+	// found in the config itself are only reported in the conditions.
+	apiReachable := conditions.Get(clusterPair.Status.Conditions, stork_api.ClusterPairConditionAPIReachable)
+	credentials := conditions.Get(clusterPair.Status.Conditions, stork_api.ClusterPairConditionCredentials)
+	if (apiReachable != nil && apiReachable.Reason == reasonUnreachable) ||
+		(credentials != nil && credentials.Reason == reasonRejected) {
+		clusterPair.Status.SchedulerStatus = stork_api.ClusterPairStatusError
+	}
+
+	if err := c.client.Update(context.TODO(), clusterPair); err != nil {
+		// The cluster pair will be probed again at the next interval if it
+		// was updated in the meantime
+		if !k8s_errors.IsConflict(err) && !k8s_errors.IsNotFound(err) {
+			logrus.Errorf("%s/%s: Error updating cluster pair status: %v", clusterPair.Namespace, clusterPair.Name, err)
+		}
+		return
+	}
+
+	// Only raise events when the conditions that failed change
+	if message := conditions.RecordEvents(c.recorder, clusterPair, previous.Conditions, clusterPair.Status.Conditions, conditions.Events{
+		FailedReason:   reasonProbeFailed,
+		FailedMessage:  "Cluster pair probe failed",
+		HealthyReason:  reasonHealthy,
+		HealthyMessage: "Cluster pair probed successfully",
+	}); message != "" {
+		logrus.Warnf("%s/%s: %v", clusterPair.Namespace, clusterPair.Name, message)
+	}
+}
+
+// probeClusterPair checks the API server of the remote cluster, the access
+// granted by the credentials, their expiry and the pairing of the storage.
+// Checks that depend on a failed one are reported as unknown. The latency of
+// the API server and the expiry of the credentials are recorded in the status.
+func (c *ClusterPairController) probeClusterPair(clusterPair *stork_api.ClusterPair) []stork_api.Condition {
+	notChecked := func(conditionType stork_api.ConditionType, message string) stork_api.Condition {
+		return conditions.New(conditionType, metav1.ConditionUnknown, reasonNotChecked, message)
+	}
+
+	credentials := c.probeCredentials(clusterPair)
+	results := []stork_api.Condition{credentials, c.probeStorage(clusterPair)}
+
+	config, err := getClusterPairConfig(clusterPair)
+	var client kubernetes.Interface
+	if err == nil {
+		config.Timeout = clusterPairProbeTimeout
+		client, err = kubernetes.NewForConfig(config)
+	}
+	if err != nil {
+		message := "Config of the remote cluster is invalid"
+		return append(results,
+			conditions.New(stork_api.ClusterPairConditionAPIReachable, metav1.ConditionFalse, "InvalidConfig", err.Error()),
+			notChecked(stork_api.ClusterPairConditionLatency, message),
+			notChecked(stork_api.ClusterPairConditionAuthorized, message),
+		)
+	}
+
+	start := time.Now()
+	_, err = client.Discovery().ServerVersion()
+	latency := time.Since(start)
+	if err != nil {
+		clusterPair.Status.APILatency = nil
+		if k8s_errors.IsUnauthorized(err) || k8s_errors.IsForbidden(err) {
+			results[0] = conditions.New(stork_api.ClusterPairConditionCredentials, metav1.ConditionFalse,
+				reasonRejected, fmt.Sprintf("Credentials were rejected by the remote cluster: %v", err))
+		}
+		message := "API server of the remote cluster is not reachable"
+		return append(results,
+			conditions.New(stork_api.ClusterPairConditionAPIReachable, metav1.ConditionFalse, reasonUnreachable, err.Error()),
+			notChecked(stork_api.ClusterPairConditionLatency, message),
+			notChecked(stork_api.ClusterPairConditionAuthorized, message),
+		)
+	}
+	clusterPair.Status.APILatency = &metav1.Duration{Duration: latency}
+	results = append(results,
+		conditions.New(stork_api.ClusterPairConditionAPIReachable, metav1.ConditionTrue, "Reachable",
+			fmt.Sprintf("API server of the remote cluster is reachable at %v", config.Host)))
+	if latency > clusterPairLatencyThreshold {
+		results = append(results, conditions.New(stork_api.ClusterPairConditionLatency, metav1.ConditionFalse, "Slow",
+			fmt.Sprintf("API server of the remote cluster took %v to respond, more than %v", latency, clusterPairLatencyThreshold)))
+	} else {
+		results = append(results, conditions.New(stork_api.ClusterPairConditionLatency, metav1.ConditionTrue, "Acceptable",
+			fmt.Sprintf("API server of the remote cluster responded in %v", latency)))
+	}
+	return append(results, probeAccess(client, clusterPair.Namespace))
+}
+
+// probeAccess checks that the credentials of the cluster pair allow the
+// operations stork performs on the remote cluster
+func probeAccess(client kubernetes.Interface, namespace string) stork_api.Condition {
+	denied := make([]string, 0)
+	for _, check := range clusterPairAccessChecks {
+		review := &authorizationv1.SelfSubjectAccessReview{
+			Spec: authorizationv1.SelfSubjectAccessReviewSpec{
+				ResourceAttributes: &authorizationv1.ResourceAttributes{
+					Group:    check.group,
+					Resource: check.resource,
+					Verb:     check.verb,
+				},
+			},
+		}
+		if check.namespaced {
+			review.Spec.ResourceAttributes.Namespace = namespace
+		}
+		result, err := client.AuthorizationV1().SelfSubjectAccessReviews().Create(context.TODO(), review, metav1.CreateOptions{})
+		if err != nil {
+			return conditions.New(stork_api.ClusterPairConditionAuthorized, metav1.ConditionUnknown, "ReviewFailed",
+				fmt.Sprintf("Error reviewing access to the remote cluster: %v", err))
+		}
+		if !result.Status.Allowed {
+			resource := check.resource
+			if check.group != "" {
+				resource = check.resource + "." + check.group
+			}
+			denied = append(denied, check.verb+" "+resource)
+		}
+	}
+	if len(denied) != 0 {
+		return conditions.New(stork_api.ClusterPairConditionAuthorized, metav1.ConditionFalse, "Forbidden",
+			fmt.Sprintf("Operations not allowed on the remote cluster: %v", strings.Join(denied, ", ")))
+	}
+	return conditions.New(stork_api.ClusterPairConditionAuthorized, metav1.ConditionTrue, "Allowed",
+		"All operations needed on the remote cluster are allowed")
+}
+
+// probeCredentials checks the expiry of the client certificate or the token
+// in the config of the cluster pair
+func (c *ClusterPairController) probeCredentials(clusterPair *stork_api.ClusterPair) stork_api.Condition {
+	clusterPair.Status.CredentialsExpiry = nil
+	config := clusterPair.Spec.Config
+	authInfoName := ""
+	if kubeContext, ok := config.Contexts[config.CurrentContext]; ok && kubeContext != nil {
+		authInfoName = kubeContext.AuthInfo
+	}
+	authInfo, ok := config.AuthInfos[authInfoName]
+	if !ok || authInfo == nil {
+		return conditions.New(stork_api.ClusterPairConditionCredentials, metav1.ConditionFalse, "NotFound",
+			fmt.Sprintf("User for context %q not found in the config", config.CurrentContext))
+	}
+
+	var expiry *time.Time
+	var err error
+	switch {
+	case len(authInfo.ClientCertificateData) != 0:
+		expiry, err = getCertificateExpiry(authInfo.ClientCertificateData)
+	case authInfo.Token != "":
+		expiry, err = getTokenExpiry(authInfo.Token)
+	default:
+		return conditions.New(stork_api.ClusterPairConditionCredentials, metav1.ConditionUnknown, reasonNotChecked,
+			"Expiry can only be checked for client certificates and tokens in the config")
+	}
+	if err != nil {
+		return conditions.New(stork_api.ClusterPairConditionCredentials, metav1.ConditionFalse, "Invalid", err.Error())
+	}
+	if expiry == nil {
+		return conditions.New(stork_api.ClusterPairConditionCredentials, metav1.ConditionTrue, "NoExpiry",
+			"Credentials don't expire")
+	}
+
+	clusterPair.Status.CredentialsExpiry = &metav1.Time{Time: *expiry}
+	remaining := time.Until(*expiry)
+	if remaining <= 0 {
+		return conditions.New(stork_api.ClusterPairConditionCredentials, metav1.ConditionFalse, "Expired",
+			fmt.Sprintf("Credentials expired at %v", expiry.UTC().Format(time.RFC3339)))
+	}
+	if remaining < clusterPairCredentialsExpiryWarning {
+		return conditions.New(stork_api.ClusterPairConditionCredentials, metav1.ConditionTrue, "ExpiringSoon",
+			fmt.Sprintf("Credentials expire at %v", expiry.UTC().Format(time.RFC3339)))
+	}
+	return conditions.New(stork_api.ClusterPairConditionCredentials, metav1.ConditionTrue, "Valid",
+		fmt.Sprintf("Credentials expire at %v", expiry.UTC().Format(time.RFC3339)))
+}
+
+// getCertificateExpiry returns the expiry of the first certificate in the
+// PEM data
+func getCertificateExpiry(data []byte) (*time.Time, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("client certificate is not PEM encoded")
+	}
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing client certificate: %v", err)
+	}
+	return &cert.NotAfter, nil
+}
+
+// getTokenExpiry returns the expiry from the exp claim of a JWT token. nil is
+// returned for tokens that aren't JWTs or don't expire.
+func getTokenExpiry(token string) (*time.Time, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, nil
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(parts[1], "="))
+	if err != nil {
+		return nil, nil
+	}
+	claims := struct {
+		Exp *int64 `json:"exp"`
+	}{}
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return nil, fmt.Errorf("error parsing token claims: %v", err)
+	}
+	if claims.Exp == nil {
+		return nil, nil
+	}
+	expiry := time.Unix(*claims.Exp, 0)
+	return &expiry, nil
+}
+
+// probeStorage checks that the storage driver still has the pair with the
+// remote storage
+func (c *ClusterPairController) probeStorage(clusterPair *stork_api.ClusterPair) stork_api.Condition {
+	if _, ok := clusterPair.Spec.Options["token"]; !ok {
+		return conditions.New(stork_api.ClusterPairConditionStoragePaired, metav1.ConditionUnknown,
+			string(stork_api.ClusterPairStatusNotProvided), "No storage options provided")
+	}
+	if clusterPair.Status.RemoteStorageID == "" {
+		return conditions.New(stork_api.ClusterPairConditionStoragePaired, metav1.ConditionFalse, "NotPaired",
+			"Storage hasn't been paired yet")
+	}
+	pairInfo, err := c.volDriver.GetPair(clusterPair.Status.RemoteStorageID)
+	if err != nil {
+		if _, ok := err.(*errors.ErrNotSupported); ok {
+			return conditions.New(stork_api.ClusterPairConditionStoragePaired, metav1.ConditionUnknown, "NotSupported",
+				"Storage driver doesn't support checking the pair")
+		}
+		return conditions.New(stork_api.ClusterPairConditionStoragePaired, metav1.ConditionUnknown, "GetPairFailed",
+			fmt.Sprintf("Error getting pair from the storage driver: %v", err))
+	}
+	if pairInfo == nil {
+		return conditions.New(stork_api.ClusterPairConditionStoragePaired, metav1.ConditionFalse, "NotFound",
+			fmt.Sprintf("Pair with remote storage %v not found", clusterPair.Status.RemoteStorageID))
+	}
+	return conditions.New(stork_api.ClusterPairConditionStoragePaired, metav1.ConditionTrue, "Paired",
+		fmt.Sprintf("Storage is paired with remote storage %v", clusterPair.Status.RemoteStorageID))
+}
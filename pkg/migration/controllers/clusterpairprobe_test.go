@@ -0,0 +1,188 @@
+//go:build unittest
+// +build unittest
+
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	stork_api "github.com/libopenstorage/stork/pkg/apis/stork/v1alpha1"
+	"github.com/libopenstorage/stork/pkg/conditions"
+	"github.com/stretchr/testify/require"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/version"
+	kubefake "k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+	"k8s.io/client-go/tools/clientcmd/api"
+	"k8s.io/client-go/tools/record"
+	runtimeclient "sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+// newTestRemoteCluster returns an API server that answers the requests made
+// by the probe, or fails all of them with the given status code
+func newTestRemoteCluster(t *testing.T, statusCode int) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if statusCode != http.StatusOK {
+			w.WriteHeader(statusCode)
+			require.NoError(t, json.NewEncoder(w).Encode(metav1.Status{
+				TypeMeta: metav1.TypeMeta{Kind: "Status", APIVersion: "v1"},
+				Status:   metav1.StatusFailure,
+				Code:     int32(statusCode),
+				Reason:   metav1.StatusReasonForbidden,
+			}))
+			return
+		}
+		if r.URL.Path == "/version" {
+			require.NoError(t, json.NewEncoder(w).Encode(version.Info{Major: "1", Minor: "25"}))
+			return
+		}
+		review := &authorizationv1.SelfSubjectAccessReview{}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(review))
+		review.Status.Allowed = true
+		w.WriteHeader(http.StatusCreated)
+		require.NoError(t, json.NewEncoder(w).Encode(review))
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func newTestProbedClusterPair(server string, authInfo string) *stork_api.ClusterPair {
+	return &stork_api.ClusterPair{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "pair",
+			Namespace: "ns",
+		},
+		Spec: stork_api.ClusterPairSpec{
+			Config: api.Config{
+				Clusters: map[string]*api.Cluster{"remote": {Server: server}},
+				Contexts: map[string]*api.Context{"remote": {Cluster: "remote", AuthInfo: authInfo}},
+				AuthInfos: map[string]*api.AuthInfo{
+					"user": {Token: "token"},
+				},
+				CurrentContext: "remote",
+			},
+		},
+		Status: stork_api.ClusterPairStatus{
+			SchedulerStatus: stork_api.ClusterPairStatusReady,
+		},
+	}
+}
+
+func probeTestClusterPair(t *testing.T, clusterPair *stork_api.ClusterPair) *stork_api.ClusterPair {
+	scheme := runtime.NewScheme()
+	require.NoError(t, stork_api.AddToScheme(scheme))
+	c := &ClusterPairController{
+		client:   fake.NewClientBuilder().WithScheme(scheme).WithObjects(clusterPair).Build(),
+		recorder: record.NewFakeRecorder(10),
+	}
+	c.updateClusterPairHealth(clusterPair)
+	updated := &stork_api.ClusterPair{}
+	require.NoError(t, c.client.Get(context.TODO(), runtimeclient.ObjectKeyFromObject(clusterPair), updated))
+	return updated
+}
+
+func requireCondition(
+	t *testing.T,
+	clusterPair *stork_api.ClusterPair,
+	conditionType stork_api.ConditionType,
+	status metav1.ConditionStatus,
+	reason string,
+) {
+	condition := conditions.Get(clusterPair.Status.Conditions, conditionType)
+	require.NotNil(t, condition, "Condition %v not set", conditionType)
+	require.Equal(t, status, condition.Status, "Unexpected status for %v", conditionType)
+	require.Equal(t, reason, condition.Reason, "Unexpected reason for %v", conditionType)
+}
+
+func TestProbeClusterPairHealthy(t *testing.T) {
+	server := newTestRemoteCluster(t, http.StatusOK)
+	clusterPair := probeTestClusterPair(t, newTestProbedClusterPair(server.URL, "user"))
+	requireCondition(t, clusterPair, stork_api.ClusterPairConditionAPIReachable, metav1.ConditionTrue, "Reachable")
+	requireCondition(t, clusterPair, stork_api.ClusterPairConditionAuthorized, metav1.ConditionTrue, "Allowed")
+	require.Equal(t, stork_api.ClusterPairStatusReady, clusterPair.Status.SchedulerStatus)
+}
+
+func TestProbeClusterPairCredentialsNotFound(t *testing.T) {
+	// Issues found in the config are only reported if the remote cluster
+	// can still be used
+	server := newTestRemoteCluster(t, http.StatusOK)
+	clusterPair := probeTestClusterPair(t, newTestProbedClusterPair(server.URL, "missing"))
+	requireCondition(t, clusterPair, stork_api.ClusterPairConditionCredentials, metav1.ConditionFalse, "NotFound")
+	requireCondition(t, clusterPair, stork_api.ClusterPairConditionAPIReachable, metav1.ConditionTrue, "Reachable")
+	require.Equal(t, stork_api.ClusterPairStatusReady, clusterPair.Status.SchedulerStatus,
+		"Scheduler status shouldn't change for credentials accepted by the remote cluster")
+}
+
+func TestProbeClusterPairRejected(t *testing.T) {
+	for _, statusCode := range []int{http.StatusUnauthorized, http.StatusForbidden} {
+		server := newTestRemoteCluster(t, statusCode)
+		clusterPair := probeTestClusterPair(t, newTestProbedClusterPair(server.URL, "user"))
+		requireCondition(t, clusterPair, stork_api.ClusterPairConditionCredentials, metav1.ConditionFalse, reasonRejected)
+		require.Equal(t, stork_api.ClusterPairStatusError, clusterPair.Status.SchedulerStatus,
+			"Scheduler status should be error for status code %v", statusCode)
+	}
+}
+
+func TestProbeClusterPairUnreachable(t *testing.T) {
+	server := newTestRemoteCluster(t, http.StatusOK)
+	server.Close()
+	clusterPair := probeTestClusterPair(t, newTestProbedClusterPair(server.URL, "user"))
+	requireCondition(t, clusterPair, stork_api.ClusterPairConditionAPIReachable, metav1.ConditionFalse, reasonUnreachable)
+	requireCondition(t, clusterPair, stork_api.ClusterPairConditionAuthorized, metav1.ConditionUnknown, reasonNotChecked)
+	require.Equal(t, stork_api.ClusterPairStatusError, clusterPair.Status.SchedulerStatus)
+}
+
+func TestProbeAccess(t *testing.T) {
+	client := kubefake.NewSimpleClientset()
+	namespaces := make(map[string]string)
+	client.PrependReactor("create", "selfsubjectaccessreviews", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		review := action.(k8stesting.CreateAction).GetObject().(*authorizationv1.SelfSubjectAccessReview)
+		attributes := review.Spec.ResourceAttributes
+		namespaces[attributes.Verb+" "+attributes.Resource] = attributes.Namespace
+		review.Status.Allowed = attributes.Resource != "statefulsets"
+		return true, review, nil
+	})
+
+	condition := probeAccess(client, "ns")
+	require.Equal(t, metav1.ConditionFalse, condition.Status)
+	require.Equal(t, "Forbidden", condition.Reason)
+	require.Contains(t, condition.Message, "update statefulsets.apps")
+	require.NotContains(t, condition.Message, "deployments")
+	require.Len(t, namespaces, len(clusterPairAccessChecks))
+	for _, check := range clusterPairAccessChecks {
+		expected := ""
+		if check.namespaced {
+			expected = "ns"
+		}
+		require.Equal(t, expected, namespaces[check.verb+" "+check.resource],
+			"Unexpected namespace for %v %v", check.verb, check.resource)
+	}
+	require.Equal(t, "", namespaces["create namespaces"])
+	require.Equal(t, "ns", namespaces["create migrationschedules"])
+}
+
+func TestStartProbingStops(t *testing.T) {
+	stopChannel := make(chan os.Signal, 1)
+	c := &ClusterPairController{stopChannel: stopChannel}
+	done := make(chan struct{})
+	go func() {
+		c.startProbing()
+		close(done)
+	}()
+	stopChannel <- os.Interrupt
+	select {
+	case <-done:
+	case <-time.After(10 * time.Second):
+		require.Fail(t, "Probing didn't stop")
+	}
+}
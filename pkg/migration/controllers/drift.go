@@ -0,0 +1,336 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	stork_api "github.com/libopenstorage/stork/pkg/apis/stork/v1alpha1"
+	storkcache "github.com/libopenstorage/stork/pkg/cache"
+	"github.com/libopenstorage/stork/pkg/errors"
+	"github.com/libopenstorage/stork/pkg/log"
+	"github.com/libopenstorage/stork/pkg/resourcecollector"
+	"github.com/libopenstorage/stork/pkg/utils"
+	"github.com/mitchellh/hashstructure"
+	storkops "github.com/portworx/sched-ops/k8s/stork"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+const (
+	reasonDriftReport = "DriftReport"
+)
+
+// updateDriftReport compares the migration with the destination cluster and
+// records the report in its status. Reports for migrations that haven't
+// finished are deferred until they do.
+func (m *MigrationController) updateDriftReport(ctx context.Context, migration *stork_api.Migration) error {
+	migration.Status.Drift = m.getMigrationDrift(migration)
+	delete(migration.Annotations, utils.MigrationDriftReportAnnotation)
+	m.recordDriftReport(migration, migration.Status.Drift)
+	return m.client.Update(ctx, migration)
+}
+
+// recordDriftReport raises an event on the object with the result of the
+// drift report
+func (m *MigrationController) recordDriftReport(object runtime.Object, report *stork_api.MigrationDriftReport) {
+	if report.Reason != "" {
+		m.recorder.Event(object,
+			v1.EventTypeWarning,
+			reasonDriftReport,
+			fmt.Sprintf("Error comparing with the destination cluster: %v", report.Reason))
+		return
+	}
+	m.recorder.Event(object,
+		v1.EventTypeNormal,
+		reasonDriftReport,
+		fmt.Sprintf("Compared with the destination cluster: %v in sync, %v stale, %v missing, %v extra",
+			report.Summary.InSync, report.Summary.Stale, report.Summary.Missing, report.Summary.Extra))
+}
+
+// getMigrationDrift compares the resources and volumes selected by the
+// migration on this cluster with the ones on the destination cluster. It
+// doesn't modify either cluster.
+func (m *MigrationController) getMigrationDrift(migration *stork_api.Migration) *stork_api.MigrationDriftReport {
+	report := &stork_api.MigrationDriftReport{
+		Timestamp: metav1.Now(),
+		Summary:   &stork_api.MigrationDriftSummary{},
+	}
+	if err := m.compareWithDestination(migration, report); err != nil {
+		log.MigrationLog(migration).Errorf("Error comparing with the destination cluster: %v", err)
+		return &stork_api.MigrationDriftReport{
+			Timestamp: report.Timestamp,
+			Reason:    err.Error(),
+		}
+	}
+	for _, resource := range report.Resources {
+		switch resource.Status {
+		case stork_api.MigrationDriftStatusInSync:
+			report.Summary.InSync++
+		case stork_api.MigrationDriftStatusStale:
+			report.Summary.Stale++
+		case stork_api.MigrationDriftStatusMissing:
+			report.Summary.Missing++
+		case stork_api.MigrationDriftStatusExtra:
+			report.Summary.Extra++
+		}
+	}
+	return report
+}
+
+func (m *MigrationController) compareWithDestination(migration *stork_api.Migration, report *stork_api.MigrationDriftReport) error {
+	clusterPair, err := storkops.Instance().GetClusterPair(migration.Spec.ClusterPair, migration.Namespace)
+	if err != nil {
+		return fmt.Errorf("error getting clusterpair: %v", err)
+	}
+	migrationNamespaces, err := m.getMigrationNamespaces(context.TODO(), migration)
+	if err != nil {
+		return fmt.Errorf("error getting namespaces: %v", err)
+	}
+	resourceCollectorOpts := getResourceCollectorOpts(migration, clusterPair)
+	srcObjects, _, err := m.getResources(
+		migrationNamespaces,
+		migration,
+		migration.Spec.Selectors,
+		migration.Spec.ExcludeSelectors,
+		resourceCollectorOpts,
+		false,
+	)
+	if err != nil {
+		return fmt.Errorf("error getting resources from source: %v", err)
+	}
+	excludeSelectors := make(map[string]string)
+	for k, v := range migration.Spec.ExcludeSelectors {
+		excludeSelectors[k] = v
+	}
+	excludeSelectors[StashCRLabel] = "true"
+	destObjects, _, err := m.getResources(
+		migrationNamespaces,
+		migration,
+		migration.Spec.Selectors,
+		excludeSelectors,
+		resourceCollectorOpts,
+		true,
+	)
+	if err != nil {
+		return fmt.Errorf("error getting resources from destination: %v", err)
+	}
+
+	if *migration.Spec.IncludeResources {
+		// Prepare the resources the same way a migration does so that their
+		// hash can be compared with the one recorded on the destination
+		crdList, err := storkcache.Instance().ListApplicationRegistrations()
+		if err != nil {
+			return err
+		}
+		if err := m.prepareResources(migration, srcObjects, clusterPair, crdList); err != nil {
+			return fmt.Errorf("error preparing resources: %v", err)
+		}
+		if err := compareResources(srcObjects, destObjects, report); err != nil {
+			return err
+		}
+		migratedObjects, err := objectToCollect(destObjects)
+		if err != nil {
+			return err
+		}
+		for _, o := range m.resourceCollector.ObjectTobeDeleted(srcObjects, migratedObjects) {
+			if err := addDriftResource(report, o, stork_api.MigrationDriftStatusExtra,
+				"Resource was migrated but no longer exists on the source cluster"); err != nil {
+				return err
+			}
+		}
+	}
+
+	if *migration.Spec.IncludeVolumes {
+		return m.compareVolumes(clusterPair, srcObjects, destObjects, report)
+	}
+	return nil
+}
+
+// compareResources reports the source objects that are missing on the
+// destination or have changed since they were last migrated
+func compareResources(srcObjects, destObjects []runtime.Unstructured, report *stork_api.MigrationDriftReport) error {
+	destIndex, err := indexObjects(destObjects)
+	if err != nil {
+		return err
+	}
+	for _, o := range srcObjects {
+		key, err := getObjectKey(o)
+		if err != nil {
+			return err
+		}
+		dest, ok := destIndex[key]
+		if !ok {
+			if err := addDriftResource(report, o, stork_api.MigrationDriftStatusMissing,
+				"Resource not found on the destination cluster"); err != nil {
+				return err
+			}
+			continue
+		}
+		status, reason := compareResourceHash(o, dest)
+		if err := addDriftResource(report, o, status, reason); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// compareResourceHash compares the hash of the source object with the one
+// recorded on the destination object when it was last migrated. The hash
+// isn't recorded on PVs since they are updated by the storage driver when
+// they are migrated, so they are only checked for existence and the drift of
+// their volumes is reported with the PVCs.
+func compareResourceHash(src, dest runtime.Unstructured) (stork_api.MigrationDriftStatusType, string) {
+	if src.GetObjectKind().GroupVersionKind().Kind == "PersistentVolume" {
+		return stork_api.MigrationDriftStatusInSync, "PersistentVolume exists on the destination cluster"
+	}
+	metadata, err := meta.Accessor(dest)
+	if err != nil {
+		return stork_api.MigrationDriftStatusStale, err.Error()
+	}
+	destHash, ok := metadata.GetAnnotations()[resourcecollector.StorkResourceHash]
+	if !ok {
+		return stork_api.MigrationDriftStatusStale, "Resource found on the destination cluster without the hash of the migrated version to compare"
+	}
+	srcHash, err := hashstructure.Hash(src, &hashstructure.HashOptions{})
+	if err != nil {
+		return stork_api.MigrationDriftStatusStale, fmt.Sprintf("Unable to generate hash for resource: %v", err)
+	}
+	if destHash != strconv.FormatUint(srcHash, 10) {
+		return stork_api.MigrationDriftStatusStale, "Resource changed since it was last migrated"
+	}
+	return stork_api.MigrationDriftStatusInSync, "Resource matches the last migrated version"
+}
+
+// compareVolumes reports the PVCs missing on the destination and the
+// replication lag of their volumes if the driver reports it
+func (m *MigrationController) compareVolumes(
+	clusterPair *stork_api.ClusterPair,
+	srcObjects, destObjects []runtime.Unstructured,
+	report *stork_api.MigrationDriftReport,
+) error {
+	destIndex, err := indexObjects(destObjects)
+	if err != nil {
+		return err
+	}
+	volumes := make([]string, 0)
+	for _, o := range srcObjects {
+		if o.GetObjectKind().GroupVersionKind().Kind != "PersistentVolumeClaim" {
+			continue
+		}
+		var pvc v1.PersistentVolumeClaim
+		if err := runtime.DefaultUnstructuredConverter.FromUnstructured(o.UnstructuredContent(), &pvc); err != nil {
+			return err
+		}
+		if pvc.Spec.VolumeName == "" {
+			continue
+		}
+		volumeInfo := &stork_api.MigrationDriftVolumeInfo{
+			PersistentVolumeClaim: pvc.Name,
+			Namespace:             pvc.Namespace,
+			Volume:                pvc.Spec.VolumeName,
+			Status:                stork_api.MigrationDriftStatusInSync,
+		}
+		key, err := getObjectKey(o)
+		if err != nil {
+			return err
+		}
+		if _, ok := destIndex[key]; !ok {
+			volumeInfo.Status = stork_api.MigrationDriftStatusMissing
+		}
+		report.Volumes = append(report.Volumes, volumeInfo)
+		volumes = append(volumes, pvc.Spec.VolumeName)
+	}
+	if len(volumes) == 0 {
+		return nil
+	}
+
+	lag, err := m.volDriver.GetMigrationReplicationLag(clusterPair, volumes)
+	reason := ""
+	if err != nil {
+		if _, ok := err.(*errors.ErrNotSupported); ok {
+			reason = "Storage driver doesn't report replication lag"
+		} else {
+			reason = fmt.Sprintf("Error getting replication lag: %v", err)
+		}
+	}
+	for _, volumeInfo := range report.Volumes {
+		if reason != "" {
+			volumeInfo.Reason = reason
+			continue
+		}
+		volumeLag, ok := lag[volumeInfo.Volume]
+		if !ok {
+			volumeInfo.Reason = "Volume hasn't been migrated"
+			continue
+		}
+		volumeInfo.ReplicationLag = &metav1.Duration{Duration: volumeLag}
+		volumeInfo.Reason = fmt.Sprintf("Volume was last migrated %v ago", volumeLag.Round(time.Second))
+	}
+	return nil
+}
+
+func addDriftResource(
+	report *stork_api.MigrationDriftReport,
+	object runtime.Unstructured,
+	status stork_api.MigrationDriftStatusType,
+	reason string,
+) error {
+	metadata, err := meta.Accessor(object)
+	if err != nil {
+		return err
+	}
+	gvk := object.GetObjectKind().GroupVersionKind()
+	resourceInfo := &stork_api.MigrationDriftResourceInfo{
+		Name:      metadata.GetName(),
+		Namespace: metadata.GetNamespace(),
+		Status:    status,
+		Reason:    reason,
+	}
+	resourceInfo.Kind = gvk.Kind
+	resourceInfo.Group = gvk.Group
+	// core Group doesn't have a name, so override it
+	if resourceInfo.Group == "" {
+		resourceInfo.Group = "core"
+	}
+	resourceInfo.Version = gvk.Version
+	report.Resources = append(report.Resources, resourceInfo)
+	return nil
+}
+
+func indexObjects(objects []runtime.Unstructured) (map[string]runtime.Unstructured, error) {
+	index := make(map[string]runtime.Unstructured)
+	for _, o := range objects {
+		key, err := getObjectKey(o)
+		if err != nil {
+			return nil, err
+		}
+		index[key] = o
+	}
+	return index, nil
+}
+
+func getObjectKey(object runtime.Unstructured) (string, error) {
+	name, namespace, kind, err := utils.GetObjectDetails(object)
+	if err != nil {
+		return "", err
+	}
+	return kind + "/" + namespace + "/" + name, nil
+}
+
+// getMigrationScheduleDrift compares the resources and volumes selected by the
+// template of the schedule with the destination cluster
+func (m *MigrationController) getMigrationScheduleDrift(migrationSchedule *stork_api.MigrationSchedule) *stork_api.MigrationDriftReport {
+	migration := &stork_api.Migration{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        migrationSchedule.Name,
+			Namespace:   migrationSchedule.Namespace,
+			Annotations: migrationSchedule.Annotations,
+		},
+		Spec: setDefaults(migrationSchedule.Spec.Template.Spec),
+	}
+	return m.getMigrationDrift(migration)
+}
@@ -0,0 +1,74 @@
+//go:build unittest
+// +build unittest
+
+package controllers
+
+import (
+	"strconv"
+	"testing"
+
+	stork_api "github.com/libopenstorage/stork/pkg/apis/stork/v1alpha1"
+	"github.com/libopenstorage/stork/pkg/resourcecollector"
+	"github.com/mitchellh/hashstructure"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+func newTestDriftObject(data string) *unstructured.Unstructured {
+	return &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "v1",
+		"kind":       "ConfigMap",
+		"metadata": map[string]interface{}{
+			"name":      "config",
+			"namespace": "ns",
+		},
+		"data": map[string]interface{}{"key": data},
+	}}
+}
+
+func TestCompareResourceHash(t *testing.T) {
+	src := newTestDriftObject("value")
+	hash, err := hashstructure.Hash(src, &hashstructure.HashOptions{})
+	require.NoError(t, err)
+
+	dest := newTestDriftObject("value")
+	status, _ := compareResourceHash(src, dest)
+	require.Equal(t, stork_api.MigrationDriftStatusStale, status, "Resource without a recorded hash shouldn't be in sync")
+
+	dest.SetAnnotations(map[string]string{resourcecollector.StorkResourceHash: strconv.FormatUint(hash, 10)})
+	status, _ = compareResourceHash(src, dest)
+	require.Equal(t, stork_api.MigrationDriftStatusInSync, status)
+
+	src = newTestDriftObject("changed")
+	status, _ = compareResourceHash(src, dest)
+	require.Equal(t, stork_api.MigrationDriftStatusStale, status)
+}
+
+func TestCompareResourcesPersistentVolume(t *testing.T) {
+	newTestPV := func() *unstructured.Unstructured {
+		return &unstructured.Unstructured{Object: map[string]interface{}{
+			"apiVersion": "v1",
+			"kind":       "PersistentVolume",
+			"metadata":   map[string]interface{}{"name": "pv"},
+			"spec":       map[string]interface{}{"persistentVolumeReclaimPolicy": "Retain"},
+		}}
+	}
+	// PVs are migrated without the hash and their spec is updated by the
+	// storage driver, so they are only checked for existence
+	dest := newTestPV()
+	dest.Object["spec"] = map[string]interface{}{"persistentVolumeReclaimPolicy": "Delete"}
+	report := &stork_api.MigrationDriftReport{}
+	require.NoError(t, compareResources([]runtime.Unstructured{newTestPV(), newTestDriftObject("value")},
+		[]runtime.Unstructured{dest, newTestDriftObject("value")}, report))
+	require.Len(t, report.Resources, 2)
+	require.Equal(t, "PersistentVolume", report.Resources[0].Kind)
+	require.Equal(t, stork_api.MigrationDriftStatusInSync, report.Resources[0].Status, report.Resources[0].Reason)
+	require.Equal(t, stork_api.MigrationDriftStatusStale, report.Resources[1].Status,
+		"ConfigMap without a recorded hash shouldn't be in sync")
+
+	report = &stork_api.MigrationDriftReport{}
+	require.NoError(t, compareResources([]runtime.Unstructured{newTestPV()}, nil, report))
+	require.Len(t, report.Resources, 1)
+	require.Equal(t, stork_api.MigrationDriftStatusMissing, report.Resources[0].Status)
+}
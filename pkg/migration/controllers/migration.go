@@ -8,6 +8,7 @@ import (
 	"reflect"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/go-openapi/inflect"
@@ -35,6 +36,7 @@ import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/kubernetes/pkg/util/slice"
 
 	"k8s.io/client-go/dynamic"
@@ -67,6 +69,9 @@ const (
 	// StorkMigrationCRDDeactivateAnnotation is the annotation used to keep track of
 	// the value to be set for deactivating crds
 	StorkMigrationCRDDeactivateAnnotation = "stork.libopenstorage.org/migrationCRDDeactivate"
+	// StorkMigrationAdmittedAnnotation is the annotation used to keep track of
+	// the migrations started within the limits of their cluster pair
+	StorkMigrationAdmittedAnnotation = "stork.libopenstorage.org/migrationAdmitted"
 	// PVReclaimAnnotation for pvc's reclaim policy
 	PVReclaimAnnotation = "stork.libopenstorage.org/reclaimPolicy"
 	// StorkAnnotationPrefix for resources created/managed by stork
@@ -84,6 +89,9 @@ const (
 	deletedRetryInterval = 10 * time.Second
 	boundRetryInterval   = 5 * time.Second
 	applyRetryInterval   = 5 * time.Second
+	// Interval at which the status of the applied resources is saved so that
+	// the migration can be resumed from it
+	resourceCheckpointInterval = 30 * time.Second
 )
 
 var (
@@ -112,6 +120,10 @@ type MigrationController struct {
 	resourceCollector       resourcecollector.ResourceCollector
 	migrationAdminNamespace string
 	migrationMaxThreads     int
+	// resourceStatusLocks protect the status of the resources of each
+	// migration while they are applied by the parallel workers, keyed by the
+	// UID of the migration
+	resourceStatusLocks sync.Map
 }
 
 // RemoteConfig contains config and clients to interact with destination cluster
@@ -248,6 +260,7 @@ func (m *MigrationController) handle(ctx context.Context, migration *stork_api.M
 				logrus.Errorf("%s: cleanup: %s", reflect.TypeOf(m), err)
 			}
 		}
+		m.resourceStatusLocks.Delete(migration.UID)
 
 		if migration.GetFinalizers() != nil {
 			controllers.RemoveFinalizer(migration, controllers.FinalizerCleanup)
@@ -259,9 +272,13 @@ func (m *MigrationController) handle(ctx context.Context, migration *stork_api.M
 
 	migration.Spec = setDefaults(migration.Spec)
 
+	if _, ok := migration.Annotations[utils.MigrationDriftReportAnnotation]; ok && migration.Status.Stage == stork_api.MigrationStageFinal {
+		return m.updateDriftReport(ctx, migration)
+	}
+
 	if migration.GetAnnotations() != nil {
 		if schedName, ok := migration.GetAnnotations()[StorkMigrationScheduleName]; ok {
-			remoteConfig, err := getClusterPairSchedulerConfig(migration.Spec.ClusterPair, migration.Namespace)
+			remoteConfig, err := GetClusterPairSchedulerConfig(migration.Spec.ClusterPair, migration.Namespace)
 			if err != nil {
 				m.recorder.Event(migration,
 					v1.EventTypeWarning,
@@ -355,6 +372,15 @@ func (m *MigrationController) handle(ctx context.Context, migration *stork_api.M
 			err.Error())
 		return nil
 	}
+	if len(migration.Spec.Waves) != 0 {
+		return m.migrateWaves(ctx, migration)
+	}
+	if migration.Status.Stage == stork_api.MigrationStageInitial ||
+		migration.Status.Stage == stork_api.MigrationStagePending {
+		if queued, err := m.queueMigration(ctx, migration); err != nil || queued {
+			return err
+		}
+	}
 	var terminationChannels []chan bool
 	var clusterDomains *stork_api.ClusterDomains
 	if !*migration.Spec.IncludeVolumes {
@@ -570,9 +596,17 @@ func (m *MigrationController) getMigrationNamespaces(ctx context.Context, migrat
 	var migrationNamespaces []string
 	uniqueNamespaces := make(map[string]bool)
 
+	namespaceSelectors := []map[string]string{migration.Spec.NamespaceSelectors}
 	for _, ns := range migration.Spec.Namespaces {
 		uniqueNamespaces[ns] = true
 	}
+	// A migration with waves migrates the namespaces of all its waves
+	for _, wave := range migration.Spec.Waves {
+		for _, ns := range wave.Namespaces {
+			uniqueNamespaces[ns] = true
+		}
+		namespaceSelectors = append(namespaceSelectors, wave.NamespaceSelectors)
+	}
 
 	config, err := rest.InClusterConfig()
 	if err != nil {
@@ -582,14 +616,16 @@ func (m *MigrationController) getMigrationNamespaces(ctx context.Context, migrat
 	if err != nil {
 		return nil, err
 	}
-	for key, val := range migration.Spec.NamespaceSelectors {
-		label := key + "=" + val
-		namespaces, err := clientset.CoreV1().Namespaces().List(ctx, metav1.ListOptions{LabelSelector: label})
-		if err != nil {
-			return nil, err
-		}
-		for _, namespace := range namespaces.Items {
-			uniqueNamespaces[namespace.GetName()] = true
+	for _, selectors := range namespaceSelectors {
+		for key, val := range selectors {
+			label := key + "=" + val
+			namespaces, err := clientset.CoreV1().Namespaces().List(ctx, metav1.ListOptions{LabelSelector: label})
+			if err != nil {
+				return nil, err
+			}
+			for _, namespace := range namespaces.Items {
+				uniqueNamespaces[namespace.GetName()] = true
+			}
 		}
 	}
 
@@ -604,7 +640,7 @@ func (m *MigrationController) purgeMigratedResources(
 	migrationNamespaces []string,
 	resourceCollectorOpts resourcecollector.Options,
 ) error {
-	remoteConfig, err := getClusterPairSchedulerConfig(migration.Spec.ClusterPair, migration.Namespace)
+	remoteConfig, err := GetClusterPairSchedulerConfig(migration.Spec.ClusterPair, migration.Namespace)
 	if err != nil {
 		return err
 	}
@@ -752,6 +788,10 @@ func (m *MigrationController) migrateVolumes(migration *stork_api.Migration, mig
 				storageStatus, err)
 		}
 
+		if err := m.setVolumeBandwidthLimit(migration); err != nil {
+			return err
+		}
+
 		volumeInfos, err := m.volDriver.StartMigration(migration, migrationNamespaces)
 		if err != nil {
 			return err
@@ -965,23 +1005,7 @@ func (m *MigrationController) migrateResources(migration *stork_api.Migration, m
 	var updateObjects, allObjects []runtime.Unstructured
 
 	var pvcsWithOwnerRef []v1.PersistentVolumeClaim
-	// Don't modify resources if mentioned explicitly in specs
-	resourceCollectorOpts := resourcecollector.Options{}
-	if *migration.Spec.SkipServiceUpdate {
-		resourceCollectorOpts.SkipServices = true
-	}
-	if clusterPair.Spec.PlatformOptions.Rancher != nil && len(clusterPair.Spec.PlatformOptions.Rancher.ProjectMappings) > 0 {
-		resourceCollectorOpts.RancherProjectMappings = make(map[string]string)
-		for k, v := range clusterPair.Spec.PlatformOptions.Rancher.ProjectMappings {
-			resourceCollectorOpts.RancherProjectMappings[k] = v
-		}
-	}
-	if *migration.Spec.IncludeNetworkPolicyWithCIDR {
-		resourceCollectorOpts.IncludeAllNetworkPolicies = true
-	}
-	if *migration.Spec.IgnoreOwnerReferencesCheck {
-		resourceCollectorOpts.IgnoreOwnerReferencesCheck = true
-	}
+	resourceCollectorOpts := getResourceCollectorOpts(migration, clusterPair)
 	if volumesOnly {
 		allObjects, pvcsWithOwnerRef, err = m.getVolumeOnlyMigrationResources(migration, migrationNamespaces, resourceCollectorOpts)
 		if err != nil {
@@ -1008,6 +1032,10 @@ func (m *MigrationController) migrateResources(migration *stork_api.Migration, m
 		}
 	}
 
+	// Resources applied before the migration was interrupted are only
+	// applied again if they changed since
+	checkpoint := getResourceCheckpoint(migration.Status.Resources)
+
 	// Save the collected resources infos in the status
 	resourceInfos := make([]*stork_api.MigrationResourceInfo, 0)
 	for _, obj := range allObjects {
@@ -1024,19 +1052,7 @@ func (m *MigrationController) migrateResources(migration *stork_api.Migration, m
 				continue
 			}
 		}
-		resourceInfo := &stork_api.MigrationResourceInfo{
-			Name:      metadata.GetName(),
-			Namespace: metadata.GetNamespace(),
-			Status:    stork_api.MigrationStatusInProgress,
-		}
-
-		resourceInfo.Kind = gvk.Kind
-		resourceInfo.Group = gvk.Group
-		// core Group doesn't have a name, so override it
-		if resourceInfo.Group == "" {
-			resourceInfo.Group = "core"
-		}
-		resourceInfo.Version = gvk.Version
+		resourceInfo := newResourceInfo(metadata, gvk, checkpoint)
 		resGroups[gvk.Group] = gvk.Version
 		resourceInfos = append(resourceInfos, resourceInfo)
 		updateObjects = append(updateObjects, obj)
@@ -1068,7 +1084,12 @@ func (m *MigrationController) migrateResources(migration *stork_api.Migration, m
 		return err
 	}
 
-	err = m.applyResources(migration, migrationNamespaces, updateObjects, resGroups, clusterPair, crdList)
+	applyObjects, err := m.skipCheckpointedResources(migration, updateObjects, checkpoint)
+	if err != nil {
+		return err
+	}
+
+	err = m.applyResources(migration, migrationNamespaces, applyObjects, resGroups, clusterPair, crdList)
 	if err != nil {
 		m.recorder.Event(migration,
 			v1.EventTypeWarning,
@@ -1098,6 +1119,8 @@ func (m *MigrationController) migrateResources(migration *stork_api.Migration, m
 		return err
 	}
 
+	// All the workers are done with the status of the resources
+	m.resourceStatusLocks.Delete(migration.UID)
 	migration.Status.Stage = stork_api.MigrationStageFinal
 	migration.Status.Status = stork_api.MigrationStatusSuccessful
 	for _, resource := range migration.Status.Resources {
@@ -1128,6 +1151,29 @@ func (m *MigrationController) migrateResources(migration *stork_api.Migration, m
 	return nil
 }
 
+// getResourceCollectorOpts returns the options used to collect the resources
+// of the migration
+func getResourceCollectorOpts(migration *stork_api.Migration, clusterPair *stork_api.ClusterPair) resourcecollector.Options {
+	// Don't modify resources if mentioned explicitly in specs
+	resourceCollectorOpts := resourcecollector.Options{}
+	if *migration.Spec.SkipServiceUpdate {
+		resourceCollectorOpts.SkipServices = true
+	}
+	if clusterPair.Spec.PlatformOptions.Rancher != nil && len(clusterPair.Spec.PlatformOptions.Rancher.ProjectMappings) > 0 {
+		resourceCollectorOpts.RancherProjectMappings = make(map[string]string)
+		for k, v := range clusterPair.Spec.PlatformOptions.Rancher.ProjectMappings {
+			resourceCollectorOpts.RancherProjectMappings[k] = v
+		}
+	}
+	if *migration.Spec.IncludeNetworkPolicyWithCIDR {
+		resourceCollectorOpts.IncludeAllNetworkPolicies = true
+	}
+	if *migration.Spec.IgnoreOwnerReferencesCheck {
+		resourceCollectorOpts.IgnoreOwnerReferencesCheck = true
+	}
+	return resourceCollectorOpts
+}
+
 func (m *MigrationController) prepareResources(
 	migration *stork_api.Migration,
 	objects []runtime.Unstructured,
@@ -1212,48 +1258,191 @@ func (m *MigrationController) updateResourceStatus(
 	status stork_api.MigrationStatusType,
 	reason string,
 ) {
-	for _, resource := range migration.Status.Resources {
-		metadata, err := meta.Accessor(object)
-		if err != nil {
-			continue
+	lock := m.getResourceStatusLock(migration)
+	lock.Lock()
+	defer lock.Unlock()
+	resource := getResourceInfo(migration, object)
+	if resource == nil {
+		return
+	}
+	metadata, err := meta.Accessor(object)
+	if err != nil {
+		return
+	}
+	if _, ok := metadata.GetAnnotations()[resourcecollector.TransformedResourceName]; ok {
+		if len(migration.Spec.TransformSpecs) != 0 && len(migration.Spec.TransformSpecs) == 1 {
+			resource.TransformedBy = migration.Spec.TransformSpecs[0]
 		}
-		gkv := object.GetObjectKind().GroupVersionKind()
+	}
+	resource.Status = status
+	resource.Reason = reason
+	eventType := v1.EventTypeNormal
+	if status == stork_api.MigrationStatusFailed {
+		eventType = v1.EventTypeWarning
+	}
+	eventMessage := fmt.Sprintf("%v %v/%v: %v",
+		object.GetObjectKind().GroupVersionKind(),
+		resource.Namespace,
+		resource.Name,
+		reason)
+	m.recorder.Event(migration, eventType, string(status), eventMessage)
+}
+
+// updateResourceHash records the hash of a resource applied on the destination
+// cluster and periodically saves the status of the resources as a checkpoint
+func (m *MigrationController) updateResourceHash(
+	migration *stork_api.Migration,
+	object runtime.Unstructured,
+	hash uint64,
+	lastCheckpoint *time.Time,
+) {
+	lock := m.getResourceStatusLock(migration)
+	lock.Lock()
+	if resource := getResourceInfo(migration, object); resource != nil {
+		resource.Hash = strconv.FormatUint(hash, 10)
+	}
+	if time.Since(*lastCheckpoint) < resourceCheckpointInterval {
+		lock.Unlock()
+		return
+	}
+	*lastCheckpoint = time.Now()
+	// Save a copy so that the workers can keep updating the status
+	checkpoint := migration.DeepCopy()
+	lock.Unlock()
+
+	if err := m.client.Update(context.TODO(), checkpoint); err != nil {
+		log.MigrationLog(migration).Warnf("Error saving status of migrated resources: %v", err)
+		return
+	}
+	lock.Lock()
+	migration.ResourceVersion = checkpoint.ResourceVersion
+	lock.Unlock()
+}
+
+// getResourceStatusLock returns the lock protecting the status of the
+// resources of the migration
+func (m *MigrationController) getResourceStatusLock(migration *stork_api.Migration) *sync.Mutex {
+	lock, _ := m.resourceStatusLocks.LoadOrStore(migration.UID, &sync.Mutex{})
+	return lock.(*sync.Mutex)
+}
+
+// getResourceInfo returns the status of the object in the migration
+func getResourceInfo(migration *stork_api.Migration, object runtime.Unstructured) *stork_api.MigrationResourceInfo {
+	metadata, err := meta.Accessor(object)
+	if err != nil {
+		return nil
+	}
+	gkv := object.GetObjectKind().GroupVersionKind()
+	for _, resource := range migration.Status.Resources {
 		if resource.Name == metadata.GetName() &&
 			resource.Namespace == metadata.GetNamespace() &&
 			(resource.Group == gkv.Group || (resource.Group == "core" && gkv.Group == "")) &&
 			resource.Version == gkv.Version &&
 			resource.Kind == gkv.Kind {
-			if _, ok := metadata.GetAnnotations()[resourcecollector.TransformedResourceName]; ok {
-				if len(migration.Spec.TransformSpecs) != 0 && len(migration.Spec.TransformSpecs) == 1 {
-					resource.TransformedBy = migration.Spec.TransformSpecs[0]
-				}
-			}
-			resource.Status = status
-			resource.Reason = reason
-			eventType := v1.EventTypeNormal
-			if status == stork_api.MigrationStatusFailed {
-				eventType = v1.EventTypeWarning
-			}
-			eventMessage := fmt.Sprintf("%v %v/%v: %v",
-				gkv,
-				resource.Namespace,
-				resource.Name,
-				reason)
-			m.recorder.Event(migration, eventType, string(status), eventMessage)
-			return
+			return resource
 		}
 	}
+	return nil
+}
+
+// newResourceInfo returns the status of a resource about to be migrated. The
+// checkpoint of an earlier attempt is kept until the resource is applied
+// again, in case the migration is interrupted before that.
+func newResourceInfo(
+	metadata metav1.Object,
+	gvk schema.GroupVersionKind,
+	checkpoint map[string]string,
+) *stork_api.MigrationResourceInfo {
+	resourceInfo := &stork_api.MigrationResourceInfo{
+		Name:      metadata.GetName(),
+		Namespace: metadata.GetNamespace(),
+		Status:    stork_api.MigrationStatusInProgress,
+	}
+
+	resourceInfo.Kind = gvk.Kind
+	resourceInfo.Group = gvk.Group
+	// core Group doesn't have a name, so override it
+	if resourceInfo.Group == "" {
+		resourceInfo.Group = "core"
+	}
+	resourceInfo.Version = gvk.Version
+	if hash, ok := checkpoint[getResourceKey(resourceInfo)]; ok {
+		resourceInfo.Status = stork_api.MigrationStatusSuccessful
+		resourceInfo.Reason = "Resource migrated successfully"
+		resourceInfo.Hash = hash
+	}
+	return resourceInfo
+}
+
+// getResourceCheckpoint returns the hashes of the resources that were applied
+// successfully by an earlier attempt of the migration
+func getResourceCheckpoint(resources []*stork_api.MigrationResourceInfo) map[string]string {
+	checkpoint := make(map[string]string)
+	for _, resource := range resources {
+		if resource.Status != stork_api.MigrationStatusSuccessful || resource.Hash == "" {
+			continue
+		}
+		checkpoint[getResourceKey(resource)] = resource.Hash
+	}
+	return checkpoint
+}
+
+func getResourceKey(resource *stork_api.MigrationResourceInfo) string {
+	return strings.Join([]string{resource.Group, resource.Version, resource.Kind, resource.Namespace, resource.Name}, "/")
+}
+
+// skipCheckpointedResources marks the resources that haven't changed since an
+// earlier attempt of the migration applied them as migrated, and returns the
+// resources that still need to be applied. PVs and PVCs are always applied
+// since they are bound to each other on the destination cluster.
+func (m *MigrationController) skipCheckpointedResources(
+	migration *stork_api.Migration,
+	objects []runtime.Unstructured,
+	checkpoint map[string]string,
+) ([]runtime.Unstructured, error) {
+	if len(checkpoint) == 0 {
+		return objects, nil
+	}
+	applyObjects := make([]runtime.Unstructured, 0, len(objects))
+	for _, o := range objects {
+		gvk := o.GetObjectKind().GroupVersionKind()
+		if gvk.Kind == "PersistentVolume" || gvk.Kind == "PersistentVolumeClaim" {
+			applyObjects = append(applyObjects, o)
+			continue
+		}
+		resource := getResourceInfo(migration, o)
+		if resource == nil {
+			applyObjects = append(applyObjects, o)
+			continue
+		}
+		key := getResourceKey(resource)
+		objHash, err := hashstructure.Hash(o, &hashstructure.HashOptions{})
+		if err != nil || checkpoint[key] != strconv.FormatUint(objHash, 10) {
+			applyObjects = append(applyObjects, o)
+			continue
+		}
+		resource.Status = stork_api.MigrationStatusSuccessful
+		resource.Reason = "Resource migrated successfully"
+		resource.Hash = checkpoint[key]
+	}
+	if skipped := len(objects) - len(applyObjects); skipped != 0 {
+		log.MigrationLog(migration).Infof("Resuming migration, skipping %v resources that were already migrated", skipped)
+		if err := m.updateMigrationCR(context.TODO(), migration); err != nil {
+			return nil, err
+		}
+	}
+	return applyObjects, nil
 }
 
 func (m *MigrationController) getRemoteClient(migration *stork_api.Migration) (*RemoteClient, error) {
-	remoteConfig, err := getClusterPairSchedulerConfig(migration.Spec.ClusterPair, migration.Namespace)
+	remoteConfig, err := GetClusterPairSchedulerConfig(migration.Spec.ClusterPair, migration.Namespace)
 	if err != nil {
 		return nil, err
 	}
 	remoteAdminConfig := remoteConfig
 	// Use the admin cluter pair for cluster scoped resources if it has been configured
 	if migration.Spec.AdminClusterPair != "" {
-		remoteAdminConfig, err = getClusterPairSchedulerConfig(migration.Spec.AdminClusterPair, m.migrationAdminNamespace)
+		remoteAdminConfig, err = GetClusterPairSchedulerConfig(migration.Spec.AdminClusterPair, m.migrationAdminNamespace)
 		if err != nil {
 			return nil, err
 		}
@@ -2318,6 +2507,13 @@ func (m *MigrationController) applyResources(
 			"Resource migrated successfully")
 	}
 
+	// Save the status of the PVs and PVCs before applying the remaining
+	// objects in parallel
+	if err := m.updateMigrationCR(context.TODO(), migration); err != nil {
+		return err
+	}
+	lastCheckpoint := time.Now()
+
 	appRegsStashMap := make(map[string]bool)
 	if !*migration.Spec.StartApplications {
 		appRegsStashMap = getAppRegsStashMap(*crdList)
@@ -2480,6 +2676,7 @@ func (m *MigrationController) applyResources(
 					o,
 					stork_api.MigrationStatusSuccessful,
 					"Resource migrated successfully")
+				m.updateResourceHash(migration, o, objHash, &lastCheckpoint)
 			}
 			errorChan <- nil
 		}
@@ -2582,7 +2779,9 @@ func (m *MigrationController) getMigrationSummary(migration *stork_api.Migration
 }
 
 func (m *MigrationController) cleanup(migration *stork_api.Migration) error {
-	if migration.Status.Stage != stork_api.MigrationStageFinal {
+	// The migrations of the waves are cancelled when they are deleted along
+	// with the migration
+	if migration.Status.Stage != stork_api.MigrationStageFinal && len(migration.Spec.Waves) == 0 {
 		return m.volDriver.CancelMigration(migration)
 	}
 	return nil
@@ -2901,7 +3100,7 @@ func (m *MigrationController) getResources(
 		rc = resourcecollector.ResourceCollector{
 			Driver: m.volDriver,
 		}
-		remoteConfig, err := getClusterPairSchedulerConfig(migration.Spec.ClusterPair, migration.Namespace)
+		remoteConfig, err := GetClusterPairSchedulerConfig(migration.Spec.ClusterPair, migration.Namespace)
 		if err != nil {
 			return objects, pvcs, err
 		}
@@ -0,0 +1,119 @@
+//go:build unittest
+// +build unittest
+
+package controllers
+
+import (
+	"context"
+	"strconv"
+	"testing"
+
+	stork_api "github.com/libopenstorage/stork/pkg/apis/stork/v1alpha1"
+	"github.com/mitchellh/hashstructure"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
+	runtimeclient "sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func newTestMigrationObject(kind, name, data string) *unstructured.Unstructured {
+	return &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "v1",
+		"kind":       kind,
+		"metadata": map[string]interface{}{
+			"name":      name,
+			"namespace": "ns",
+		},
+		"spec": map[string]interface{}{"data": data},
+	}}
+}
+
+func newTestMigrationController(t *testing.T, objects ...runtimeclient.Object) *MigrationController {
+	scheme := runtime.NewScheme()
+	require.NoError(t, stork_api.AddToScheme(scheme))
+	return &MigrationController{
+		client:   fake.NewClientBuilder().WithScheme(scheme).WithObjects(objects...).Build(),
+		recorder: record.NewFakeRecorder(100),
+	}
+}
+
+func getTestObjectHash(t *testing.T, object runtime.Unstructured) string {
+	hash, err := hashstructure.Hash(object, &hashstructure.HashOptions{})
+	require.NoError(t, err)
+	return strconv.FormatUint(hash, 10)
+}
+
+// startTestMigrationAttempt sets the status of the resources like an attempt
+// of the migration does before applying them
+func startTestMigrationAttempt(migration *stork_api.Migration, objects []runtime.Unstructured) map[string]string {
+	checkpoint := getResourceCheckpoint(migration.Status.Resources)
+	resourceInfos := make([]*stork_api.MigrationResourceInfo, 0)
+	for _, o := range objects {
+		metadata, _ := o.(*unstructured.Unstructured)
+		resourceInfos = append(resourceInfos, newResourceInfo(metadata, o.GetObjectKind().GroupVersionKind(), checkpoint))
+	}
+	migration.Status.Resources = resourceInfos
+	return checkpoint
+}
+
+func TestGetResourceCheckpoint(t *testing.T) {
+	resources := []*stork_api.MigrationResourceInfo{
+		{Name: "applied", Namespace: "ns", Status: stork_api.MigrationStatusSuccessful, Hash: "1"},
+		{Name: "nohash", Namespace: "ns", Status: stork_api.MigrationStatusSuccessful},
+		{Name: "failed", Namespace: "ns", Status: stork_api.MigrationStatusFailed, Hash: "2"},
+		{Name: "pending", Namespace: "ns", Status: stork_api.MigrationStatusInProgress, Hash: "3"},
+	}
+	for _, resource := range resources {
+		resource.Group = "core"
+		resource.Version = "v1"
+		resource.Kind = "ConfigMap"
+	}
+	require.Equal(t, map[string]string{"core/v1/ConfigMap/ns/applied": "1"}, getResourceCheckpoint(resources))
+}
+
+func TestSkipCheckpointedResources(t *testing.T) {
+	unchanged := newTestMigrationObject("ConfigMap", "unchanged", "value")
+	changed := newTestMigrationObject("ConfigMap", "changed", "value")
+	pvc := newTestMigrationObject("PersistentVolumeClaim", "pvc", "value")
+	pending := newTestMigrationObject("ConfigMap", "pending", "value")
+	objects := []runtime.Unstructured{unchanged, changed, pvc, pending}
+
+	migration := &stork_api.Migration{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "migration",
+			Namespace: "ns",
+		},
+	}
+	m := newTestMigrationController(t, migration)
+
+	// The first attempt was interrupted after applying all but one resource
+	startTestMigrationAttempt(migration, objects)
+	for _, o := range []runtime.Unstructured{unchanged, changed, pvc} {
+		resource := getResourceInfo(migration, o)
+		resource.Status = stork_api.MigrationStatusSuccessful
+		resource.Hash = getTestObjectHash(t, o)
+	}
+
+	// The second attempt is interrupted before applying any resource
+	changed.Object["spec"] = map[string]interface{}{"data": "changed"}
+	checkpoint := startTestMigrationAttempt(migration, objects)
+	require.Len(t, checkpoint, 3)
+	applyObjects, err := m.skipCheckpointedResources(migration, objects, checkpoint)
+	require.NoError(t, err)
+	require.ElementsMatch(t, []runtime.Unstructured{changed, pvc, pending}, applyObjects)
+
+	saved := &stork_api.Migration{}
+	require.NoError(t, m.client.Get(context.TODO(), runtimeclient.ObjectKeyFromObject(migration), saved))
+	require.Equal(t, stork_api.MigrationStatusSuccessful, getResourceInfo(saved, unchanged).Status, "Skipped resource not saved as migrated")
+
+	// The third attempt still skips the resource applied by the first one
+	checkpoint = startTestMigrationAttempt(saved, objects)
+	require.Len(t, checkpoint, 3, "Checkpoint lost after the second attempt")
+	applyObjects, err = m.skipCheckpointedResources(saved, objects, checkpoint)
+	require.NoError(t, err)
+	require.ElementsMatch(t, []runtime.Unstructured{changed, pvc, pending}, applyObjects)
+	require.Equal(t, stork_api.MigrationStatusInProgress, getResourceInfo(saved, pending).Status)
+}
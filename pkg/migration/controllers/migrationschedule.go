@@ -13,6 +13,7 @@ import (
 	"github.com/libopenstorage/stork/pkg/k8sutils"
 	"github.com/libopenstorage/stork/pkg/log"
 	"github.com/libopenstorage/stork/pkg/schedule"
+	"github.com/libopenstorage/stork/pkg/utils"
 	"github.com/libopenstorage/stork/pkg/version"
 	"github.com/portworx/sched-ops/k8s/apiextensions"
 	"github.com/portworx/sched-ops/k8s/core"
@@ -43,11 +44,13 @@ const (
 )
 
 // NewMigrationSchedule creates a new instance of MigrationScheduleController.
-func NewMigrationSchedule(mgr manager.Manager, d volume.Driver, r record.EventRecorder) *MigrationScheduleController {
+// Drift reports for the schedules are generated by the migration controller.
+func NewMigrationSchedule(mgr manager.Manager, d volume.Driver, r record.EventRecorder, mc *MigrationController) *MigrationScheduleController {
 	return &MigrationScheduleController{
-		client:    mgr.GetClient(),
-		volDriver: d,
-		recorder:  r,
+		client:              mgr.GetClient(),
+		volDriver:           d,
+		recorder:            r,
+		migrationController: mc,
 	}
 }
 
@@ -55,8 +58,9 @@ func NewMigrationSchedule(mgr manager.Manager, d volume.Driver, r record.EventRe
 type MigrationScheduleController struct {
 	client runtimeclient.Client
 
-	volDriver volume.Driver
-	recorder  record.EventRecorder
+	volDriver           volume.Driver
+	recorder            record.EventRecorder
+	migrationController *MigrationController
 }
 
 // Init Initialize the migration schedule controller
@@ -117,6 +121,12 @@ func (m *MigrationScheduleController) handle(ctx context.Context, migrationSched
 		return nil
 	}
 	migrationSchedule.Spec = setScheduleDefaults(migrationSchedule.Spec)
+	if _, ok := migrationSchedule.Annotations[utils.MigrationDriftReportAnnotation]; ok && m.migrationController != nil {
+		migrationSchedule.Status.Drift = m.migrationController.getMigrationScheduleDrift(migrationSchedule)
+		delete(migrationSchedule.Annotations, utils.MigrationDriftReportAnnotation)
+		m.migrationController.recordDriftReport(migrationSchedule, migrationSchedule.Status.Drift)
+		return m.client.Update(ctx, migrationSchedule)
+	}
 	if migrationSchedule.GetAnnotations() != nil {
 		if _, ok := migrationSchedule.GetAnnotations()[StorkMigrationScheduleCopied]; ok {
 			// check status of all migrated app in cluster
@@ -125,7 +135,7 @@ func (m *MigrationScheduleController) handle(ctx context.Context, migrationSched
 		}
 	}
 	if !(*migrationSchedule.Spec.Suspend) {
-		remoteConfig, err := getClusterPairSchedulerConfig(migrationSchedule.Spec.Template.Spec.ClusterPair, migrationSchedule.Namespace)
+		remoteConfig, err := GetClusterPairSchedulerConfig(migrationSchedule.Spec.Template.Spec.ClusterPair, migrationSchedule.Namespace)
 		if err != nil {
 			m.recorder.Event(migrationSchedule,
 				v1.EventTypeWarning,
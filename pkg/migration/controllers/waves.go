@@ -0,0 +1,337 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	stork_api "github.com/libopenstorage/stork/pkg/apis/stork/v1alpha1"
+	"github.com/libopenstorage/stork/pkg/log"
+	"github.com/libopenstorage/stork/pkg/utils"
+	storkops "github.com/portworx/sched-ops/k8s/stork"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+const (
+	defaultWaveReadinessTimeout = 10 * time.Minute
+)
+
+// waveExcludedAnnotations are the annotations of a migration that aren't
+// copied to the migrations of its waves
+var waveExcludedAnnotations = map[string]bool{
+	utils.MigrationDriftReportAnnotation: true,
+	StorkMigrationScheduleName:           true,
+	v1.LastAppliedConfigAnnotation:       true,
+	StorkMigrationAdmittedAnnotation:     true,
+}
+
+// validateWaves checks that the namespaces and rules of a migration with
+// waves are only specified in its waves, and that the names of the waves can
+// be used for the names of their migrations
+func validateWaves(migration *stork_api.Migration) error {
+	if len(migration.Spec.Namespaces) != 0 || len(migration.Spec.NamespaceSelectors) != 0 {
+		return fmt.Errorf("namespaces and namespaceSelectors need to be specified in the waves of the migration")
+	}
+	if migration.Spec.PreExecRule != "" || migration.Spec.PostExecRule != "" {
+		return fmt.Errorf("preExecRule and postExecRule need to be specified in the waves of the migration")
+	}
+	names := make(map[string]bool)
+	for _, wave := range migration.Spec.Waves {
+		if wave.Name == "" {
+			return fmt.Errorf("name of a wave can't be empty")
+		}
+		if errs := validation.IsDNS1123Label(wave.Name); len(errs) != 0 {
+			return fmt.Errorf("invalid name for wave %v: %v", wave.Name, strings.Join(errs, ", "))
+		}
+		if name := getWaveMigrationName(migration, wave); len(name) > validation.DNS1123SubdomainMaxLength {
+			return fmt.Errorf("name %v of the migration for wave %v is longer than %v characters",
+				name, wave.Name, validation.DNS1123SubdomainMaxLength)
+		}
+		if names[wave.Name] {
+			return fmt.Errorf("wave %v is specified more than once", wave.Name)
+		}
+		names[wave.Name] = true
+		if len(wave.Namespaces) == 0 && len(wave.NamespaceSelectors) == 0 {
+			return fmt.Errorf("wave %v doesn't have any namespaces or namespaceSelectors", wave.Name)
+		}
+	}
+	return nil
+}
+
+// migrateWaves migrates the waves of the migration one after the other. A
+// migration is created for each wave, and the next wave is only started once
+// it has finished and, if the wave has a readiness gate, its applications are
+// ready on the destination cluster.
+func (m *MigrationController) migrateWaves(ctx context.Context, migration *stork_api.Migration) error {
+	if migration.Status.Stage == stork_api.MigrationStageInitial {
+		if err := validateWaves(migration); err != nil {
+			m.failWaves(migration, err.Error())
+			return m.updateMigrationCR(ctx, migration)
+		}
+		migration.Status.Waves = make([]*stork_api.MigrationWaveInfo, 0)
+		for _, wave := range migration.Spec.Waves {
+			migration.Status.Waves = append(migration.Status.Waves, &stork_api.MigrationWaveInfo{
+				Name:      wave.Name,
+				Migration: getWaveMigrationName(migration, wave),
+				Status:    stork_api.MigrationStatusPending,
+			})
+		}
+		migration.Status.Stage = stork_api.MigrationStageWaves
+		migration.Status.Status = stork_api.MigrationStatusInProgress
+		return m.updateMigrationCR(ctx, migration)
+	}
+
+	for i, wave := range migration.Spec.Waves {
+		if i >= len(migration.Status.Waves) {
+			break
+		}
+		waveInfo := migration.Status.Waves[i]
+		switch waveInfo.Status {
+		case stork_api.MigrationStatusSuccessful, stork_api.MigrationStatusPartialSuccess:
+			continue
+		case stork_api.MigrationStatusPending:
+			if err := m.startWave(migration, wave, waveInfo); err != nil {
+				return err
+			}
+			return m.updateMigrationCR(ctx, migration)
+		case stork_api.MigrationStatusInProgress:
+			if err := m.checkWave(ctx, migration, wave, waveInfo); err != nil {
+				return err
+			}
+			return m.updateMigrationCR(ctx, migration)
+		default:
+			return nil
+		}
+	}
+
+	migration.Status.Stage = stork_api.MigrationStageFinal
+	migration.Status.Status = stork_api.MigrationStatusSuccessful
+	for _, waveInfo := range migration.Status.Waves {
+		if waveInfo.Status == stork_api.MigrationStatusPartialSuccess {
+			migration.Status.Status = stork_api.MigrationStatusPartialSuccess
+		}
+	}
+	migration.Status.FinishTimestamp = metav1.Now()
+	m.recorder.Event(migration,
+		v1.EventTypeNormal,
+		string(migration.Status.Status),
+		fmt.Sprintf("Migrated %v waves", len(migration.Status.Waves)))
+	return m.updateMigrationCR(ctx, migration)
+}
+
+// startWave creates the migration for the wave
+func (m *MigrationController) startWave(
+	migration *stork_api.Migration,
+	wave stork_api.MigrationWave,
+	waveInfo *stork_api.MigrationWaveInfo,
+) error {
+	spec := migration.Spec
+	spec.Namespaces = wave.Namespaces
+	spec.NamespaceSelectors = wave.NamespaceSelectors
+	spec.PreExecRule = wave.PreExecRule
+	spec.PostExecRule = wave.PostExecRule
+	spec.Waves = nil
+	waveMigration := &stork_api.Migration{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        waveInfo.Migration,
+			Namespace:   migration.Namespace,
+			Annotations: make(map[string]string),
+			OwnerReferences: []metav1.OwnerReference{
+				{
+					Name:       migration.Name,
+					UID:        migration.UID,
+					Kind:       "Migration",
+					APIVersion: stork_api.SchemeGroupVersion.String(),
+				},
+			},
+		},
+		Spec: spec,
+	}
+	for k, v := range migration.Annotations {
+		if !waveExcludedAnnotations[k] {
+			waveMigration.Annotations[k] = v
+		}
+	}
+	log.MigrationLog(migration).Infof("Starting wave %v with migration %v", wave.Name, waveInfo.Migration)
+	if _, err := storkops.Instance().CreateMigration(waveMigration); err != nil && !errors.IsAlreadyExists(err) {
+		return err
+	}
+	waveInfo.Status = stork_api.MigrationStatusInProgress
+	waveInfo.Reason = fmt.Sprintf("Migrating namespaces with migration %v", waveInfo.Migration)
+	waveInfo.StartTimestamp = metav1.Now()
+	return nil
+}
+
+// checkWave updates the status of the wave from its migration and waits for
+// its applications to be ready on the destination cluster once it has
+// finished
+func (m *MigrationController) checkWave(
+	ctx context.Context,
+	migration *stork_api.Migration,
+	wave stork_api.MigrationWave,
+	waveInfo *stork_api.MigrationWaveInfo,
+) error {
+	waveMigration, err := storkops.Instance().GetMigration(waveInfo.Migration, migration.Namespace)
+	if err != nil {
+		if errors.IsNotFound(err) {
+			m.failWave(migration, waveInfo, fmt.Sprintf("Migration %v for the wave was deleted", waveInfo.Migration))
+			return nil
+		}
+		return err
+	}
+	m.updateWaveProgress(migration)
+	if waveMigration.Status.Stage != stork_api.MigrationStageFinal {
+		return nil
+	}
+	if waveMigration.Status.Status != stork_api.MigrationStatusSuccessful &&
+		waveMigration.Status.Status != stork_api.MigrationStatusPartialSuccess {
+		m.failWave(migration, waveInfo,
+			fmt.Sprintf("Migration %v for the wave finished with status %v", waveInfo.Migration, waveMigration.Status.Status))
+		return nil
+	}
+
+	if wave.Readiness != nil {
+		ready, reason, err := m.isWaveReady(ctx, waveMigration)
+		if err != nil {
+			return err
+		}
+		if !ready {
+			timeout := defaultWaveReadinessTimeout
+			if wave.Readiness.Timeout != nil {
+				timeout = wave.Readiness.Timeout.Duration
+			}
+			if time.Since(waveMigration.Status.FinishTimestamp.Time) > timeout {
+				m.failWave(migration, waveInfo,
+					fmt.Sprintf("Applications weren't ready on the destination cluster after %v: %v", timeout, reason))
+				return nil
+			}
+			waveInfo.Reason = fmt.Sprintf("Waiting for applications to be ready on the destination cluster: %v", reason)
+			log.MigrationLog(migration).Infof("Wave %v: %v", wave.Name, waveInfo.Reason)
+			return nil
+		}
+	}
+
+	waveInfo.Status = waveMigration.Status.Status
+	waveInfo.Reason = fmt.Sprintf("Namespaces were migrated by migration %v", waveInfo.Migration)
+	waveInfo.FinishTimestamp = metav1.Now()
+	m.recorder.Event(migration,
+		v1.EventTypeNormal,
+		string(waveInfo.Status),
+		fmt.Sprintf("Wave %v migrated successfully", wave.Name))
+	return nil
+}
+
+// isWaveReady checks that the deployments and statefulsets migrated by the
+// wave have all their replicas ready on the destination cluster. If the
+// applications aren't started they are scaled down on the destination
+// cluster, so the wave is only ready once all its resources were migrated.
+func (m *MigrationController) isWaveReady(ctx context.Context, waveMigration *stork_api.Migration) (bool, string, error) {
+	if waveMigration.Spec.StartApplications == nil || !*waveMigration.Spec.StartApplications {
+		for _, resource := range waveMigration.Status.Resources {
+			if resource.Status != stork_api.MigrationStatusSuccessful {
+				return false, fmt.Sprintf("%v %v/%v wasn't migrated: %v",
+					resource.Kind, resource.Namespace, resource.Name, resource.Reason), nil
+			}
+		}
+		return true, "", nil
+	}
+	remoteClient, err := m.getRemoteClient(waveMigration)
+	if err != nil {
+		return false, "", err
+	}
+	namespaces, err := m.getMigrationNamespaces(ctx, waveMigration)
+	if err != nil {
+		return false, "", err
+	}
+	for _, ns := range namespaces {
+		deployments, err := remoteClient.adminClient.AppsV1().Deployments(ns).List(ctx, metav1.ListOptions{})
+		if err != nil {
+			return false, "", err
+		}
+		for _, deployment := range deployments.Items {
+			replicas := int32(1)
+			if deployment.Spec.Replicas != nil {
+				replicas = *deployment.Spec.Replicas
+			}
+			if deployment.Status.ReadyReplicas < replicas {
+				return false, fmt.Sprintf("Deployment %v/%v has %v/%v replicas ready",
+					ns, deployment.Name, deployment.Status.ReadyReplicas, replicas), nil
+			}
+		}
+		statefulSets, err := remoteClient.adminClient.AppsV1().StatefulSets(ns).List(ctx, metav1.ListOptions{})
+		if err != nil {
+			return false, "", err
+		}
+		for _, statefulSet := range statefulSets.Items {
+			replicas := int32(1)
+			if statefulSet.Spec.Replicas != nil {
+				replicas = *statefulSet.Spec.Replicas
+			}
+			if statefulSet.Status.ReadyReplicas < replicas {
+				return false, fmt.Sprintf("StatefulSet %v/%v has %v/%v replicas ready",
+					ns, statefulSet.Name, statefulSet.Status.ReadyReplicas, replicas), nil
+			}
+		}
+	}
+	return true, "", nil
+}
+
+// updateWaveProgress collects the volumes and resources migrated by the waves
+// in the status of the migration
+func (m *MigrationController) updateWaveProgress(migration *stork_api.Migration) {
+	volumes := make([]*stork_api.MigrationVolumeInfo, 0)
+	resources := make([]*stork_api.MigrationResourceInfo, 0)
+	for _, waveInfo := range migration.Status.Waves {
+		if waveInfo.Status == stork_api.MigrationStatusPending {
+			continue
+		}
+		waveMigration, err := storkops.Instance().GetMigration(waveInfo.Migration, migration.Namespace)
+		if err != nil {
+			log.MigrationLog(migration).Warnf("Error getting migration %v for wave %v: %v", waveInfo.Migration, waveInfo.Name, err)
+			continue
+		}
+		volumes = append(volumes, waveMigration.Status.Volumes...)
+		resources = append(resources, waveMigration.Status.Resources...)
+		if !waveMigration.Status.VolumeMigrationFinishTimestamp.IsZero() {
+			migration.Status.VolumeMigrationFinishTimestamp = waveMigration.Status.VolumeMigrationFinishTimestamp
+		}
+		if !waveMigration.Status.ResourceMigrationFinishTimestamp.IsZero() {
+			migration.Status.ResourceMigrationFinishTimestamp = waveMigration.Status.ResourceMigrationFinishTimestamp
+		}
+	}
+	migration.Status.Volumes = volumes
+	migration.Status.Resources = resources
+}
+
+// failWave records the failure of a wave and fails the migration so that the
+// remaining waves aren't started
+func (m *MigrationController) failWave(
+	migration *stork_api.Migration,
+	waveInfo *stork_api.MigrationWaveInfo,
+	reason string,
+) {
+	waveInfo.Status = stork_api.MigrationStatusFailed
+	waveInfo.Reason = reason
+	waveInfo.FinishTimestamp = metav1.Now()
+	m.failWaves(migration, fmt.Sprintf("Wave %v failed: %v", waveInfo.Name, reason))
+}
+
+// failWaves fails the migration, the caller updates it
+func (m *MigrationController) failWaves(migration *stork_api.Migration, reason string) {
+	log.MigrationLog(migration).Errorf(reason)
+	m.recorder.Event(migration,
+		v1.EventTypeWarning,
+		string(stork_api.MigrationStatusFailed),
+		reason)
+	migration.Status.Stage = stork_api.MigrationStageFinal
+	migration.Status.Status = stork_api.MigrationStatusFailed
+	migration.Status.FinishTimestamp = metav1.Now()
+}
+
+func getWaveMigrationName(migration *stork_api.Migration, wave stork_api.MigrationWave) string {
+	return migration.Name + "-" + wave.Name
+}
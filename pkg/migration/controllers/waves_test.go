@@ -0,0 +1,232 @@
+//go:build unittest
+// +build unittest
+
+package controllers
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	stork_api "github.com/libopenstorage/stork/pkg/apis/stork/v1alpha1"
+	fakeclient "github.com/libopenstorage/stork/pkg/client/clientset/versioned/fake"
+	"github.com/libopenstorage/stork/pkg/utils"
+	storkops "github.com/portworx/sched-ops/k8s/stork"
+	"github.com/stretchr/testify/require"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func newTestWaveMigration(startApplications bool) (*stork_api.Migration, stork_api.MigrationWave) {
+	wave := stork_api.MigrationWave{
+		Name:       "wave",
+		Namespaces: []string{"ns"},
+		Readiness:  &stork_api.MigrationWaveReadiness{},
+	}
+	migration := &stork_api.Migration{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "migration",
+			Namespace: "ns",
+			Annotations: map[string]string{
+				"app":                                "value",
+				utils.MigrationDriftReportAnnotation: "true",
+				StorkMigrationScheduleName:           "schedule",
+				v1.LastAppliedConfigAnnotation:       "{}",
+			},
+		},
+		Spec: stork_api.MigrationSpec{
+			ClusterPair:       "clusterpair",
+			StartApplications: &startApplications,
+			Waves:             []stork_api.MigrationWave{wave},
+		},
+	}
+	return migration, wave
+}
+
+func setupTestWaveClients() {
+	storkops.SetInstance(storkops.New(fake.NewSimpleClientset(), fakeclient.NewSimpleClientset(), nil))
+}
+
+func TestValidateWaves(t *testing.T) {
+	tests := []struct {
+		name          string
+		update        func(migration *stork_api.Migration)
+		expectedError string
+	}{
+		{
+			name:   "valid",
+			update: func(migration *stork_api.Migration) {},
+		},
+		{
+			name: "namespaces outside waves",
+			update: func(migration *stork_api.Migration) {
+				migration.Spec.Namespaces = []string{"ns"}
+			},
+			expectedError: "namespaces and namespaceSelectors",
+		},
+		{
+			name: "empty name",
+			update: func(migration *stork_api.Migration) {
+				migration.Spec.Waves[0].Name = ""
+			},
+			expectedError: "can't be empty",
+		},
+		{
+			name: "invalid name",
+			update: func(migration *stork_api.Migration) {
+				migration.Spec.Waves[0].Name = "Wave_1"
+			},
+			expectedError: "invalid name for wave Wave_1",
+		},
+		{
+			name: "name too long for a label",
+			update: func(migration *stork_api.Migration) {
+				migration.Spec.Waves[0].Name = strings.Repeat("w", 64)
+			},
+			expectedError: "invalid name for wave",
+		},
+		{
+			name: "migration name too long",
+			update: func(migration *stork_api.Migration) {
+				migration.Name = strings.Repeat("m", 250)
+			},
+			expectedError: "longer than 253 characters",
+		},
+		{
+			name: "duplicate wave",
+			update: func(migration *stork_api.Migration) {
+				migration.Spec.Waves = append(migration.Spec.Waves, migration.Spec.Waves[0])
+			},
+			expectedError: "specified more than once",
+		},
+		{
+			name: "wave without namespaces",
+			update: func(migration *stork_api.Migration) {
+				migration.Spec.Waves[0].Namespaces = nil
+			},
+			expectedError: "doesn't have any namespaces",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			migration, _ := newTestWaveMigration(true)
+			test.update(migration)
+			err := validateWaves(migration)
+			if test.expectedError == "" {
+				require.NoError(t, err)
+				return
+			}
+			require.Error(t, err)
+			require.Contains(t, err.Error(), test.expectedError)
+		})
+	}
+}
+
+func TestStartWave(t *testing.T) {
+	setupTestWaveClients()
+	migration, wave := newTestWaveMigration(true)
+	waveInfo := &stork_api.MigrationWaveInfo{
+		Name:      wave.Name,
+		Migration: getWaveMigrationName(migration, wave),
+		Status:    stork_api.MigrationStatusPending,
+	}
+	m := newTestMigrationController(t)
+	require.NoError(t, m.startWave(migration, wave, waveInfo))
+	require.Equal(t, stork_api.MigrationStatusInProgress, waveInfo.Status)
+
+	waveMigration, err := storkops.Instance().GetMigration(waveInfo.Migration, migration.Namespace)
+	require.NoError(t, err)
+	require.Equal(t, map[string]string{"app": "value"}, waveMigration.Annotations, "Annotations of the migration not filtered")
+	require.Equal(t, []string{"ns"}, waveMigration.Spec.Namespaces)
+	require.Empty(t, waveMigration.Spec.Waves)
+}
+
+func TestCheckWaveApplicationsNotStarted(t *testing.T) {
+	setupTestWaveClients()
+	migration, wave := newTestWaveMigration(false)
+	waveInfo := &stork_api.MigrationWaveInfo{
+		Name:      wave.Name,
+		Migration: getWaveMigrationName(migration, wave),
+		Status:    stork_api.MigrationStatusInProgress,
+	}
+	migration.Status.Waves = []*stork_api.MigrationWaveInfo{waveInfo}
+	resource := &stork_api.MigrationResourceInfo{
+		Name:      "app",
+		Namespace: "ns",
+		Status:    stork_api.MigrationStatusFailed,
+		Reason:    "error",
+	}
+	resource.Kind = "Deployment"
+	startApplications := false
+	waveMigration := &stork_api.Migration{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      waveInfo.Migration,
+			Namespace: migration.Namespace,
+		},
+		Spec: stork_api.MigrationSpec{
+			ClusterPair:       "clusterpair",
+			StartApplications: &startApplications,
+		},
+		Status: stork_api.MigrationStatus{
+			Stage:           stork_api.MigrationStageFinal,
+			Status:          stork_api.MigrationStatusPartialSuccess,
+			FinishTimestamp: metav1.Now(),
+			Resources:       []*stork_api.MigrationResourceInfo{resource},
+		},
+	}
+	waveMigration, err := storkops.Instance().CreateMigration(waveMigration)
+	require.NoError(t, err)
+
+	m := newTestMigrationController(t)
+	require.NoError(t, m.checkWave(context.TODO(), migration, wave, waveInfo))
+	require.Equal(t, stork_api.MigrationStatusInProgress, waveInfo.Status, "Wave finished with resources not migrated")
+	require.Contains(t, waveInfo.Reason, "Deployment ns/app wasn't migrated: error")
+
+	waveMigration.Status.FinishTimestamp = metav1.NewTime(waveMigration.Status.FinishTimestamp.Add(-defaultWaveReadinessTimeout))
+	_, err = storkops.Instance().UpdateMigration(waveMigration)
+	require.NoError(t, err)
+	require.NoError(t, m.checkWave(context.TODO(), migration, wave, waveInfo))
+	require.Equal(t, stork_api.MigrationStatusFailed, waveInfo.Status)
+	require.Equal(t, stork_api.MigrationStatusFailed, migration.Status.Status)
+}
+
+func TestCheckWaveApplicationsNotStartedReady(t *testing.T) {
+	setupTestWaveClients()
+	migration, wave := newTestWaveMigration(false)
+	waveInfo := &stork_api.MigrationWaveInfo{
+		Name:      wave.Name,
+		Migration: getWaveMigrationName(migration, wave),
+		Status:    stork_api.MigrationStatusInProgress,
+	}
+	migration.Status.Waves = []*stork_api.MigrationWaveInfo{waveInfo}
+	resource := &stork_api.MigrationResourceInfo{
+		Name:      "app",
+		Namespace: "ns",
+		Status:    stork_api.MigrationStatusSuccessful,
+	}
+	resource.Kind = "Deployment"
+	startApplications := false
+	_, err := storkops.Instance().CreateMigration(&stork_api.Migration{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      waveInfo.Migration,
+			Namespace: migration.Namespace,
+		},
+		Spec: stork_api.MigrationSpec{
+			ClusterPair:       "clusterpair",
+			StartApplications: &startApplications,
+		},
+		Status: stork_api.MigrationStatus{
+			Stage:           stork_api.MigrationStageFinal,
+			Status:          stork_api.MigrationStatusSuccessful,
+			FinishTimestamp: metav1.Now(),
+			Resources:       []*stork_api.MigrationResourceInfo{resource},
+		},
+	})
+	require.NoError(t, err)
+
+	m := newTestMigrationController(t)
+	require.NoError(t, m.checkWave(context.TODO(), migration, wave, waveInfo))
+	require.Equal(t, stork_api.MigrationStatusSuccessful, waveInfo.Status)
+	require.Len(t, migration.Status.Resources, 1)
+}
@@ -2,6 +2,7 @@ package migration
 
 import (
 	"fmt"
+	"os"
 
 	"github.com/libopenstorage/stork/drivers/volume"
 	"github.com/libopenstorage/stork/pkg/migration/controllers"
@@ -21,9 +22,9 @@ type Migration struct {
 }
 
 // Init init
-func (m *Migration) Init(mgr manager.Manager, migrationAdminNamespace string, migrationMaxThreads int) error {
+func (m *Migration) Init(mgr manager.Manager, migrationAdminNamespace string, migrationMaxThreads int, stopChannel chan os.Signal) error {
 	m.clusterPairController = controllers.NewClusterPair(mgr, m.Driver, m.Recorder)
-	err := m.clusterPairController.Init(mgr)
+	err := m.clusterPairController.Init(mgr, stopChannel)
 	if err != nil {
 		return fmt.Errorf("error initializing clusterpair controller: %v", err)
 	}
@@ -34,7 +35,7 @@ func (m *Migration) Init(mgr manager.Manager, migrationAdminNamespace string, mi
 		return fmt.Errorf("error initializing migration controller: %v", err)
 	}
 
-	m.migrationScheduleController = controllers.NewMigrationSchedule(mgr, m.Driver, m.Recorder)
+	m.migrationScheduleController = controllers.NewMigrationSchedule(mgr, m.Driver, m.Recorder, m.migrationController)
 	err = m.migrationScheduleController.Init(mgr)
 	if err != nil {
 		return fmt.Errorf("error initializing migration schedule controller: %v", err)
@@ -1,5 +1,18 @@
 package common
 
+import (
+	"time"
+)
+
+const (
+	// LockModeCompliance doesn't let anyone delete or overwrite a locked
+	// object before its retention expires
+	LockModeCompliance = "COMPLIANCE"
+	// LockModeGovernance lets users with special permissions delete or
+	// overwrite a locked object before its retention expires
+	LockModeGovernance = "GOVERNANCE"
+)
+
 // ObjLockInfo struct
 type ObjLockInfo struct {
 	LockMode             string
@@ -7,3 +20,21 @@ type ObjLockInfo struct {
 	RetentionPeriodDays  int64
 	RetentionPeriodYears int64
 }
+
+// HasRetention returns true if objects written to the bucket are retained
+// in compliance or governance mode
+func (o *ObjLockInfo) HasRetention() bool {
+	if !o.LockEnabled {
+		return false
+	}
+	if o.LockMode != LockModeCompliance && o.LockMode != LockModeGovernance {
+		return false
+	}
+	return o.RetentionPeriodDays != 0 || o.RetentionPeriodYears != 0
+}
+
+// RetainUntil returns the time until which an object written at start is
+// retained by the default retention of the bucket
+func (o *ObjLockInfo) RetainUntil(start time.Time) time.Time {
+	return start.AddDate(int(o.RetentionPeriodYears), 0, int(o.RetentionPeriodDays))
+}
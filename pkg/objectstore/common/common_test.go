@@ -0,0 +1,31 @@
+//go:build unittest
+// +build unittest
+
+package common
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestHasRetention(t *testing.T) {
+	require.False(t, (&ObjLockInfo{}).HasRetention(), "Expected no retention without lock")
+	require.False(t, (&ObjLockInfo{LockEnabled: true, LockMode: LockModeCompliance}).HasRetention(),
+		"Expected no retention without retention period")
+	require.False(t, (&ObjLockInfo{LockEnabled: true, RetentionPeriodDays: 7}).HasRetention(),
+		"Expected no retention without lock mode")
+	require.True(t, (&ObjLockInfo{LockEnabled: true, LockMode: LockModeGovernance, RetentionPeriodDays: 7}).HasRetention(),
+		"Expected retention in governance mode")
+	require.True(t, (&ObjLockInfo{LockEnabled: true, LockMode: LockModeCompliance, RetentionPeriodYears: 1}).HasRetention(),
+		"Expected retention in compliance mode")
+}
+
+func TestRetainUntil(t *testing.T) {
+	start := time.Date(2023, 1, 31, 12, 0, 0, 0, time.UTC)
+	objLockInfo := &ObjLockInfo{LockEnabled: true, LockMode: LockModeCompliance, RetentionPeriodDays: 30}
+	require.Equal(t, time.Date(2023, 3, 2, 12, 0, 0, 0, time.UTC), objLockInfo.RetainUntil(start))
+	objLockInfo = &ObjLockInfo{LockEnabled: true, LockMode: LockModeCompliance, RetentionPeriodYears: 2}
+	require.Equal(t, time.Date(2025, 1, 31, 12, 0, 0, 0, time.UTC), objLockInfo.RetainUntil(start))
+}
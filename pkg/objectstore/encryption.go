@@ -0,0 +1,70 @@
+package objectstore
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+
+	stork_api "github.com/libopenstorage/stork/pkg/apis/stork/v1alpha1"
+	"github.com/libopenstorage/stork/pkg/crypto"
+	"github.com/libopenstorage/stork/pkg/log"
+)
+
+// GetEncryptionKeyring returns the keys that can be used to decrypt objects
+// from the backup location
+func GetEncryptionKeyring(backupLocation *stork_api.BackupLocation) *crypto.Keyring {
+	return &crypto.Keyring{
+		Default: backupLocation.Location.EncryptionV2Key,
+		Keys:    backupLocation.GetEncryptionKeys(),
+	}
+}
+
+// EncryptData encrypts data uploaded to the backup location with its active
+// encryption key. The ID of the key is recorded in the data so that it can
+// still be decrypted after the key is rotated.
+func EncryptData(backupLocation *stork_api.BackupLocation, data []byte) ([]byte, error) {
+	if backupLocation.Location.EncryptionKey != "" {
+		return nil, fmt.Errorf("EncryptionKey is deprecated, use EncryptionKeyV2 instead")
+	}
+	keyID, encryptionKey, err := backupLocation.GetActiveEncryptionKey()
+	if err != nil {
+		return nil, err
+	}
+	if encryptionKey == "" {
+		return data, nil
+	}
+	var buf bytes.Buffer
+	writer, err := crypto.NewKeyEncryptWriter(&buf, keyID, encryptionKey)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := writer.Write(data); err != nil {
+		return nil, err
+	}
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// DecryptData decrypts data downloaded from the backup location with the
+// keyring of the location. Data that fails to decrypt is returned as is so
+// that objects uploaded before a key was set can still be read.
+func DecryptData(backupLocation *stork_api.BackupLocation, data []byte) ([]byte, error) {
+	if backupLocation.Location.EncryptionKey != "" {
+		return nil, fmt.Errorf("EncryptionKey is deprecated, use EncryptionKeyV2 instead")
+	}
+	keyring := GetEncryptionKeyring(backupLocation)
+	if keyring.IsEmpty() {
+		return data, nil
+	}
+	reader, err := crypto.NewKeyringDecryptReader(bytes.NewReader(data), keyring)
+	if err == nil {
+		var decryptData []byte
+		if decryptData, err = io.ReadAll(reader); err == nil {
+			return decryptData, nil
+		}
+	}
+	log.BackupLocationLog(backupLocation).Debugf("Decrypt failed: %v, returning data directly", err)
+	return data, nil
+}
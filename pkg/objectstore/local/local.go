@@ -0,0 +1,46 @@
+package local
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	stork_api "github.com/libopenstorage/stork/pkg/apis/stork/v1alpha1"
+	"github.com/libopenstorage/stork/pkg/objectstore/common"
+	"github.com/sirupsen/logrus"
+	"gocloud.dev/blob"
+	"gocloud.dev/blob/fileblob"
+)
+
+func getBucketPath(backupLocation *stork_api.BackupLocation) (string, error) {
+	if !filepath.IsAbs(backupLocation.Location.Path) {
+		return "", fmt.Errorf("path for local backup location %v/%v should be an absolute path, got %q",
+			backupLocation.Namespace, backupLocation.Name, backupLocation.Location.Path)
+	}
+	return filepath.Clean(backupLocation.Location.Path), nil
+}
+
+// GetBucket gets a reference to the bucket for that backup location. The
+// bucket is the directory given by the location path.
+func GetBucket(backupLocation *stork_api.BackupLocation) (*blob.Bucket, error) {
+	bucketPath, err := getBucketPath(backupLocation)
+	if err != nil {
+		return nil, err
+	}
+	return fileblob.OpenBucket(bucketPath, nil)
+}
+
+// CreateBucket creates the directory for the bucket location
+func CreateBucket(backupLocation *stork_api.BackupLocation) error {
+	bucketPath, err := getBucketPath(backupLocation)
+	if err != nil {
+		return err
+	}
+	return os.MkdirAll(bucketPath, 0755)
+}
+
+// GetObjLockInfo fetches the object lock configuration of a bucket
+func GetObjLockInfo(backupLocation *stork_api.BackupLocation) (*common.ObjLockInfo, error) {
+	logrus.Infof("object lock is not supported for local backup location")
+	return &common.ObjLockInfo{}, nil
+}
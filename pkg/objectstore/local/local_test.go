@@ -0,0 +1,67 @@
+//go:build unittest
+// +build unittest
+
+package local
+
+import (
+	"context"
+	"io"
+	"path/filepath"
+	"testing"
+
+	stork_api "github.com/libopenstorage/stork/pkg/apis/stork/v1alpha1"
+	"github.com/stretchr/testify/require"
+	"gocloud.dev/blob"
+)
+
+func TestBucket(t *testing.T) {
+	backupLocation := &stork_api.BackupLocation{
+		Location: stork_api.BackupLocationItem{
+			Type: stork_api.BackupLocationLocal,
+			Path: filepath.Join(t.TempDir(), "backups"),
+		},
+	}
+	_, err := GetBucket(backupLocation)
+	require.Error(t, err, "Expected error before creating bucket")
+	require.NoError(t, CreateBucket(backupLocation), "Error creating bucket")
+	require.NoError(t, CreateBucket(backupLocation), "Error creating existing bucket")
+
+	bucket, err := GetBucket(backupLocation)
+	require.NoError(t, err, "Error getting bucket")
+	defer bucket.Close()
+	require.NoError(t, bucket.WriteAll(context.TODO(), "ns/backup1/uid1/metadata.json", []byte("{}"), nil))
+	require.NoError(t, bucket.WriteAll(context.TODO(), "ns/backup2/uid2/metadata.json", []byte("{}"), nil))
+
+	iterator := bucket.List(&blob.ListOptions{
+		Prefix:    "ns/",
+		Delimiter: "/",
+	})
+	keys := make([]string, 0)
+	for {
+		object, err := iterator.Next(context.TODO())
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err, "Error listing objects")
+		require.True(t, object.IsDir, "Expected only directories")
+		keys = append(keys, object.Key)
+	}
+	require.Equal(t, []string{"ns/backup1/", "ns/backup2/"}, keys)
+
+	require.NoError(t, bucket.Delete(context.TODO(), "ns/backup1/uid1/metadata.json"))
+	exists, err := bucket.Exists(context.TODO(), "ns/backup1/uid1/metadata.json")
+	require.NoError(t, err, "Error checking object")
+	require.False(t, exists, "Object wasn't deleted")
+}
+
+func TestRelativePath(t *testing.T) {
+	backupLocation := &stork_api.BackupLocation{
+		Location: stork_api.BackupLocationItem{
+			Type: stork_api.BackupLocationLocal,
+			Path: "backups",
+		},
+	}
+	require.Error(t, CreateBucket(backupLocation), "Expected error for relative path")
+	_, err := GetBucket(backupLocation)
+	require.Error(t, err, "Expected error for relative path")
+}
@@ -1,11 +1,188 @@
 package nfs
 
 import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
 	stork_api "github.com/libopenstorage/stork/pkg/apis/stork/v1alpha1"
 	"github.com/libopenstorage/stork/pkg/objectstore/common"
 	"github.com/sirupsen/logrus"
+	"gocloud.dev/blob"
+	"gocloud.dev/blob/fileblob"
+)
+
+const (
+	// DefaultMountRoot is the directory under which the NFS exports of the
+	// backup locations are expected to be mounted, usually in the pod spec
+	DefaultMountRoot = "/mnt/nfs-target"
+	// MountRootEnvVar can be used to override the default mount root
+	MountRootEnvVar = "NFS_MOUNT_ROOT"
+	// SelfMountEnvVar can be set to true to have stork mount the exports
+	// that aren't already mounted, which needs the nfs client utilities and
+	// the SYS_ADMIN capability in the stork container
+	SelfMountEnvVar = "NFS_SELF_MOUNT"
+
+	mountTimeout  = 2 * time.Minute
+	mountInfoPath = "/proc/self/mountinfo"
+)
+
+var (
+	// mountExport mounts the NFS export at the target path, it is replaced
+	// in the tests
+	mountExport = func(source, target, options string) error {
+		ctx, cancel := context.WithTimeout(context.Background(), mountTimeout)
+		defer cancel()
+		args := []string{"-t", "nfs"}
+		if options != "" {
+			args = append(args, "-o", options)
+		}
+		args = append(args, source, target)
+		if output, err := exec.CommandContext(ctx, "mount", args...).CombinedOutput(); err != nil {
+			return fmt.Errorf("%v: %s", err, strings.TrimSpace(string(output)))
+		}
+		return nil
+	}
+	// isMountPoint checks if a filesystem is mounted at the path, it is
+	// replaced in the tests
+	isMountPoint = func(path string) (bool, error) {
+		file, err := os.Open(mountInfoPath)
+		if err != nil {
+			return false, err
+		}
+		defer file.Close()
+		scanner := bufio.NewScanner(file)
+		for scanner.Scan() {
+			// The fifth field is the mount point, with spaces escaped
+			fields := strings.Fields(scanner.Text())
+			if len(fields) > 4 && strings.ReplaceAll(fields[4], "\\040", " ") == path {
+				return true, nil
+			}
+		}
+		return false, scanner.Err()
+	}
+
+	// mountLocks has a lock for each mount path so that an export is only
+	// mounted once
+	mountLocks sync.Map
 )
 
+func getMountRoot() string {
+	if mountRoot := os.Getenv(MountRootEnvVar); mountRoot != "" {
+		return mountRoot
+	}
+	return DefaultMountRoot
+}
+
+func isSelfMountEnabled() bool {
+	enabled, _ := strconv.ParseBool(os.Getenv(SelfMountEnvVar))
+	return enabled
+}
+
+// isSubPath checks that the path is under the parent directory
+func isSubPath(parent, path string) bool {
+	rel, err := filepath.Rel(parent, path)
+	return err == nil && rel != "." && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// GetMountPath returns the path where the export of the backup location is
+// expected to be mounted, <mount root>/<server address>/<sub path>
+func GetMountPath(backupLocation *stork_api.BackupLocation) (string, error) {
+	if backupLocation.Location.NFSConfig == nil {
+		return "", fmt.Errorf("nfsConfig not set for backup location %v/%v",
+			backupLocation.Namespace, backupLocation.Name)
+	}
+	mountRoot := getMountRoot()
+	mountPath := filepath.Join(
+		mountRoot,
+		backupLocation.Location.NFSConfig.ServerAddr,
+		backupLocation.Location.NFSConfig.SubPath)
+	if !isSubPath(mountRoot, mountPath) {
+		return "", fmt.Errorf("nfs mount path %v for backup location %v/%v is not under %v",
+			mountPath, backupLocation.Namespace, backupLocation.Name, mountRoot)
+	}
+	return mountPath, nil
+}
+
+func getBucketPath(backupLocation *stork_api.BackupLocation) (string, error) {
+	mountPath, err := GetMountPath(backupLocation)
+	if err != nil {
+		return "", err
+	}
+	if isSelfMountEnabled() {
+		if err := ensureMounted(backupLocation, mountPath); err != nil {
+			return "", err
+		}
+	}
+	info, err := os.Stat(mountPath)
+	if err != nil {
+		return "", fmt.Errorf("nfs export for backup location %v/%v is not mounted at %v: %v",
+			backupLocation.Namespace, backupLocation.Name, mountPath, err)
+	}
+	if !info.IsDir() {
+		return "", fmt.Errorf("nfs mount path %v is not a directory", mountPath)
+	}
+	bucketPath := filepath.Join(mountPath, backupLocation.Location.Path)
+	if bucketPath != mountPath && !isSubPath(mountPath, bucketPath) {
+		return "", fmt.Errorf("path %v of backup location %v/%v is not under the nfs mount path %v",
+			backupLocation.Location.Path, backupLocation.Namespace, backupLocation.Name, mountPath)
+	}
+	return bucketPath, nil
+}
+
+// ensureMounted mounts the export of the backup location at the mount path
+// unless it has already been mounted there, by stork or in the pod spec
+func ensureMounted(backupLocation *stork_api.BackupLocation, mountPath string) error {
+	lock, _ := mountLocks.LoadOrStore(mountPath, &sync.Mutex{})
+	lock.(*sync.Mutex).Lock()
+	defer lock.(*sync.Mutex).Unlock()
+	mounted, err := isMountPoint(mountPath)
+	if err != nil {
+		return fmt.Errorf("error checking if nfs export is mounted at %v: %v", mountPath, err)
+	}
+	if mounted {
+		return nil
+	}
+	if err := os.MkdirAll(mountPath, 0755); err != nil {
+		return fmt.Errorf("error creating nfs mount path %v: %v", mountPath, err)
+	}
+	nfsConfig := backupLocation.Location.NFSConfig
+	source := nfsConfig.ServerAddr + ":/" + strings.TrimPrefix(nfsConfig.SubPath, "/")
+	logrus.Infof("Mounting nfs export %v at %v for backup location %v/%v",
+		source, mountPath, backupLocation.Namespace, backupLocation.Name)
+	if err := mountExport(source, mountPath, nfsConfig.MountOptions); err != nil {
+		return fmt.Errorf("error mounting nfs export %v for backup location %v/%v: %v",
+			source, backupLocation.Namespace, backupLocation.Name, err)
+	}
+	return nil
+}
+
+// GetBucket gets a reference to the bucket for that backup location. The
+// bucket is the directory with the location path under the mounted export.
+func GetBucket(backupLocation *stork_api.BackupLocation) (*blob.Bucket, error) {
+	bucketPath, err := getBucketPath(backupLocation)
+	if err != nil {
+		return nil, err
+	}
+	return fileblob.OpenBucket(bucketPath, nil)
+}
+
+// CreateBucket creates the directory for the bucket under the mounted export
+func CreateBucket(backupLocation *stork_api.BackupLocation) error {
+	bucketPath, err := getBucketPath(backupLocation)
+	if err != nil {
+		return err
+	}
+	return os.MkdirAll(bucketPath, 0755)
+}
+
 // GetObjLockInfo fetches the object lock configuration of a bucket
 func GetObjLockInfo(backupLocation *stork_api.BackupLocation) (*common.ObjLockInfo, error) {
 	logrus.Infof("object lock is not supported for nfs server")
@@ -0,0 +1,188 @@
+//go:build unittest
+// +build unittest
+
+package nfs
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	stork_api "github.com/libopenstorage/stork/pkg/apis/stork/v1alpha1"
+	"github.com/stretchr/testify/require"
+)
+
+func newBackupLocation() *stork_api.BackupLocation {
+	return &stork_api.BackupLocation{
+		Location: stork_api.BackupLocationItem{
+			Type: stork_api.BackupLocationNFS,
+			Path: "bucket",
+			NFSConfig: &stork_api.NFSConfig{
+				ServerAddr: "10.0.0.1",
+				SubPath:    "/export",
+			},
+		},
+	}
+}
+
+// fakeMounts replaces the mount helpers and returns the mounts done
+func fakeMounts(t *testing.T, mountErr error) map[string]string {
+	mounts := make(map[string]string)
+	var lock sync.Mutex
+	origMountExport := mountExport
+	origIsMountPoint := isMountPoint
+	t.Cleanup(func() {
+		mountExport = origMountExport
+		isMountPoint = origIsMountPoint
+	})
+	mountExport = func(source, target, options string) error {
+		if mountErr != nil {
+			return mountErr
+		}
+		// Take some time like a real mount so that concurrent mounts
+		// would overlap
+		time.Sleep(10 * time.Millisecond)
+		lock.Lock()
+		defer lock.Unlock()
+		if _, ok := mounts[target]; ok {
+			return fmt.Errorf("%v already mounted", target)
+		}
+		mounts[target] = source
+		return nil
+	}
+	isMountPoint = func(path string) (bool, error) {
+		lock.Lock()
+		defer lock.Unlock()
+		_, ok := mounts[path]
+		return ok, nil
+	}
+	return mounts
+}
+
+func TestBucket(t *testing.T) {
+	mountRoot := t.TempDir()
+	t.Setenv(MountRootEnvVar, mountRoot)
+	t.Setenv(SelfMountEnvVar, "true")
+	backupLocation := newBackupLocation()
+
+	fakeMounts(t, fmt.Errorf("mount failed"))
+	_, err := GetBucket(backupLocation)
+	require.Error(t, err, "Expected error when export can't be mounted")
+	require.Error(t, CreateBucket(backupLocation), "Expected error when export can't be mounted")
+
+	mounts := fakeMounts(t, nil)
+	mountPath, err := GetMountPath(backupLocation)
+	require.NoError(t, err, "Error getting mount path")
+	require.Equal(t, filepath.Join(mountRoot, "10.0.0.1", "export"), mountPath)
+	require.NoError(t, CreateBucket(backupLocation), "Error creating bucket")
+	require.Equal(t, map[string]string{mountPath: "10.0.0.1:/export"}, mounts, "Export not mounted")
+	require.NoError(t, CreateBucket(backupLocation), "Error creating existing bucket")
+
+	bucket, err := GetBucket(backupLocation)
+	require.NoError(t, err, "Error getting bucket")
+	defer bucket.Close()
+	require.NoError(t, bucket.WriteAll(context.TODO(), "ns/backup/uid/metadata.json", []byte("{}"), nil))
+	_, err = os.Stat(filepath.Join(mountPath, "bucket", "ns", "backup", "uid", "metadata.json"))
+	require.NoError(t, err, "Object not written under the mount path")
+	data, err := bucket.ReadAll(context.TODO(), "ns/backup/uid/metadata.json")
+	require.NoError(t, err, "Error reading object")
+	require.Equal(t, []byte("{}"), data)
+}
+
+func TestMountedExport(t *testing.T) {
+	mountRoot := t.TempDir()
+	t.Setenv(MountRootEnvVar, mountRoot)
+	t.Setenv(SelfMountEnvVar, "true")
+	backupLocation := newBackupLocation()
+	mountPath, err := GetMountPath(backupLocation)
+	require.NoError(t, err, "Error getting mount path")
+	require.NoError(t, os.MkdirAll(mountPath, 0755), "Error creating mount path")
+
+	// Exports that are already mounted, for example in the pod spec,
+	// shouldn't be mounted again
+	mounts := fakeMounts(t, fmt.Errorf("mount failed"))
+	mounts[mountPath] = "10.0.0.1:/export"
+	require.NoError(t, CreateBucket(backupLocation), "Error creating bucket on mounted export")
+}
+
+func TestSelfMountDisabled(t *testing.T) {
+	mountRoot := t.TempDir()
+	t.Setenv(MountRootEnvVar, mountRoot)
+	backupLocation := newBackupLocation()
+	mounts := fakeMounts(t, nil)
+	require.Error(t, CreateBucket(backupLocation), "Expected error when export isn't mounted in the pod spec")
+	require.Empty(t, mounts, "Export mounted without self mount enabled")
+
+	mountPath, err := GetMountPath(backupLocation)
+	require.NoError(t, err, "Error getting mount path")
+	require.NoError(t, os.MkdirAll(mountPath, 0755), "Error creating mount path")
+	require.NoError(t, CreateBucket(backupLocation), "Error creating bucket on export mounted in the pod spec")
+	require.Empty(t, mounts, "Export mounted without self mount enabled")
+}
+
+func TestConcurrentMounts(t *testing.T) {
+	mountRoot := t.TempDir()
+	t.Setenv(MountRootEnvVar, mountRoot)
+	t.Setenv(SelfMountEnvVar, "true")
+	backupLocation := newBackupLocation()
+	mounts := fakeMounts(t, nil)
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 10)
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs <- CreateBucket(backupLocation)
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		require.NoError(t, err, "Error creating bucket concurrently")
+	}
+	require.Len(t, mounts, 1, "Export mounted more than once")
+}
+
+func TestPathOutsideMountRoot(t *testing.T) {
+	mountRoot := t.TempDir()
+	t.Setenv(MountRootEnvVar, mountRoot)
+	t.Setenv(SelfMountEnvVar, "true")
+	testCases := []struct {
+		name       string
+		serverAddr string
+		subPath    string
+		path       string
+	}{
+		{name: "sub path", serverAddr: "10.0.0.1", subPath: "../../etc", path: "bucket"},
+		{name: "server address", serverAddr: "..", subPath: "", path: "bucket"},
+		{name: "empty", serverAddr: "", subPath: "", path: "bucket"},
+		{name: "location path", serverAddr: "10.0.0.1", subPath: "/export", path: "../../../etc"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mounts := fakeMounts(t, nil)
+			backupLocation := newBackupLocation()
+			backupLocation.Location.NFSConfig.ServerAddr = tc.serverAddr
+			backupLocation.Location.NFSConfig.SubPath = tc.subPath
+			backupLocation.Location.Path = tc.path
+			require.Error(t, CreateBucket(backupLocation), "Expected error for path outside the mount root")
+			_, err := GetBucket(backupLocation)
+			require.Error(t, err, "Expected error for path outside the mount root")
+			for target := range mounts {
+				require.Equal(t, filepath.Join(mountRoot, "10.0.0.1", "export"), target, "Unexpected mount")
+			}
+		})
+	}
+}
+
+func TestMissingConfig(t *testing.T) {
+	backupLocation := newBackupLocation()
+	backupLocation.Location.NFSConfig = nil
+	_, err := GetBucket(backupLocation)
+	require.Error(t, err, "Expected error without nfsConfig")
+}
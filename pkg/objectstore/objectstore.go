@@ -7,6 +7,7 @@ import (
 	"github.com/libopenstorage/stork/pkg/objectstore/azure"
 	"github.com/libopenstorage/stork/pkg/objectstore/common"
 	"github.com/libopenstorage/stork/pkg/objectstore/google"
+	"github.com/libopenstorage/stork/pkg/objectstore/local"
 	"github.com/libopenstorage/stork/pkg/objectstore/nfs"
 	"github.com/libopenstorage/stork/pkg/objectstore/s3"
 	"gocloud.dev/blob"
@@ -25,6 +26,10 @@ func GetBucket(backupLocation *stork_api.BackupLocation) (*blob.Bucket, error) {
 		return azure.GetBucket(backupLocation)
 	case stork_api.BackupLocationS3:
 		return s3.GetBucket(backupLocation)
+	case stork_api.BackupLocationNFS:
+		return nfs.GetBucket(backupLocation)
+	case stork_api.BackupLocationLocal:
+		return local.GetBucket(backupLocation)
 	default:
 		return nil, fmt.Errorf("invalid backupLocation type: %v", backupLocation.Location.Type)
 	}
@@ -43,6 +48,10 @@ func CreateBucket(backupLocation *stork_api.BackupLocation) error {
 		return azure.CreateBucket(backupLocation)
 	case stork_api.BackupLocationS3:
 		return s3.CreateBucket(backupLocation)
+	case stork_api.BackupLocationNFS:
+		return nfs.CreateBucket(backupLocation)
+	case stork_api.BackupLocationLocal:
+		return local.CreateBucket(backupLocation)
 	default:
 		return fmt.Errorf("invalid backupLocation type: %v", backupLocation.Location.Type)
 	}
@@ -62,6 +71,8 @@ func GetObjLockInfo(backupLocation *stork_api.BackupLocation) (*common.ObjLockIn
 		return s3.GetObjLockInfo(backupLocation)
 	case stork_api.BackupLocationNFS:
 		return nfs.GetObjLockInfo(backupLocation)
+	case stork_api.BackupLocationLocal:
+		return local.GetObjLockInfo(backupLocation)
 	default:
 		return nil, fmt.Errorf("invalid backupLocation type: %v", backupLocation.Location.Type)
 	}
@@ -59,6 +59,10 @@ const (
 	// CurrentStorageClassName is the annotation used to store the current storage class of the PV before
 	// taking backup as we will reset it to empty.
 	CurrentStorageClassName = "stork.libopenstorage.org/current-storage-class-name"
+	// RestoreFieldManager is the field manager used to merge restored
+	// resources into existing resources. The same name is used on every
+	// restore so that it keeps owning the fields it restored.
+	RestoreFieldManager = "stork-restore"
 
 	// ServiceKind for k8s service resources
 	ServiceKind = "Service"
@@ -1250,6 +1254,39 @@ func (r *ResourceCollector) mergeAndUpdateResource(
 	return nil
 }
 
+// MergeSupported returns true if ApplyResource merges the resource into an
+// existing resource instead of failing when it already exists
+func (r *ResourceCollector) MergeSupported(
+	object runtime.Unstructured,
+	opts *Options,
+) bool {
+	return r.mergeSupportedForResource(object) || r.mergeSupportedForRancherResource(object, opts)
+}
+
+// GetExistingResource returns the resource that exists on the cluster for the
+// given resource, nil if it doesn't exist
+func (r *ResourceCollector) GetExistingResource(
+	dynamicInterface dynamic.Interface,
+	object runtime.Unstructured,
+) (*unstructured.Unstructured, error) {
+	metadata, err := meta.Accessor(object)
+	if err != nil {
+		return nil, err
+	}
+	dynamicClient, err := r.getDynamicClient(dynamicInterface, object)
+	if err != nil {
+		return nil, err
+	}
+	existing, err := dynamicClient.Get(context.TODO(), metadata.GetName(), metav1.GetOptions{})
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return existing, nil
+}
+
 // ApplyResource applies a given resource using the provided client interface
 func (r *ResourceCollector) ApplyResource(
 	dynamicInterface dynamic.Interface,
@@ -1281,6 +1318,82 @@ func (r *ResourceCollector) ApplyResource(
 	return err
 }
 
+// MergeResource creates the resource or merges it into the existing resource.
+// Resources that ApplyResource knows how to merge are merged the same way,
+// others are applied with a server-side apply using RestoreFieldManager. The
+// fields of the existing resource managed by others that conflicted with the
+// apply are overwritten and returned. With dryRun nothing is changed and only
+// the conflicts are returned.
+func (r *ResourceCollector) MergeResource(
+	dynamicInterface dynamic.Interface,
+	object runtime.Unstructured,
+	opts *Options,
+	dryRun bool,
+) ([]string, error) {
+	dynamicClient, err := r.getDynamicClient(dynamicInterface, object)
+	if err != nil {
+		return nil, err
+	}
+	if r.MergeSupported(object, opts) {
+		if dryRun {
+			return nil, nil
+		}
+		_, err = dynamicClient.Create(context.TODO(), object.(*unstructured.Unstructured), metav1.CreateOptions{})
+		if err != nil && apierrors.IsAlreadyExists(err) {
+			return nil, r.mergeAndUpdateResource(object, dynamicClient, opts)
+		}
+		return nil, err
+	}
+
+	applyObject := object.(*unstructured.Unstructured).DeepCopy()
+	// Fields set by the API server can't be part of the applied
+	// configuration
+	for _, field := range [][]string{
+		{"metadata", "resourceVersion"},
+		{"metadata", "uid"},
+		{"metadata", "creationTimestamp"},
+		{"metadata", "generation"},
+		{"metadata", "selfLink"},
+		{"metadata", "managedFields"},
+		{"status"},
+	} {
+		unstructured.RemoveNestedField(applyObject.Object, field...)
+	}
+	applyOptions := metav1.ApplyOptions{FieldManager: RestoreFieldManager}
+	if dryRun {
+		applyOptions.DryRun = []string{metav1.DryRunAll}
+	}
+	_, err = dynamicClient.Apply(context.TODO(), applyObject.GetName(), applyObject, applyOptions)
+	if err == nil || !apierrors.IsConflict(err) {
+		return nil, err
+	}
+
+	conflicts := getApplyConflicts(err)
+	if dryRun {
+		return conflicts, nil
+	}
+	// The backed up values take precedence over the values set by other
+	// field managers
+	applyOptions.Force = true
+	_, err = dynamicClient.Apply(context.TODO(), applyObject.GetName(), applyObject, applyOptions)
+	return conflicts, err
+}
+
+// getApplyConflicts returns the fields that conflicted with a server-side
+// apply
+func getApplyConflicts(err error) []string {
+	conflicts := make([]string, 0)
+	if status, ok := err.(apierrors.APIStatus); ok && status.Status().Details != nil {
+		for _, cause := range status.Status().Details.Causes {
+			conflicts = append(conflicts, fmt.Sprintf("%v: %v", cause.Field, cause.Message))
+		}
+	}
+	if len(conflicts) == 0 {
+		conflicts = append(conflicts, err.Error())
+	}
+	return conflicts
+}
+
 // DeleteResources deletes given resources using the provided client interface
 func (r *ResourceCollector) DeleteResources(
 	dynamicInterface dynamic.Interface,
@@ -0,0 +1,174 @@
+//go:build unittest
+// +build unittest
+
+package resourcecollector
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func newTestApplyConflict() error {
+	return apierrors.NewApplyConflict([]metav1.StatusCause{
+		{
+			Type:    metav1.CauseTypeFieldManagerConflict,
+			Message: `conflict with "kubectl"`,
+			Field:   ".spec.replicas",
+		},
+		{
+			Type:    metav1.CauseTypeFieldManagerConflict,
+			Message: `conflict with "hpa"`,
+			Field:   ".spec.template",
+		},
+	}, "Apply failed with 2 conflicts")
+}
+
+func newTestMergeObject(kind string) *unstructured.Unstructured {
+	o := &unstructured.Unstructured{}
+	o.SetAPIVersion("apps/v1")
+	o.SetKind(kind)
+	o.SetName("web")
+	o.SetNamespace("ns")
+	o.SetResourceVersion("10")
+	o.SetUID("uid")
+	o.Object["spec"] = map[string]interface{}{"replicas": int64(3)}
+	o.Object["status"] = map[string]interface{}{"readyReplicas": int64(3)}
+	return o
+}
+
+func TestGetApplyConflicts(t *testing.T) {
+	tests := []struct {
+		name      string
+		err       error
+		conflicts []string
+	}{
+		{
+			name: "apply conflict",
+			err:  newTestApplyConflict(),
+			conflicts: []string{
+				`.spec.replicas: conflict with "kubectl"`,
+				`.spec.template: conflict with "hpa"`,
+			},
+		},
+		{
+			name:      "conflict without causes",
+			err:       apierrors.NewConflict(schema.GroupResource{Resource: "deployments"}, "web", fmt.Errorf("modified")),
+			conflicts: []string{`Operation cannot be fulfilled on deployments "web": modified`},
+		},
+		{
+			name:      "other error",
+			err:       fmt.Errorf("connection refused"),
+			conflicts: []string{"connection refused"},
+		},
+	}
+	for _, test := range tests {
+		require.Equal(t, test.conflicts, getApplyConflicts(test.err), test.name)
+	}
+}
+
+func TestMergeResource(t *testing.T) {
+	tests := []struct {
+		name string
+		// applyErrors are returned by the successive applies
+		applyErrors []error
+		dryRun      bool
+		conflicts   []string
+		expectErr   bool
+		applies     int
+	}{
+		{
+			name:    "no conflict",
+			applies: 1,
+		},
+		{
+			name:        "conflict",
+			applyErrors: []error{newTestApplyConflict()},
+			conflicts: []string{
+				`.spec.replicas: conflict with "kubectl"`,
+				`.spec.template: conflict with "hpa"`,
+			},
+			// The apply is forced after the conflicts
+			applies: 2,
+		},
+		{
+			name:        "conflict dry run",
+			applyErrors: []error{newTestApplyConflict()},
+			dryRun:      true,
+			conflicts: []string{
+				`.spec.replicas: conflict with "kubectl"`,
+				`.spec.template: conflict with "hpa"`,
+			},
+			applies: 1,
+		},
+		{
+			name:        "forced apply fails",
+			applyErrors: []error{newTestApplyConflict(), fmt.Errorf("forced apply failed")},
+			expectErr:   true,
+			applies:     2,
+		},
+		{
+			name:        "other error",
+			applyErrors: []error{apierrors.NewForbidden(schema.GroupResource{Resource: "deployments"}, "web", fmt.Errorf("denied"))},
+			expectErr:   true,
+			applies:     1,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			dynamicClient := dynamicfake.NewSimpleDynamicClient(runtime.NewScheme())
+			applied := make([]*unstructured.Unstructured, 0)
+			dynamicClient.PrependReactor("patch", "deployments", func(action k8stesting.Action) (bool, runtime.Object, error) {
+				patch := action.(k8stesting.PatchAction)
+				require.Equal(t, types.ApplyPatchType, patch.GetPatchType())
+				object := &unstructured.Unstructured{}
+				require.NoError(t, object.UnmarshalJSON(patch.GetPatch()))
+				applied = append(applied, object)
+				if len(applied) <= len(test.applyErrors) {
+					return true, nil, test.applyErrors[len(applied)-1]
+				}
+				return true, object, nil
+			})
+
+			r := &ResourceCollector{}
+			conflicts, err := r.MergeResource(dynamicClient, newTestMergeObject("Deployment"), &Options{}, test.dryRun)
+			if test.expectErr {
+				require.Error(t, err)
+			} else {
+				require.NoError(t, err)
+				require.Equal(t, test.conflicts, conflicts)
+			}
+			require.Len(t, applied, test.applies)
+			// The fields set by the API server aren't applied
+			for _, object := range applied {
+				require.Empty(t, object.GetResourceVersion())
+				require.Empty(t, object.GetUID())
+				require.NotContains(t, object.Object, "status")
+				replicas, _, _ := unstructured.NestedInt64(object.Object, "spec", "replicas")
+				require.Equal(t, int64(3), replicas)
+			}
+		})
+	}
+}
+
+func TestMergeResourceMergeSupported(t *testing.T) {
+	dynamicClient := dynamicfake.NewSimpleDynamicClient(runtime.NewScheme())
+	object := newTestMergeObject("ServiceAccount")
+	object.SetAPIVersion("v1")
+	r := &ResourceCollector{}
+
+	// Resources that are merged by stork aren't changed by a dry run
+	conflicts, err := r.MergeResource(dynamicClient, object, &Options{}, true)
+	require.NoError(t, err)
+	require.Empty(t, conflicts)
+	require.Empty(t, dynamicClient.Actions())
+}
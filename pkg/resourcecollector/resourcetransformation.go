@@ -9,7 +9,10 @@ import (
 	storkops "github.com/portworx/sched-ops/k8s/stork"
 	"github.com/sirupsen/logrus"
 	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime"
 )
 
@@ -43,6 +46,49 @@ func GetResourcePatch(transformName string, namespaces []string) (map[string]sto
 	return patch, nil
 }
 
+// GetTransformPatches returns the patches from the transformation specs that
+// select the object. Unlike GetResourcePatch the specs are matched against the
+// object itself, so objects that aren't on the cluster, like the ones being
+// restored from a backup, can be transformed as well.
+func GetTransformPatches(
+	transform *stork_api.ResourceTransformation,
+	object runtime.Unstructured,
+) ([]stork_api.TransformResourceInfo, error) {
+	metadata, err := meta.Accessor(object)
+	if err != nil {
+		return nil, err
+	}
+	gvk := object.GetObjectKind().GroupVersionKind()
+	patches := make([]stork_api.TransformResourceInfo, 0)
+	for _, spec := range transform.Spec.Objects {
+		resource := strings.Split(spec.Resource, "/")
+		if len(resource) != 3 {
+			return nil, fmt.Errorf("invalid resource type should be in format <group>/<version>/<kind>, actual: %s", spec.Resource)
+		}
+		group := resource[0]
+		if group == "core" {
+			group = ""
+		}
+		if group != gvk.Group || resource[1] != gvk.Version || resource[2] != gvk.Kind {
+			continue
+		}
+		if !labels.SelectorFromSet(spec.Selectors).Matches(labels.Set(metadata.GetLabels())) {
+			continue
+		}
+		patches = append(patches, stork_api.TransformResourceInfo{
+			Name:      metadata.GetName(),
+			Namespace: metadata.GetNamespace(),
+			GroupVersionKind: metav1.GroupVersionKind{
+				Group:   gvk.Group,
+				Version: gvk.Version,
+				Kind:    gvk.Kind,
+			},
+			Specs: spec,
+		})
+	}
+	return patches, nil
+}
+
 // this method transform object as per resource transformation specified in each namespaces
 func TransformResources(
 	object runtime.Unstructured,
@@ -46,6 +46,213 @@ func ScaleReplicas(namespace string, activate bool, printFunc func(string, strin
 	updateStashedCMObjects(namespace, activate, printFunc, config)
 }
 
+// RecordReplicas annotates the applications in the namespace with the
+// annotations that are set on migrated applications, so that they can be
+// scaled down and then activated again with ScaleReplicas. The current
+// replicas are recorded for the kinds scaled by ScaleReplicas and the current
+// values of the suspend options for the CRs of the ApplicationRegistrations.
+func RecordReplicas(namespace string, printFunc func(string, string), config *rest.Config) error {
+	statefulSets, err := apps.Instance().ListStatefulSets(namespace, metav1.ListOptions{})
+	if err != nil {
+		return err
+	}
+	for _, statefulSet := range statefulSets.Items {
+		if statefulSet.Spec.Replicas == nil || !recordReplicaCount(&statefulSet.ObjectMeta, *statefulSet.Spec.Replicas) {
+			continue
+		}
+		if _, err := apps.Instance().UpdateStatefulSet(&statefulSet); err != nil {
+			return fmt.Errorf("error recording replicas for statefulset %v/%v: %v", statefulSet.Namespace, statefulSet.Name, err)
+		}
+		printFunc(fmt.Sprintf("Recorded %v replicas for statefulset %v/%v", *statefulSet.Spec.Replicas, statefulSet.Namespace, statefulSet.Name), "out")
+	}
+
+	deployments, err := apps.Instance().ListDeployments(namespace, metav1.ListOptions{})
+	if err != nil {
+		return err
+	}
+	for _, deployment := range deployments.Items {
+		if deployment.Spec.Replicas == nil || !recordReplicaCount(&deployment.ObjectMeta, *deployment.Spec.Replicas) {
+			continue
+		}
+		if _, err := apps.Instance().UpdateDeployment(&deployment); err != nil {
+			return fmt.Errorf("error recording replicas for deployment %v/%v: %v", deployment.Namespace, deployment.Name, err)
+		}
+		printFunc(fmt.Sprintf("Recorded %v replicas for deployment %v/%v", *deployment.Spec.Replicas, deployment.Namespace, deployment.Name), "out")
+	}
+
+	replicaSets, err := apps.Instance().ListReplicaSets(namespace, metav1.ListOptions{})
+	if err != nil {
+		return err
+	}
+	for _, replicaSet := range replicaSets {
+		// Replicasets owned by deployments are scaled down with them
+		if replicaSet.OwnerReferences != nil || replicaSet.Spec.Replicas == nil ||
+			!recordReplicaCount(&replicaSet.ObjectMeta, *replicaSet.Spec.Replicas) {
+			continue
+		}
+		if _, err := apps.Instance().UpdateReplicaSet(&replicaSet); err != nil {
+			return fmt.Errorf("error recording replicas for replicaset %v/%v: %v", replicaSet.Namespace, replicaSet.Name, err)
+		}
+		printFunc(fmt.Sprintf("Recorded %v replicas for replicaset %v/%v", *replicaSet.Spec.Replicas, replicaSet.Namespace, replicaSet.Name), "out")
+	}
+
+	deploymentConfigs, err := openshift.Instance().ListDeploymentConfigs(namespace)
+	if err != nil && !errors.IsNotFound(err) {
+		return err
+	}
+	if err == nil {
+		for _, deployment := range deploymentConfigs.Items {
+			if !recordReplicaCount(&deployment.ObjectMeta, deployment.Spec.Replicas) {
+				continue
+			}
+			if _, err := openshift.Instance().UpdateDeploymentConfig(&deployment); err != nil {
+				return fmt.Errorf("error recording replicas for deploymentconfig %v/%v: %v", deployment.Namespace, deployment.Name, err)
+			}
+			printFunc(fmt.Sprintf("Recorded %v replicas for deploymentconfig %v/%v", deployment.Spec.Replicas, deployment.Namespace, deployment.Name), "out")
+		}
+	}
+
+	for _, kind := range []string{"IBPPeer", "IBPCA", "IBPOrderer", "IBPConsole"} {
+		if err := recordIBPReplicas(kind, namespace, printFunc); err != nil {
+			return err
+		}
+	}
+	return recordCRDSuspendOptions(namespace, printFunc, config)
+}
+
+// recordReplicaCount sets the replicas annotation on the object unless it has
+// already been recorded. Returns true if the object needs to be updated.
+func recordReplicaCount(metadata *metav1.ObjectMeta, replicas int32) bool {
+	if _, present := metadata.Annotations[migration.StorkMigrationReplicasAnnotation]; present {
+		return false
+	}
+	if metadata.Annotations == nil {
+		metadata.Annotations = make(map[string]string)
+	}
+	metadata.Annotations[migration.StorkMigrationReplicasAnnotation] = strconv.Itoa(int(replicas))
+	return true
+}
+
+func recordIBPReplicas(kind string, namespace string, printFunc func(string, string)) error {
+	objects, err := dynamic.Instance().ListObjects(
+		&metav1.ListOptions{
+			TypeMeta: metav1.TypeMeta{
+				Kind:       kind,
+				APIVersion: "ibp.com/v1alpha1"},
+		},
+		namespace)
+	if err != nil {
+		if errors.IsNotFound(err) {
+			return nil
+		}
+		return err
+	}
+	for _, o := range objects.Items {
+		annotations := o.GetAnnotations()
+		if _, present := annotations[migration.StorkMigrationReplicasAnnotation]; present {
+			continue
+		}
+		replicas, found, err := unstructured.NestedInt64(o.Object, "spec", "replicas")
+		if err != nil || !found {
+			continue
+		}
+		if annotations == nil {
+			annotations = make(map[string]string)
+		}
+		annotations[migration.StorkMigrationReplicasAnnotation] = strconv.FormatInt(replicas, 10)
+		o.SetAnnotations(annotations)
+		if _, err := dynamic.Instance().UpdateObject(&o); err != nil {
+			return fmt.Errorf("error recording replicas for %v %v/%v: %v", strings.ToLower(kind), o.GetNamespace(), o.GetName(), err)
+		}
+		printFunc(fmt.Sprintf("Recorded %v replicas for %v %v/%v", replicas, strings.ToLower(kind), o.GetNamespace(), o.GetName()), "out")
+	}
+	return nil
+}
+
+// recordCRDSuspendOptions records the current values of the suspend options of
+// the CRs in the namespace in the format used by the migration controller,
+// "<value to activate>,<value to suspend>". Boolean options don't need to be
+// recorded.
+func recordCRDSuspendOptions(namespace string, printFunc func(string, string), config *rest.Config) error {
+	crdList, err := storkops.Instance().ListApplicationRegistrations()
+	if err != nil {
+		return err
+	}
+	if len(crdList.Items) == 0 {
+		return nil
+	}
+	configClient, err := k8sdynamic.NewForConfig(config)
+	if err != nil {
+		return err
+	}
+
+	ruleset := resourcecollector.GetDefaultRuleSet()
+	for _, res := range crdList.Items {
+		for _, crd := range res.Resources {
+			suspendOptions := crd.NestedSuspendOptions
+			if crd.SuspendOptions.Path != "" {
+				suspendOptions = append(suspendOptions, crd.SuspendOptions)
+			}
+			if len(suspendOptions) == 0 {
+				continue
+			}
+			gvk := schema.FromAPIVersionAndKind(crd.Group+"/"+crd.Version, crd.Kind)
+			client := configClient.Resource(gvk.GroupVersion().WithResource(ruleset.Pluralize(strings.ToLower(gvk.Kind)))).Namespace(namespace)
+			objects, err := client.List(context.TODO(), metav1.ListOptions{})
+			if err != nil {
+				if errors.IsNotFound(err) {
+					continue
+				}
+				return err
+			}
+			for _, o := range objects.Items {
+				annotations := o.GetAnnotations()
+				if annotations == nil {
+					annotations = make(map[string]string)
+				}
+				update := false
+				for _, suspend := range suspendOptions {
+					fields := strings.Split(suspend.Path, ".")
+					if len(fields) <= 1 {
+						continue
+					}
+					if _, present := annotations[migration.StorkAnnotationPrefix+suspend.Path]; present {
+						continue
+					}
+					var currVal string
+					switch suspend.Type {
+					case "int":
+						curr, found, err := unstructured.NestedInt64(o.Object, fields...)
+						if err != nil || !found {
+							continue
+						}
+						currVal = strconv.FormatInt(curr, 10)
+					case "string":
+						curr, _, err := unstructured.NestedString(o.Object, fields...)
+						if err != nil {
+							continue
+						}
+						currVal = curr
+					default:
+						continue
+					}
+					annotations[migration.StorkAnnotationPrefix+suspend.Path] = currVal + "," + suspend.Value
+					update = true
+				}
+				if !update {
+					continue
+				}
+				o.SetAnnotations(annotations)
+				if _, err := client.Update(context.TODO(), &o, metav1.UpdateOptions{}); err != nil {
+					return fmt.Errorf("error recording suspend options for %v %v/%v: %v", strings.ToLower(crd.Kind), o.GetNamespace(), o.GetName(), err)
+				}
+				printFunc(fmt.Sprintf("Recorded suspend options for %v %v/%v", strings.ToLower(crd.Kind), o.GetNamespace(), o.GetName()), "out")
+			}
+		}
+	}
+	return nil
+}
+
 func updateStatefulSets(namespace string, activate bool, printFunc func(string, string)) {
 	statefulSets, err := apps.Instance().ListStatefulSets(namespace, metav1.ListOptions{})
 	if err != nil {
@@ -298,7 +505,8 @@ func updateCronJobObjects(namespace string, activate bool, printFunc func(string
 	}
 
 	for _, cronJob := range cronJobs.Items {
-		*cronJob.Spec.Suspend = !activate
+		suspend := !activate
+		cronJob.Spec.Suspend = &suspend
 		_, err = batch.Instance().UpdateCronJob(&cronJob)
 		if err != nil {
 			printFunc(fmt.Sprintf("Error updating suspend option for cronJob %v/%v : %v", cronJob.Namespace, cronJob.Name, err), "err")
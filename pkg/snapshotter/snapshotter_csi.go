@@ -1040,13 +1040,8 @@ func (c *csiDriver) UploadSnapshotObjects(
 	if err != nil {
 		return err
 	}
-	if backupLocation.Location.EncryptionKey != "" {
-		return fmt.Errorf("EncryptionKey is deprecated, use EncryptionKeyV2 instead")
-	}
-	if backupLocation.Location.EncryptionV2Key != "" {
-		if data, err = crypto.Encrypt(data, backupLocation.Location.EncryptionV2Key); err != nil {
-			return err
-		}
+	if data, err = objectstore.EncryptData(backupLocation, data); err != nil {
+		return err
 	}
 	var options blob.WriterOptions
 	if backupLocation.Location.S3Config != nil {
@@ -1105,17 +1100,17 @@ func (c *csiDriver) DownloadSnapshotObjects(
 		if err != nil {
 			return snapshotInfoList, err
 		}
-		if backupLocation.Location.EncryptionV2Key != "" {
-			if decryptData, err := crypto.Decrypt(data, backupLocation.Location.EncryptionV2Key); err != nil {
-			} else {
-				data = decryptData
-			}
+		if data, err = objectstore.DecryptData(backupLocation, data); err != nil {
+			return snapshotInfoList, err
 		}
 	} else {
-		data, err = DownloadObject(objectPath, backupLocation.Location.EncryptionV2Key)
+		data, err = DownloadObject(objectPath, "")
 		if err != nil {
 			return snapshotInfoList, err
 		}
+		if data, err = objectstore.DecryptData(backupLocation, data); err != nil {
+			return snapshotInfoList, err
+		}
 		if len(data) == 0 {
 			return snapshotInfoList, fmt.Errorf("decrypted data from %s is empty", objectPath)
 		}
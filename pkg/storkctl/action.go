@@ -15,6 +15,8 @@ import (
 
 const (
 	failoverCommand                    = "failover"
+	switchoverCommand                  = "switchover"
+	failbackCommand                    = "failback"
 	nameTimeSuffixFormat string        = "2006-01-02-150405"
 	actionWaitTimeout    time.Duration = 10 * time.Minute
 	actionWaitInterval   time.Duration = 10 * time.Second
@@ -29,6 +31,8 @@ func newTriggerCommand(cmdFactory Factory, ioStreams genericclioptions.IOStreams
 
 	triggerCommands.AddCommand(
 		newFailoverCommand(cmdFactory, ioStreams),
+		newSwitchoverCommand(cmdFactory, ioStreams),
+		newFailbackCommand(cmdFactory, ioStreams),
 	)
 	return triggerCommands
 }
@@ -87,6 +91,74 @@ func newFailoverCommand(cmdFactory Factory, ioStreams genericclioptions.IOStream
 	return failoverCommand
 }
 
+func newSwitchoverCommand(cmdFactory Factory, ioStreams genericclioptions.IOStreams) *cobra.Command {
+	var migrationScheduleName string
+	switchoverCommand := &cobra.Command{
+		Use:   switchoverCommand,
+		Short: "Initiate a planned switchover of the applications migrated by a MigrationSchedule",
+		Run: func(c *cobra.Command, args []string) {
+			startMigrationScheduleAction(cmdFactory, ioStreams, storkv1.ActionSpec{
+				ActionType:            storkv1.ActionTypeSwitchover,
+				MigrationScheduleName: migrationScheduleName,
+			})
+		},
+	}
+	switchoverCommand.Flags().StringVarP(&migrationScheduleName, "migrationSchedule", "m", "", "MigrationSchedule whose applications should be switched over to the destination cluster")
+	return switchoverCommand
+}
+
+func newFailbackCommand(cmdFactory Factory, ioStreams genericclioptions.IOStreams) *cobra.Command {
+	var migrationScheduleName string
+	var reverseClusterPair string
+	failbackCommand := &cobra.Command{
+		Use:   failbackCommand,
+		Short: "Initiate a failback of the applications migrated by a MigrationSchedule from the destination cluster",
+		Run: func(c *cobra.Command, args []string) {
+			startMigrationScheduleAction(cmdFactory, ioStreams, storkv1.ActionSpec{
+				ActionType:            storkv1.ActionTypeFailback,
+				MigrationScheduleName: migrationScheduleName,
+				ReverseClusterPair:    reverseClusterPair,
+			})
+		},
+	}
+	failbackCommand.Flags().StringVarP(&migrationScheduleName, "migrationSchedule", "m", "", "MigrationSchedule whose applications should be failed back from the destination cluster")
+	failbackCommand.Flags().StringVarP(&reverseClusterPair, "reverseClusterPair", "", "", "ClusterPair on the destination cluster to migrate the applications back with, defaults to the ClusterPair of the MigrationSchedule")
+	return failbackCommand
+}
+
+// startMigrationScheduleAction creates an Action for the MigrationSchedule in
+// the namespace if no other Action is pending there
+func startMigrationScheduleAction(cmdFactory Factory, ioStreams genericclioptions.IOStreams, spec storkv1.ActionSpec) {
+	if spec.MigrationScheduleName == "" {
+		util.CheckErr(fmt.Errorf("need to provide the MigrationSchedule to %v", spec.ActionType))
+		return
+	}
+	namespace := cmdFactory.GetNamespace()
+	if _, err := storkops.Instance().GetMigrationSchedule(spec.MigrationScheduleName, namespace); err != nil {
+		util.CheckErr(fmt.Errorf("migrationschedule %v does not exist in namespace %v", spec.MigrationScheduleName, namespace))
+		return
+	}
+	if incompleteAction := getAnyIncompleteAction(namespace); incompleteAction != nil {
+		util.CheckErr(fmt.Errorf("failed to start %v as namespace %v has action %v in state %v",
+			spec.ActionType, namespace, incompleteAction.Name, incompleteAction.Status))
+		return
+	}
+	action := storkv1.Action{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      newActionName(spec.ActionType),
+			Namespace: namespace,
+		},
+		Spec:   spec,
+		Status: storkv1.ActionStatusScheduled,
+	}
+	if _, err := storkops.Instance().CreateAction(&action); err != nil {
+		util.CheckErr(fmt.Errorf("failed to start %v for migrationschedule %v: %v", spec.ActionType, spec.MigrationScheduleName, err))
+		return
+	}
+	printMsg(fmt.Sprintf("Started %v for migrationschedule %v/%v", spec.ActionType, namespace, spec.MigrationScheduleName), ioStreams.Out)
+	printMsg(getDescribeActionMessage(&action), ioStreams.Out)
+}
+
 func isActionIncomplete(action *storkv1.Action) bool {
 	return action.Status == storkv1.ActionStatusScheduled || action.Status == storkv1.ActionStatusInProgress
 }
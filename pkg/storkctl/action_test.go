@@ -0,0 +1,95 @@
+//go:build unittest
+// +build unittest
+
+package storkctl
+
+import (
+	"fmt"
+	"testing"
+
+	storkv1 "github.com/libopenstorage/stork/pkg/apis/stork/v1alpha1"
+	storkops "github.com/portworx/sched-ops/k8s/stork"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+)
+
+// startActionAndVerify runs the command and returns the Action it created,
+// whose name depends on the time it was started at
+func startActionAndVerify(t *testing.T, cmdArgs []string, actionType storkv1.ActionType) *storkv1.Action {
+	streams, _, buf, _ := genericclioptions.NewTestIOStreams()
+	cmd := NewCommand(testFactory, streams.In, streams.Out, streams.ErrOut)
+	cmd.SetOutput(buf)
+	cmd.SetArgs(cmdArgs)
+	require.NoError(t, cmd.Execute(), "Error executing command: %v", cmd)
+
+	actions, err := storkops.Instance().ListActions("test")
+	require.NoError(t, err, "Error listing actions")
+	require.Len(t, actions.Items, 1, "Expected one action to be created")
+	action := &actions.Items[0]
+	require.Equal(t, actionType, action.Spec.ActionType, "Action type mismatch")
+	require.Equal(t, storkv1.ActionStatusScheduled, action.Status, "Action status mismatch")
+
+	expected := fmt.Sprintf("Started %v for migrationschedule test/migrationschedule\n", actionType) +
+		fmt.Sprintf("To check Action status use: kubectl describe action %v -n test\n", action.Name)
+	require.Equal(t, expected, buf.String())
+	return action
+}
+
+func TestSwitchoverMissingMigrationSchedule(t *testing.T) {
+	defer resetTest()
+	cmdArgs := []string{"trigger", "switchover"}
+	expected := "error: need to provide the MigrationSchedule to switchover"
+	testCommon(t, cmdArgs, nil, expected, true)
+}
+
+func TestSwitchoverNonExistentMigrationSchedule(t *testing.T) {
+	defer resetTest()
+	cmdArgs := []string{"trigger", "switchover", "-m", "missing", "-n", "test"}
+	expected := "error: migrationschedule missing does not exist in namespace test"
+	testCommon(t, cmdArgs, nil, expected, true)
+}
+
+func TestSwitchoverWithIncompleteAction(t *testing.T) {
+	defer resetTest()
+	createMigrationScheduleAndVerify(t, "migrationschedule", "testpolicy", "test", "clusterpair1", []string{"test"}, "", "", false)
+	_, err := storkops.Instance().CreateAction(&storkv1.Action{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "failover",
+			Namespace: "test",
+		},
+		Spec: storkv1.ActionSpec{
+			ActionType: storkv1.ActionTypeFailover,
+		},
+		Status: storkv1.ActionStatusInProgress,
+	})
+	require.NoError(t, err, "Error creating action")
+
+	cmdArgs := []string{"trigger", "switchover", "-m", "migrationschedule", "-n", "test"}
+	expected := "error: failed to start switchover as namespace test has action failover in state In-Progress"
+	testCommon(t, cmdArgs, nil, expected, true)
+}
+
+func TestSwitchover(t *testing.T) {
+	defer resetTest()
+	createMigrationScheduleAndVerify(t, "migrationschedule", "testpolicy", "test", "clusterpair1", []string{"test"}, "", "", false)
+	cmdArgs := []string{"trigger", "switchover", "-m", "migrationschedule", "-n", "test"}
+	action := startActionAndVerify(t, cmdArgs, storkv1.ActionTypeSwitchover)
+	require.Equal(t, "migrationschedule", action.Spec.MigrationScheduleName, "MigrationSchedule name mismatch")
+}
+
+func TestFailbackMissingMigrationSchedule(t *testing.T) {
+	defer resetTest()
+	cmdArgs := []string{"trigger", "failback"}
+	expected := "error: need to provide the MigrationSchedule to failback"
+	testCommon(t, cmdArgs, nil, expected, true)
+}
+
+func TestFailback(t *testing.T) {
+	defer resetTest()
+	createMigrationScheduleAndVerify(t, "migrationschedule", "testpolicy", "test", "clusterpair1", []string{"test"}, "", "", false)
+	cmdArgs := []string{"trigger", "failback", "-m", "migrationschedule", "--reverseClusterPair", "reverse", "-n", "test"}
+	action := startActionAndVerify(t, cmdArgs, storkv1.ActionTypeFailback)
+	require.Equal(t, "migrationschedule", action.Spec.MigrationScheduleName, "MigrationSchedule name mismatch")
+	require.Equal(t, "reverse", action.Spec.ReverseClusterPair, "ReverseClusterPair mismatch")
+}
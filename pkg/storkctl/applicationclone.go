@@ -33,6 +33,7 @@ func newCreateApplicationCloneCommand(cmdFactory Factory, ioStreams genericcliop
 	var postExecRule string
 	var waitForCompletion bool
 	var replacePolicy string
+	var transformSpec string
 
 	createApplicationCloneCommand := &cobra.Command{
 		Use:     applicationCloneSubcommand,
@@ -53,6 +54,9 @@ func newCreateApplicationCloneCommand(cmdFactory Factory, ioStreams genericcliop
 					ReplacePolicy:        storkv1.ApplicationCloneReplacePolicyType(replacePolicy),
 				},
 			}
+			if transformSpec != "" {
+				applicationClone.Spec.TransformSpecs = []string{transformSpec}
+			}
 			applicationClone.Name = applicationCloneName
 			applicationClone.Namespace = cmdFactory.GetNamespace()
 			_, err := storkops.Instance().CreateApplicationClone(applicationClone)
@@ -80,6 +84,7 @@ func newCreateApplicationCloneCommand(cmdFactory Factory, ioStreams genericcliop
 	createApplicationCloneCommand.Flags().StringVarP(&sourceNamespace, "sourceNamespace", "", "", "The namespace from where applications should be cloned")
 	createApplicationCloneCommand.Flags().StringVarP(&destinationNamespace, "destinationNamespace", "", "", "The namespace to where the applications should be cloned")
 	createApplicationCloneCommand.Flags().StringVarP(&replacePolicy, "replacePolicy", "r", "Retain", "Policy to use if resources being cloned already exist in destination namespace (Retain or Delete).")
+	createApplicationCloneCommand.Flags().StringVarP(&transformSpec, "transformSpec", "", "", "ResourceTransformation in the source namespace to apply to the resources being cloned")
 
 	return createApplicationCloneCommand
 }
@@ -35,6 +35,17 @@ func newCreateApplicationRestoreCommand(cmdFactory Factory, ioStreams genericcli
 	var replacePolicy string
 	var resources string
 	var nsMapping string
+	var dryRun bool
+	var namespaces []string
+	var selectors map[string]string
+	var excludeSelectors map[string]string
+	var resourceTypes []string
+	var excludeResourceTypes []string
+	var preExecRule string
+	var postExecRule string
+	var transformSpec string
+	var backupScheduleName string
+	var pointInTime string
 
 	createApplicationRestoreCommand := &cobra.Command{
 		Use:     applicationRestoreSubcommand,
@@ -55,39 +66,79 @@ func newCreateApplicationRestoreCommand(cmdFactory Factory, ioStreams genericcli
 				return
 			}
 
-			if backupName == "" {
-				util.CheckErr(fmt.Errorf("need to provide BackupName to restore"))
+			if backupName == "" && backupScheduleName == "" {
+				util.CheckErr(fmt.Errorf("need to provide BackupName or BackupSchedule to restore"))
 				return
 			}
-
-			backup, err := storkops.Instance().GetApplicationBackup(backupName, cmdFactory.GetNamespace())
-			if err != nil {
-				util.CheckErr(fmt.Errorf("applicationbackup %s does not exist in namespace %s", backupName, cmdFactory.GetNamespace()))
+			if backupName != "" && backupScheduleName != "" {
+				util.CheckErr(fmt.Errorf("only one of BackupName and BackupSchedule can be provided"))
 				return
 			}
-			backupObjectsMap := getBackupObjectsMap(backup)
 
 			applicationRestoreName = args[0]
 			applicationRestore := &storkv1.ApplicationRestore{
 				Spec: storkv1.ApplicationRestoreSpec{
-					BackupLocation: backupLocation,
-					BackupName:     backupName,
-					ReplacePolicy:  storkv1.ApplicationRestoreReplacePolicyType(replacePolicy),
+					BackupLocation:       backupLocation,
+					BackupName:           backupName,
+					BackupScheduleName:   backupScheduleName,
+					ReplacePolicy:        storkv1.ApplicationRestoreReplacePolicyType(replacePolicy),
+					DryRun:               dryRun,
+					Namespaces:           namespaces,
+					Selectors:            selectors,
+					ExcludeSelectors:     excludeSelectors,
+					ResourceTypes:        resourceTypes,
+					ExcludeResourceTypes: excludeResourceTypes,
+					PreExecRule:          preExecRule,
+					PostExecRule:         postExecRule,
 				},
 			}
-			if len(resources) > 0 {
-				objects, err := getObjectInfos(resources, backupObjectsMap, ioStreams)
+			if transformSpec != "" {
+				applicationRestore.Spec.TransformSpecs = []string{transformSpec}
+			}
+			if backupScheduleName != "" {
+				// The backup is picked by the controller so the resources
+				// and namespaces can't be checked against it
+				if len(resources) > 0 {
+					util.CheckErr(fmt.Errorf("resources can't be selected when restoring from a BackupSchedule"))
+					return
+				}
+				if pointInTime != "" {
+					restoreTime, err := time.Parse(time.RFC3339, pointInTime)
+					if err != nil {
+						util.CheckErr(fmt.Errorf("invalid pointInTime %v, it should be in RFC3339 format: %v", pointInTime, err))
+						return
+					}
+					applicationRestore.Spec.PointInTime = &metav1.Time{Time: restoreTime}
+				}
+				applicationRestore.Spec.NamespaceMapping, err = parseNamespaceMapping(nsMapping)
 				if err != nil {
-					util.CheckErr(fmt.Errorf("error in creating applicationrestore: %v", err))
+					util.CheckErr(err)
 					return
 				}
-				applicationRestore.Spec.IncludeResources = objects
-			}
+			} else {
+				if pointInTime != "" {
+					util.CheckErr(fmt.Errorf("pointInTime can only be provided with a BackupSchedule"))
+					return
+				}
+				backup, err := storkops.Instance().GetApplicationBackup(backupName, cmdFactory.GetNamespace())
+				if err != nil {
+					util.CheckErr(fmt.Errorf("applicationbackup %s does not exist in namespace %s", backupName, cmdFactory.GetNamespace()))
+					return
+				}
+				if len(resources) > 0 {
+					objects, err := getObjectInfos(resources, getBackupObjectsMap(backup), ioStreams)
+					if err != nil {
+						util.CheckErr(fmt.Errorf("error in creating applicationrestore: %v", err))
+						return
+					}
+					applicationRestore.Spec.IncludeResources = objects
+				}
 
-			applicationRestore.Spec.NamespaceMapping, err = getNamespaceMapping(backup, nsMapping)
-			if err != nil {
-				util.CheckErr(err)
-				return
+				applicationRestore.Spec.NamespaceMapping, err = getNamespaceMapping(backup, nsMapping)
+				if err != nil {
+					util.CheckErr(err)
+					return
+				}
 			}
 			applicationRestore.Name = applicationRestoreName
 			applicationRestore.Namespace = cmdFactory.GetNamespace()
@@ -113,8 +164,19 @@ func newCreateApplicationRestoreCommand(cmdFactory Factory, ioStreams genericcli
 	createApplicationRestoreCommand.Flags().BoolVarP(&waitForCompletion, "wait", "", false, "Wait for applicationrestore to complete")
 	createApplicationRestoreCommand.Flags().StringVarP(&backupLocation, "backupLocation", "l", "", "BackupLocation to use for the restore")
 	createApplicationRestoreCommand.Flags().StringVarP(&backupName, "backupName", "b", "", "Backup to restore from")
-	createApplicationRestoreCommand.Flags().StringVarP(&replacePolicy, "replacePolicy", "r", "Retain", "Policy to use if resources being restored already exist (Retain or Delete).")
+	createApplicationRestoreCommand.Flags().StringVarP(&backupScheduleName, "backupSchedule", "", "", "ApplicationBackupSchedule to restore the latest successful backup from")
+	createApplicationRestoreCommand.Flags().StringVarP(&pointInTime, "pointInTime", "", "", "Restore the latest backup from the BackupSchedule triggered at or before this time, in RFC3339 format")
+	createApplicationRestoreCommand.Flags().StringVarP(&replacePolicy, "replacePolicy", "r", "Retain", "Policy to use if resources being restored already exist (Retain, Delete or Merge).")
 	createApplicationRestoreCommand.Flags().StringVarP(&nsMapping, "namespaceMapping", "", "", "Namespace mapping for each of the backed up namespaces, ex: <\"srcns1:destns1,srcns2:destns2\">")
+	createApplicationRestoreCommand.Flags().BoolVarP(&dryRun, "dryRun", "", false, "Only report what the restore would do without restoring anything")
+	createApplicationRestoreCommand.Flags().StringSliceVarP(&namespaces, "namespaces", "", nil, "Comma separated subset of the backed up namespaces to restore")
+	createApplicationRestoreCommand.Flags().StringToStringVar(&selectors, "selectors", nil, "Only resources with all the provided labels will be restored")
+	createApplicationRestoreCommand.Flags().StringToStringVar(&excludeSelectors, "excludeSelectors", nil, "Resources with any of the provided labels won't be restored")
+	createApplicationRestoreCommand.Flags().StringSliceVarP(&resourceTypes, "resourceTypes", "", nil, "Comma separated list of the resource types to restore, ex: Deployment,PersistentVolumeClaim")
+	createApplicationRestoreCommand.Flags().StringSliceVarP(&excludeResourceTypes, "excludeResourceTypes", "", nil, "Comma separated list of the resource types that won't be restored, ex: Secret,ConfigMap")
+	createApplicationRestoreCommand.Flags().StringVarP(&preExecRule, "preExecRule", "", "", "Rule to run once the resources have been applied, its actions run until the restore completes")
+	createApplicationRestoreCommand.Flags().StringVarP(&postExecRule, "postExecRule", "", "", "Rule to run once the resources have been applied and the pods are ready")
+	createApplicationRestoreCommand.Flags().StringVarP(&transformSpec, "transformSpec", "", "", "ResourceTransformation to apply to the resources being restored")
 	createApplicationRestoreCommand.Flags().StringVarP(&resources, "resources", "", "",
 		"Specific resources for restoring, should be given in format \"<kind>/<namespace>/<name>,<kind>/<namespace>/<name>,<kind>/<name>\", ex: \"<Deployment>/<ns1>/<dep1>,<PersistentVolumeClaim>/<ns1>/<pvc1>,<ClusterRole>/<clusterrole1>\"")
 
@@ -298,19 +360,27 @@ func waitForApplicationRestore(name, namespace string, ioStreams genericclioptio
 	return msg, err
 }
 
-func getNamespaceMapping(backup *storkv1.ApplicationBackup, inputMappings string) (map[string]string, error) {
+func parseNamespaceMapping(inputMappings string) (map[string]string, error) {
 	inputMappingMap := make(map[string]string)
-	outputNSMapping := make(map[string]string)
 	if len(inputMappings) > 0 {
 		for _, inputMapping := range strings.Split(inputMappings, ",") {
 			nsMapping := strings.Split(inputMapping, ":")
 			if len(nsMapping) == 2 {
 				inputMappingMap[nsMapping[0]] = nsMapping[1]
 			} else {
-				return outputNSMapping, fmt.Errorf("invalid input namespace mapping %s", inputMapping)
+				return inputMappingMap, fmt.Errorf("invalid input namespace mapping %s", inputMapping)
 			}
 		}
 	}
+	return inputMappingMap, nil
+}
+
+func getNamespaceMapping(backup *storkv1.ApplicationBackup, inputMappings string) (map[string]string, error) {
+	outputNSMapping := make(map[string]string)
+	inputMappingMap, err := parseNamespaceMapping(inputMappings)
+	if err != nil {
+		return outputNSMapping, err
+	}
 	for _, ns := range backup.Spec.Namespaces {
 		outputNSMapping[ns] = ns
 		if newNS, ok := inputMappingMap[ns]; ok {
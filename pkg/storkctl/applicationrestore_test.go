@@ -305,6 +305,56 @@ func TestCreateApplicationRestores(t *testing.T) {
 	createApplicationRestoreAndVerify(t, "createrestore", "default", []string{"namespace1"}, "backuplocation", "backupname", "", true, true)
 }
 
+func TestCreateApplicationRestoreDryRun(t *testing.T) {
+	defer resetTest()
+	createBackupLocationAndVerify(t, "backuplocation", "default")
+	createApplicationBackupAndVerify(t, "backupname", "default", []string{"namespace1"}, "backuplocation", "", "", "")
+
+	cmdArgs := []string{"create", "apprestores", "dryrunrestore", "--backupLocation", "backuplocation", "--backupName", "backupname", "--dryRun"}
+	expected := "ApplicationRestore dryrunrestore started successfully\n"
+	testCommon(t, cmdArgs, nil, expected, false)
+
+	restore, err := storkops.Instance().GetApplicationRestore("dryrunrestore", "default")
+	require.NoError(t, err, "Error getting restore")
+	require.True(t, restore.Spec.DryRun, "ApplicationRestore dryRun mismatch")
+}
+
+func TestCreateApplicationRestoreWithSelectors(t *testing.T) {
+	defer resetTest()
+	createBackupLocationAndVerify(t, "backuplocation", "default")
+	createApplicationBackupAndVerify(t, "backupname", "default", []string{"namespace1", "namespace2"}, "backuplocation", "", "", "")
+
+	cmdArgs := []string{"create", "apprestores", "selectorrestore", "--backupLocation", "backuplocation", "--backupName", "backupname",
+		"--namespaces", "namespace1", "--selectors", "app=db", "--excludeSelectors", "tier=cache",
+		"--resourceTypes", "StatefulSet,PersistentVolumeClaim", "--excludeResourceTypes", "Secret"}
+	expected := "ApplicationRestore selectorrestore started successfully\n"
+	testCommon(t, cmdArgs, nil, expected, false)
+
+	restore, err := storkops.Instance().GetApplicationRestore("selectorrestore", "default")
+	require.NoError(t, err, "Error getting restore")
+	require.Equal(t, []string{"namespace1"}, restore.Spec.Namespaces, "ApplicationRestore namespaces mismatch")
+	require.Equal(t, map[string]string{"app": "db"}, restore.Spec.Selectors, "ApplicationRestore selectors mismatch")
+	require.Equal(t, map[string]string{"tier": "cache"}, restore.Spec.ExcludeSelectors, "ApplicationRestore excludeSelectors mismatch")
+	require.Equal(t, []string{"StatefulSet", "PersistentVolumeClaim"}, restore.Spec.ResourceTypes, "ApplicationRestore resourceTypes mismatch")
+	require.Equal(t, []string{"Secret"}, restore.Spec.ExcludeResourceTypes, "ApplicationRestore excludeResourceTypes mismatch")
+}
+
+func TestCreateApplicationRestoreWithRules(t *testing.T) {
+	defer resetTest()
+	createBackupLocationAndVerify(t, "backuplocation", "default")
+	createApplicationBackupAndVerify(t, "backupname", "default", []string{"namespace1"}, "backuplocation", "", "", "")
+
+	cmdArgs := []string{"create", "apprestores", "rulerestore", "--backupLocation", "backuplocation", "--backupName", "backupname",
+		"--preExecRule", "prerule", "--postExecRule", "postrule"}
+	expected := "ApplicationRestore rulerestore started successfully\n"
+	testCommon(t, cmdArgs, nil, expected, false)
+
+	restore, err := storkops.Instance().GetApplicationRestore("rulerestore", "default")
+	require.NoError(t, err, "Error getting restore")
+	require.Equal(t, "prerule", restore.Spec.PreExecRule, "ApplicationRestore preExecRule mismatch")
+	require.Equal(t, "postrule", restore.Spec.PostExecRule, "ApplicationRestore postExecRule mismatch")
+}
+
 func TestCreateApplicationRestoresMissingParameters(t *testing.T) {
 	defer resetTest()
 	cmdArgs := []string{"create", "apprestores", "createrestore", "--backupName", "backupname"}
@@ -313,7 +363,7 @@ func TestCreateApplicationRestoresMissingParameters(t *testing.T) {
 
 	createBackupLocationAndVerify(t, "backuplocation", "default")
 	cmdArgs = []string{"create", "apprestores", "createrestore", "--backupLocation", "backuplocation"}
-	expected = "error: need to provide BackupName to restore"
+	expected = "error: need to provide BackupName or BackupSchedule to restore"
 	testCommon(t, cmdArgs, nil, expected, true)
 }
 
@@ -0,0 +1,187 @@
+package storkctl
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/libopenstorage/stork/pkg/backupobject"
+	"github.com/libopenstorage/stork/pkg/crypto"
+	storkops "github.com/portworx/sched-ops/k8s/stork"
+	"github.com/spf13/cobra"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+	"k8s.io/kubectl/pkg/cmd/util"
+)
+
+var backupArchiveSubcommand = "backup"
+var backupArchiveAliases = []string{"backups", "applicationbackup", "applicationbackups"}
+
+func newExportCommand(cmdFactory Factory, ioStreams genericclioptions.IOStreams) *cobra.Command {
+	exportCommands := &cobra.Command{
+		Use:   "export",
+		Short: "Export resources to a file",
+	}
+
+	exportCommands.AddCommand(
+		newExportBackupCommand(cmdFactory, ioStreams),
+	)
+
+	return exportCommands
+}
+
+func newImportCommand(cmdFactory Factory, ioStreams genericclioptions.IOStreams) *cobra.Command {
+	importCommands := &cobra.Command{
+		Use:   "import",
+		Short: "Import resources from a file",
+	}
+
+	importCommands.AddCommand(
+		newImportBackupCommand(cmdFactory, ioStreams),
+	)
+
+	return importCommands
+}
+
+func newExportBackupCommand(cmdFactory Factory, ioStreams genericclioptions.IOStreams) *cobra.Command {
+	var fileName string
+	var passphrase string
+
+	exportBackupCommand := &cobra.Command{
+		Use:     backupArchiveSubcommand,
+		Aliases: backupArchiveAliases,
+		Short:   "Export an applicationbackup and its data to an archive",
+		Run: func(c *cobra.Command, args []string) {
+			if len(args) != 1 {
+				util.CheckErr(fmt.Errorf("exactly one name needs to be provided for applicationbackup name"))
+				return
+			}
+			if fileName == "" {
+				util.CheckErr(fmt.Errorf("need to provide the file to export the applicationbackup to"))
+				return
+			}
+			if err := exportBackup(args[0], cmdFactory.GetNamespace(), fileName, passphrase); err != nil {
+				util.CheckErr(err)
+				return
+			}
+			msg := fmt.Sprintf("ApplicationBackup %v exported to %v", args[0], fileName)
+			printMsg(msg, ioStreams.Out)
+		},
+	}
+	exportBackupCommand.Flags().StringVarP(&fileName, "file", "f", "", "File to write the archive to")
+	exportBackupCommand.Flags().StringVarP(&passphrase, "passphrase", "", "", "Passphrase to encrypt the archive with")
+
+	return exportBackupCommand
+}
+
+func exportBackup(name string, namespace string, fileName string, passphrase string) (err error) {
+	backup, err := storkops.Instance().GetApplicationBackup(name, namespace)
+	if err != nil {
+		return err
+	}
+	backupLocation, err := storkops.Instance().GetBackupLocation(backup.Spec.BackupLocation, namespace)
+	if err != nil {
+		return err
+	}
+
+	file, err := os.Create(fileName)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if closeErr := file.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			_ = os.Remove(fileName)
+		}
+	}()
+	writer := bufio.NewWriter(file)
+	var archiveWriter io.Writer = writer
+	var encryptWriter io.WriteCloser
+	if passphrase != "" {
+		if encryptWriter, err = crypto.NewEncryptWriter(writer, passphrase); err != nil {
+			return err
+		}
+		archiveWriter = encryptWriter
+	}
+	if err = backupobject.Export(backup, backupLocation, archiveWriter); err != nil {
+		return fmt.Errorf("error exporting applicationbackup %v: %v", name, err)
+	}
+	if encryptWriter != nil {
+		if err = encryptWriter.Close(); err != nil {
+			return err
+		}
+	}
+	return writer.Flush()
+}
+
+func newImportBackupCommand(cmdFactory Factory, ioStreams genericclioptions.IOStreams) *cobra.Command {
+	var fileName string
+	var passphrase string
+	var backupLocation string
+
+	importBackupCommand := &cobra.Command{
+		Use:     backupArchiveSubcommand,
+		Aliases: backupArchiveAliases,
+		Short:   "Import an applicationbackup and its data from an archive",
+		Run: func(c *cobra.Command, args []string) {
+			if fileName == "" {
+				util.CheckErr(fmt.Errorf("need to provide the file to import the applicationbackup from"))
+				return
+			}
+			if backupLocation == "" {
+				util.CheckErr(fmt.Errorf("need to provide BackupLocation to import the applicationbackup to"))
+				return
+			}
+			name, err := importBackup(fileName, backupLocation, cmdFactory.GetNamespace(), passphrase)
+			if err != nil {
+				util.CheckErr(err)
+				return
+			}
+			msg := fmt.Sprintf("ApplicationBackup %v imported from %v", name, fileName)
+			printMsg(msg, ioStreams.Out)
+		},
+	}
+	importBackupCommand.Flags().StringVarP(&fileName, "file", "f", "", "File to read the archive from")
+	importBackupCommand.Flags().StringVarP(&passphrase, "passphrase", "", "", "Passphrase the archive was encrypted with")
+	importBackupCommand.Flags().StringVarP(&backupLocation, "backupLocation", "b", "", "BackupLocation to import the applicationbackup to")
+
+	return importBackupCommand
+}
+
+func importBackup(fileName string, backupLocationName string, namespace string, passphrase string) (string, error) {
+	backupLocation, err := storkops.Instance().GetBackupLocation(backupLocationName, namespace)
+	if err != nil {
+		return "", err
+	}
+
+	file, err := os.Open(fileName)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+	reader := bufio.NewReader(file)
+	header, err := reader.Peek(crypto.StreamHeaderLen)
+	if err != nil && err != io.EOF {
+		return "", err
+	}
+	var archiveReader io.Reader = reader
+	if crypto.IsStreamEncrypted(header) {
+		if passphrase == "" {
+			return "", fmt.Errorf("archive %v is encrypted, need to provide the passphrase", fileName)
+		}
+		if archiveReader, err = crypto.NewStreamDecryptReader(reader, passphrase); err != nil {
+			return "", err
+		}
+	}
+
+	backup, err := backupobject.Import(archiveReader, backupLocation)
+	if err != nil {
+		return "", fmt.Errorf("error importing applicationbackup from %v: %v", fileName, err)
+	}
+	if _, err := storkops.Instance().CreateApplicationBackup(backup); err != nil {
+		return "", err
+	}
+	return backup.Name, nil
+}
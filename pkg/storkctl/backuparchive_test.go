@@ -0,0 +1,129 @@
+//go:build unittest
+// +build unittest
+
+package storkctl
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	storkv1 "github.com/libopenstorage/stork/pkg/apis/stork/v1alpha1"
+	storkops "github.com/portworx/sched-ops/k8s/stork"
+	"github.com/stretchr/testify/require"
+	meta "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+var exportedBackupObjects = map[string]string{
+	"resources.json":  `[{"apiVersion":"v1","kind":"ConfigMap","metadata":{"name":"config","namespace":"app"}}]`,
+	"namespaces.json": `[{"apiVersion":"v1","kind":"Namespace","metadata":{"name":"app"}}]`,
+	"driver-data":     "driver",
+}
+
+func createLocalBackupLocation(t *testing.T, name string, namespace string) *storkv1.BackupLocation {
+	backupLocation := &storkv1.BackupLocation{
+		ObjectMeta: meta.ObjectMeta{
+			Name:      name,
+			Namespace: namespace,
+		},
+		Location: storkv1.BackupLocationItem{
+			Type: storkv1.BackupLocationLocal,
+			Path: t.TempDir(),
+		},
+	}
+	backupLocation, err := storkops.Instance().CreateBackupLocation(backupLocation)
+	require.NoError(t, err, "Error creating backuplocation")
+	return backupLocation
+}
+
+func createExportedBackup(t *testing.T, location *storkv1.BackupLocation) *storkv1.ApplicationBackup {
+	backup := &storkv1.ApplicationBackup{
+		ObjectMeta: meta.ObjectMeta{
+			Name:      "exportbackup",
+			Namespace: location.Namespace,
+			UID:       "exportbackup-uid",
+		},
+		Spec: storkv1.ApplicationBackupSpec{
+			BackupLocation: location.Name,
+			Namespaces:     []string{"app"},
+		},
+		Status: storkv1.ApplicationBackupStatus{
+			Status:           storkv1.ApplicationBackupStatusSuccessful,
+			Stage:            storkv1.ApplicationBackupStageFinal,
+			BackupPath:       filepath.Join(location.Namespace, "exportbackup", "exportbackup-uid"),
+			TriggerTimestamp: meta.NewTime(time.Date(2021, 1, 2, 3, 4, 5, 0, time.UTC)),
+		},
+	}
+	backupDir := filepath.Join(location.Location.Path, backup.Status.BackupPath)
+	require.NoError(t, os.MkdirAll(backupDir, 0755))
+	for name, data := range exportedBackupObjects {
+		require.NoError(t, os.WriteFile(filepath.Join(backupDir, name), []byte(data), 0644))
+	}
+	backup, err := storkops.Instance().CreateApplicationBackup(backup)
+	require.NoError(t, err, "Error creating applicationbackup")
+	return backup
+}
+
+func TestExportBackupNoFile(t *testing.T) {
+	cmdArgs := []string{"export", "backup", "exportbackup"}
+	expected := "error: need to provide the file to export the applicationbackup to"
+	testCommon(t, cmdArgs, nil, expected, true)
+}
+
+func TestImportBackupNoBackupLocation(t *testing.T) {
+	cmdArgs := []string{"import", "backup", "-f", "archive.tar"}
+	expected := "error: need to provide BackupLocation to import the applicationbackup to"
+	testCommon(t, cmdArgs, nil, expected, true)
+}
+
+func TestExportBackupNotCompleted(t *testing.T) {
+	defer resetTest()
+	source := createLocalBackupLocation(t, "source", "default")
+	backup := createExportedBackup(t, source)
+	backup.Status.Status = storkv1.ApplicationBackupStatusInProgress
+	_, err := storkops.Instance().UpdateApplicationBackup(backup)
+	require.NoError(t, err, "Error updating applicationbackup")
+
+	fileName := filepath.Join(t.TempDir(), "archive.tar")
+	cmdArgs := []string{"export", "backup", "exportbackup", "-f", fileName}
+	expected := "error: error exporting applicationbackup exportbackup: backup default/exportbackup hasn't completed successfully"
+	testCommon(t, cmdArgs, nil, expected, true)
+	_, err = os.Stat(fileName)
+	require.True(t, os.IsNotExist(err), "Archive should have been removed")
+}
+
+func TestExportImportBackup(t *testing.T) {
+	defer resetTest()
+	source := createLocalBackupLocation(t, "source", "default")
+	destination := createLocalBackupLocation(t, "destination", "dest")
+	createExportedBackup(t, source)
+
+	fileName := filepath.Join(t.TempDir(), "archive.tar")
+	cmdArgs := []string{"export", "backup", "exportbackup", "-f", fileName, "--passphrase", "secret"}
+	expected := "ApplicationBackup exportbackup exported to " + fileName + "\n"
+	testCommon(t, cmdArgs, nil, expected, false)
+
+	cmdArgs = []string{"import", "backup", "-n", "dest", "-f", fileName, "-b", "destination"}
+	expected = "error: archive " + fileName + " is encrypted, need to provide the passphrase"
+	testCommon(t, cmdArgs, nil, expected, true)
+
+	cmdArgs = []string{"import", "backup", "-n", "dest", "-f", fileName, "-b", "destination", "--passphrase", "secret"}
+	expected = "ApplicationBackup exportbackup-2021-01-02-030405 imported from " + fileName + "\n"
+	testCommon(t, cmdArgs, nil, expected, false)
+
+	imported, err := storkops.Instance().GetApplicationBackup("exportbackup-2021-01-02-030405", "dest")
+	require.NoError(t, err, "Error getting imported applicationbackup")
+	require.Equal(t, "destination", imported.Spec.BackupLocation)
+	require.Equal(t, storkv1.ApplicationBackupReclaimPolicyRetain, imported.Spec.ReclaimPolicy)
+	require.Equal(t, storkv1.ApplicationBackupIntegrityVerified, imported.Status.Integrity)
+	backupPath := filepath.Join("dest", "exportbackup", "exportbackup-uid")
+	require.Equal(t, backupPath, imported.Status.BackupPath)
+
+	backupDir := filepath.Join(destination.Location.Path, backupPath)
+	for name, data := range exportedBackupObjects {
+		actual, err := os.ReadFile(filepath.Join(backupDir, name))
+		require.NoError(t, err, "Error reading imported object %v", name)
+		require.Equal(t, data, string(actual))
+	}
+}
@@ -2,6 +2,7 @@ package storkctl
 
 import (
 	"fmt"
+	"strings"
 
 	storkv1 "github.com/libopenstorage/stork/pkg/apis/stork/v1alpha1"
 	storkops "github.com/portworx/sched-ops/k8s/stork"
@@ -18,9 +19,10 @@ const (
 	hiddenString             = "<HIDDEN>"
 )
 
-var s3BackupLocationColumns = []string{"NAME", "PATH", "ACCESS-KEY-ID", "SECRET-ACCESS-KEY", "REGION", "ENDPOINT", "SSL-DISABLED"}
-var azureBackupLocationColumns = []string{"NAME", "PATH", "STORAGE-ACCOUNT-NAME", "STORAGE-ACCOUNT-KEY"}
-var googleBackupLocationColumns = []string{"NAME", "PATH", "PROJECT-ID"}
+var s3BackupLocationColumns = []string{"NAME", "PATH", "ACCESS-KEY-ID", "SECRET-ACCESS-KEY", "REGION", "ENDPOINT", "SSL-DISABLED", "STATUS", "LAST-CHECKED"}
+var azureBackupLocationColumns = []string{"NAME", "PATH", "STORAGE-ACCOUNT-NAME", "STORAGE-ACCOUNT-KEY", "STATUS", "LAST-CHECKED"}
+var googleBackupLocationColumns = []string{"NAME", "PATH", "PROJECT-ID", "STATUS", "LAST-CHECKED"}
+var localBackupLocationColumns = []string{"NAME", "PATH", "STATUS", "LAST-CHECKED"}
 
 func newGetBackupLocationCommand(cmdFactory Factory, ioStreams genericclioptions.IOStreams) *cobra.Command {
 	var showSecrets bool
@@ -74,6 +76,7 @@ func newGetBackupLocationCommand(cmdFactory Factory, ioStreams genericclioptions
 			s3BackupLocations := &storkv1.BackupLocationList{}
 			azureBackupLocations := &storkv1.BackupLocationList{}
 			googleBackupLocations := &storkv1.BackupLocationList{}
+			localBackupLocations := &storkv1.BackupLocationList{}
 			unknownBackupLocations := &storkv1.BackupLocationList{}
 			for _, bl := range backupLocations.Items {
 				switch bl.Location.Type {
@@ -92,6 +95,8 @@ func newGetBackupLocationCommand(cmdFactory Factory, ioStreams genericclioptions
 						bl.Location.GoogleConfig.AccountKey = hiddenString
 					}
 					googleBackupLocations.Items = append(googleBackupLocations.Items, bl)
+				case storkv1.BackupLocationLocal:
+					localBackupLocations.Items = append(localBackupLocations.Items, bl)
 				default:
 					unknownBackupLocations.Items = append(unknownBackupLocations.Items, bl)
 				}
@@ -133,6 +138,16 @@ func newGetBackupLocationCommand(cmdFactory Factory, ioStreams genericclioptions
 						return
 					}
 				}
+				if len(localBackupLocations.Items) != 0 {
+					if _, err := fmt.Fprintf(ioStreams.Out, "\nLocal:\n------\n"); err != nil {
+						util.CheckErr(err)
+						return
+					}
+					if err := printObjects(c, localBackupLocations, cmdFactory, localBackupLocationColumns, localBackupLocationPrinter, ioStreams.Out); err != nil {
+						util.CheckErr(err)
+						return
+					}
+				}
 			} else {
 				if err := printObjects(c, backupLocations, cmdFactory, nil, nil, ioStreams.Out); err != nil {
 					util.CheckErr(err)
@@ -165,7 +180,9 @@ func s3BackupLocationPrinter(
 				backupLocation.Location.S3Config.SecretAccessKey,
 				backupLocation.Location.S3Config.Region,
 				backupLocation.Location.S3Config.Endpoint,
-				backupLocation.Location.S3Config.DisableSSL},
+				backupLocation.Location.S3Config.DisableSSL,
+				getBackupLocationStatus(&backupLocation),
+				toTimeString(backupLocation.Status.LastChecked.Time)},
 		)
 		rows = append(rows, row)
 
@@ -186,7 +203,9 @@ func azureBackupLocationPrinter(
 			[]interface{}{backupLocation.Name,
 				backupLocation.Location.Path,
 				backupLocation.Location.AzureConfig.StorageAccountName,
-				backupLocation.Location.AzureConfig.StorageAccountKey},
+				backupLocation.Location.AzureConfig.StorageAccountKey,
+				getBackupLocationStatus(&backupLocation),
+				toTimeString(backupLocation.Status.LastChecked.Time)},
 		)
 		rows = append(rows, row)
 	}
@@ -206,9 +225,50 @@ func googleBackupLocationPrinter(
 		row := getRow(&backupLocation,
 			[]interface{}{backupLocation.Name,
 				backupLocation.Location.Path,
-				backupLocation.Location.GoogleConfig.ProjectID},
+				backupLocation.Location.GoogleConfig.ProjectID,
+				getBackupLocationStatus(&backupLocation),
+				toTimeString(backupLocation.Status.LastChecked.Time)},
+		)
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+func localBackupLocationPrinter(
+	backupLocationList *storkv1.BackupLocationList,
+	options printers.GenerateOptions,
+) ([]metav1beta1.TableRow, error) {
+	if backupLocationList == nil {
+		return nil, nil
+	}
+
+	rows := make([]metav1beta1.TableRow, 0)
+	for _, backupLocation := range backupLocationList.Items {
+		row := getRow(&backupLocation,
+			[]interface{}{backupLocation.Name,
+				backupLocation.Location.Path,
+				getBackupLocationStatus(&backupLocation),
+				toTimeString(backupLocation.Status.LastChecked.Time)},
 		)
 		rows = append(rows, row)
 	}
 	return rows, nil
 }
+
+// getBackupLocationStatus returns Ready if all the checks of the last
+// validation passed, the checks that failed otherwise
+func getBackupLocationStatus(backupLocation *storkv1.BackupLocation) string {
+	if backupLocation.Status.LastChecked.IsZero() {
+		return "Unknown"
+	}
+	failed := make([]string, 0)
+	for _, condition := range backupLocation.Status.Conditions {
+		if condition.Status == metav1.ConditionFalse {
+			failed = append(failed, string(condition.Type)+"=False")
+		}
+	}
+	if len(failed) == 0 {
+		return "Ready"
+	}
+	return strings.Join(failed, ",")
+}
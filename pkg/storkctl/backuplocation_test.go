@@ -5,6 +5,7 @@ package storkctl
 
 import (
 	"testing"
+	"time"
 
 	storkv1 "github.com/libopenstorage/stork/pkg/apis/stork/v1alpha1"
 	"github.com/portworx/sched-ops/k8s/core"
@@ -43,8 +44,8 @@ func TestBackupLocationNotFound(t *testing.T) {
 	testCommon(t, cmdArgs, nil, expected, true)
 
 	expected = "\nS3:\n---\n" +
-		"NAME            PATH   ACCESS-KEY-ID   SECRET-ACCESS-KEY   REGION      ENDPOINT           SSL-DISABLED\n" +
-		"testlocation1                          <HIDDEN>            us-east-1   s3.amazonaws.com   false\n"
+		"NAME            PATH   ACCESS-KEY-ID   SECRET-ACCESS-KEY   REGION      ENDPOINT           SSL-DISABLED   STATUS    LAST-CHECKED\n" +
+		"testlocation1                          <HIDDEN>            us-east-1   s3.amazonaws.com   false          Unknown   \n"
 	cmdArgs = []string{"get", "backuplocation", "testlocation1"}
 	testCommon(t, cmdArgs, nil, expected, false)
 }
@@ -65,8 +66,8 @@ func TestS3BackupLocation(t *testing.T) {
 	require.NoError(t, err, "Error creating backuplocation")
 
 	expected := "\nS3:\n---\n" +
-		"NAME         PATH   ACCESS-KEY-ID   SECRET-ACCESS-KEY   REGION      ENDPOINT           SSL-DISABLED\n" +
-		"s3location                          <HIDDEN>            us-east-1   s3.amazonaws.com   false\n"
+		"NAME         PATH   ACCESS-KEY-ID   SECRET-ACCESS-KEY   REGION      ENDPOINT           SSL-DISABLED   STATUS    LAST-CHECKED\n" +
+		"s3location                          <HIDDEN>            us-east-1   s3.amazonaws.com   false          Unknown   \n"
 	cmdArgs := []string{"get", "backuplocation", "s3location"}
 	testCommon(t, cmdArgs, nil, expected, false)
 
@@ -82,13 +83,13 @@ func TestS3BackupLocation(t *testing.T) {
 	require.NoError(t, err, "Error updating backuplocation")
 
 	expected = "\nS3:\n---\n" +
-		"NAME         PATH       ACCESS-KEY-ID   SECRET-ACCESS-KEY   REGION      ENDPOINT    SSL-DISABLED\n" +
-		"s3location   testpath   accesskey       <HIDDEN>            us-west-1   127.0.0.1   true\n"
+		"NAME         PATH       ACCESS-KEY-ID   SECRET-ACCESS-KEY   REGION      ENDPOINT    SSL-DISABLED   STATUS    LAST-CHECKED\n" +
+		"s3location   testpath   accesskey       <HIDDEN>            us-west-1   127.0.0.1   true           Unknown   \n"
 	testCommon(t, cmdArgs, nil, expected, false)
 
 	expected = "\nS3:\n---\n" +
-		"NAME         PATH       ACCESS-KEY-ID   SECRET-ACCESS-KEY   REGION      ENDPOINT    SSL-DISABLED\n" +
-		"s3location   testpath   accesskey       secretKey           us-west-1   127.0.0.1   true\n"
+		"NAME         PATH       ACCESS-KEY-ID   SECRET-ACCESS-KEY   REGION      ENDPOINT    SSL-DISABLED   STATUS    LAST-CHECKED\n" +
+		"s3location   testpath   accesskey       secretKey           us-west-1   127.0.0.1   true           Unknown   \n"
 	cmdArgs = []string{"get", "backuplocation", "s3location", "-s"}
 	testCommon(t, cmdArgs, nil, expected, false)
 }
@@ -109,8 +110,8 @@ func TestAzureBackupLocation(t *testing.T) {
 	require.NoError(t, err, "Error creating backuplocation")
 
 	expected := "\nAzureBlob:\n----------\n" +
-		"NAME            PATH   STORAGE-ACCOUNT-NAME   STORAGE-ACCOUNT-KEY\n" +
-		"azurelocation                                 <HIDDEN>\n"
+		"NAME            PATH   STORAGE-ACCOUNT-NAME   STORAGE-ACCOUNT-KEY   STATUS    LAST-CHECKED\n" +
+		"azurelocation                                 <HIDDEN>              Unknown   \n"
 	cmdArgs := []string{"get", "backuplocation", "azurelocation"}
 	testCommon(t, cmdArgs, nil, expected, false)
 
@@ -123,13 +124,13 @@ func TestAzureBackupLocation(t *testing.T) {
 	require.NoError(t, err, "Error updating backuplocation")
 
 	expected = "\nAzureBlob:\n----------\n" +
-		"NAME            PATH       STORAGE-ACCOUNT-NAME   STORAGE-ACCOUNT-KEY\n" +
-		"azurelocation   testpath   accountname            <HIDDEN>\n"
+		"NAME            PATH       STORAGE-ACCOUNT-NAME   STORAGE-ACCOUNT-KEY   STATUS    LAST-CHECKED\n" +
+		"azurelocation   testpath   accountname            <HIDDEN>              Unknown   \n"
 	testCommon(t, cmdArgs, nil, expected, false)
 
 	expected = "\nAzureBlob:\n----------\n" +
-		"NAME            PATH       STORAGE-ACCOUNT-NAME   STORAGE-ACCOUNT-KEY\n" +
-		"azurelocation   testpath   accountname            accountkey\n"
+		"NAME            PATH       STORAGE-ACCOUNT-NAME   STORAGE-ACCOUNT-KEY   STATUS    LAST-CHECKED\n" +
+		"azurelocation   testpath   accountname            accountkey            Unknown   \n"
 	cmdArgs = []string{"get", "backuplocation", "azurelocation", "-s"}
 	testCommon(t, cmdArgs, nil, expected, false)
 }
@@ -150,8 +151,8 @@ func TestGoogleBackupLocation(t *testing.T) {
 	require.NoError(t, err, "Error creating backuplocation")
 
 	expected := "\nGoogleCloudStorage:\n-------------------\n" +
-		"NAME             PATH   PROJECT-ID\n" +
-		"googlelocation          \n"
+		"NAME             PATH   PROJECT-ID   STATUS    LAST-CHECKED\n" +
+		"googlelocation                       Unknown   \n"
 	cmdArgs := []string{"get", "backuplocation", "googlelocation"}
 	testCommon(t, cmdArgs, nil, expected, false)
 
@@ -163,8 +164,72 @@ func TestGoogleBackupLocation(t *testing.T) {
 	require.NoError(t, err, "Error updating backuplocation")
 
 	expected = "\nGoogleCloudStorage:\n-------------------\n" +
-		"NAME             PATH       PROJECT-ID\n" +
-		"googlelocation   testpath   testproject\n"
+		"NAME             PATH       PROJECT-ID    STATUS    LAST-CHECKED\n" +
+		"googlelocation   testpath   testproject   Unknown   \n"
+	testCommon(t, cmdArgs, nil, expected, false)
+}
+
+func TestLocalBackupLocation(t *testing.T) {
+	defer resetTest()
+
+	backupLocation := &storkv1.BackupLocation{
+		ObjectMeta: meta.ObjectMeta{
+			Name:      "locallocation",
+			Namespace: "default",
+		},
+		Location: storkv1.BackupLocationItem{
+			Type: storkv1.BackupLocationLocal,
+			Path: "/var/lib/stork/backups",
+		},
+	}
+	_, err := storkops.Instance().CreateBackupLocation(backupLocation)
+	require.NoError(t, err, "Error creating backuplocation")
+
+	expected := "\nLocal:\n------\n" +
+		"NAME            PATH                     STATUS    LAST-CHECKED\n" +
+		"locallocation   /var/lib/stork/backups   Unknown   \n"
+	cmdArgs := []string{"get", "backuplocation", "locallocation"}
+	testCommon(t, cmdArgs, nil, expected, false)
+}
+
+func TestBackupLocationStatus(t *testing.T) {
+	defer resetTest()
+
+	lastChecked := meta.NewTime(time.Now())
+	backupLocation := &storkv1.BackupLocation{
+		ObjectMeta: meta.ObjectMeta{
+			Name:      "statuslocation",
+			Namespace: "default",
+		},
+		Location: storkv1.BackupLocationItem{
+			Type: storkv1.BackupLocationLocal,
+			Path: "/var/lib/stork/backups",
+		},
+		Status: storkv1.BackupLocationStatus{
+			LastChecked: lastChecked,
+			Conditions: []storkv1.Condition{
+				{Type: storkv1.BackupLocationConditionCredentials, Status: meta.ConditionTrue},
+				{Type: storkv1.BackupLocationConditionBucket, Status: meta.ConditionFalse},
+				{Type: storkv1.BackupLocationConditionReadWrite, Status: meta.ConditionUnknown},
+			},
+		},
+	}
+	backupLocation, err := storkops.Instance().CreateBackupLocation(backupLocation)
+	require.NoError(t, err, "Error creating backuplocation")
+
+	expected := "\nLocal:\n------\n" +
+		"NAME             PATH                     STATUS                  LAST-CHECKED\n" +
+		"statuslocation   /var/lib/stork/backups   BucketAvailable=False   " + toTimeString(lastChecked.Time) + "\n"
+	cmdArgs := []string{"get", "backuplocation", "statuslocation"}
+	testCommon(t, cmdArgs, nil, expected, false)
+
+	backupLocation.Status.Conditions[1].Status = meta.ConditionTrue
+	_, err = storkops.Instance().UpdateBackupLocation(backupLocation)
+	require.NoError(t, err, "Error updating backuplocation")
+
+	expected = "\nLocal:\n------\n" +
+		"NAME             PATH                     STATUS   LAST-CHECKED\n" +
+		"statuslocation   /var/lib/stork/backups   Ready    " + toTimeString(lastChecked.Time) + "\n"
 	testCommon(t, cmdArgs, nil, expected, false)
 }
 
@@ -232,32 +297,32 @@ func TestAllBackupLocation(t *testing.T) {
 	require.NoError(t, err, "Error creating backuplocation")
 
 	expected := "\nAzureBlob:\n----------\n" +
-		"NAME            PATH        STORAGE-ACCOUNT-NAME   STORAGE-ACCOUNT-KEY\n" +
-		"azurelocation   azurepath   accountname            <HIDDEN>\n"
+		"NAME            PATH        STORAGE-ACCOUNT-NAME   STORAGE-ACCOUNT-KEY   STATUS    LAST-CHECKED\n" +
+		"azurelocation   azurepath   accountname            <HIDDEN>              Unknown   \n"
 	cmdArgs := []string{"get", "backuplocation", "azurelocation", "-n", "azure"}
 	testCommon(t, cmdArgs, nil, expected, false)
 
 	expected = "\nS3:\n---\n" +
-		"NAME         PATH     ACCESS-KEY-ID   SECRET-ACCESS-KEY   REGION      ENDPOINT    SSL-DISABLED\n" +
-		"s3location   s3path   accesskey       <HIDDEN>            us-west-1   127.0.0.1   true\n"
+		"NAME         PATH     ACCESS-KEY-ID   SECRET-ACCESS-KEY   REGION      ENDPOINT    SSL-DISABLED   STATUS    LAST-CHECKED\n" +
+		"s3location   s3path   accesskey       <HIDDEN>            us-west-1   127.0.0.1   true           Unknown   \n"
 	cmdArgs = []string{"get", "backuplocation", "s3location", "-n", "s3"}
 	testCommon(t, cmdArgs, nil, expected, false)
 
 	expected = "\nGoogleCloudStorage:\n-------------------\n" +
-		"NAME             PATH       PROJECT-ID\n" +
-		"googlelocation   testpath   testproject\n"
+		"NAME             PATH       PROJECT-ID    STATUS    LAST-CHECKED\n" +
+		"googlelocation   testpath   testproject   Unknown   \n"
 	cmdArgs = []string{"get", "backuplocation", "googlelocation", "-n", "google"}
 	testCommon(t, cmdArgs, nil, expected, false)
 
 	expected = "\nS3:\n---\n" +
-		"NAMESPACE   NAME         PATH     ACCESS-KEY-ID   SECRET-ACCESS-KEY   REGION      ENDPOINT    SSL-DISABLED\n" +
-		"s3          s3location   s3path   accesskey       <HIDDEN>            us-west-1   127.0.0.1   true\n\n" +
+		"NAMESPACE   NAME         PATH     ACCESS-KEY-ID   SECRET-ACCESS-KEY   REGION      ENDPOINT    SSL-DISABLED   STATUS    LAST-CHECKED\n" +
+		"s3          s3location   s3path   accesskey       <HIDDEN>            us-west-1   127.0.0.1   true           Unknown   \n\n" +
 		"GoogleCloudStorage:\n-------------------\n" +
-		"NAMESPACE   NAME             PATH       PROJECT-ID\n" +
-		"google      googlelocation   testpath   testproject\n\n" +
+		"NAMESPACE   NAME             PATH       PROJECT-ID    STATUS    LAST-CHECKED\n" +
+		"google      googlelocation   testpath   testproject   Unknown   \n\n" +
 		"AzureBlob:\n----------\n" +
-		"NAMESPACE   NAME            PATH        STORAGE-ACCOUNT-NAME   STORAGE-ACCOUNT-KEY\n" +
-		"azure       azurelocation   azurepath   accountname            <HIDDEN>\n"
+		"NAMESPACE   NAME            PATH        STORAGE-ACCOUNT-NAME   STORAGE-ACCOUNT-KEY   STATUS    LAST-CHECKED\n" +
+		"azure       azurelocation   azurepath   accountname            <HIDDEN>              Unknown   \n"
 	cmdArgs = []string{"get", "backuplocation", "--all-namespaces"}
 	testCommon(t, cmdArgs, nil, expected, false)
 }
@@ -42,7 +42,7 @@ const (
 )
 
 var (
-	clusterPairColumns = []string{"NAME", "STORAGE-STATUS", "SCHEDULER-STATUS", "CREATED"}
+	clusterPairColumns = []string{"NAME", "STORAGE-STATUS", "SCHEDULER-STATUS", "HEALTH", "LAST-PROBED", "CREATED"}
 
 	projectMappingHelpString = "Project mappings between source and destination clusters (currently supported only for Rancher).\n" +
 		"Use comma-separated <source-project-id>=<dest-project-id> pairs.\n" +
@@ -127,6 +127,8 @@ func clusterPairPrinter(
 			[]interface{}{clusterPair.Name,
 				clusterPair.Status.StorageStatus,
 				clusterPair.Status.SchedulerStatus,
+				getClusterPairHealth(&clusterPair),
+				toTimeString(clusterPair.Status.LastProbed.Time),
 				creationTime},
 		)
 		rows = append(rows, row)
@@ -134,6 +136,24 @@ func clusterPairPrinter(
 	return rows, nil
 }
 
+// getClusterPairHealth returns the conditions that failed in the last probe
+// of the cluster pair
+func getClusterPairHealth(clusterPair *storkv1.ClusterPair) string {
+	if clusterPair.Status.LastProbed.IsZero() {
+		return "Unknown"
+	}
+	failed := make([]string, 0)
+	for _, condition := range clusterPair.Status.Conditions {
+		if condition.Status == meta.ConditionFalse {
+			failed = append(failed, string(condition.Type)+"=False")
+		}
+	}
+	if len(failed) == 0 {
+		return "Healthy"
+	}
+	return strings.Join(failed, ",")
+}
+
 func getStringData(fileName string) (string, error) {
 	file, err := os.Open(fileName)
 	if err != nil {
@@ -69,17 +69,17 @@ func TestGetClusterPair(t *testing.T) {
 	createClusterPairAndVerify(t, "getclusterpairtest", "test")
 
 	cmdArgs := []string{"get", "clusterpair", "getclusterpairtest", "-n", "test"}
-	expected := "NAME                 STORAGE-STATUS   SCHEDULER-STATUS   CREATED\n" +
-		"getclusterpairtest                                       \n"
+	expected := "NAME                 STORAGE-STATUS   SCHEDULER-STATUS   HEALTH    LAST-PROBED   CREATED\n" +
+		"getclusterpairtest                                       Unknown                 \n"
 	testCommon(t, cmdArgs, nil, expected, false)
 
 	createClusterPairAndVerify(t, "getclusterpairtest2", "test")
 	testCommon(t, cmdArgs, nil, expected, false)
 
 	cmdArgs = []string{"get", "clusterpair", "getclusterpairtest", "getclusterpairtest2", "-n", "test"}
-	expected = "NAME                  STORAGE-STATUS   SCHEDULER-STATUS   CREATED\n" +
-		"getclusterpairtest                                        \n" +
-		"getclusterpairtest2                                       \n"
+	expected = "NAME                  STORAGE-STATUS   SCHEDULER-STATUS   HEALTH    LAST-PROBED   CREATED\n" +
+		"getclusterpairtest                                        Unknown                 \n" +
+		"getclusterpairtest2                                       Unknown                 \n"
 	testCommon(t, cmdArgs, nil, expected, false)
 
 	cmdArgs = []string{"get", "clusterpair", "-n", "test"}
@@ -87,15 +87,15 @@ func TestGetClusterPair(t *testing.T) {
 
 	createClusterPairAndVerify(t, "getclusterpairtest", "test1")
 	cmdArgs = []string{"get", "clusterpair", "-n", "test1"}
-	expected = "NAME                 STORAGE-STATUS   SCHEDULER-STATUS   CREATED\n" +
-		"getclusterpairtest                                       \n"
+	expected = "NAME                 STORAGE-STATUS   SCHEDULER-STATUS   HEALTH    LAST-PROBED   CREATED\n" +
+		"getclusterpairtest                                       Unknown                 \n"
 	testCommon(t, cmdArgs, nil, expected, false)
 
 	cmdArgs = []string{"get", "clusterpair", "--all-namespaces"}
-	expected = "NAMESPACE   NAME                  STORAGE-STATUS   SCHEDULER-STATUS   CREATED\n" +
-		"test        getclusterpairtest                                        \n" +
-		"test        getclusterpairtest2                                       \n" +
-		"test1       getclusterpairtest                                        \n"
+	expected = "NAMESPACE   NAME                  STORAGE-STATUS   SCHEDULER-STATUS   HEALTH    LAST-PROBED   CREATED\n" +
+		"test        getclusterpairtest                                        Unknown                 \n" +
+		"test        getclusterpairtest2                                       Unknown                 \n" +
+		"test1       getclusterpairtest                                        Unknown                 \n"
 	testCommon(t, cmdArgs, nil, expected, false)
 }
 
@@ -110,8 +110,43 @@ func TestGetClusterPairsWithStatus(t *testing.T) {
 	_, err = storkops.Instance().UpdateClusterPair(clusterPair)
 	require.NoError(t, err, "Error updating Clusterpair")
 	cmdArgs := []string{"get", "clusterpair", "clusterpairstatustest"}
-	expected := "NAME                    STORAGE-STATUS   SCHEDULER-STATUS   CREATED\n" +
-		"clusterpairstatustest   Ready            Ready              " + toTimeString(clusterPair.CreationTimestamp.Time) + "\n"
+	expected := "NAME                    STORAGE-STATUS   SCHEDULER-STATUS   HEALTH    LAST-PROBED   CREATED\n" +
+		"clusterpairstatustest   Ready            Ready              Unknown                 " + toTimeString(clusterPair.CreationTimestamp.Time) + "\n"
+	testCommon(t, cmdArgs, nil, expected, false)
+}
+
+func TestGetClusterPairsWithHealth(t *testing.T) {
+	defer resetTest()
+	createClusterPairAndVerify(t, "clusterpairhealthtest", "default")
+	clusterPair, err := storkops.Instance().GetClusterPair("clusterpairhealthtest", "default")
+	require.NoError(t, err, "Error getting Clusterpair")
+	clusterPair.CreationTimestamp = metav1.Now()
+	clusterPair.Status.StorageStatus = storkv1.ClusterPairStatusReady
+	clusterPair.Status.SchedulerStatus = storkv1.ClusterPairStatusReady
+	clusterPair.Status.LastProbed = metav1.Now()
+	clusterPair.Status.Conditions = append(clusterPair.Status.Conditions,
+		storkv1.Condition{Type: storkv1.ClusterPairConditionAPIReachable, Status: metav1.ConditionTrue},
+		storkv1.Condition{Type: storkv1.ClusterPairConditionStoragePaired, Status: metav1.ConditionUnknown},
+	)
+	_, err = storkops.Instance().UpdateClusterPair(clusterPair)
+	require.NoError(t, err, "Error updating Clusterpair")
+	cmdArgs := []string{"get", "clusterpair", "clusterpairhealthtest"}
+	expected := "NAME                    STORAGE-STATUS   SCHEDULER-STATUS   HEALTH    LAST-PROBED           CREATED\n" +
+		"clusterpairhealthtest   Ready            Ready              Healthy   " + toTimeString(clusterPair.Status.LastProbed.Time) + "   " +
+		toTimeString(clusterPair.CreationTimestamp.Time) + "\n"
+	testCommon(t, cmdArgs, nil, expected, false)
+
+	clusterPair, err = storkops.Instance().GetClusterPair("clusterpairhealthtest", "default")
+	require.NoError(t, err, "Error getting Clusterpair")
+	clusterPair.Status.Conditions = append(clusterPair.Status.Conditions,
+		storkv1.Condition{Type: storkv1.ClusterPairConditionAuthorized, Status: metav1.ConditionFalse},
+		storkv1.Condition{Type: storkv1.ClusterPairConditionCredentials, Status: metav1.ConditionFalse},
+	)
+	_, err = storkops.Instance().UpdateClusterPair(clusterPair)
+	require.NoError(t, err, "Error updating Clusterpair")
+	expected = "NAME                    STORAGE-STATUS   SCHEDULER-STATUS   HEALTH                                    LAST-PROBED           CREATED\n" +
+		"clusterpairhealthtest   Ready            Ready              Authorized=False,CredentialsValid=False   " + toTimeString(clusterPair.Status.LastProbed.Time) + "   " +
+		toTimeString(clusterPair.CreationTimestamp.Time) + "\n"
 	testCommon(t, cmdArgs, nil, expected, false)
 }
 
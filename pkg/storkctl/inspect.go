@@ -0,0 +1,257 @@
+package storkctl
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	storkv1 "github.com/libopenstorage/stork/pkg/apis/stork/v1alpha1"
+	"github.com/libopenstorage/stork/pkg/backupobject"
+	storkops "github.com/portworx/sched-ops/k8s/stork"
+	"github.com/spf13/cobra"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	metav1beta1 "k8s.io/apimachinery/pkg/apis/meta/v1beta1"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+	"k8s.io/kubectl/pkg/cmd/util"
+	"k8s.io/kubernetes/pkg/printers"
+)
+
+var inspectBackupSubcommand = "backup"
+var inspectBackupAliases = []string{"backups", "applicationbackup", "applicationbackups"}
+
+var inspectBackupObjectColumns = []string{"NAME", "KIND", "APIVERSION"}
+var inspectBackupVolumeColumns = []string{"NAMESPACE", "PVC", "VOLUME", "DRIVER", "STATUS", "SIZE"}
+
+func newInspectCommand(cmdFactory Factory, ioStreams genericclioptions.IOStreams) *cobra.Command {
+	inspectCommands := &cobra.Command{
+		Use:   "inspect",
+		Short: "Inspect the contents of resources",
+	}
+
+	inspectCommands.AddCommand(
+		newInspectBackupCommand(cmdFactory, ioStreams),
+	)
+
+	return inspectCommands
+}
+
+func newInspectBackupCommand(cmdFactory Factory, ioStreams genericclioptions.IOStreams) *cobra.Command {
+	var backupLocationName string
+	var backupPath string
+	var dumpObjects []string
+
+	inspectBackupCommand := &cobra.Command{
+		Use:     inspectBackupSubcommand,
+		Aliases: inspectBackupAliases,
+		Short:   "Inspect the objects stored in the backup location for an applicationbackup",
+		Run: func(c *cobra.Command, args []string) {
+			var backup *storkv1.ApplicationBackup
+			var err error
+			if len(args) == 1 {
+				if backupLocationName != "" || backupPath != "" {
+					util.CheckErr(fmt.Errorf("location and path can't be provided with an applicationbackup name"))
+					return
+				}
+				backup, err = storkops.Instance().GetApplicationBackup(args[0], cmdFactory.GetNamespace())
+				if err != nil {
+					util.CheckErr(err)
+					return
+				}
+				if backup.Status.BackupPath == "" {
+					util.CheckErr(fmt.Errorf("applicationbackup %v hasn't uploaded any objects", backup.Name))
+					return
+				}
+				backupLocationName = backup.Spec.BackupLocation
+				backupPath = backup.Status.BackupPath
+			} else if len(args) != 0 || backupLocationName == "" || backupPath == "" {
+				util.CheckErr(fmt.Errorf("either an applicationbackup name or the location and path of a backup need to be provided"))
+				return
+			}
+
+			backupLocation, err := storkops.Instance().GetBackupLocation(backupLocationName, cmdFactory.GetNamespace())
+			if err != nil {
+				util.CheckErr(err)
+				return
+			}
+			contents, err := backupobject.Inspect(backupLocation, backupPath)
+			if err != nil {
+				util.CheckErr(fmt.Errorf("error inspecting backup at %v: %v", backupPath, err))
+				return
+			}
+			// The status of the applicationbackup is more recent than the
+			// metadata when the backup is inspected by name
+			if backup == nil {
+				backup = contents.Backup
+			}
+
+			if len(dumpObjects) != 0 {
+				if err := dumpBackupObjects(contents, dumpObjects, ioStreams.Out); err != nil {
+					util.CheckErr(err)
+				}
+				return
+			}
+			if err := printBackupContents(c, backup, backupLocationName, backupPath, contents, ioStreams.Out); err != nil {
+				util.CheckErr(err)
+				return
+			}
+		},
+	}
+	inspectBackupCommand.Flags().StringVarP(&backupLocationName, "location", "", "", "BackupLocation to inspect the backup from when the applicationbackup doesn't exist")
+	inspectBackupCommand.Flags().StringVarP(&backupPath, "path", "", "", "Path of the backup in the backup location")
+	inspectBackupCommand.Flags().StringSliceVarP(&dumpObjects, "dump", "", nil, "Comma separated list of objects to print as YAML, as [namespace/]kind/name")
+
+	return inspectBackupCommand
+}
+
+func printBackupContents(
+	cmd *cobra.Command,
+	backup *storkv1.ApplicationBackup,
+	backupLocationName string,
+	backupPath string,
+	contents *backupobject.Contents,
+	out io.Writer,
+) error {
+	summary := make([][2]string, 0)
+	if backup != nil {
+		summary = append(summary,
+			[2]string{"Name", backup.Name},
+			[2]string{"Namespace", backup.Namespace},
+		)
+	}
+	summary = append(summary,
+		[2]string{"BackupLocation", backupLocationName},
+		[2]string{"Path", backupPath},
+	)
+	if backup != nil {
+		summary = append(summary,
+			[2]string{"Status", string(backup.Status.Status)},
+			[2]string{"Created", toTimeString(backup.Status.TriggerTimestamp.Time)},
+		)
+	}
+	integrity := string(contents.Integrity)
+	if contents.IntegrityReason != "" {
+		integrity += " (" + contents.IntegrityReason + ")"
+	}
+	summary = append(summary, [2]string{"Integrity", integrity})
+	for _, line := range summary {
+		if _, err := fmt.Fprintf(out, "%-16s%v\n", line[0]+":", line[1]); err != nil {
+			return err
+		}
+	}
+
+	for _, section := range []struct {
+		name    string
+		objects []*unstructured.Unstructured
+	}{
+		{"Namespaces", contents.Namespaces},
+		{"CRDs", contents.CRDs},
+		{"Resources", contents.Resources},
+	} {
+		if _, err := fmt.Fprintf(out, "\n%v:\n", section.name); err != nil {
+			return err
+		}
+		if len(section.objects) == 0 {
+			handleEmptyList(out)
+			continue
+		}
+		objectList := &unstructured.UnstructuredList{}
+		for _, object := range section.objects {
+			objectList.Items = append(objectList.Items, *object)
+		}
+		if err := printTable(cmd, objectList, inspectBackupObjectColumns, section.name == "Resources", backupObjectPrinter, out); err != nil {
+			return err
+		}
+	}
+
+	if _, err := fmt.Fprintf(out, "\nVolumes:\n"); err != nil {
+		return err
+	}
+	if backup == nil || len(backup.Status.Volumes) == 0 {
+		handleEmptyList(out)
+		return nil
+	}
+	return printTable(cmd, backup, inspectBackupVolumeColumns, false, backupVolumePrinter, out)
+}
+
+func backupObjectPrinter(
+	objectList *unstructured.UnstructuredList,
+	options printers.GenerateOptions,
+) ([]metav1beta1.TableRow, error) {
+	if objectList == nil {
+		return nil, nil
+	}
+
+	rows := make([]metav1beta1.TableRow, 0)
+	for _, object := range objectList.Items {
+		row := getRow(&object,
+			[]interface{}{object.GetName(),
+				object.GetKind(),
+				object.GetAPIVersion()},
+		)
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+func backupVolumePrinter(
+	backup *storkv1.ApplicationBackup,
+	options printers.GenerateOptions,
+) ([]metav1beta1.TableRow, error) {
+	if backup == nil {
+		return nil, nil
+	}
+
+	rows := make([]metav1beta1.TableRow, 0)
+	for _, volume := range backup.Status.Volumes {
+		row := getRow(backup,
+			[]interface{}{volume.Namespace,
+				volume.PersistentVolumeClaim,
+				volume.Volume,
+				volume.DriverName,
+				volume.Status,
+				volume.TotalSize},
+		)
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+// dumpBackupObjects prints the objects of the backup matching the selectors as
+// YAML. A selector is [namespace/]kind/name, the kind is case insensitive.
+func dumpBackupObjects(contents *backupobject.Contents, selectors []string, out io.Writer) error {
+	printer, err := (&genericclioptions.JSONYamlPrintFlags{}).ToPrinter(outputFormatYaml)
+	if err != nil {
+		return err
+	}
+	allObjects := make([]*unstructured.Unstructured, 0)
+	allObjects = append(allObjects, contents.Namespaces...)
+	allObjects = append(allObjects, contents.CRDs...)
+	allObjects = append(allObjects, contents.Resources...)
+	for _, selector := range selectors {
+		parts := strings.Split(selector, "/")
+		var namespace, kind, name string
+		switch len(parts) {
+		case 2:
+			kind, name = parts[0], parts[1]
+		case 3:
+			namespace, kind, name = parts[0], parts[1], parts[2]
+		default:
+			return fmt.Errorf("invalid object %q, should be [namespace/]kind/name", selector)
+		}
+		found := false
+		for _, object := range allObjects {
+			if strings.EqualFold(object.GetKind(), kind) &&
+				object.GetName() == name &&
+				object.GetNamespace() == namespace {
+				found = true
+				if err := printer.PrintObj(object, out); err != nil {
+					return err
+				}
+			}
+		}
+		if !found {
+			return fmt.Errorf("object %v not found in backup", selector)
+		}
+	}
+	return nil
+}
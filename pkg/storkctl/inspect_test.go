@@ -0,0 +1,95 @@
+//go:build unittest
+// +build unittest
+
+package storkctl
+
+import (
+	"testing"
+
+	storkv1 "github.com/libopenstorage/stork/pkg/apis/stork/v1alpha1"
+	storkops "github.com/portworx/sched-ops/k8s/stork"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInspectBackupNoArgs(t *testing.T) {
+	cmdArgs := []string{"inspect", "backup"}
+	expected := "error: either an applicationbackup name or the location and path of a backup need to be provided"
+	testCommon(t, cmdArgs, nil, expected, true)
+}
+
+func TestInspectBackup(t *testing.T) {
+	defer resetTest()
+	source := createLocalBackupLocation(t, "source", "default")
+	backup := createExportedBackup(t, source)
+	backup.Status.Volumes = []*storkv1.ApplicationBackupVolumeInfo{
+		{
+			Namespace:             "app",
+			PersistentVolumeClaim: "pvc1",
+			Volume:                "pv1",
+			DriverName:            "kdmp",
+			Status:                storkv1.ApplicationBackupStatusSuccessful,
+			TotalSize:             1024,
+		},
+	}
+	_, err := storkops.Instance().UpdateApplicationBackup(backup)
+	require.NoError(t, err, "Error updating applicationbackup")
+
+	cmdArgs := []string{"inspect", "backup", "exportbackup"}
+	expected := "Name:           exportbackup\n" +
+		"Namespace:      default\n" +
+		"BackupLocation: source\n" +
+		"Path:           default/exportbackup/exportbackup-uid\n" +
+		"Status:         Successful\n" +
+		"Created:        02 Jan 21 03:04 UTC\n" +
+		"Integrity:      Unverified\n" +
+		"\nNamespaces:\n" +
+		"NAME   KIND        APIVERSION\n" +
+		"app    Namespace   v1\n" +
+		"\nCRDs:\n" +
+		"No resources found.\n" +
+		"\nResources:\n" +
+		"NAMESPACE   NAME     KIND        APIVERSION\n" +
+		"app         config   ConfigMap   v1\n" +
+		"\nVolumes:\n" +
+		"NAMESPACE   PVC    VOLUME   DRIVER   STATUS       SIZE\n" +
+		"app         pvc1   pv1      kdmp     Successful   1024\n"
+	testCommon(t, cmdArgs, nil, expected, false)
+
+	cmdArgs = []string{"inspect", "backup", "exportbackup", "--dump", "app/configmap/config"}
+	expected = "apiVersion: v1\n" +
+		"kind: ConfigMap\n" +
+		"metadata:\n" +
+		"  name: config\n" +
+		"  namespace: app\n"
+	testCommon(t, cmdArgs, nil, expected, false)
+
+	cmdArgs = []string{"inspect", "backup", "exportbackup", "--dump", "configmap/config"}
+	expected = "error: object configmap/config not found in backup"
+	testCommon(t, cmdArgs, nil, expected, true)
+}
+
+func TestInspectBackupFromLocation(t *testing.T) {
+	defer resetTest()
+	source := createLocalBackupLocation(t, "source", "default")
+	createExportedBackup(t, source)
+
+	cmdArgs := []string{"inspect", "backup", "--location", "source", "--path", "default/exportbackup/exportbackup-uid"}
+	expected := "BackupLocation: source\n" +
+		"Path:           default/exportbackup/exportbackup-uid\n" +
+		"Integrity:      Unverified\n" +
+		"\nNamespaces:\n" +
+		"NAME   KIND        APIVERSION\n" +
+		"app    Namespace   v1\n" +
+		"\nCRDs:\n" +
+		"No resources found.\n" +
+		"\nResources:\n" +
+		"NAMESPACE   NAME     KIND        APIVERSION\n" +
+		"app         config   ConfigMap   v1\n" +
+		"\nVolumes:\n" +
+		"No resources found.\n"
+	testCommon(t, cmdArgs, nil, expected, false)
+
+	cmdArgs = []string{"inspect", "backup", "--location", "source", "--path", "default/missing"}
+	expected = "error: error inspecting backup at default/missing: resources.json not found"
+	testCommon(t, cmdArgs, nil, expected, true)
+}
@@ -4,12 +4,14 @@
 package storkctl
 
 import (
+	"fmt"
 	"strings"
 	"testing"
 	"time"
 
 	storkv1 "github.com/libopenstorage/stork/pkg/apis/stork/v1alpha1"
 	migration "github.com/libopenstorage/stork/pkg/migration/controllers"
+	"github.com/libopenstorage/stork/pkg/utils"
 	ocpv1 "github.com/openshift/api/apps/v1"
 	"github.com/portworx/sched-ops/k8s/apps"
 	"github.com/portworx/sched-ops/k8s/core"
@@ -466,3 +468,154 @@ func setMigrationStatus(name, namespace string, isFail bool, t *testing.T) {
 	_, err = storkops.Instance().UpdateMigration(migrResp)
 	require.NoError(t, err, "Error updating Migrations")
 }
+
+var driftTimestamp = metav1.NewTime(time.Date(2023, 5, 1, 10, 30, 0, 0, time.Local))
+
+func setMigrationDrift(t *testing.T, name, namespace string) {
+	migr, err := storkops.Instance().GetMigration(name, namespace)
+	require.NoError(t, err, "Error getting migration")
+	delete(migr.Annotations, utils.MigrationDriftReportAnnotation)
+	migr.Status.Drift = newTestMigrationDrift()
+	_, err = storkops.Instance().UpdateMigration(migr)
+	require.NoError(t, err, "Error updating migration")
+}
+
+func newTestMigrationDrift() *storkv1.MigrationDriftReport {
+	return &storkv1.MigrationDriftReport{
+		Timestamp: driftTimestamp,
+		Summary: &storkv1.MigrationDriftSummary{
+			InSync:  1,
+			Missing: 1,
+		},
+		Resources: []*storkv1.MigrationDriftResourceInfo{
+			{
+				Name:      "mysql",
+				Namespace: "namespace1",
+				GroupVersionKind: metav1.GroupVersionKind{
+					Group:   "apps",
+					Version: "v1",
+					Kind:    "Deployment",
+				},
+				Status: storkv1.MigrationDriftStatusInSync,
+				Reason: "Resource matches the last migrated version",
+			},
+			{
+				Name:      "mysql-config",
+				Namespace: "namespace1",
+				GroupVersionKind: metav1.GroupVersionKind{
+					Group:   "core",
+					Version: "v1",
+					Kind:    "ConfigMap",
+				},
+				Status: storkv1.MigrationDriftStatusMissing,
+				Reason: "Resource not found on the destination cluster",
+			},
+		},
+		Volumes: []*storkv1.MigrationDriftVolumeInfo{
+			{
+				PersistentVolumeClaim: "mysql-data",
+				Namespace:             "namespace1",
+				Volume:                "pvc-1234",
+				Status:                storkv1.MigrationDriftStatusInSync,
+				ReplicationLag:        &metav1.Duration{Duration: 5 * time.Minute},
+				Reason:                "Volume was last migrated 5m0s ago",
+			},
+		},
+	}
+}
+
+func getMigrationDriftExpected(name, clusterPair string) string {
+	return "Name:           " + name + "\n" +
+		"Namespace:      default\n" +
+		"ClusterPair:    " + clusterPair + "\n" +
+		"Compared:       " + toTimeString(driftTimestamp.Time) + "\n" +
+		"Summary:        1 in sync, 0 stale, 1 missing, 0 extra\n" +
+		"\nResources:\n" +
+		"KIND         NAMESPACE    NAME           STATUS    REASON\n" +
+		"Deployment   namespace1   mysql          InSync    Resource matches the last migrated version\n" +
+		"ConfigMap    namespace1   mysql-config   Missing   Resource not found on the destination cluster\n" +
+		"\nVolumes:\n" +
+		"NAMESPACE    PVC          VOLUME     STATUS   REPLICATION-LAG   REASON\n" +
+		"namespace1   mysql-data   pvc-1234   InSync   5m0s              Volume was last migrated 5m0s ago\n"
+}
+
+func TestGetMigrationDriftNoName(t *testing.T) {
+	defer resetTest()
+	cmdArgs := []string{"diff", "migrations"}
+	expected := "error: exactly one name needs to be provided for migration diff"
+	testCommon(t, cmdArgs, nil, expected, true)
+}
+
+func TestGetMigrationDriftNoReport(t *testing.T) {
+	defer resetTest()
+	createMigrationAndVerify(t, "driftmigration", "default", "clusterpair1", []string{"namespace1"}, "", "")
+	cmdArgs := []string{"diff", "migrations", "driftmigration", "--refresh=false"}
+	expected := "error: no drift report found for driftmigration, run the command with --refresh to generate one"
+	testCommon(t, cmdArgs, nil, expected, true)
+}
+
+func TestGetMigrationDrift(t *testing.T) {
+	defer resetTest()
+	createMigrationAndVerify(t, "driftmigration", "default", "clusterpair1", []string{"namespace1"}, "", "")
+	setMigrationDrift(t, "driftmigration", "default")
+	cmdArgs := []string{"diff", "migrations", "driftmigration", "--refresh=false"}
+	testCommon(t, cmdArgs, nil, getMigrationDriftExpected("driftmigration", "clusterpair1"), false)
+}
+
+func TestGetMigrationDriftRefresh(t *testing.T) {
+	defer resetTest()
+	createMigrationAndVerify(t, "driftmigration", "default", "clusterpair1", []string{"namespace1"}, "", "")
+
+	// Generate the report once it has been requested, like the migration
+	// controller would
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- waitForDriftReportRequest("driftmigration", "default")
+	}()
+	cmdArgs := []string{"diff", "migrations", "driftmigration", "--timeout", "1m"}
+	testCommon(t, cmdArgs, nil, getMigrationDriftExpected("driftmigration", "clusterpair1"), false)
+	require.NoError(t, <-errCh, "Error generating drift report")
+}
+
+// waitForDriftReportRequest generates the drift report once it has been
+// requested, like the migration controller would
+func waitForDriftReportRequest(name, namespace string) error {
+	for {
+		migr, err := storkops.Instance().GetMigration(name, namespace)
+		if err != nil {
+			return fmt.Errorf("error getting migration: %v", err)
+		}
+		if _, ok := migr.Annotations[utils.MigrationDriftReportAnnotation]; ok {
+			delete(migr.Annotations, utils.MigrationDriftReportAnnotation)
+			migr.Status.Drift = newTestMigrationDrift()
+			_, err = storkops.Instance().UpdateMigration(migr)
+			return err
+		}
+		time.Sleep(time.Second)
+	}
+}
+
+func TestGetMigrationScheduleDrift(t *testing.T) {
+	defer resetTest()
+	createMigrationScheduleAndVerify(t, "driftschedule", "testpolicy", "default", "clusterpair1", []string{"default"}, "", "", false)
+	migrationSchedule, err := storkops.Instance().GetMigrationSchedule("driftschedule", "default")
+	require.NoError(t, err, "Error getting migrationschedule")
+	migrationSchedule.Status.Drift = &storkv1.MigrationDriftReport{
+		Timestamp: driftTimestamp,
+		Reason:    "error getting clusterpair: not found",
+	}
+	_, err = storkops.Instance().UpdateMigrationSchedule(migrationSchedule)
+	require.NoError(t, err, "Error updating migrationschedule")
+
+	expected := "Name:           driftschedule\n" +
+		"Namespace:      default\n" +
+		"ClusterPair:    clusterpair1\n" +
+		"Compared:       " + toTimeString(driftTimestamp.Time) + "\n" +
+		"Error:          error getting clusterpair: not found\n"
+	cmdArgs := []string{"diff", "migrationschedules", "driftschedule", "--refresh=false"}
+	testCommon(t, cmdArgs, nil, expected, false)
+
+	cmdArgs = []string{"diff", "migrationschedules", "--refresh=false"}
+	expected = "error: exactly one name needs to be provided for migrationschedule diff"
+	testCommon(t, cmdArgs, nil, expected, true)
+}
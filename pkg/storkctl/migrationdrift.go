@@ -0,0 +1,272 @@
+package storkctl
+
+import (
+	"fmt"
+	"io"
+	"time"
+
+	storkv1 "github.com/libopenstorage/stork/pkg/apis/stork/v1alpha1"
+	"github.com/libopenstorage/stork/pkg/utils"
+	storkops "github.com/portworx/sched-ops/k8s/stork"
+	"github.com/portworx/sched-ops/task"
+	"github.com/spf13/cobra"
+	metav1beta1 "k8s.io/apimachinery/pkg/apis/meta/v1beta1"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+	"k8s.io/kubectl/pkg/cmd/util"
+	"k8s.io/kubernetes/pkg/printers"
+)
+
+const (
+	driftReportTimeout       = 30 * time.Minute
+	driftReportRetryInterval = 5 * time.Second
+)
+
+var (
+	driftResourceColumns = []string{"KIND", "NAMESPACE", "NAME", "STATUS", "REASON"}
+	driftVolumeColumns   = []string{"NAMESPACE", "PVC", "VOLUME", "STATUS", "REPLICATION-LAG", "REASON"}
+)
+
+func newDiffCommand(cmdFactory Factory, ioStreams genericclioptions.IOStreams) *cobra.Command {
+	diffCommands := &cobra.Command{
+		Use:   "diff",
+		Short: "Compare migrated resources and volumes with the destination cluster",
+	}
+
+	diffCommands.AddCommand(
+		newDiffMigrationCommand(cmdFactory, ioStreams),
+		newDiffMigrationScheduleCommand(cmdFactory, ioStreams),
+	)
+
+	return diffCommands
+}
+
+func newDiffMigrationCommand(cmdFactory Factory, ioStreams genericclioptions.IOStreams) *cobra.Command {
+	var refresh bool
+	var timeout time.Duration
+
+	diffMigrationCommand := &cobra.Command{
+		Use:     migrationSubcommand,
+		Aliases: migrationAliases,
+		Short:   "Compare the resources and volumes of a migration with the destination cluster",
+		Run: func(c *cobra.Command, args []string) {
+			if len(args) != 1 {
+				util.CheckErr(fmt.Errorf("exactly one name needs to be provided for migration diff"))
+				return
+			}
+			namespace := cmdFactory.GetNamespace()
+			getDrift := func() (*storkv1.Migration, error) {
+				return storkops.Instance().GetMigration(args[0], namespace)
+			}
+			requestDrift := func() error {
+				migr, err := storkops.Instance().GetMigration(args[0], namespace)
+				if err != nil {
+					return err
+				}
+				migr.Annotations = addDriftReportAnnotation(migr.Annotations)
+				_, err = storkops.Instance().UpdateMigration(migr)
+				return err
+			}
+			printDrift(c, getDrift, requestDrift, refresh, timeout, ioStreams.Out)
+		},
+	}
+	addDriftFlags(diffMigrationCommand, &refresh, &timeout)
+
+	return diffMigrationCommand
+}
+
+func newDiffMigrationScheduleCommand(cmdFactory Factory, ioStreams genericclioptions.IOStreams) *cobra.Command {
+	var refresh bool
+	var timeout time.Duration
+
+	diffMigrationScheduleCommand := &cobra.Command{
+		Use:     migrationScheduleSubcommand,
+		Aliases: migrationScheduleAliases,
+		Short:   "Compare the resources and volumes of a migrationschedule with the destination cluster",
+		Run: func(c *cobra.Command, args []string) {
+			if len(args) != 1 {
+				util.CheckErr(fmt.Errorf("exactly one name needs to be provided for migrationschedule diff"))
+				return
+			}
+			namespace := cmdFactory.GetNamespace()
+			getDrift := func() (*storkv1.Migration, error) {
+				schedule, err := storkops.Instance().GetMigrationSchedule(args[0], namespace)
+				if err != nil {
+					return nil, err
+				}
+				// The drift of the schedule is printed like the drift of a
+				// migration with its template
+				return &storkv1.Migration{
+					ObjectMeta: schedule.ObjectMeta,
+					Spec:       schedule.Spec.Template.Spec,
+					Status:     storkv1.MigrationStatus{Drift: schedule.Status.Drift},
+				}, nil
+			}
+			requestDrift := func() error {
+				schedule, err := storkops.Instance().GetMigrationSchedule(args[0], namespace)
+				if err != nil {
+					return err
+				}
+				schedule.Annotations = addDriftReportAnnotation(schedule.Annotations)
+				_, err = storkops.Instance().UpdateMigrationSchedule(schedule)
+				return err
+			}
+			printDrift(c, getDrift, requestDrift, refresh, timeout, ioStreams.Out)
+		},
+	}
+	addDriftFlags(diffMigrationScheduleCommand, &refresh, &timeout)
+
+	return diffMigrationScheduleCommand
+}
+
+func addDriftFlags(cmd *cobra.Command, refresh *bool, timeout *time.Duration) {
+	cmd.Flags().BoolVarP(refresh, "refresh", "", true, "Generate a new drift report instead of printing the last one")
+	cmd.Flags().DurationVarP(timeout, "timeout", "", driftReportTimeout, "Time to wait for the drift report to be generated")
+}
+
+// addDriftReportAnnotation requests stork to compare the migration or
+// migrationschedule with the destination cluster
+func addDriftReportAnnotation(annotations map[string]string) map[string]string {
+	if annotations == nil {
+		annotations = make(map[string]string)
+	}
+	annotations[utils.MigrationDriftReportAnnotation] = time.Now().Format(time.RFC3339)
+	return annotations
+}
+
+// printDrift prints the drift report returned by getDrift, after requesting
+// a new one and waiting for it to be generated if refresh is set
+func printDrift(
+	cmd *cobra.Command,
+	getDrift func() (*storkv1.Migration, error),
+	requestDrift func() error,
+	refresh bool,
+	timeout time.Duration,
+	out io.Writer,
+) {
+	if refresh {
+		if err := requestDrift(); err != nil {
+			util.CheckErr(err)
+			return
+		}
+		t := func() (interface{}, bool, error) {
+			migr, err := getDrift()
+			if err != nil {
+				return nil, false, err
+			}
+			if _, ok := migr.Annotations[utils.MigrationDriftReportAnnotation]; ok || migr.Status.Drift == nil {
+				return nil, true, fmt.Errorf("drift report for %v hasn't been generated yet", migr.Name)
+			}
+			return nil, false, nil
+		}
+		if _, err := task.DoRetryWithTimeout(t, timeout, driftReportRetryInterval); err != nil {
+			util.CheckErr(fmt.Errorf("error waiting for the drift report: %v", err))
+			return
+		}
+	}
+
+	migr, err := getDrift()
+	if err != nil {
+		util.CheckErr(err)
+		return
+	}
+	if migr.Status.Drift == nil {
+		util.CheckErr(fmt.Errorf("no drift report found for %v, run the command with --refresh to generate one", migr.Name))
+		return
+	}
+	if err := printMigrationDrift(cmd, migr, out); err != nil {
+		util.CheckErr(err)
+		return
+	}
+}
+
+func printMigrationDrift(cmd *cobra.Command, migr *storkv1.Migration, out io.Writer) error {
+	drift := migr.Status.Drift
+	summary := [][2]string{
+		{"Name", migr.Name},
+		{"Namespace", migr.Namespace},
+		{"ClusterPair", migr.Spec.ClusterPair},
+		{"Compared", toTimeString(drift.Timestamp.Time)},
+	}
+	if drift.Reason != "" {
+		summary = append(summary, [2]string{"Error", drift.Reason})
+	} else if drift.Summary != nil {
+		summary = append(summary, [2]string{"Summary",
+			fmt.Sprintf("%v in sync, %v stale, %v missing, %v extra",
+				drift.Summary.InSync, drift.Summary.Stale, drift.Summary.Missing, drift.Summary.Extra)})
+	}
+	for _, line := range summary {
+		if _, err := fmt.Fprintf(out, "%-16s%v\n", line[0]+":", line[1]); err != nil {
+			return err
+		}
+	}
+	if drift.Reason != "" {
+		return nil
+	}
+
+	if _, err := fmt.Fprintf(out, "\nResources:\n"); err != nil {
+		return err
+	}
+	if len(drift.Resources) == 0 {
+		handleEmptyList(out)
+	} else if err := printTable(cmd, migr, driftResourceColumns, false, driftResourcePrinter, out); err != nil {
+		return err
+	}
+
+	if _, err := fmt.Fprintf(out, "\nVolumes:\n"); err != nil {
+		return err
+	}
+	if len(drift.Volumes) == 0 {
+		handleEmptyList(out)
+		return nil
+	}
+	return printTable(cmd, migr, driftVolumeColumns, false, driftVolumePrinter, out)
+}
+
+func driftResourcePrinter(
+	migr *storkv1.Migration,
+	options printers.GenerateOptions,
+) ([]metav1beta1.TableRow, error) {
+	if migr == nil || migr.Status.Drift == nil {
+		return nil, nil
+	}
+
+	rows := make([]metav1beta1.TableRow, 0)
+	for _, resource := range migr.Status.Drift.Resources {
+		row := getRow(migr,
+			[]interface{}{resource.Kind,
+				resource.Namespace,
+				resource.Name,
+				resource.Status,
+				resource.Reason},
+		)
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+func driftVolumePrinter(
+	migr *storkv1.Migration,
+	options printers.GenerateOptions,
+) ([]metav1beta1.TableRow, error) {
+	if migr == nil || migr.Status.Drift == nil {
+		return nil, nil
+	}
+
+	rows := make([]metav1beta1.TableRow, 0)
+	for _, volume := range migr.Status.Drift.Volumes {
+		lag := ""
+		if volume.ReplicationLag != nil {
+			lag = volume.ReplicationLag.Duration.Round(time.Second).String()
+		}
+		row := getRow(migr,
+			[]interface{}{volume.Namespace,
+				volume.PersistentVolumeClaim,
+				volume.Volume,
+				volume.Status,
+				lag,
+				volume.Reason},
+		)
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
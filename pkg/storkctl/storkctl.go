@@ -38,6 +38,10 @@ func NewCommand(cmdFactory Factory, in io.Reader, out io.Writer, errOut io.Write
 		newResumeCommand(cmdFactory, ioStreams),
 		newVersionCommand(cmdFactory, ioStreams),
 		newTriggerCommand(cmdFactory, ioStreams),
+		newExportCommand(cmdFactory, ioStreams),
+		newImportCommand(cmdFactory, ioStreams),
+		newInspectCommand(cmdFactory, ioStreams),
+		newDiffCommand(cmdFactory, ioStreams),
 	)
 
 	cmds.PersistentFlags().AddGoFlagSet(flag.CommandLine)
@@ -92,8 +92,15 @@ const (
 	PxbackupObjectUIDKey = PxbackupAnnotationPrefix + "backup-uid"
 	// PxbackupObjectNameKey - annotation key name for backup object name with px-backup prefix
 	PxbackupObjectNameKey = PxbackupAnnotationPrefix + "backup-name"
+	// ApplicationBackupScheduleNameAnnotation - annotation key with the name of the schedule that created a backup
+	ApplicationBackupScheduleNameAnnotation = "stork.libopenstorage.org/applicationBackupScheduleName"
+	// ApplicationBackupSyncedFromAnnotation - annotation key with the name of the backup location a backup was synced from
+	ApplicationBackupSyncedFromAnnotation = "stork.libopenstorage.org/synced-from"
 	// SkipResourceAnnotation - annotation value to skip resource during resource collector
 	SkipResourceAnnotation = "stork.libopenstorage.org/skip-resource"
+	// MigrationDriftReportAnnotation - annotation key to request a drift report for a Migration or MigrationSchedule,
+	// removed once the report has been written to the status
+	MigrationDriftReportAnnotation = "stork.libopenstorage.org/drift-report-requested"
 	// StorkAPIVersion API version
 	StorkAPIVersion = "stork.libopenstorage.org/v1alpha1"
 	// BackupLocationKind CR kind